@@ -0,0 +1,5 @@
+// Package geolocationv1 holds the protobuf messages and the gRPC service of the geolocation lookups, generated from
+// geolocation.proto with protoc-gen-go and protoc-gen-go-grpc.
+package geolocationv1
+
+//go:generate protoc -I ../../.. --go_out=../../.. --go_opt=paths=source_relative --go-grpc_out=../../.. --go-grpc_opt=paths=source_relative api/geolocation/v1/geolocation.proto
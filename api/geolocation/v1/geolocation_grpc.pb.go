@@ -0,0 +1,222 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: api/geolocation/v1/geolocation.proto
+
+package geolocationv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	GeoLocation_Lookup_FullMethodName       = "/findhotel.geolocation.v1.GeoLocation/Lookup"
+	GeoLocation_BatchLookup_FullMethodName  = "/findhotel.geolocation.v1.GeoLocation/BatchLookup"
+	GeoLocation_StreamLookup_FullMethodName = "/findhotel.geolocation.v1.GeoLocation/StreamLookup"
+)
+
+// GeoLocationClient is the client API for GeoLocation service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type GeoLocationClient interface {
+	// Lookup returns the most specific geolocation of an ip, as it resolved at as_of when that is set
+	Lookup(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (*LookupResponse, error)
+	// BatchLookup looks up to 1000 ips up in one query. An invalid or unknown ip fails its own result only.
+	BatchLookup(ctx context.Context, in *BatchLookupRequest, opts ...grpc.CallOption) (*BatchLookupResponse, error)
+	// StreamLookup answers every request of the stream with a result, in the order of the requests
+	StreamLookup(ctx context.Context, opts ...grpc.CallOption) (GeoLocation_StreamLookupClient, error)
+}
+
+type geoLocationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGeoLocationClient(cc grpc.ClientConnInterface) GeoLocationClient {
+	return &geoLocationClient{cc}
+}
+
+func (c *geoLocationClient) Lookup(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (*LookupResponse, error) {
+	out := new(LookupResponse)
+	err := c.cc.Invoke(ctx, GeoLocation_Lookup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *geoLocationClient) BatchLookup(ctx context.Context, in *BatchLookupRequest, opts ...grpc.CallOption) (*BatchLookupResponse, error) {
+	out := new(BatchLookupResponse)
+	err := c.cc.Invoke(ctx, GeoLocation_BatchLookup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *geoLocationClient) StreamLookup(ctx context.Context, opts ...grpc.CallOption) (GeoLocation_StreamLookupClient, error) {
+	stream, err := c.cc.NewStream(ctx, &GeoLocation_ServiceDesc.Streams[0], GeoLocation_StreamLookup_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &geoLocationStreamLookupClient{stream}
+	return x, nil
+}
+
+type GeoLocation_StreamLookupClient interface {
+	Send(*LookupRequest) error
+	Recv() (*LookupResult, error)
+	grpc.ClientStream
+}
+
+type geoLocationStreamLookupClient struct {
+	grpc.ClientStream
+}
+
+func (x *geoLocationStreamLookupClient) Send(m *LookupRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *geoLocationStreamLookupClient) Recv() (*LookupResult, error) {
+	m := new(LookupResult)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GeoLocationServer is the server API for GeoLocation service.
+// All implementations must embed UnimplementedGeoLocationServer
+// for forward compatibility
+type GeoLocationServer interface {
+	// Lookup returns the most specific geolocation of an ip, as it resolved at as_of when that is set
+	Lookup(context.Context, *LookupRequest) (*LookupResponse, error)
+	// BatchLookup looks up to 1000 ips up in one query. An invalid or unknown ip fails its own result only.
+	BatchLookup(context.Context, *BatchLookupRequest) (*BatchLookupResponse, error)
+	// StreamLookup answers every request of the stream with a result, in the order of the requests
+	StreamLookup(GeoLocation_StreamLookupServer) error
+	mustEmbedUnimplementedGeoLocationServer()
+}
+
+// UnimplementedGeoLocationServer must be embedded to have forward compatible implementations.
+type UnimplementedGeoLocationServer struct {
+}
+
+func (UnimplementedGeoLocationServer) Lookup(context.Context, *LookupRequest) (*LookupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Lookup not implemented")
+}
+func (UnimplementedGeoLocationServer) BatchLookup(context.Context, *BatchLookupRequest) (*BatchLookupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchLookup not implemented")
+}
+func (UnimplementedGeoLocationServer) StreamLookup(GeoLocation_StreamLookupServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamLookup not implemented")
+}
+func (UnimplementedGeoLocationServer) mustEmbedUnimplementedGeoLocationServer() {}
+
+// UnsafeGeoLocationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GeoLocationServer will
+// result in compilation errors.
+type UnsafeGeoLocationServer interface {
+	mustEmbedUnimplementedGeoLocationServer()
+}
+
+func RegisterGeoLocationServer(s grpc.ServiceRegistrar, srv GeoLocationServer) {
+	s.RegisterService(&GeoLocation_ServiceDesc, srv)
+}
+
+func _GeoLocation_Lookup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GeoLocationServer).Lookup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GeoLocation_Lookup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GeoLocationServer).Lookup(ctx, req.(*LookupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GeoLocation_BatchLookup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchLookupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GeoLocationServer).BatchLookup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GeoLocation_BatchLookup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GeoLocationServer).BatchLookup(ctx, req.(*BatchLookupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GeoLocation_StreamLookup_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(GeoLocationServer).StreamLookup(&geoLocationStreamLookupServer{stream})
+}
+
+type GeoLocation_StreamLookupServer interface {
+	Send(*LookupResult) error
+	Recv() (*LookupRequest, error)
+	grpc.ServerStream
+}
+
+type geoLocationStreamLookupServer struct {
+	grpc.ServerStream
+}
+
+func (x *geoLocationStreamLookupServer) Send(m *LookupResult) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *geoLocationStreamLookupServer) Recv() (*LookupRequest, error) {
+	m := new(LookupRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GeoLocation_ServiceDesc is the grpc.ServiceDesc for GeoLocation service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var GeoLocation_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "findhotel.geolocation.v1.GeoLocation",
+	HandlerType: (*GeoLocationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Lookup",
+			Handler:    _GeoLocation_Lookup_Handler,
+		},
+		{
+			MethodName: "BatchLookup",
+			Handler:    _GeoLocation_BatchLookup_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamLookup",
+			Handler:       _GeoLocation_StreamLookup_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "api/geolocation/v1/geolocation.proto",
+}
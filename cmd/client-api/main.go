@@ -1,18 +1,38 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/ohmpatel1997/findhotel/internal/controller"
 	"github.com/ohmpatel1997/findhotel/internal/model"
+	"github.com/ohmpatel1997/findhotel/internal/rpc"
 	"github.com/ohmpatel1997/findhotel/internal/service"
 	"github.com/ohmpatel1997/findhotel/lib/config"
+	"github.com/ohmpatel1997/findhotel/lib/db"
 	"github.com/ohmpatel1997/findhotel/lib/db/init"
 	"github.com/ohmpatel1997/findhotel/lib/log"
 	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+)
+
+const (
+	backendDatabase = "database"
+	backendPostgres = "postgres" // an alias of database, whichever dialect it has
+	backendMemory   = "memory"
+	backendMMDB     = "mmdb"
+
+	// envDevelopment is the ENV the api may run in without api keys
+	envDevelopment = "development"
 )
 
 func main() {
@@ -27,38 +47,454 @@ func main() {
 	l := zlog.New()
 	l.Info("### Starting up client api ###", nil)
 
-	host := os.Getenv("POSTGRES_HOST")
-	dbName := os.Getenv("POSTGRES_DB")
-	password := os.Getenv("POSTGRES_PASSWORD")
-	user := os.Getenv("POSTGRES_USER")
-	dbPort := os.Getenv("POSTGRES_PORT")
-	conStr := fmt.Sprintf("postgres://%v:%v@%v:%v/%v", user, password, host, dbPort, dbName)
+	managers, err := db.Open(cfg.DB)
+	if err != nil {
+		panic(err)
+	}
+	if len(cfg.DB.Replicas) > 0 {
+		managers.GeoLocations, err = withReplicas(cfg.DB, managers.GeoLocations)
+		if err != nil {
+			panic(err)
+		}
+	}
 
-	db, err := pgsql.New(cfg.DB, conStr)
+	manager, reload, err := newGeoLocationManager(cfg.Lookup, managers)
 	if err != nil {
 		panic(err)
 	}
+	var cache model.CachedGeoLocationManager
+	if cfg.Lookup != nil && cfg.Lookup.Cache != nil && cfg.Lookup.Cache.Enabled {
+		cache = newCache(cfg.Lookup.Cache, manager)
+		manager = cache
+	}
+	switch {
+	case reload != nil:
+		// the cache is dropped once the backend has the new data, an import only reaching it with the next reload
+		go reloadGeoLocations(time.Duration(cfg.Lookup.ReloadSeconds)*time.Second, func() error {
+			if err := reload(); err != nil {
+				return err
+			}
+			if cache != nil {
+				invalidateCache(cache, "reloaded the lookup backend")
+			}
+			return nil
+		})
+	case cache != nil:
+		go service.WatchImports(context.Background(), managers.ImportRuns, time.Duration(cfg.Lookup.Cache.ImportPollSeconds)*time.Second, func() {
+			invalidateCache(cache, "an import finished")
+		})
+	}
 
-	manager := model.NewGeoLocationManager(db)
+	cities, stats, err := newDatasetViews(cfg.Lookup, managers)
+	if err != nil {
+		panic(err)
+	}
 
 	srv := service.NewGeolocationService(manager)
-	cntrl := controller.NewController(srv)
-	router := registerRoutes(cntrl)
+	cntrl := controller.NewController(srv, service.NewCityService(cities), service.NewStatsService(stats))
+	realIP, err := router.NewRealIP(cfg.Server.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+	apiKeys := newAPIKeyService(cfg.Auth, managers.APIKeys)
+	limiter, adminAuth, err := newAuth(cfg.Auth, apiKeys)
+	if err != nil {
+		panic(err)
+	}
+	apiKeyAuth := func(next http.Handler) http.Handler { return next }
+	if limiter != nil {
+		apiKeyAuth = limiter.Middleware
+	}
+	r := registerRoutes(cntrl, controller.NewAdminController(apiKeys), realIP, apiKeyAuth, adminAuth)
+
+	if cfg.Server.MetricsPort != "" {
+		if err := serveMetrics(cfg.Server.MetricsPort); err != nil {
+			panic(err)
+		}
+	}
+
+	var grpcServer *rpc.Server
+	if cfg.Server.GRPCPort != "" {
+		grpcServer, err = serveGRPC(cfg.Server.GRPCPort, srv, limiter)
+		if err != nil {
+			panic(err)
+		}
+	}
 
-	err = router.ListenAndServeTLS(cfg.Server)
+	err = r.ListenAndServeTLS(cfg.Server)
 	if err != nil {
 		panic(err)
 	}
+
+	if grpcServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		grpcServer.Stop(ctx)
+	}
+}
+
+// serveMetrics serves the Prometheus metrics on port/metrics until the process exits, apart from the public endpoints
+func serveMetrics(port string) error {
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", router.MetricsHandler())
+	go func() {
+		if err := http.Serve(lis, mux); err != nil {
+			zlog.Logger().Error("error serving metrics", err, zlog.ParamsType{"Port": port})
+		}
+	}()
+	zlog.Logger().Info("serving metrics", zlog.ParamsType{"Port": port})
+	return nil
+}
+
+// serveGRPC serves the gRPC lookups on port until the returned server is stopped, behind the api keys of limiter
+// unless it is nil
+func serveGRPC(port string, srv service.GeoLocationService, limiter *router.APIKeyLimiter) (*rpc.Server, error) {
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []grpc.ServerOption
+	if limiter != nil {
+		opts = rpc.APIKeyInterceptors(limiter)
+	}
+	server := rpc.NewServer(srv, opts...)
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			zlog.Logger().Error("error running grpc server", err, nil)
+		}
+	}()
+	zlog.Logger().Info("serving grpc", zlog.ParamsType{"Port": port})
+	return server, nil
+}
+
+// withReplicas sends the lookups of primary to the configured replicas, checking their health in the background
+func withReplicas(cfg *config.Database, primary model.GeoLocationManager) (model.GeoLocationManager, error) {
+	if cfg.Dialect != "" && cfg.Dialect != pgsql.DriverName {
+		return nil, fmt.Errorf("replicas aren't supported with the %q dialect", cfg.Dialect)
+	}
+
+	var replicas []*model.Replica
+	names := map[string]bool{db.PrimaryName: true}
+	for _, r := range cfg.Replicas {
+		port := r.Port
+		if port == "" {
+			port = os.Getenv("POSTGRES_PORT")
+		}
+		name := r.Name
+		if name == "" {
+			name = r.Host + ":" + port
+		}
+		// the metrics of the replicas are told apart by their names
+		if names[name] {
+			return nil, fmt.Errorf("replica name %q is taken by the primary or another replica", name)
+		}
+		names[name] = true
+
+		conn, err := pgsql.NewReplica(cfg, name, pgsql.URL(r.Host, port))
+		if err != nil {
+			return nil, err
+		}
+		if err := prometheus.Register(pgsql.NewPoolCollector(conn, name)); err != nil {
+			return nil, err
+		}
+		replicas = append(replicas, &model.Replica{
+			Name:         name,
+			GeoLocations: model.NewGeoLocationManager(conn),
+			Lag:          model.NewPostgresReplicationLag(conn),
+		})
+	}
+
+	manager := model.NewReplicatedGeoLocationManager(primary, replicas, model.ReplicaOptions{
+		MaxLag:        time.Duration(cfg.ReplicaMaxLagSeconds) * time.Second,
+		CheckInterval: time.Duration(cfg.ReplicaCheckSeconds) * time.Second,
+	})
+	manager.Check(context.Background())
+	for _, status := range manager.Status() {
+		if status.Healthy {
+			zlog.Logger().Info("replica in rotation", zlog.ParamsType{"Replica": status.Name, "Lag": status.Lag.String()})
+			continue
+		}
+		zlog.Logger().Error("replica out of rotation", status.Err, zlog.ParamsType{"Replica": status.Name})
+	}
+
+	go manager.Run(context.Background())
+	return manager, nil
+}
+
+// newGeoLocationManager returns the lookup backend chosen in the configuration, the database by default, and the
+// function reloading it, nil for the database
+func newGeoLocationManager(cfg *config.Lookup, managers *model.Managers) (model.GeoLocationManager, func() error, error) {
+	if cfg == nil || cfg.Backend == "" || cfg.Backend == backendDatabase || cfg.Backend == backendPostgres {
+		return managers.GeoLocations, nil, nil
+	}
+	if cfg.Backend == backendMMDB {
+		return newMMDBManager(cfg)
+	}
+	if cfg.Backend != backendMemory {
+		return nil, nil, fmt.Errorf("unknown lookup backend %q", cfg.Backend)
+	}
+
+	src := managers.Source
+	if cfg.SnapshotFile != "" {
+		src = model.NewSnapshotSource(cfg.SnapshotFile)
+	}
+	manager, err := model.NewMemoryGeoLocationManager(context.Background(), src)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, _ := manager.Loaded()
+	zlog.Logger().Info("loaded the geolocations into memory", zlog.ParamsType{"Rows": rows, "Snapshot": cfg.SnapshotFile})
+
+	reload := func() error {
+		if err := manager.Reload(context.Background()); err != nil {
+			return err
+		}
+		rows, _ := manager.Loaded()
+		zlog.Logger().Info("reloaded the geolocations", zlog.ParamsType{"Rows": rows})
+		return nil
+	}
+	return manager, reload, nil
+}
+
+// newMMDBManager serves the lookups from a MaxMind DB file, ours exported by cmd/export or a GeoIP2 City database
+func newMMDBManager(cfg *config.Lookup) (model.GeoLocationManager, func() error, error) {
+	if cfg.MMDBFile == "" {
+		return nil, nil, errors.New("the mmdb lookup backend needs lookup.mmdb_file")
+	}
+	manager, err := model.NewMMDBGeoLocationManager(context.Background(), cfg.MMDBFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata, _ := manager.Loaded()
+	zlog.Logger().Info("loaded the mmdb file", zlog.ParamsType{
+		"File": cfg.MMDBFile, "Type": metadata.DatabaseType, "Built": time.Unix(int64(metadata.BuildEpoch), 0).UTC().Format(time.RFC3339),
+	})
+
+	reload := func() error {
+		if err := manager.Reload(context.Background()); err != nil {
+			return err
+		}
+		metadata, _ := manager.Loaded()
+		zlog.Logger().Info("reloaded the mmdb file", zlog.ParamsType{"Type": metadata.DatabaseType, "Built": time.Unix(int64(metadata.BuildEpoch), 0).UTC().Format(time.RFC3339)})
+		return nil
+	}
+	return manager, reload, nil
+}
+
+// reloadGeoLocations calls reload every interval and on SIGHUP
+func reloadGeoLocations(interval time.Duration, reload func() error) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-hup:
+		case <-tick:
+		}
+		if err := reload(); err != nil {
+			zlog.Logger().Error("error reloading the geolocations, still serving the previous ones", err, nil)
+		}
+	}
+}
+
+// newDatasetViews returns the city search and the cached aggregations of the dataset the lookups are served from.
+// Both are refreshed whenever an import run finishes or is rolled back.
+func newDatasetViews(cfg *config.Lookup, managers *model.Managers) (model.CitySearchManager, model.CachedStatsManager, error) {
+	citySrc, statsManager := managers.Cities, managers.Stats
+	var interval time.Duration
+	if cfg != nil {
+		if cfg.Backend == backendMemory && cfg.SnapshotFile != "" {
+			src := model.NewSnapshotSource(cfg.SnapshotFile)
+			citySrc, statsManager = model.NewGeoLocationCitySource(src), model.NewMemoryStatsManager(src)
+		}
+		if cfg.Cache != nil {
+			interval = time.Duration(cfg.Cache.ImportPollSeconds) * time.Second
+		}
+	}
+
+	cities, err := model.NewCitySearchManager(context.Background(), citySrc)
+	if err != nil {
+		return nil, nil, err
+	}
+	count, _ := cities.Loaded()
+	zlog.Logger().Info("loaded the cities for the search", zlog.ParamsType{"Cities": count})
+	stats := model.NewCachedStatsManager(statsManager)
+
+	go service.WatchImports(context.Background(), managers.ImportRuns, interval, func() {
+		stats.Invalidate()
+		if err := cities.Reload(context.Background()); err != nil {
+			zlog.Logger().Error("error reloading the cities, still searching the previous ones", err, nil)
+			return
+		}
+		count, _ := cities.Loaded()
+		zlog.Logger().Info("an import finished, reloaded the cities and dropped the cached stats", zlog.ParamsType{"Cities": count})
+	})
+	return cities, stats, nil
+}
+
+// newCache wraps manager in a cache
+func newCache(cfg *config.Cache, manager model.GeoLocationManager) model.CachedGeoLocationManager {
+	cache := model.NewCachedGeoLocationManager(manager, model.CacheOptions{
+		MaxEntries:    cfg.MaxEntries,
+		TTL:           time.Duration(cfg.TTLSeconds) * time.Second,
+		NegativeTTL:   time.Duration(cfg.NegativeTTLSeconds) * time.Second,
+		LookupTimeout: time.Duration(cfg.LookupTimeoutSeconds) * time.Second,
+	})
+	prometheus.MustRegister(cacheMetrics(cache)...)
+	return cache
+}
+
+// invalidateCache drops the cached lookups once the data changed, logging the stats of the cache until then
+func invalidateCache(cache model.CachedGeoLocationManager, reason string) {
+	stats := cache.Stats()
+	cache.Invalidate()
+	zlog.Logger().Info(reason+", invalidated the lookup cache", zlog.ParamsType{
+		"Hits": stats.Hits, "Negative Hits": stats.NegativeHits, "Misses": stats.Misses, "Coalesced": stats.Coalesced,
+		"Evictions": stats.Evictions, "Entries": stats.Entries,
+	})
+}
+
+// cacheMetrics exposes the stats of cache, its hit rate being the rate of the hits over the one of the hits and misses
+func cacheMetrics(cache model.CachedGeoLocationManager) []prometheus.Collector {
+	counter := func(name, help string, value func(model.CacheStats) int64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{Name: "lookup_cache_" + name, Help: help}, func() float64 {
+			return float64(value(cache.Stats()))
+		})
+	}
+	return []prometheus.Collector{
+		counter("hits_total", "The lookups answered by the cache, negative hits included.", func(s model.CacheStats) int64 { return s.Hits }),
+		counter("negative_hits_total", "The lookups answered by a cached not found.", func(s model.CacheStats) int64 { return s.NegativeHits }),
+		counter("misses_total", "The lookups the cache passed on, coalesced ones included.", func(s model.CacheStats) int64 { return s.Misses }),
+		counter("coalesced_total", "The misses that waited for the lookup of another caller.", func(s model.CacheStats) int64 { return s.Coalesced }),
+		counter("evictions_total", "The entries evicted to make room for others.", func(s model.CacheStats) int64 { return s.Evictions }),
+		counter("invalidations_total", "The times the cache was dropped after an import.", func(s model.CacheStats) int64 { return s.Invalidations }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: "lookup_cache_entries", Help: "The lookups cached."}, func() float64 {
+			return float64(cache.Stats().Entries)
+		}),
+	}
+}
+
+// newAPIKeyService issues the api keys with the configured default limits
+func newAPIKeyService(cfg *config.Auth, manager model.APIKeyManager) service.APIKeyService {
+	var defaults service.APIKeyLimits
+	if cfg != nil {
+		defaults = service.APIKeyLimits{RateLimit: cfg.DefaultRateLimit, Burst: cfg.DefaultBurst}
+	}
+	return service.NewAPIKeyService(manager, defaults)
 }
 
-func registerRoutes(clientCntrl controller.ClientController) router.Router {
+// newAuth returns the limiter authenticating the /v1 endpoints and the gRPC lookups with api keys, nil when auth
+// isn't enabled, which only ENV=development allows, and the middleware of the admin endpoints checking the
+// ADMIN_TOKEN, nil when it isn't set. The ADMIN_TOKEN is required with auth enabled, the admin endpoints being the
+// only way to issue the keys.
+func newAuth(cfg *config.Auth, apiKeys service.APIKeyService) (*router.APIKeyLimiter, func(http.Handler) http.Handler, error) {
+	var limiter *router.APIKeyLimiter
+	switch {
+	case cfg != nil && cfg.Enabled:
+		limiter = router.NewAPIKeyLimiter(apiKeys, router.APIKeyOptions{CacheTTL: time.Duration(cfg.KeyCacheSeconds) * time.Second})
+	case os.Getenv("ENV") == envDevelopment:
+		zlog.Logger().Warn("api key authentication is off, the endpoints are open", nil)
+	default:
+		return nil, nil, errors.New("api key authentication is off, which leaves the endpoints open: set auth.enabled, or ENV=" + envDevelopment + " to run without it")
+	}
+
+	token := os.Getenv("ADMIN_TOKEN")
+	if token == "" && limiter != nil {
+		return nil, nil, errors.New("api key authentication is on but ADMIN_TOKEN isn't set, so no key could be issued: set ADMIN_TOKEN")
+	}
+	if token == "" {
+		zlog.Logger().Info("ADMIN_TOKEN isn't set, the admin endpoints are off", nil)
+		return limiter, nil, nil
+	}
+	return limiter, router.NewAdminAuth(token), nil
+}
+
+// registerRoutes registers the endpoints of clientCntrl behind apiKeyAuth, and the ones of adminCntrl behind
+// adminAuth unless it is nil. realIP resolves the address of the callers behind proxies.
+func registerRoutes(clientCntrl controller.ClientController, adminCntrl controller.AdminController,
+	realIP, apiKeyAuth, adminAuth func(http.Handler) http.Handler) router.Router {
 	r := router.NewBasicRouter()
 
 	r.Route(clientCntrl.GetAPIVersionPath("/ip-info"), func(r router.Router) {
+		r.Use(apiKeyAuth)
+
 		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 			clientCntrl.GetGeolocationData(w, r)
 		})
+		r.Get("/me", func(w http.ResponseWriter, r *http.Request) {
+			clientCntrl.GetCallerGeolocationData(w, r)
+		}, realIP)
+		r.Post("/batch", func(w http.ResponseWriter, r *http.Request) {
+			clientCntrl.GetGeolocationDataBatch(w, r)
+		})
+	})
+
+	r.Route(clientCntrl.GetAPIVersionPath("/cities"), func(r router.Router) {
+		r.Use(apiKeyAuth)
+
+		r.Get("/search", func(w http.ResponseWriter, r *http.Request) {
+			clientCntrl.SearchCities(w, r)
+		})
+	})
+
+	r.Route(clientCntrl.GetAPIVersionPath("/stats"), func(r router.Router) {
+		r.Use(apiKeyAuth)
+
+		r.Get("/countries", func(w http.ResponseWriter, r *http.Request) {
+			clientCntrl.GetCountryStats(w, r)
+		})
+		r.Get("/countries/{code}/cities", func(w http.ResponseWriter, r *http.Request) {
+			clientCntrl.GetCityStats(w, r)
+		})
+	})
+
+	r.Route(clientCntrl.GetAPIVersionPath("/geolocations"), func(r router.Router) {
+		r.Use(apiKeyAuth)
+
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			clientCntrl.ListGeolocations(w, r)
+		})
+	})
+
+	r.Route(clientCntrl.GetAPIVersionPath("/graphql"), func(r router.Router) {
+		r.Use(apiKeyAuth)
+
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			clientCntrl.GraphQL(w, r)
+		})
+		r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+			clientCntrl.GraphQL(w, r)
+		})
 	})
 
+	if adminAuth != nil {
+		r.Route(adminCntrl.GetAPIVersionPath("/admin/api-keys"), func(r router.Router) {
+			r.Use(adminAuth)
+
+			r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+				adminCntrl.IssueAPIKey(w, r)
+			})
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				adminCntrl.ListAPIKeys(w, r)
+			})
+			r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+				adminCntrl.RevokeAPIKey(w, r)
+			})
+		})
+	}
+
 	return r
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	"github.com/ohmpatel1997/findhotel/lib/config"
+	"github.com/ohmpatel1997/findhotel/lib/db"
+	zlog "github.com/ohmpatel1997/findhotel/lib/log"
+)
+
+func main() {
+	_ = zlog.New()
+
+	cfgPath := flag.String("p", "./cmd/import/config.yaml", "The configuration path, the database is the one of the importer")
+	output := flag.String("o", "geolocations.mmdb", "The MaxMind DB file to write")
+	flag.Usage = usage
+	flag.Parse()
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		panic(err)
+	}
+
+	managers, err := db.Open(cfg.DB)
+	if err != nil {
+		panic(err)
+	}
+
+	rows, err := model.WriteMMDB(context.Background(), managers.Source, *output)
+	if err != nil {
+		zlog.Logger().Error("error exporting the geolocations", err, zlog.ParamsType{"File": *output})
+		os.Exit(1)
+	}
+
+	zlog.Logger().Info("Successfully Exported Geolocations", zlog.ParamsType{"File": *output, "Rows": rows})
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage:
+    export [OPTIONS]    Compile the geolocations into a MaxMind DB file, readable by the MaxMind readers
+
+Options:
+`)
+	flag.PrintDefaults()
+}
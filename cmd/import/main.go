@@ -1,59 +1,259 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/ohmpatel1997/findhotel/internal/model"
 	"github.com/ohmpatel1997/findhotel/internal/service"
 	"github.com/ohmpatel1997/findhotel/lib/config"
-	pgsql "github.com/ohmpatel1997/findhotel/lib/db/init"
+	"github.com/ohmpatel1997/findhotel/lib/db"
 	zlog "github.com/ohmpatel1997/findhotel/lib/log"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func main() {
 	_ = zlog.New()
 
-	zlog.Logger().Info("importing the data. please wait....", nil)
-
 	cfgPath := flag.String("p", "./cmd/import/config.yaml", "The configuration path")
-	dumpFilePath := flag.String("s", "./cmd/import/data_dump.csv", "The configuration path")
+	dumpFiles := inputsFlag{}
+	flag.Var(&dumpFiles, "s", "The data dump files, globs or directories. May be repeated or comma separated (default ./cmd/import/data_dump.csv)")
+	parallelism := flag.Int("parallel", 0, "The number of files imported at the same time (default import.parallelism)")
+	encoding := flag.String("encoding", "", "The encoding of the data dump files: auto, utf-8, latin-1, windows-1252, utf-16le or utf-16be (default import.encoding)")
+	wait := flag.Bool("wait", false, "Wait for a running import to finish instead of failing")
+	waitTimeout := flag.Duration("wait-timeout", 0, "How long to wait for a running import, 0 waits forever")
+	force := flag.Bool("force", false, "Import the data dump even if it was already imported")
+	limit := flag.Int("limit", 50, "The number of ledger entries to list, 0 lists all")
+	metricsAddr := flag.String("metrics", "", "The address to serve the metrics on while running, e.g. :9102 (default import.metrics_address)")
+	flag.Usage = usage
 	flag.Parse()
 	cfg, err := config.Load(*cfgPath)
 	if err != nil {
 		panic(err)
 	}
 
-	file, err := os.Open(*dumpFilePath)
+	managers, err := db.Open(cfg.DB)
 	if err != nil {
-		panic(err.Error())
+		panic(err)
+	}
+
+	if *metricsAddr == "" && cfg.Import != nil {
+		*metricsAddr = cfg.Import.MetricsAddress
+	}
+	if *metricsAddr != "" {
+		serveMetrics(*metricsAddr)
 	}
-	defer file.Close()
 
-	host := os.Getenv("POSTGRES_HOST")
-	dbName := os.Getenv("POSTGRES_DB")
-	password := os.Getenv("POSTGRES_PASSWORD")
-	user := os.Getenv("POSTGRES_USER")
-	dbPort := os.Getenv("POSTGRES_PORT")
+	importSrv := service.NewImportService(managers.ImportRuns, managers.ImportLedger, managers.GeoLocations)
 
-	conStr := fmt.Sprintf("postgres://%v:%v@%v:%v/%v", user, password, host, dbPort, dbName)
+	// reading the ledger doesn't touch geolocations, so it doesn't need the import lock
+	if flag.Arg(0) == "ledger" {
+		if err := runLedger(importSrv, flag.Arg(1), *limit); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
 
-	db, err := pgsql.New(cfg.DB, conStr)
-	if err != nil {
+	// neither does writing a snapshot for the in-memory lookups of the api
+	if flag.Arg(0) == "snapshot" {
+		if err := runSnapshot(managers.Source, flag.Arg(1)); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	lockOpts := service.ImportLockOptions{
+		Wait:        *wait,
+		WaitTimeout: *waitTimeout,
+	}
+	if cfg.Import != nil {
+		lockOpts.StaleAfter = time.Duration(cfg.Import.LockStaleSeconds) * time.Second
+		lockOpts.HeartbeatInterval = time.Duration(cfg.Import.LockHeartbeatSeconds) * time.Second
+		lockOpts.PollInterval = time.Duration(cfg.Import.LockPollSeconds) * time.Second
+	}
+
+	ctx, unlock, err := service.NewImportLock(managers.ImportLocks, lockOpts).Lock(context.Background())
+	switch {
+	case errors.Is(err, service.ErrImportLocked):
+		zlog.Logger().Error("import aborted", err, nil)
+		os.Exit(1)
+	case err != nil:
 		panic(err)
 	}
 
-	manager := model.NewGeoLocationManager(db)
-	parserService := service.NewParser(file, manager)
+	switch flag.Arg(0) {
+	case "":
+		if len(dumpFiles) == 0 {
+			dumpFiles = inputsFlag{"./cmd/import/data_dump.csv"}
+		}
+		opts := service.ImportOptions{Force: *force, Parallelism: *parallelism, Encoding: *encoding}
+		if opts.Parallelism == 0 && cfg.Import != nil {
+			opts.Parallelism = cfg.Import.Parallelism
+		}
+		if opts.Encoding == "" && cfg.Import != nil {
+			opts.Encoding = cfg.Import.Encoding
+		}
+		err = runImport(ctx, importSrv, dumpFiles, opts)
+	case "rollback":
+		err = runRollback(ctx, importSrv, flag.Arg(1))
+	default:
+		err = fmt.Errorf("unknown command %q", flag.Arg(0))
+		zlog.Logger().Error("import aborted", err, nil)
+	}
 
-	timeTaken, invalid, validData, err := parserService.ParseAndStore()
+	unlock()
 	if err != nil {
-		zlog.Logger().Error("error parsing", err, nil)
-		return
+		os.Exit(1)
+	}
+}
+
+// serveMetrics serves the counters of the importer, and of its database, on addr/metrics until the process exits.
+// A run ending between two scrapes isn't seen in full, its totals are logged regardless.
+func serveMetrics(addr string) {
+	prometheus.MustRegister(service.ImportMetrics()...)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", router.MetricsHandler())
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			zlog.Logger().Error("error serving metrics", err, zlog.ParamsType{"Address": addr})
+		}
+	}()
+	zlog.Logger().Info("serving metrics", zlog.ParamsType{"Address": addr})
+}
+
+func runImport(ctx context.Context, importSrv service.ImportService, inputs []string, opts service.ImportOptions) error {
+	files, err := service.ResolveInputs(inputs)
+	if err != nil {
+		zlog.Logger().Error("error resolving data dump files", err, nil)
+		return err
+	}
+
+	zlog.Logger().Info("importing the data. please wait....", zlog.ParamsType{"Files": len(files)})
+
+	result, err := importSrv.Import(ctx, files, opts)
+	if result != nil {
+		for _, f := range result.Files {
+			if f.Skipped {
+				zlog.Logger().Info("File was already imported, skipping. Use -force to import it again", zlog.ParamsType{"File": f.File, "Run ID": f.RunID.String(), "SHA256": f.SHA256})
+				continue
+			}
+			if f.InvalidEncoding > 0 {
+				zlog.Logger().Warn("Rejected rows with invalid byte sequences", zlog.ParamsType{"File": f.File, "Encoding": f.Encoding, "Rows": f.InvalidEncoding, "First Lines": f.InvalidEncodingLines})
+			}
+			zlog.Logger().Info("File Metrics", zlog.ParamsType{"File": f.File, "Encoding": f.Encoding, "Time Taken": f.TimeTaken, "Valid Data": f.Valid, "Invalid Data": f.Invalid})
+		}
+	}
+	if err != nil {
+		params := zlog.ParamsType{}
+		if result != nil {
+			params["Run ID"] = result.RunID.String()
+		}
+		zlog.Logger().Error("error parsing", err, params)
+		return err
+	}
+
+	if result.Skipped {
+		zlog.Logger().Info("Every file was already imported, nothing to do", nil)
+		return nil
+	}
+
+	zlog.Logger().Info("Successfully Parsed And Stored", zlog.ParamsType{"Run ID": result.RunID.String()})
+
+	zlog.Logger().Info("Metrics", zlog.ParamsType{"Time Taken": result.TimeTaken, "Valid Data": result.Valid, "Invalid Data": result.Invalid, "Files": len(result.Files)})
+	return nil
+}
+
+func runRollback(ctx context.Context, importSrv service.ImportService, arg string) error {
+	runID, err := uuid.Parse(arg)
+	if err != nil {
+		err = fmt.Errorf("invalid run id %q: %w", arg, err)
+		zlog.Logger().Error("rollback aborted", err, nil)
+		return err
+	}
+
+	result, err := importSrv.Rollback(ctx, runID)
+	if err != nil {
+		zlog.Logger().Error("error rolling back import run", err, zlog.ParamsType{"Run ID": runID.String()})
+		return err
 	}
 
-	zlog.Logger().Info("Successfully Parsed And Stored", nil)
+	zlog.Logger().Info("Successfully Rolled Back", zlog.ParamsType{"Run ID": runID.String(), "Deleted": result.Deleted, "Restored": result.Restored})
+	return nil
+}
+
+func runLedger(importSrv service.ImportService, cmd string, limit int) error {
+	if cmd != "list" {
+		err := fmt.Errorf("unknown ledger command %q", cmd)
+		zlog.Logger().Error("ledger aborted", err, nil)
+		return err
+	}
+
+	entries, err := importSrv.Ledger(context.Background(), limit)
+	if err != nil {
+		zlog.Logger().Error("error listing import ledger", err, nil)
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STARTED\tRUN ID\tSTATUS\tFILE\tSHA256\tVALID\tINVALID\tDURATION")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%.2fs\n",
+			e.CreatedAt.Format(time.RFC3339), e.RunID, e.Status, e.FileName, e.SHA256, e.ValidCount, e.InvalidCount, e.DurationSeconds)
+	}
+	return w.Flush()
+}
+
+func runSnapshot(src model.GeoLocationSource, path string) error {
+	if path == "" {
+		err := errors.New("missing snapshot file")
+		zlog.Logger().Error("snapshot aborted", err, nil)
+		return err
+	}
+
+	rows, err := model.WriteSnapshot(context.Background(), src, path)
+	if err != nil {
+		zlog.Logger().Error("error writing snapshot", err, zlog.ParamsType{"File": path})
+		return err
+	}
+
+	zlog.Logger().Info("Successfully Written Snapshot", zlog.ParamsType{"File": path, "Rows": rows})
+	return nil
+}
+
+// inputsFlag collects the -s values, the flag may be repeated and takes comma separated lists
+type inputsFlag []string
+
+func (f *inputsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *inputsFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*f = append(*f, v)
+		}
+	}
+	return nil
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage:
+    import [OPTIONS]                    Import the data dump files as a new import run
+    import [OPTIONS] rollback RUN_ID    Restore geolocations to their state before the run
+    import [OPTIONS] ledger list        Show the import history
+    import [OPTIONS] snapshot FILE      Write the geolocations to a snapshot file for the in-memory api backend
 
-	zlog.Logger().Info("Metrics", zlog.ParamsType{"Time Taken": timeTaken, "Valid Data": validData, "Invalid Data": invalid})
+Options:
+`)
+	flag.PrintDefaults()
 }
@@ -1,14 +1,12 @@
 package common
 
 import (
-	"regexp"
+	"bytes"
+	"errors"
+	"net"
 	"strings"
 )
 
-var (
-	ipRegex = regexp.MustCompile(`^(([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5])\.){3}([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5])$`)
-)
-
 const (
 	IP           = "ip_address"
 	CountryCode  = "country_code"
@@ -17,9 +15,67 @@ const (
 	Latitude     = "latitude"
 	Longitude    = "longitude"
 	MysteryValue = "mystery_value"
+	Network      = "network"
+	StartIP      = "start_ip"
+	EndIP        = "end_ip"
+)
+
+var (
+	ErrInvalidRange = errors.New("invalid ip range")
 )
 
-func IsIpv4Regex(ipAddress string) bool {
-	ipAddress = strings.Trim(ipAddress, " ")
-	return ipRegex.MatchString(ipAddress)
+// ParseIP returns the address of an IPv4 or IPv6 ip, nil when it isn't one. It is what the importer and every lookup
+// take for a valid ip, IPv4 in dotted decimal without leading zeros and IPv6 in any of its notations.
+func ParseIP(ip string) net.IP {
+	return net.ParseIP(ip)
+}
+
+// CityKey is the form city names are compared in: case folded, with whitespace trimmed and collapsed. The sqlite
+// databases have it as the city_key function, for the migrations.
+func CityKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// ParseNetwork returns the first and the last address of a CIDR network and its canonical notation.
+// A single host network is noted as its address.
+func ParseNetwork(network string) (net.IP, net.IP, string, error) {
+	_, ipNet, err := net.ParseCIDR(strings.TrimSpace(network))
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	start := ipNet.IP
+	end := make(net.IP, len(start))
+	for i := range start {
+		end[i] = start[i] | ^ipNet.Mask[i]
+	}
+
+	if ones, bits := ipNet.Mask.Size(); ones == bits {
+		return start, end, start.String(), nil
+	}
+	return start, end, ipNet.String(), nil
+}
+
+// ParseRange validates the inclusive range between two addresses of the same family and returns its canonical notation
+func ParseRange(startIP, endIP string) (net.IP, net.IP, string, error) {
+	start := net.ParseIP(strings.TrimSpace(startIP))
+	end := net.ParseIP(strings.TrimSpace(endIP))
+	if start == nil || end == nil {
+		return nil, nil, "", ErrInvalidRange
+	}
+
+	if (start.To4() == nil) != (end.To4() == nil) {
+		return nil, nil, "", ErrInvalidRange
+	}
+	if start4 := start.To4(); start4 != nil {
+		start, end = start4, end.To4()
+	}
+
+	switch bytes.Compare(start, end) {
+	case 1:
+		return nil, nil, "", ErrInvalidRange
+	case 0:
+		return start, end, start.String(), nil
+	}
+	return start, end, start.String() + "-" + end.String(), nil
 }
@@ -6,26 +6,194 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestIsIpv4Regex(t *testing.T) {
+func TestParseIP(t *testing.T) {
 	cases := []struct {
 		Name         string
-		ExpectedResp bool
+		ExpectedResp string
 		Req          string
 	}{
 		{
 			Name:         "valid",
-			ExpectedResp: true,
+			ExpectedResp: "192.0.2.146",
 			Req:          "192.0.2.146",
 		},
 		{
-			Name:         "invalid due to space",
-			ExpectedResp: false,
-			Req:          "192. 0.2.146",
+			Name:         "ipv6",
+			ExpectedResp: "2001:db8::1",
+			Req:          "2001:0db8:0000::1",
 		},
 		{
-			Name:         "invalid due to wrong format",
-			ExpectedResp: false,
-			Req:          "192.0.2.146.123",
+			Name:         "ipv4 mapped ipv6",
+			ExpectedResp: "192.0.2.146",
+			Req:          "::ffff:192.0.2.146",
+		},
+		{
+			Name: "invalid due to space",
+			Req:  "192. 0.2.146",
+		},
+		{
+			Name: "invalid due to wrong format",
+			Req:  "192.0.2.146.123",
+		},
+		{
+			Name: "invalid due to leading zeros",
+			Req:  "192.0.2.046",
+		},
+		{
+			Name: "invalid due to network",
+			Req:  "192.0.2.0/24",
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			ip := ParseIP(tt.Req)
+			if tt.ExpectedResp == "" {
+				assert.Nil(ip)
+				return
+			}
+			assert.Equal(tt.ExpectedResp, ip.String())
+		})
+	}
+}
+
+func TestCityKey(t *testing.T) {
+	cases := []struct {
+		Name         string
+		ExpectedResp string
+		Req          string
+	}{
+		{
+			Name:         "case folded",
+			ExpectedResp: "new delhi",
+			Req:          "New DELHI",
+		},
+		{
+			Name:         "whitespace trimmed and collapsed",
+			ExpectedResp: "new delhi",
+			Req:          " new \t delhi\n",
+		},
+		{
+			Name:         "non ascii letters folded",
+			ExpectedResp: "čačak",
+			Req:          "ČAČAK",
+		},
+		{
+			Name: "only whitespace",
+			Req:  " \t ",
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.ExpectedResp, CityKey(tt.Req))
+		})
+	}
+}
+
+func TestParseNetwork(t *testing.T) {
+	cases := []struct {
+		Name          string
+		Req           string
+		ExpectedStart string
+		ExpectedEnd   string
+		ExpectedKey   string
+		ExpectedError bool
+	}{
+		{
+			Name:          "ipv4 network",
+			Req:           "10.1.0.0/16",
+			ExpectedStart: "10.1.0.0",
+			ExpectedEnd:   "10.1.255.255",
+			ExpectedKey:   "10.1.0.0/16",
+		},
+		{
+			Name:          "host bits are masked",
+			Req:           "10.1.2.3/16",
+			ExpectedStart: "10.1.0.0",
+			ExpectedEnd:   "10.1.255.255",
+			ExpectedKey:   "10.1.0.0/16",
+		},
+		{
+			Name:          "single host",
+			Req:           "192.0.2.146/32",
+			ExpectedStart: "192.0.2.146",
+			ExpectedEnd:   "192.0.2.146",
+			ExpectedKey:   "192.0.2.146",
+		},
+		{
+			Name:          "ipv6 network",
+			Req:           "2001:db8::/120",
+			ExpectedStart: "2001:db8::",
+			ExpectedEnd:   "2001:db8::ff",
+			ExpectedKey:   "2001:db8::/120",
+		},
+		{
+			Name:          "invalid",
+			Req:           "10.1.0.0/33",
+			ExpectedError: true,
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			start, end, key, err := ParseNetwork(tt.Req)
+			assert.Equal(tt.ExpectedError, err != nil)
+			if err != nil {
+				return
+			}
+			assert.Equal(tt.ExpectedStart, start.String())
+			assert.Equal(tt.ExpectedEnd, end.String())
+			assert.Equal(tt.ExpectedKey, key)
+		})
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	cases := []struct {
+		Name          string
+		Start         string
+		End           string
+		ExpectedKey   string
+		ExpectedError bool
+	}{
+		{
+			Name:        "ipv4 range",
+			Start:       "1.0.0.0",
+			End:         "1.0.0.255",
+			ExpectedKey: "1.0.0.0-1.0.0.255",
+		},
+		{
+			Name:        "single address",
+			Start:       "1.0.0.7",
+			End:         "1.0.0.7",
+			ExpectedKey: "1.0.0.7",
+		},
+		{
+			Name:          "reversed",
+			Start:         "1.0.0.255",
+			End:           "1.0.0.0",
+			ExpectedError: true,
+		},
+		{
+			Name:          "mixed families",
+			Start:         "1.0.0.0",
+			End:           "2001:db8::",
+			ExpectedError: true,
+		},
+		{
+			Name:          "invalid address",
+			Start:         "1.0.0",
+			End:           "1.0.0.255",
+			ExpectedError: true,
 		},
 	}
 
@@ -34,7 +202,9 @@ func TestIsIpv4Regex(t *testing.T) {
 		t.Run(tt.Name, func(t *testing.T) {
 			t.Parallel()
 			assert := assert.New(t)
-			assert.Equal(tt.ExpectedResp, IsIpv4Regex(tt.Req))
+			_, _, key, err := ParseRange(tt.Start, tt.End)
+			assert.Equal(tt.ExpectedError, err != nil)
+			assert.Equal(tt.ExpectedKey, key)
 		})
 	}
 }
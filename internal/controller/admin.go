@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ohmpatel1997/findhotel/internal/service"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+)
+
+const (
+	URLParamAPIKeyID = "id"
+
+	// maxAPIKeyBodyBytes bounds the body issuing a key
+	maxAPIKeyBodyBytes = 1 << 16
+)
+
+// AdminController serves the endpoints managing the api, behind the admin token
+type AdminController interface {
+	GetAPIVersionPath(string) string
+
+	IssueAPIKey(http.ResponseWriter, *http.Request)
+	ListAPIKeys(http.ResponseWriter, *http.Request)
+	RevokeAPIKey(http.ResponseWriter, *http.Request)
+}
+
+type adminController struct {
+	apiKeySrv service.APIKeyService
+}
+
+func NewAdminController(apiKeys service.APIKeyService) AdminController {
+	return &adminController{
+		apiKeySrv: apiKeys,
+	}
+}
+
+func (c *adminController) GetAPIVersionPath(p string) string {
+	return "/" + clientApiVersion + p
+}
+
+// IssueAPIKey issues a key, the response is the only time the key itself is shown
+func (c *adminController) IssueAPIKey(w http.ResponseWriter, r *http.Request) {
+	req := new(service.IssueKeyRequest)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIKeyBodyBytes)).Decode(req); err != nil {
+		router.RenderError(w, router.NewHttpError("invalid body, expected {\"name\": \"...\", \"rate_limit\": ..., \"burst\": ...}", 400))
+		return
+	}
+
+	response, err := c.apiKeySrv.Issue(r.Context(), req)
+	if err != nil {
+		router.RenderError(w, err)
+		return
+	}
+
+	router.Render(router.Response{
+		Writer: w,
+		Data:   response,
+		Status: 201,
+	})
+}
+
+func (c *adminController) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	response, err := c.apiKeySrv.List(r.Context())
+	if err != nil {
+		router.RenderError(w, err)
+		return
+	}
+
+	router.Render(router.Response{
+		Writer: w,
+		Data:   response,
+		Status: 200,
+	})
+}
+
+func (c *adminController) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	req := &service.RevokeKeyRequest{ID: router.URLParam(r, URLParamAPIKeyID)}
+
+	response, err := c.apiKeySrv.Revoke(r.Context(), req)
+	if err != nil {
+		router.RenderError(w, err)
+		return
+	}
+
+	router.Render(router.Response{
+		Writer: w,
+		Data:   response,
+		Status: 200,
+	})
+}
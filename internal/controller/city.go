@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ohmpatel1997/findhotel/internal/service"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+)
+
+const (
+	ParamQuery = "q"
+)
+
+func (c *clientController) SearchCities(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := &service.CitySearchRequest{
+		Query:       query.Get(ParamQuery),
+		CountryCode: query.Get(ParamCountryCode),
+	}
+	if len(req.Query) == 0 {
+		router.RenderError(w, router.NewHttpError("query param q could not be found", 400))
+		return
+	}
+	if limit := query.Get(ParamLimit); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			router.RenderError(w, router.NewHttpError("invalid limit", 400))
+			return
+		}
+		req.Limit = n
+	}
+
+	response, err := c.citySrv.SearchCities(r.Context(), req)
+	if err != nil {
+		router.RenderError(w, err)
+		return
+	}
+
+	router.Render(router.Response{
+		Writer: w,
+		Data:   response,
+		Status: 200,
+	})
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"net/http"
 
+	"github.com/graphql-go/graphql"
 	"github.com/ohmpatel1997/findhotel/internal/service"
 )
 
@@ -17,16 +18,38 @@ type ClientController interface {
 	GetAPIVersionPath(string) string
 
 	GetGeolocationData(http.ResponseWriter, *http.Request)
+	GetCallerGeolocationData(http.ResponseWriter, *http.Request)
+	GetGeolocationDataBatch(http.ResponseWriter, *http.Request)
+	ListGeolocations(http.ResponseWriter, *http.Request)
+
+	SearchCities(http.ResponseWriter, *http.Request)
+
+	GetCountryStats(http.ResponseWriter, *http.Request)
+	GetCityStats(http.ResponseWriter, *http.Request)
+
+	GraphQL(http.ResponseWriter, *http.Request)
 }
 
 type clientController struct {
 	geolocationSrv service.GeoLocationService
+	citySrv        service.CityService
+	statsSrv       service.StatsService
+	graphqlSchema  graphql.Schema
 }
 
-func NewController(geolocation service.GeoLocationService) ClientController {
-	return &clientController{
+func NewController(geolocation service.GeoLocationService, city service.CityService, stats service.StatsService) ClientController {
+	c := &clientController{
 		geolocationSrv: geolocation,
+		citySrv:        city,
+		statsSrv:       stats,
+	}
+
+	schema, err := c.newGraphQLSchema()
+	if err != nil {
+		panic("invalid graphql schema: " + err.Error())
 	}
+	c.graphqlSchema = schema
+	return c
 }
 
 func (c *clientController) GetAPIVersion() string {
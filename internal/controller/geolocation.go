@@ -1,23 +1,67 @@
 package controller
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ohmpatel1997/findhotel/internal/model"
 	"github.com/ohmpatel1997/findhotel/internal/service"
 	"github.com/ohmpatel1997/findhotel/lib/router"
 )
 
 const (
-	ParamIP = "ip"
+	ParamIP          = "ip"
+	ParamCoordinates = "coordinates"
+	ParamAsOf        = "as_of"
+
+	ParamCountryCode = "country_code"
+	ParamCountry     = "country"
+	ParamCity        = "city"
+	ParamBoundingBox = "bbox" // min_longitude,min_latitude,max_longitude,max_latitude
+	ParamSort        = "sort"
+	ParamOrder       = "order" // asc or desc
+	ParamLimit       = "limit"
+	ParamCursor      = "cursor"
+
+	// dateLayout is accepted for as_of besides RFC 3339, meaning the start of the day in UTC
+	dateLayout = "2006-01-02"
+
+	// maxBatchBodyBytes bounds the body of a batch lookup, room for MaxBatchSize ipv6 addresses
+	maxBatchBodyBytes = 1 << 20
+
+	// CoordinatesString keeps latitude and longitude as strings, the response shape old clients expect
+	CoordinatesString = "string"
 )
 
 func (c *clientController) GetGeolocationData(w http.ResponseWriter, r *http.Request) {
-	req := new(service.GetRequest)
-	req.IP = r.URL.Query().Get(ParamIP)
-	if len(req.IP) == 0 {
+	ip := r.URL.Query().Get(ParamIP)
+	if len(ip) == 0 {
 		router.RenderError(w, router.NewHttpError("path param could not be found", 400))
 		return
 	}
+	c.renderGeolocationData(w, r, ip)
+}
+
+// GetCallerGeolocationData looks up the address the request came from, the client's one behind trusted proxies
+func (c *clientController) GetCallerGeolocationData(w http.ResponseWriter, r *http.Request) {
+	c.renderGeolocationData(w, r, router.ClientIP(r))
+}
+
+// renderGeolocationData looks ip up with the options of the query of r
+func (c *clientController) renderGeolocationData(w http.ResponseWriter, r *http.Request, ip string) {
+	req := &service.GetRequest{IP: ip}
+	if asOf := r.URL.Query().Get(ParamAsOf); asOf != "" {
+		var err error
+		req.AsOf, err = parseAsOf(asOf)
+		if err != nil {
+			router.RenderError(w, router.NewHttpError("invalid as_of, expected an RFC 3339 time or a date", 400))
+			return
+		}
+	}
 
 	response, err := c.geolocationSrv.GetIPData(r.Context(), req)
 	if err != nil {
@@ -25,9 +69,111 @@ func (c *clientController) GetGeolocationData(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	router.RenderJSON(router.Response{
+	var data interface{} = response
+	if r.URL.Query().Get(ParamCoordinates) == CoordinatesString {
+		data = response.Legacy()
+	}
+
+	router.Render(router.Response{
+		Writer: w,
+		Data:   data,
+		Status: 200,
+	})
+}
+
+func (c *clientController) GetGeolocationDataBatch(w http.ResponseWriter, r *http.Request) {
+	req := new(service.BatchRequest)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)).Decode(req); err != nil {
+		router.RenderError(w, router.NewHttpError("invalid body, expected {\"ips\": [...]}", 400))
+		return
+	}
+
+	response, err := c.geolocationSrv.GetIPDataBatch(r.Context(), req)
+	if err != nil {
+		router.RenderError(w, err)
+		return
+	}
+
+	router.Render(router.Response{
 		Writer: w,
 		Data:   response,
 		Status: 200,
 	})
 }
+
+func parseAsOf(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(dateLayout, value)
+}
+
+func (c *clientController) ListGeolocations(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := &service.ListRequest{
+		CountryCode: query.Get(ParamCountryCode),
+		Country:     query.Get(ParamCountry),
+		City:        query.Get(ParamCity),
+		Sort:        query.Get(ParamSort),
+		Cursor:      query.Get(ParamCursor),
+	}
+
+	switch query.Get(ParamOrder) {
+	case "", "asc":
+	case "desc":
+		req.Desc = true
+	default:
+		router.RenderError(w, router.NewHttpError("invalid order, expected asc or desc", 400))
+		return
+	}
+	if limit := query.Get(ParamLimit); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			router.RenderError(w, router.NewHttpError("invalid limit", 400))
+			return
+		}
+		req.Limit = n
+	}
+	if bbox := query.Get(ParamBoundingBox); bbox != "" {
+		box, err := parseBoundingBox(bbox)
+		if err != nil {
+			router.RenderError(w, router.NewHttpError("invalid bbox, expected min_longitude,min_latitude,max_longitude,max_latitude", 400))
+			return
+		}
+		req.BoundingBox = box
+	}
+
+	response, err := c.geolocationSrv.ListGeolocations(r.Context(), req)
+	if err != nil {
+		router.RenderError(w, err)
+		return
+	}
+
+	router.Render(router.Response{
+		Writer: w,
+		Data:   response,
+		Status: 200,
+	})
+}
+
+// parseBoundingBox parses a bbox in the usual order of longitude before latitude
+func parseBoundingBox(value string) (*model.BoundingBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, errors.New("expected 4 coordinates")
+	}
+	coords := make([]float64, 4)
+	for i, part := range parts {
+		var err error
+		coords[i], err = strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &model.BoundingBox{
+		MinLongitude: coords[0],
+		MinLatitude:  coords[1],
+		MaxLongitude: coords[2],
+		MaxLatitude:  coords[3],
+	}, nil
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+)
+
+const (
+	ParamGraphQLQuery         = "query"
+	ParamGraphQLVariables     = "variables"
+	ParamGraphQLOperationName = "operationName"
+
+	// maxGraphQLBodyBytes bounds the body of a GraphQL request
+	maxGraphQLBodyBytes = 1 << 20
+)
+
+// graphQLRequest is the body of a POST, or the query params of a GET
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// GraphQL runs a query over the lookups, the geolocations and the country stats. Like the usual GraphQL servers, it
+// answers 200 with the errors in the body once the request could be read, errors of the query included.
+func (c *clientController) GraphQL(w http.ResponseWriter, r *http.Request) {
+	req := new(graphQLRequest)
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Query = query.Get(ParamGraphQLQuery)
+		req.OperationName = query.Get(ParamGraphQLOperationName)
+		if variables := query.Get(ParamGraphQLVariables); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				router.RenderError(w, router.NewHttpError("invalid variables, expected a JSON object", 400))
+				return
+			}
+		}
+	} else if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLBodyBytes)).Decode(req); err != nil {
+		router.RenderError(w, router.NewHttpError("invalid body, expected {\"query\": \"...\", \"variables\": {...}}", 400))
+		return
+	}
+	if len(req.Query) == 0 {
+		router.RenderError(w, router.NewHttpError("query could not be found", 400))
+		return
+	}
+
+	router.RenderJSON(router.Response{
+		Writer: w,
+		Data:   c.executeGraphQL(r.Context(), req),
+		Status: 200,
+	})
+}
+
+// executeGraphQL validates the query and checks it against the limits before running it
+func (c *clientController) executeGraphQL(ctx context.Context, req *graphQLRequest) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if validation := graphql.ValidateDocument(&c.graphqlSchema, doc, graphql.SpecifiedRules); !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+	if err := checkGraphQLLimits(doc, req.OperationName, req.Variables); err != nil {
+		// wrapped for its extensions, which only the errors of the resolvers get otherwise
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(gqlerrors.NewError(err.Error(), nil, "", nil, nil, err))}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        c.graphqlSchema,
+		Root:          &graphQLRoot{},
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+}
+
+// graphQLError is an error of a resolver, with the status the REST endpoints would answer it with in its extensions
+type graphQLError struct {
+	message string
+	status  int
+}
+
+func (e *graphQLError) Error() string {
+	return e.message
+}
+
+func (e *graphQLError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"status": e.status}
+}
+
+// graphQLErrorOf returns err as a graphQLError, nil for nil. Like RenderError, it hides the errors other than
+// HttpError behind a 500.
+func graphQLErrorOf(err error) error {
+	if err == nil {
+		return nil
+	}
+	var httpError *router.HttpError
+	if errors.As(err, &httpError) {
+		return &graphQLError{message: httpError.Message, status: httpError.Status}
+	}
+	return &graphQLError{message: "Internal Server Error", status: 500}
+}
@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	"github.com/ohmpatel1997/findhotel/internal/service"
+)
+
+const (
+	// MaxGraphQLDepth is the deepest nesting of fields a query may select, introspection included. The deepest the
+	// schema has is 5, and the introspection query of the GraphQL tools nests 13, while the introspection types are
+	// cyclic, __Type.fields.type being a __Type again.
+	MaxGraphQLDepth = 13
+
+	// MaxGraphQLComplexity is the most fields a query may resolve, as estimated before running it. A full page of
+	// geolocations fits, but not with the country stats of each.
+	MaxGraphQLComplexity = 25000
+
+	// countriesEstimate is the number of countries the complexity of the countries field is estimated with
+	countriesEstimate = 250
+)
+
+// checkGraphQLLimits rejects the operation of doc that would run deeper or resolve more fields than the limits
+func checkGraphQLLimits(doc *ast.Document, operationName string, variables map[string]interface{}) error {
+	q := &queryCost{
+		fragments:  make(map[string]*ast.FragmentDefinition),
+		variables:  variables,
+		depths:     make(map[string]int),
+		complexity: make(map[string]int),
+	}
+	var op *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch d := def.(type) {
+		case *ast.OperationDefinition:
+			if op == nil && (operationName == "" || d.Name != nil && d.Name.Value == operationName) {
+				op = d
+			}
+		case *ast.FragmentDefinition:
+			q.fragments[d.Name.Value] = d
+		}
+	}
+	if op == nil {
+		return nil // left to the execution to report
+	}
+
+	if depth := q.depthOf(op.SelectionSet); depth > MaxGraphQLDepth {
+		return &graphQLError{
+			message: fmt.Sprintf("query depth %d exceeds the limit of %d", depth, MaxGraphQLDepth),
+			status:  400,
+		}
+	}
+	if complexity := q.complexityOf(op.SelectionSet, true); complexity > MaxGraphQLComplexity {
+		return &graphQLError{
+			message: fmt.Sprintf("query complexity %d exceeds the limit of %d", complexity, MaxGraphQLComplexity),
+			status:  400,
+		}
+	}
+	return nil
+}
+
+// queryCost measures a validated query, so its fragments are known and don't spread into themselves. The depth and
+// complexity of the fragments are memoized, a fragment spread many times is measured once.
+type queryCost struct {
+	fragments  map[string]*ast.FragmentDefinition
+	variables  map[string]interface{}
+	depths     map[string]int
+	complexity map[string]int
+}
+
+// depthOf returns the deepest nesting of fields in set, fragments count as their fields
+func (q *queryCost) depthOf(set *ast.SelectionSet) int {
+	if set == nil {
+		return 0
+	}
+	max := 0
+	for _, selection := range set.Selections {
+		depth := 0
+		switch s := selection.(type) {
+		case *ast.Field:
+			depth = 1 + q.depthOf(s.SelectionSet)
+		case *ast.InlineFragment:
+			depth = q.depthOf(s.SelectionSet)
+		case *ast.FragmentSpread:
+			name := s.Name.Value
+			d, ok := q.depths[name]
+			if !ok {
+				if fragment := q.fragments[name]; fragment != nil {
+					d = q.depthOf(fragment.SelectionSet)
+				}
+				q.depths[name] = d
+			}
+			depth = d
+		}
+		if depth > max {
+			max = depth
+		}
+	}
+	return max
+}
+
+// complexityOf returns the number of fields set resolves at most. Every field costs one, the introspection ones
+// included, and the fields of the items of the root lists count once per item they may return.
+func (q *queryCost) complexityOf(set *ast.SelectionSet, root bool) int {
+	if set == nil {
+		return 0
+	}
+	total := 0
+	for _, selection := range set.Selections {
+		switch s := selection.(type) {
+		case *ast.Field:
+			items := 1
+			if root {
+				items = q.itemsOf(s)
+			}
+			total += 1 + items*q.complexityOf(s.SelectionSet, false)
+		case *ast.InlineFragment:
+			total += q.complexityOf(s.SelectionSet, root)
+		case *ast.FragmentSpread:
+			name := s.Name.Value
+			if root {
+				// the items of the root fields depend on their arguments, which the memo doesn't keep
+				if fragment := q.fragments[name]; fragment != nil {
+					total += q.complexityOf(fragment.SelectionSet, true)
+				}
+				continue
+			}
+			c, ok := q.complexity[name]
+			if !ok {
+				if fragment := q.fragments[name]; fragment != nil {
+					c = q.complexityOf(fragment.SelectionSet, false)
+				}
+				q.complexity[name] = c
+			}
+			total += c
+		}
+		if total > MaxGraphQLComplexity {
+			return total // over the limit already, which keeps the product of the items of the root lists small
+		}
+	}
+	return total
+}
+
+// itemsOf returns the number of items a root field may return
+func (q *queryCost) itemsOf(field *ast.Field) int {
+	switch field.Name.Value {
+	case gqlFieldGeolocations:
+		limit := model.DefaultListLimit
+		if n, ok := q.intArgument(field, "limit"); ok && n > 0 {
+			limit = n
+		}
+		if limit > model.MaxListLimit {
+			limit = model.MaxListLimit
+		}
+		return limit
+	case gqlFieldIPInfoBatch:
+		if n, ok := q.listArgumentLen(field, "ips"); ok && n <= service.MaxBatchSize {
+			return n
+		}
+		return service.MaxBatchSize
+	case gqlFieldCountries:
+		return countriesEstimate
+	}
+	return 1
+}
+
+func (q *queryCost) argument(field *ast.Field, name string) (interface{}, bool) {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != name {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.Variable:
+			value, ok := q.variables[v.Name.Value]
+			return value, ok
+		case *ast.IntValue:
+			n, err := strconv.Atoi(v.Value)
+			return n, err == nil
+		case *ast.ListValue:
+			return v.Values, true
+		}
+		return nil, false
+	}
+	return nil, false
+}
+
+func (q *queryCost) intArgument(field *ast.Field, name string) (int, bool) {
+	value, _ := q.argument(field, name)
+	switch n := value.(type) {
+	case int:
+		return n, true
+	case float64: // from the JSON of the variables
+		return int(n), true
+	}
+	return 0, false
+}
+
+func (q *queryCost) listArgumentLen(field *ast.Field, name string) (int, bool) {
+	value, _ := q.argument(field, name)
+	switch list := value.(type) {
+	case []ast.Value:
+		return len(list), true
+	case []interface{}:
+		return len(list), true
+	case string: // a single value of a variable is coerced to a list of it
+		return 1, true
+	}
+	return 0, false
+}
@@ -0,0 +1,314 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	"github.com/ohmpatel1997/findhotel/internal/service"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+)
+
+// The fields are named like the JSON fields of the REST responses, so that the default resolver reads them from the
+// same service types
+const (
+	gqlFieldIPInfo       = "ip_info"
+	gqlFieldIPInfoBatch  = "ip_info_batch"
+	gqlFieldGeolocations = "geolocations"
+	gqlFieldCountries    = "countries"
+	gqlFieldCountry      = "country"
+)
+
+// graphQLRoot is the root value of a request. It loads the country stats once for all the geolocations of the
+// request asking for theirs.
+type graphQLRoot struct {
+	once      sync.Once
+	countries map[string]*service.CountryStatsItem
+	err       error
+}
+
+func (root *graphQLRoot) country(ctx context.Context, stats service.StatsService, code string) (*service.CountryStatsItem, error) {
+	root.once.Do(func() {
+		resp, err := stats.CountryStats(ctx)
+		if err != nil {
+			root.err = err
+			return
+		}
+		root.countries = make(map[string]*service.CountryStatsItem, len(resp.Countries))
+		for _, c := range resp.Countries {
+			root.countries[c.CountryCode] = c
+		}
+	})
+	if root.err != nil {
+		return nil, root.err
+	}
+	return root.countries[strings.ToUpper(strings.TrimSpace(code))], nil
+}
+
+func (c *clientController) newGraphQLSchema() (graphql.Schema, error) {
+	centroidType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Centroid",
+		Fields: graphql.Fields{
+			"latitude":  &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"longitude": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		},
+	})
+
+	boundingBoxType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "BoundingBox",
+		Fields: graphql.Fields{
+			"min_latitude":  &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"min_longitude": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"max_latitude":  &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"max_longitude": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		},
+	})
+
+	countryStatsType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "CountryStats",
+		Fields: graphql.Fields{
+			"country_id":   &graphql.Field{Type: graphql.Int},
+			"country_code": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"country":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"ips":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Description: "geolocations in the country, a network counting once"},
+			"cities":       &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Description: "distinct cities"},
+			"centroid":     &graphql.Field{Type: graphql.NewNonNull(centroidType)},
+			"bbox":         &graphql.Field{Type: graphql.NewNonNull(boundingBoxType)},
+		},
+	})
+
+	geolocationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Geolocation",
+		Fields: graphql.Fields{
+			"ip_address": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"network": &graphql.Field{
+				Type:        graphql.String,
+				Description: "the network or range the ip was found in, unless the row is for the ip alone",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return nullIfEmpty(p.Source.(*service.GeoLocationResponse).Network), nil
+				},
+			},
+			"country":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"country_code":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"country_id":    &graphql.Field{Type: graphql.Int},
+			"city":          &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"city_id":       &graphql.Field{Type: graphql.Int},
+			"latitude":      &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"longitude":     &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"mystery_value": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"valid_from":    &graphql.Field{Type: graphql.DateTime},
+			"valid_to":      &graphql.Field{Type: graphql.DateTime},
+			"country_stats": &graphql.Field{
+				Type:        countryStatsType,
+				Description: "the stats of the country of the geolocation, null if it has none",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					g := p.Source.(*service.GeoLocationResponse)
+					if g.CountryCode == "" {
+						return nil, nil
+					}
+					country, err := p.Info.RootValue.(*graphQLRoot).country(p.Context, c.statsSrv, g.CountryCode)
+					if err != nil || country == nil {
+						return nil, graphQLErrorOf(err)
+					}
+					return country, nil
+				},
+			},
+		},
+	})
+
+	errorType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Error",
+		Fields: graphql.Fields{
+			"message": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"status":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	lookupResultType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "LookupResult",
+		Description: "the geolocation of one ip of a batch, or why it couldn't be looked up",
+		Fields: graphql.Fields{
+			"ip_address":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"geolocation": &graphql.Field{Type: geolocationType},
+			"error":       &graphql.Field{Type: errorType},
+		},
+	})
+
+	geolocationPageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "GeolocationPage",
+		Fields: graphql.Fields{
+			"geolocations": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(geolocationType)))},
+			"next_cursor": &graphql.Field{
+				Type:        graphql.String,
+				Description: "the cursor of the next page, null on the last one",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return nullIfEmpty(p.Source.(*service.ListResponse).NextCursor), nil
+				},
+			},
+		},
+	})
+
+	sortType := graphql.NewEnum(graphql.EnumConfig{
+		Name: "Sort",
+		Values: graphql.EnumValueConfigMap{
+			"IP":        &graphql.EnumValueConfig{Value: model.SortIP},
+			"COUNTRY":   &graphql.EnumValueConfig{Value: model.SortCountry},
+			"CITY":      &graphql.EnumValueConfig{Value: model.SortCity},
+			"LATITUDE":  &graphql.EnumValueConfig{Value: model.SortLatitude},
+			"LONGITUDE": &graphql.EnumValueConfig{Value: model.SortLongitude},
+		},
+	})
+
+	orderType := graphql.NewEnum(graphql.EnumConfig{
+		Name: "Order",
+		Values: graphql.EnumValueConfigMap{
+			"ASC":  &graphql.EnumValueConfig{Value: "asc"},
+			"DESC": &graphql.EnumValueConfig{Value: "desc"},
+		},
+	})
+
+	boundingBoxInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "BoundingBoxInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"min_longitude": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Float)},
+			"min_latitude":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Float)},
+			"max_longitude": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Float)},
+			"max_latitude":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Float)},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			gqlFieldIPInfo: &graphql.Field{
+				Type:        geolocationType,
+				Description: "looks an ip up, as it resolved at as_of if set",
+				Args: graphql.FieldConfigArgument{
+					"ip":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"as_of": &graphql.ArgumentConfig{Type: graphql.String, Description: "an RFC 3339 time or a date"},
+				},
+				Resolve: c.resolveIPInfo,
+			},
+			gqlFieldIPInfoBatch: &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(lookupResultType))),
+				Description: "looks the ips up, the results are in the order of the ips",
+				Args: graphql.FieldConfigArgument{
+					"ips": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+				},
+				Resolve: c.resolveIPInfoBatch,
+			},
+			gqlFieldGeolocations: &graphql.Field{
+				Type:        graphql.NewNonNull(geolocationPageType),
+				Description: "lists a page of the geolocations, the arguments not set don't filter",
+				Args: graphql.FieldConfigArgument{
+					"country_code": &graphql.ArgumentConfig{Type: graphql.String},
+					"country":      &graphql.ArgumentConfig{Type: graphql.String},
+					"city":         &graphql.ArgumentConfig{Type: graphql.String},
+					"bbox":         &graphql.ArgumentConfig{Type: boundingBoxInput},
+					"sort":         &graphql.ArgumentConfig{Type: sortType},
+					"order":        &graphql.ArgumentConfig{Type: orderType},
+					"limit":        &graphql.ArgumentConfig{Type: graphql.Int},
+					"cursor":       &graphql.ArgumentConfig{Type: graphql.String, Description: "next_cursor of the previous page"},
+				},
+				Resolve: c.resolveGeolocations,
+			},
+			gqlFieldCountries: &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(countryStatsType))),
+				Description: "the stats of the countries, the ones with the most ips first",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					resp, err := c.statsSrv.CountryStats(p.Context)
+					if err != nil {
+						return nil, graphQLErrorOf(err)
+					}
+					return resp.Countries, nil
+				},
+			},
+			gqlFieldCountry: &graphql.Field{
+				Type:        countryStatsType,
+				Description: "the stats of a country by its code, null if it has no ips",
+				Args: graphql.FieldConfigArgument{
+					"code": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					country, err := p.Info.RootValue.(*graphQLRoot).country(p.Context, c.statsSrv, p.Args["code"].(string))
+					if err != nil || country == nil {
+						return nil, graphQLErrorOf(err)
+					}
+					return country, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+func (c *clientController) resolveIPInfo(p graphql.ResolveParams) (interface{}, error) {
+	req := &service.GetRequest{IP: p.Args["ip"].(string)}
+	if asOf, ok := p.Args["as_of"].(string); ok {
+		var err error
+		req.AsOf, err = parseAsOf(asOf)
+		if err != nil {
+			return nil, graphQLErrorOf(router.NewHttpError("invalid as_of, expected an RFC 3339 time or a date", 400))
+		}
+	}
+
+	response, err := c.geolocationSrv.GetIPData(p.Context, req)
+	if err != nil {
+		return nil, graphQLErrorOf(err)
+	}
+	return response, nil
+}
+
+func (c *clientController) resolveIPInfoBatch(p graphql.ResolveParams) (interface{}, error) {
+	req := &service.BatchRequest{}
+	for _, ip := range p.Args["ips"].([]interface{}) {
+		req.IPs = append(req.IPs, ip.(string))
+	}
+
+	response, err := c.geolocationSrv.GetIPDataBatch(p.Context, req)
+	if err != nil {
+		return nil, graphQLErrorOf(err)
+	}
+	return response.Results, nil
+}
+
+func (c *clientController) resolveGeolocations(p graphql.ResolveParams) (interface{}, error) {
+	req := &service.ListRequest{}
+	req.CountryCode, _ = p.Args["country_code"].(string)
+	req.Country, _ = p.Args["country"].(string)
+	req.City, _ = p.Args["city"].(string)
+	req.Sort, _ = p.Args["sort"].(string)
+	req.Cursor, _ = p.Args["cursor"].(string)
+	req.Desc = p.Args["order"] == "desc"
+	if limit, ok := p.Args["limit"].(int); ok {
+		if limit <= 0 {
+			return nil, graphQLErrorOf(router.NewHttpError("invalid limit", 400))
+		}
+		req.Limit = limit
+	}
+	if bbox, ok := p.Args["bbox"].(map[string]interface{}); ok {
+		req.BoundingBox = &model.BoundingBox{
+			MinLongitude: bbox["min_longitude"].(float64),
+			MinLatitude:  bbox["min_latitude"].(float64),
+			MaxLongitude: bbox["max_longitude"].(float64),
+			MaxLatitude:  bbox["max_latitude"].(float64),
+		}
+	}
+
+	response, err := c.geolocationSrv.ListGeolocations(p.Context, req)
+	if err != nil {
+		return nil, graphQLErrorOf(err)
+	}
+	return response, nil
+}
+
+// nullIfEmpty resolves the fields the JSON responses omit when empty as null
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,216 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	modelMocks "github.com/ohmpatel1997/findhotel/internal/model/mocks"
+	"github.com/ohmpatel1997/findhotel/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var testRows = []*model.Geolocation{
+	{IP: "10.1.0.0/16", StartIP: "10.1.0.0", EndIP: "10.1.255.255", CountryCode: "IN", Country: "India", City: "Pune", Latitude: 18.5, Longitude: 73.8},
+	{IP: "10.1.2.3", StartIP: "10.1.2.3", EndIP: "10.1.2.3", CountryCode: "IN", Country: "India", City: "Surat", Latitude: 21.2, Longitude: 72.8, MysteryValue: "7"},
+	{IP: "20.0.0.1", StartIP: "20.0.0.1", EndIP: "20.0.0.1", CountryCode: "DE", Country: "Germany", City: "Berlin", Latitude: 52.5, Longitude: 13.4},
+}
+
+// newTestController serves the rows, and the stats of India alone
+func newTestController(t *testing.T) ClientController {
+	manager, err := model.NewMemoryGeoLocationManager(context.TODO(), func(ctx context.Context, fn func(*model.Geolocation) error) error {
+		for _, g := range testRows {
+			if err := fn(g); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error loading the index %v", err)
+	}
+
+	stats := new(modelMocks.StatsManager)
+	stats.On("CountryStats", mock.Anything).Return([]*model.CountryStats{
+		{
+			CountryID:   1,
+			CountryCode: "IN",
+			Country:     "India",
+			IPs:         2,
+			Cities:      2,
+			Extent:      model.Extent{Latitude: 19.85, Longitude: 73.3, MinLatitude: 18.5, MinLongitude: 72.8, MaxLatitude: 21.2, MaxLongitude: 73.8},
+		},
+	}, nil)
+
+	return NewController(service.NewGeolocationService(manager), nil, service.NewStatsService(stats))
+}
+
+func TestGraphQL(t *testing.T) {
+	cases := []struct {
+		Name           string
+		Method         string
+		Body           string
+		Query          url.Values
+		ExpectedStatus int
+		ExpectedBody   string
+	}{
+		{
+			Name:           "lookup with country stats",
+			Method:         http.MethodPost,
+			Body:           `{"query": "{ ip_info(ip: \"10.1.2.3\") { ip_address city network country_stats { ips centroid { latitude } } } }"}`,
+			ExpectedStatus: 200,
+			ExpectedBody:   `{"data":{"ip_info":{"city":"Surat","country_stats":{"centroid":{"latitude":19.85},"ips":2},"ip_address":"10.1.2.3","network":null}}}`,
+		},
+		{
+			Name:           "variables and a country without stats",
+			Method:         http.MethodPost,
+			Body:           `{"query": "query Lookup($ip: String!) { ip_info(ip: $ip) { network city country_stats { ips } } }", "variables": {"ip": "20.0.0.1"}, "operationName": "Lookup"}`,
+			ExpectedStatus: 200,
+			ExpectedBody:   `{"data":{"ip_info":{"city":"Berlin","country_stats":null,"network":null}}}`,
+		},
+		{
+			Name:           "get",
+			Method:         http.MethodGet,
+			Query:          url.Values{"query": {"query($ip: String!) { ip_info(ip: $ip) { city network } }"}, "variables": {`{"ip": "10.1.9.9"}`}},
+			ExpectedStatus: 200,
+			ExpectedBody:   `{"data":{"ip_info":{"city":"Pune","network":"10.1.0.0/16"}}}`,
+		},
+		{
+			Name:           "batch",
+			Method:         http.MethodPost,
+			Body:           `{"query": "{ ip_info_batch(ips: [\"10.1.2.3\", \"ip1\"]) { ip_address geolocation { city } error { message status } } }"}`,
+			ExpectedStatus: 200,
+			ExpectedBody:   `{"data":{"ip_info_batch":[{"error":null,"geolocation":{"city":"Surat"},"ip_address":"10.1.2.3"},{"error":{"message":"invalid ip","status":400},"geolocation":null,"ip_address":"ip1"}]}}`,
+		},
+		{
+			Name:           "list",
+			Method:         http.MethodPost,
+			Body:           `{"query": "{ geolocations(country_code: \"IN\", sort: CITY, order: DESC, limit: 1) { geolocations { city } next_cursor } }"}`,
+			ExpectedStatus: 200,
+			ExpectedBody:   `{"data":{"geolocations":{"geolocations":[{"city":"Surat"}],"next_cursor":`,
+		},
+		{
+			Name:           "countries",
+			Method:         http.MethodPost,
+			Body:           `{"query": "{ countries { country_code bbox { max_latitude } } country(code: \"in\") { country } }"}`,
+			ExpectedStatus: 200,
+			ExpectedBody:   `{"data":{"countries":[{"bbox":{"max_latitude":21.2},"country_code":"IN"}],"country":{"country":"India"}}}`,
+		},
+		{
+			Name:           "not found with the status in the extensions",
+			Method:         http.MethodPost,
+			Body:           `{"query": "{ ip_info(ip: \"11.0.0.1\") { city } }"}`,
+			ExpectedStatus: 200,
+			ExpectedBody:   `{"data":{"ip_info":null},"errors":[{"message":"data not found with given ip","locations":[{"line":1,"column":3}],"path":["ip_info"],"extensions":{"status":404}}]}`,
+		},
+		{
+			Name:           "invalid query",
+			Method:         http.MethodPost,
+			Body:           `{"query": "{ ip_info(ip: \"10.1.2.3\") { town } }"}`,
+			ExpectedStatus: 200,
+			ExpectedBody:   `{"data":null,"errors":[{"message":"Cannot query field \"town\" on type \"Geolocation\".","locations":[{"line":1,"column":29}]}]}`,
+		},
+		{
+			Name:           "too complex",
+			Method:         http.MethodPost,
+			Body:           `{"query": "query($limit: Int) { a: geolocations(limit: 1000) { ...page } b: geolocations(limit: $limit) { ...page } c: geolocations(limit: 1000) { ...page } } fragment page on GeolocationPage { geolocations { city country_stats { country ips cities centroid { latitude longitude } } } }", "variables": {"limit": 5000}}`,
+			ExpectedStatus: 200,
+			ExpectedBody:   `{"data":null,"errors":[{"message":"query complexity 27003 exceeds the limit of 25000","locations":[],"extensions":{"status":400}}]}`,
+		},
+		{
+			Name:           "invalid body",
+			Method:         http.MethodPost,
+			Body:           `query { countries }`,
+			ExpectedStatus: 400,
+			ExpectedBody:   `{"message":"invalid body, expected {\"query\": \"...\", \"variables\": {...}}","status":400}`,
+		},
+		{
+			Name:           "no query",
+			Method:         http.MethodGet,
+			ExpectedStatus: 400,
+			ExpectedBody:   `{"message":"query could not be found","status":400}`,
+		},
+	}
+
+	cntrl := newTestController(t)
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			req := httptest.NewRequest(tt.Method, "/v1/graphql?"+tt.Query.Encode(), strings.NewReader(tt.Body))
+			w := httptest.NewRecorder()
+			cntrl.GraphQL(w, req)
+			assert.Equal(tt.ExpectedStatus, w.Code)
+			assert.True(strings.HasPrefix(w.Body.String(), tt.ExpectedBody), "got %s", w.Body.String())
+		})
+	}
+}
+
+func TestCheckGraphQLLimits(t *testing.T) {
+	cases := []struct {
+		Name          string
+		Query         string
+		Variables     map[string]interface{}
+		ExpectedError string
+	}{
+		{
+			Name:  "within the limits",
+			Query: "{ geolocations(limit: 1000) { geolocations { ip_address city country_stats { ips } } } }",
+		},
+		{
+			Name:  "deepest fields of the schema",
+			Query: "{ ip_info_batch(ips: [\"1.2.3.4\"]) { geolocation { country_stats { centroid { latitude } } } } }",
+		},
+		{
+			Name:          "too deep",
+			Query:         "{ a { b { c { d { e { f { g { h { i { j { k { l { m { n } } } } } } } } } } } } } }",
+			ExpectedError: "query depth 14 exceeds the limit of 13",
+		},
+		{
+			Name:          "too deep through fragments",
+			Query:         "{ a { ...b } } fragment b on B { b { ...c } } fragment c on C { c { d { e { f { g { h { i { j { k { l { m { n } } } } } } } } } } } }",
+			ExpectedError: "query depth 14 exceeds the limit of 13",
+		},
+		{
+			Name:  "introspection query of the tools",
+			Query: testutil.IntrospectionQuery,
+		},
+		{
+			Name:          "introspection too deep",
+			Query:         "{ __schema { types { fields { type { fields { type { fields { type { fields { type { fields { type { fields { name } } } } } } } } } } } } } }",
+			ExpectedError: "query depth 14 exceeds the limit of 13",
+		},
+		{
+			Name:          "batch of a variable",
+			Query:         "query($ips: [String!]!) { ip_info_batch(ips: $ips) { geolocation { ip_address network country country_code country_id city city_id latitude longitude mystery_value valid_from valid_to country_stats { ips cities country country_code centroid { latitude longitude } bbox { min_latitude min_longitude max_latitude max_longitude } } } } }",
+			Variables:     map[string]interface{}{"ips": make([]interface{}, 1000)},
+			ExpectedError: "query complexity 26001 exceeds the limit of 25000",
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			doc, err := parser.Parse(parser.ParseParams{Source: tt.Query})
+			if err != nil {
+				t.Fatalf("Error parsing the query %v", err)
+			}
+			err = checkGraphQLLimits(doc, "", tt.Variables)
+			if tt.ExpectedError == "" {
+				assert.Nil(err)
+			} else if assert.NotNil(err) {
+				assert.Equal(tt.ExpectedError, err.Error())
+			}
+		})
+	}
+}
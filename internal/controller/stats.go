@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/ohmpatel1997/findhotel/internal/service"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+)
+
+const (
+	URLParamCountryCode = "code"
+)
+
+func (c *clientController) GetCountryStats(w http.ResponseWriter, r *http.Request) {
+	response, err := c.statsSrv.CountryStats(r.Context())
+	if err != nil {
+		router.RenderError(w, err)
+		return
+	}
+
+	router.Render(router.Response{
+		Writer: w,
+		Data:   response,
+		Status: 200,
+	})
+}
+
+func (c *clientController) GetCityStats(w http.ResponseWriter, r *http.Request) {
+	req := &service.CityStatsRequest{CountryCode: router.URLParam(r, URLParamCountryCode)}
+
+	response, err := c.statsSrv.CityStats(r.Context(), req)
+	if err != nil {
+		router.RenderError(w, err)
+		return
+	}
+
+	router.Render(router.Response{
+		Writer: w,
+		Data:   response,
+		Status: 200,
+	})
+}
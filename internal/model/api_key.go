@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey is a key the api authenticates requests with. The key itself isn't stored, only its hash.
+type APIKey struct {
+	tableName struct{} `pg:"api_keys"`
+
+	ID        uuid.UUID `pg:"id,pk,type:uuid,default:uuid_generate_v4()"`
+	Name      string    `pg:"name"`
+	Hash      string    `pg:"hash"`       // hex sha256 of the key
+	Prefix    string    `pg:"prefix"`     // the start of the key, to tell the keys apart
+	RateLimit int       `pg:"rate_limit"` // requests per minute
+	Burst     int       `pg:"burst"`      // requests allowed at once, over the rate
+	CreatedAt time.Time `pg:"created_at,default:now()"`
+	RevokedAt time.Time `pg:"revoked_at"` // zero while the key is valid
+}
+
+// Revoked reports whether the key no longer authenticates requests
+func (k *APIKey) Revoked() bool {
+	return !k.RevokedAt.IsZero()
+}
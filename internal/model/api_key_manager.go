@@ -0,0 +1,75 @@
+package model
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+)
+
+//go:generate mockery --name APIKeyManager --output=mocks
+type APIKeyManager interface {
+	Create(ctx context.Context, key *APIKey) error
+	// FindByHash returns the key with the hash, revoked or not
+	FindByHash(ctx context.Context, hash string) (*APIKey, error)
+	// Revoke marks the key revoked and returns it, a key revoked already keeps the time it was first revoked at
+	Revoke(ctx context.Context, id uuid.UUID) (*APIKey, error)
+	// List returns the keys, the newest first
+	List(ctx context.Context) ([]*APIKey, error)
+}
+
+type apiKeyManager struct {
+	db *pg.DB
+}
+
+func NewAPIKeyManager(db *pg.DB) APIKeyManager {
+	return &apiKeyManager{
+		db: db,
+	}
+}
+
+func (m *apiKeyManager) Create(ctx context.Context, key *APIKey) error {
+	_, err := m.db.ModelContext(ctx, key).Returning("*").Insert()
+	return err
+}
+
+func (m *apiKeyManager) FindByHash(ctx context.Context, hash string) (*APIKey, error) {
+	key := new(APIKey)
+
+	err := m.db.ModelContext(ctx, key).Where("hash = ?", hash).Select()
+	switch {
+	case errors.Is(err, pg.ErrNoRows):
+		return nil, ErrAPIKeyNotFound
+	case err != nil:
+		return nil, err
+	}
+	return key, nil
+}
+
+func (m *apiKeyManager) Revoke(ctx context.Context, id uuid.UUID) (*APIKey, error) {
+	key := &APIKey{ID: id}
+
+	_, err := m.db.QueryOneContext(ctx, key, "UPDATE api_keys SET revoked_at = coalesce(revoked_at, now()) WHERE id = ? RETURNING *", id)
+	switch {
+	case errors.Is(err, pg.ErrNoRows):
+		return nil, ErrAPIKeyNotFound
+	case err != nil:
+		return nil, err
+	}
+	return key, nil
+}
+
+func (m *apiKeyManager) List(ctx context.Context) ([]*APIKey, error) {
+	var keys []*APIKey
+
+	err := m.db.ModelContext(ctx, &keys).Order("created_at DESC").Select()
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
@@ -0,0 +1,333 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/ohmpatel1997/findhotel/internal/common"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	DefaultCitySearchLimit = 10
+	MaxCitySearchLimit     = 50
+
+	// minCitySimilarity is the share of trigrams a name must have in common with the query, like pg_trgm's default
+	minCitySimilarity = 0.3
+)
+
+// CityMatch is a city of the dataset with the number of geolocations in it, and how well it matched a search
+type CityMatch struct {
+	CityID      int64   `pg:"city_id"`
+	City        string  `pg:"city"`
+	CountryID   int64   `pg:"country_id"`
+	CountryCode string  `pg:"country_code"`
+	Country     string  `pg:"country"`
+	IPs         int64   `pg:"ips"` // networks and ranges count once
+	Score       float64 `pg:"-"`   // 1 for a name equal to the query once folded
+}
+
+type CitySearch struct {
+	Query       string
+	CountryCode string // only search the cities of this country when set
+	Limit       int    // DefaultCitySearchLimit when zero
+}
+
+// CitySource calls fn for every city of the dataset that has geolocations
+type CitySource func(ctx context.Context, fn func(*CityMatch) error) error
+
+// CitySearchManager matches free text against the city names of the dataset. Accents, case and punctuation are
+// ignored and typos are tolerated, so "sao paulo" finds São Paulo and "munchen" finds München.
+//
+//go:generate mockery --name CitySearchManager --output=mocks
+type CitySearchManager interface {
+	// SearchCities returns the best matches first, and of equally good ones those with the most geolocations
+	SearchCities(ctx context.Context, search *CitySearch) ([]*CityMatch, error)
+	// Reload loads the cities again, searches keep using the previous ones meanwhile
+	Reload(ctx context.Context) error
+	// Loaded returns the number of cities searched and when they were loaded
+	Loaded() (int, time.Time)
+}
+
+type citySearchManager struct {
+	src CitySource
+
+	mu       sync.RWMutex
+	index    *cityIndex
+	loadedAt time.Time
+}
+
+// NewCitySearchManager loads the cities of src into memory and returns a manager searching them
+func NewCitySearchManager(ctx context.Context, src CitySource) (CitySearchManager, error) {
+	m := &citySearchManager{src: src}
+	if err := m.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *citySearchManager) Reload(ctx context.Context) error {
+	index := &cityIndex{byTrigram: make(map[string][]int)}
+	err := m.src(ctx, func(c *CityMatch) error {
+		index.add(c)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.index = index
+	m.loadedAt = time.Now()
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *citySearchManager) Loaded() (int, time.Time) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.index.cities), m.loadedAt
+}
+
+func (m *citySearchManager) SearchCities(ctx context.Context, search *CitySearch) ([]*CityMatch, error) {
+	limit := search.Limit
+	if limit <= 0 {
+		limit = DefaultCitySearchLimit
+	}
+	if limit > MaxCitySearchLimit {
+		limit = MaxCitySearchLimit
+	}
+
+	m.mu.RLock()
+	index := m.index
+	m.mu.RUnlock()
+
+	return index.search(newSearchTerm(search.Query), countryKey(search.CountryCode), limit), nil
+}
+
+// searchTerm is a name folded for comparison, with its trigrams
+type searchTerm struct {
+	folded   string
+	trigrams map[string]bool
+}
+
+func newSearchTerm(name string) searchTerm {
+	folded := foldName(name)
+	return searchTerm{folded: folded, trigrams: trigrams(folded)}
+}
+
+// similarity is the share of the trigrams of both terms they have in common
+func (t searchTerm) similarity(other searchTerm) float64 {
+	if len(t.trigrams) == 0 || len(other.trigrams) == 0 {
+		return 0
+	}
+	common := 0
+	for tri := range t.trigrams {
+		if other.trigrams[tri] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(t.trigrams)+len(other.trigrams)-common)
+}
+
+// score rates how well name matches the query t. Typing the start of a name ranks it like a close match, since
+// the searches come from a search box.
+func (t searchTerm) score(name searchTerm) float64 {
+	if t.folded == name.folded {
+		return 1
+	}
+	score := t.similarity(name)
+	if len(t.folded) >= 3 && strings.HasPrefix(name.folded, t.folded) {
+		prefix := 0.5 + 0.4*float64(len(t.folded))/float64(len(name.folded))
+		if prefix > score {
+			score = prefix
+		}
+	}
+	return score
+}
+
+type cityIndex struct {
+	cities    []*CityMatch
+	terms     []searchTerm
+	byTrigram map[string][]int // positions in cities
+}
+
+func (x *cityIndex) add(c *CityMatch) {
+	term := newSearchTerm(c.City)
+	if term.folded == "" {
+		return
+	}
+	city := *c
+	city.CountryCode = countryKey(city.CountryCode)
+	city.Score = 0
+	for tri := range term.trigrams {
+		x.byTrigram[tri] = append(x.byTrigram[tri], len(x.cities))
+	}
+	x.cities = append(x.cities, &city)
+	x.terms = append(x.terms, term)
+}
+
+func (x *cityIndex) search(query searchTerm, countryCode string, limit int) []*CityMatch {
+	// only the names sharing a trigram with the query can reach the minimum similarity
+	candidates := make(map[int]bool)
+	for tri := range query.trigrams {
+		for _, i := range x.byTrigram[tri] {
+			candidates[i] = true
+		}
+	}
+
+	matches := []*CityMatch{}
+	for i := range candidates {
+		city := x.cities[i]
+		if countryCode != "" && city.CountryCode != countryCode {
+			continue
+		}
+		score := query.score(x.terms[i])
+		if score < minCitySimilarity {
+			continue
+		}
+		match := *city
+		match.Score = score
+		matches = append(matches, &match)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.IPs != b.IPs {
+			return a.IPs > b.IPs
+		}
+		if a.City != b.City {
+			return a.City < b.City
+		}
+		return a.CityID < b.CityID
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// foldings are the letters that don't decompose into a base letter and accents
+var foldings = strings.NewReplacer("ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "ł", "l", "đ", "d", "ð", "d", "þ", "th", "ı", "i")
+
+// foldName lower cases name, strips its accents and replaces punctuation with spaces, so that names are compared
+// the way they are typed on any keyboard
+func foldName(name string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), strings.ToLower(name))
+	if err != nil {
+		folded = strings.ToLower(name)
+	}
+	folded = foldings.Replace(folded)
+	folded = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, folded)
+	return strings.Join(strings.Fields(folded), " ")
+}
+
+// trigrams returns the trigrams of the words of folded, each padded with two spaces in front and one behind like
+// pg_trgm does, so that the start of words weighs more than their end
+func trigrams(folded string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(folded) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+const citiesQuery = `SELECT ci.id AS city_id, ci.name AS city, co.id AS country_id, co.code AS country_code,
+	co.name AS country, count(*) AS ips
+	FROM cities ci
+	JOIN countries co ON co.id = ci.country_id
+	JOIN geolocations g ON g.city_id = ci.id
+	GROUP BY ci.id, ci.name, co.id, co.code, co.name`
+
+// NewPostgresCitySource returns a source reading the cities table of postgres
+func NewPostgresCitySource(db *pg.DB) CitySource {
+	return func(ctx context.Context, fn func(*CityMatch) error) error {
+		var cities []*CityMatch
+		if _, err := db.QueryContext(ctx, &cities, citiesQuery); err != nil {
+			return err
+		}
+		for _, c := range cities {
+			if err := fn(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// NewSQLCitySource returns a source reading the cities table of sqlite or mysql
+func NewSQLCitySource(db *sql.DB) CitySource {
+	return func(ctx context.Context, fn func(*CityMatch) error) error {
+		rows, err := db.QueryContext(ctx, citiesQuery)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			c := new(CityMatch)
+			if err := rows.Scan(&c.CityID, &c.City, &c.CountryID, &c.CountryCode, &c.Country, &c.IPs); err != nil {
+				return err
+			}
+			if err := fn(c); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	}
+}
+
+// NewGeoLocationCitySource returns a source counting the cities of the geolocations of src, for the in-memory
+// lookups. Rows without ids, as in snapshots from before the reference tables, are grouped by name.
+func NewGeoLocationCitySource(src GeoLocationSource) CitySource {
+	return func(ctx context.Context, fn func(*CityMatch) error) error {
+		type key struct {
+			code string
+			city string
+		}
+		var order []key
+		cities := make(map[key]*CityMatch)
+		err := src(ctx, func(g *Geolocation) error {
+			k := key{countryKey(g.CountryCode), common.CityKey(g.City)}
+			if k.city == "" {
+				return nil
+			}
+			c, ok := cities[k]
+			if !ok {
+				c = &CityMatch{CityID: g.CityID, City: g.City, CountryID: g.CountryID, CountryCode: k.code, Country: g.Country}
+				cities[k] = c
+				order = append(order, k)
+			}
+			c.IPs++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range order {
+			if err := fn(cities[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,141 @@
+package model
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFoldName(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Expected string
+	}{
+		{Name: "São Paulo", Expected: "sao paulo"},
+		{Name: "München", Expected: "munchen"},
+		{Name: "  Frankfurt am Main ", Expected: "frankfurt am main"},
+		{Name: "Saint-Étienne", Expected: "saint etienne"},
+		{Name: "Łódź", Expected: "lodz"},
+		{Name: "Großenhain", Expected: "grossenhain"},
+		{Name: "Tromsø", Expected: "tromso"},
+		{Name: "İstanbul", Expected: "istanbul"},
+		{Name: "--", Expected: ""},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.Expected, foldName(tt.Name))
+		})
+	}
+}
+
+func TestSearchCities(t *testing.T) {
+	rows := []*Geolocation{
+		{CityID: 1, City: "São Paulo", CountryCode: "BR", Country: "Brazil"},
+		{CityID: 1, City: "São Paulo", CountryCode: "BR", Country: "Brazil"},
+		{CityID: 2, City: "São Paulo de Olivença", CountryCode: "BR", Country: "Brazil"},
+		{CityID: 3, City: "München", CountryCode: "DE", Country: "Germany"},
+		{CityID: 4, City: "Münchenbernsdorf", CountryCode: "DE", Country: "Germany"},
+		{CityID: 5, City: "Paris", CountryCode: "FR", Country: "France"},
+		{CityID: 5, City: "Paris", CountryCode: "FR", Country: "France"},
+		{CityID: 5, City: "Paris", CountryCode: "FR", Country: "France"},
+		{CityID: 6, City: "Paris", CountryCode: "US", Country: "United States"},
+		{CityID: 7, City: "Parma", CountryCode: "IT", Country: "Italy"},
+		{City: ""},
+	}
+
+	cities, err := NewCitySearchManager(context.TODO(), NewGeoLocationCitySource(sliceSource(rows)))
+	if err != nil {
+		t.Fatalf("Error loading the cities %v", err)
+	}
+	count, _ := cities.Loaded()
+	assert.Equal(t, 7, count)
+
+	cases := []struct {
+		Name        string
+		Search      CitySearch
+		ExpectedIDs []int64
+	}{
+		{
+			Name:        "accents folded",
+			Search:      CitySearch{Query: "sao paulo"},
+			ExpectedIDs: []int64{1, 2},
+		},
+		{
+			Name:        "umlaut folded",
+			Search:      CitySearch{Query: "MUNCHEN"},
+			ExpectedIDs: []int64{3, 4},
+		},
+		{
+			Name:        "typo",
+			Search:      CitySearch{Query: "muenchen"},
+			ExpectedIDs: []int64{3},
+		},
+		{
+			Name:        "equal matches by ips",
+			Search:      CitySearch{Query: "paris"},
+			ExpectedIDs: []int64{5, 6, 7},
+		},
+		{
+			Name:        "country",
+			Search:      CitySearch{Query: "paris", CountryCode: "us"},
+			ExpectedIDs: []int64{6},
+		},
+		{
+			Name:        "start of a name",
+			Search:      CitySearch{Query: "par"},
+			ExpectedIDs: []int64{5, 6, 7},
+		},
+		{
+			Name:        "limit",
+			Search:      CitySearch{Query: "par", Limit: 1},
+			ExpectedIDs: []int64{5},
+		},
+		{
+			Name:        "no match",
+			Search:      CitySearch{Query: "tokyo"},
+			ExpectedIDs: []int64{},
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			matches, err := cities.SearchCities(context.TODO(), &tt.Search)
+			assert.Nil(err)
+			ids := []int64{}
+			for _, m := range matches {
+				ids = append(ids, m.CityID)
+			}
+			assert.Equal(tt.ExpectedIDs, ids)
+		})
+	}
+}
+
+func TestSQLiteCitySource(t *testing.T) {
+	assert := assert.New(t)
+	db := newSQLiteDB(t)
+	ctx := context.TODO()
+
+	assert.Nil(NewSQLiteGeoLocationManager(db).BulkInsert(ctx, []*Geolocation{
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", CountryCode: "DE", Country: "Germany", City: "München"},
+		{IP: "10.0.0.2", StartIP: "10.0.0.2", EndIP: "10.0.0.2", CountryCode: "DE", Country: "Germany", City: "münchen"},
+		{IP: "10.0.0.3", StartIP: "10.0.0.3", EndIP: "10.0.0.3", CountryCode: "DE", Country: "Germany", City: "Berlin"},
+	}))
+
+	cities, err := NewCitySearchManager(ctx, NewSQLCitySource(db))
+	assert.Nil(err)
+	matches, err := cities.SearchCities(ctx, &CitySearch{Query: "munchen"})
+	assert.Nil(err)
+	assert.Len(matches, 1)
+	assert.Equal("München", matches[0].City)
+	assert.Equal("DE", matches[0].CountryCode)
+	assert.Equal("Germany", matches[0].Country)
+	assert.Equal(int64(2), matches[0].IPs)
+	assert.Equal(1.0, matches[0].Score)
+}
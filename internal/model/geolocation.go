@@ -8,13 +8,21 @@ import (
 
 type Geolocation struct {
 	ID           uuid.UUID `pg:"id, type:uuid, default:gen_random_uuid(), unique"`
-	IP           string    `pg:"ip"`
+	IP           string    `pg:"ip"` // the addresses the row covers as imported: an ip, a CIDR network or a start-end range
+	StartIP      string    `pg:"start_ip,type:inet"`
+	EndIP        string    `pg:"end_ip,type:inet"`
 	Country      string    `pg:"country"`
 	CountryCode  string    `pg:"country_code"`
 	City         string    `pg:"city"`
-	Latitude     string    `pg:"latitude"`
-	Longitude    string    `pg:"longitude"`
+	Latitude     float64   `pg:"latitude,use_zero"`
+	Longitude    float64   `pg:"longitude,use_zero"`
 	MysteryValue string    `pg:"mystery_value"`
+	CountryID    int64     `pg:"country_id"` // the reference rows, zero without a country code or a city
+	CityID       int64     `pg:"city_id"`
 	CreatedAt    time.Time `sql:"DEFAULT:current_timestamp"`
 	ModifiedAt   time.Time `sql:"DEFAULT:current_timestamp"`
+
+	// set by as-of lookups, the version found was current from ValidFrom until ValidTo, which is zero if it still is
+	ValidFrom time.Time `pg:"-"`
+	ValidTo   time.Time `pg:"-"`
 }
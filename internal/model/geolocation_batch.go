@@ -0,0 +1,55 @@
+package model
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/ohmpatel1997/findhotel/internal/common"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+)
+
+// IPLookup is the result for one ip of a batch lookup
+type IPLookup struct {
+	IP          string
+	Geolocation *Geolocation // nil when Err is set
+	Err         error        // what FindDataByIP returns for the ip alone, like an invalid or not found ip
+}
+
+// newLookups returns the lookups of ips, those of invalid ips failed already, and the addresses of the others. The
+// address of an invalid ip is nil.
+func newLookups(ips []string) ([]*IPLookup, []net.IP) {
+	lookups := make([]*IPLookup, len(ips))
+	addrs := make([]net.IP, len(ips))
+	for i, ip := range ips {
+		lookups[i] = &IPLookup{IP: ip}
+		addrs[i] = common.ParseIP(ip)
+		if addrs[i] == nil {
+			lookups[i].Err = router.NewHttpError("invalid ip", 400)
+		}
+	}
+	return lookups, addrs
+}
+
+// notFound fails the lookups nothing was found for
+func notFound(lookups []*IPLookup) {
+	for _, l := range lookups {
+		if l.Geolocation == nil && l.Err == nil {
+			l.Err = router.NewHttpError("data not found with given ip", 404)
+		}
+	}
+}
+
+// lookupEach looks the ips up one at a time with find, for the backends where a lookup is only a read of memory.
+// The batch fails on the first server error.
+func lookupEach(ctx context.Context, ips []string, find func(context.Context, string, time.Time) (*Geolocation, error)) ([]*IPLookup, error) {
+	lookups := make([]*IPLookup, len(ips))
+	for i, ip := range ips {
+		geo, err := find(ctx, ip, time.Time{})
+		if isServerError(err) {
+			return nil, err
+		}
+		lookups[i] = &IPLookup{IP: ip, Geolocation: geo, Err: err}
+	}
+	return lookups, nil
+}
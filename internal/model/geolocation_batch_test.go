@@ -0,0 +1,124 @@
+package model
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindDataByIPs(t *testing.T) {
+	rows := []*Geolocation{
+		{IP: "10.0.0.0/8", StartIP: "10.0.0.0", EndIP: "10.255.255.255", CountryCode: "IN", City: "Delhi"},
+		{IP: "10.1.0.0/16", StartIP: "10.1.0.0", EndIP: "10.1.255.255", CountryCode: "IN", City: "Pune"},
+		{IP: "10.1.2.3", StartIP: "10.1.2.3", EndIP: "10.1.2.3", CountryCode: "IN", City: "Surat"},
+		{IP: "2001:db8::/32", StartIP: "2001:db8::", EndIP: "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", CountryCode: "DE", City: "Berlin"},
+	}
+	for _, g := range rows {
+		g.ID = uuid.New()
+	}
+
+	memory, err := NewMemoryGeoLocationManager(context.TODO(), sliceSource(rows))
+	if err != nil {
+		t.Fatalf("Error loading the index %v", err)
+	}
+	sqlite := NewSQLiteGeoLocationManager(newSQLiteDB(t))
+	if err := sqlite.BulkInsert(context.TODO(), rows); err != nil {
+		t.Fatalf("Error inserting rows %v", err)
+	}
+	managers := map[string]GeoLocationManager{"memory": memory, "sqlite": sqlite}
+
+	notFoundErr := router.NewHttpError("data not found with given ip", 404)
+	invalidErr := router.NewHttpError("invalid ip", 400)
+
+	cases := []struct {
+		Name           string
+		IPs            []string
+		ExpectedCities []string // empty where the lookup fails
+		ExpectedErrors []error
+	}{
+		{
+			Name:           "most specific row of every ip",
+			IPs:            []string{"10.1.2.3", "10.1.2.4", "10.2.0.1", "2001:db8::1"},
+			ExpectedCities: []string{"Surat", "Pune", "Delhi", "Berlin"},
+			ExpectedErrors: []error{nil, nil, nil, nil},
+		},
+		{
+			Name:           "failed lookups keep their place",
+			IPs:            []string{"11.0.0.1", "10.1.2.3", "not an ip", "10.1.2.3", "::1"},
+			ExpectedCities: []string{"", "Surat", "", "Surat", ""},
+			ExpectedErrors: []error{notFoundErr, nil, invalidErr, nil, notFoundErr},
+		},
+		{
+			Name:           "only invalid ips",
+			IPs:            []string{""},
+			ExpectedCities: []string{""},
+			ExpectedErrors: []error{invalidErr},
+		},
+		{
+			Name: "empty batch",
+		},
+	}
+
+	for name, manager := range managers {
+		for _, tt := range cases {
+			tt := tt
+			manager := manager
+			t.Run(name+"/"+tt.Name, func(t *testing.T) {
+				t.Parallel()
+				assert := assert.New(t)
+				lookups, err := manager.FindDataByIPs(context.TODO(), tt.IPs)
+				assert.Nil(err)
+				if !assert.Len(lookups, len(tt.IPs)) {
+					return
+				}
+				for i, l := range lookups {
+					assert.Equal(tt.IPs[i], l.IP)
+					assert.Equal(tt.ExpectedErrors[i], l.Err)
+					if tt.ExpectedCities[i] == "" {
+						assert.Nil(l.Geolocation)
+						continue
+					}
+					assert.Equal(tt.ExpectedCities[i], l.Geolocation.City)
+				}
+			})
+		}
+	}
+}
+
+func TestCachedFindDataByIPs(t *testing.T) {
+	assert := assert.New(t)
+	next := &countingManager{rows: map[string]*Geolocation{
+		"10.0.0.1": {IP: "10.0.0.1", City: "Pune"},
+		"10.0.0.2": {IP: "10.0.0.2", City: "Surat"},
+	}}
+	cache := NewCachedGeoLocationManager(next, CacheOptions{})
+
+	lookups, err := cache.FindDataByIPs(context.TODO(), []string{"10.0.0.1", "10.0.0.9"})
+	assert.Nil(err)
+	assert.Equal("Pune", lookups[0].Geolocation.City)
+	assert.Equal(router.NewHttpError("data not found with given ip", 404), lookups[1].Err)
+
+	// the cached ips, found or not, are answered from the cache and the others looked up in one batch
+	lookups, err = cache.FindDataByIPs(context.TODO(), []string{"10.0.0.9", "10.0.0.2", "10.0.0.1"})
+	assert.Nil(err)
+	assert.Equal(router.NewHttpError("data not found with given ip", 404), lookups[0].Err)
+	assert.Equal("Surat", lookups[1].Geolocation.City)
+	assert.Equal("Pune", lookups[2].Geolocation.City)
+	assert.Equal(int32(2), atomic.LoadInt32(&next.calls))
+	assert.Equal(CacheStats{Hits: 2, NegativeHits: 1, Misses: 3, Entries: 3}, cache.Stats())
+
+	// callers can't change the cached rows
+	lookups[2].Geolocation.City = "Agra"
+	geo, err := cache.FindDataByIP(context.TODO(), "10.0.0.1", time.Time{})
+	assert.Nil(err)
+	assert.Equal("Pune", geo.City)
+
+	next.err = router.NewHttpError("custom error", 500)
+	_, err = cache.FindDataByIPs(context.TODO(), []string{"10.0.0.3"})
+	assert.Equal(next.err, err)
+}
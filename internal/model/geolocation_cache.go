@@ -0,0 +1,303 @@
+package model
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ohmpatel1997/findhotel/lib/router"
+)
+
+const (
+	defaultCacheSize          = 100000
+	defaultCacheTTL           = 10 * time.Minute
+	defaultCacheNegativeTTL   = time.Minute
+	defaultCacheLookupTimeout = 10 * time.Second
+)
+
+type CacheOptions struct {
+	MaxEntries    int           // the least recently used entries are evicted beyond this
+	TTL           time.Duration // how long a found geolocation is served from the cache
+	NegativeTTL   time.Duration // how long a not found ip is served from the cache
+	LookupTimeout time.Duration // how long a lookup shared by the callers missing the same ip may take
+}
+
+type CacheStats struct {
+	Hits          int64
+	NegativeHits  int64 // hits of cached not found ips, also counted in Hits
+	Misses        int64
+	Coalesced     int64 // misses that waited for the lookup of another caller instead of querying
+	Evictions     int64
+	Invalidations int64
+	Entries       int
+}
+
+// CachedGeoLocationManager is a GeoLocationManager caching the lookups of another one
+type CachedGeoLocationManager interface {
+	GeoLocationManager
+	Stats() CacheStats
+	// Invalidate drops every cached lookup, after the data changed
+	Invalidate()
+}
+
+type cacheEntry struct {
+	ip        string
+	geo       *Geolocation
+	err       error // the not found error of a negative entry
+	expiresAt time.Time
+}
+
+type cachedManager struct {
+	next GeoLocationManager
+	opts CacheOptions
+	now  func() time.Time
+
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List // most recently used first
+	generation int64      // bumped by Invalidate, so lookups started before don't fill the cache
+	stats      CacheStats
+
+	calls callGroup
+}
+
+// NewCachedGeoLocationManager returns a read-through cache in front of next. Concurrent misses for the same ip
+// are coalesced into one lookup of next.
+func NewCachedGeoLocationManager(next GeoLocationManager, opts CacheOptions) CachedGeoLocationManager {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = defaultCacheSize
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = defaultCacheTTL
+	}
+	if opts.NegativeTTL <= 0 {
+		opts.NegativeTTL = defaultCacheNegativeTTL
+	}
+	if opts.LookupTimeout <= 0 {
+		opts.LookupTimeout = defaultCacheLookupTimeout
+	}
+
+	return &cachedManager{
+		next:    next,
+		opts:    opts,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// FindDataByIP caches the current geolocations only, as-of lookups go to the wrapped manager. The lookup of a miss
+// is shared with the callers missing the same ip meanwhile, so it runs on a context of its own rather than on the one
+// of the caller starting it, and a caller giving up doesn't fail the others.
+func (m *cachedManager) FindDataByIP(ctx context.Context, ip string, asOf time.Time) (*Geolocation, error) {
+	if !asOf.IsZero() {
+		return m.next.FindDataByIP(ctx, ip, asOf)
+	}
+	if geo, err, ok := m.get(ip); ok {
+		return geo, err
+	}
+
+	m.mu.Lock()
+	generation := m.generation
+	m.mu.Unlock()
+
+	v, err, shared := m.calls.Do(ctx, ip, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), m.opts.LookupTimeout)
+		defer cancel()
+		geo, err := m.next.FindDataByIP(ctx, ip, time.Time{})
+		m.set(ip, geo, err, generation)
+		return geo, err
+	})
+	if shared {
+		m.mu.Lock()
+		m.stats.Coalesced++
+		m.mu.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return copyGeolocation(v.(*Geolocation)), nil
+}
+
+// FindDataByIPs answers the cached ips from the cache and looks the others up in one batch of the wrapped manager.
+// Unlike single lookups, batches aren't coalesced with concurrent misses of the same ips.
+func (m *cachedManager) FindDataByIPs(ctx context.Context, ips []string) ([]*IPLookup, error) {
+	m.mu.Lock()
+	generation := m.generation
+	m.mu.Unlock()
+
+	lookups := make([]*IPLookup, len(ips))
+	var missed []string
+	var positions []int
+	for i, ip := range ips {
+		if geo, err, ok := m.get(ip); ok {
+			lookups[i] = &IPLookup{IP: ip, Geolocation: geo, Err: err}
+			continue
+		}
+		missed = append(missed, ip)
+		positions = append(positions, i)
+	}
+	if len(missed) == 0 {
+		return lookups, nil
+	}
+
+	found, err := m.next.FindDataByIPs(ctx, missed)
+	if err != nil {
+		return nil, err
+	}
+	for j, l := range found {
+		m.set(l.IP, l.Geolocation, l.Err, generation)
+		if l.Geolocation != nil {
+			l.Geolocation = copyGeolocation(l.Geolocation)
+		}
+		lookups[positions[j]] = l
+	}
+	return lookups, nil
+}
+
+// BulkInsert writes through to the wrapped manager and invalidates the cache
+func (m *cachedManager) BulkInsert(ctx context.Context, geolocation []*Geolocation) error {
+	err := m.next.BulkInsert(ctx, geolocation)
+	m.Invalidate()
+	return err
+}
+
+// List isn't cached, the pages are read from the wrapped manager
+func (m *cachedManager) List(ctx context.Context, query *GeolocationQuery) (*GeolocationPage, error) {
+	return m.next.List(ctx, query)
+}
+
+func (m *cachedManager) Stats() CacheStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.stats
+	stats.Entries = m.lru.Len()
+	return stats
+}
+
+func (m *cachedManager) Invalidate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = make(map[string]*list.Element)
+	m.lru.Init()
+	m.generation++
+	m.stats.Invalidations++
+}
+
+func (m *cachedManager) get(ip string) (*Geolocation, error, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.entries[ip]
+	if !ok {
+		m.stats.Misses++
+		return nil, nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if !m.now().Before(entry.expiresAt) {
+		m.remove(el)
+		m.stats.Misses++
+		return nil, nil, false
+	}
+
+	m.lru.MoveToFront(el)
+	m.stats.Hits++
+	if entry.err != nil {
+		m.stats.NegativeHits++
+		return nil, entry.err, true
+	}
+	return copyGeolocation(entry.geo), nil, true
+}
+
+// set caches the result of a lookup, found geolocations and not found errors only
+func (m *cachedManager) set(ip string, geo *Geolocation, err error, generation int64) {
+	ttl := m.opts.TTL
+	if err != nil {
+		var httpErr *router.HttpError
+		if !errors.As(err, &httpErr) || httpErr.Status != 404 {
+			return
+		}
+		ttl = m.opts.NegativeTTL
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if generation != m.generation { // the data changed during the lookup
+		return
+	}
+
+	entry := &cacheEntry{ip: ip, geo: geo, err: err, expiresAt: m.now().Add(ttl)}
+	if el, ok := m.entries[ip]; ok {
+		el.Value = entry
+		m.lru.MoveToFront(el)
+		return
+	}
+	m.entries[ip] = m.lru.PushFront(entry)
+
+	for m.lru.Len() > m.opts.MaxEntries {
+		m.remove(m.lru.Back())
+		m.stats.Evictions++
+	}
+}
+
+func (m *cachedManager) remove(el *list.Element) {
+	m.lru.Remove(el)
+	delete(m.entries, el.Value.(*cacheEntry).ip)
+}
+
+// copyGeolocation keeps callers from changing the cached geolocation
+func copyGeolocation(geo *Geolocation) *Geolocation {
+	resp := *geo
+	return &resp
+}
+
+// callGroup runs one call per key at a time, the callers arriving meanwhile share its result
+type callGroup struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// Do runs fn unless a call of key is running already, and waits for the result until ctx is done. The call keeps
+// running for the other callers when ctx is done first, so fn must not use the context of a caller.
+func (g *callGroup) Do(ctx context.Context, key string, fn func() (interface{}, error)) (interface{}, error, bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	c, shared := g.calls[key]
+	if !shared {
+		c = &call{done: make(chan struct{})}
+		g.calls[key] = c
+		go g.run(key, c, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err, shared
+	case <-ctx.Done():
+		return nil, ctx.Err(), shared
+	}
+}
+
+func (g *callGroup) run(key string, c *call, fn func() (interface{}, error)) {
+	defer func() {
+		if r := recover(); r != nil { // the callers get it as an error, there is no one to recover it otherwise
+			c.err = fmt.Errorf("panic in the call of %s: %v", key, r)
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn()
+}
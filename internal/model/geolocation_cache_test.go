@@ -0,0 +1,261 @@
+package model
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingManager answers from rows and counts the lookups per ip, release blocks them until it is closed
+type countingManager struct {
+	rows    map[string]*Geolocation
+	err     error
+	release chan struct{}
+	calls   int32
+}
+
+func (m *countingManager) FindDataByIP(ctx context.Context, ip string, asOf time.Time) (*Geolocation, error) {
+	atomic.AddInt32(&m.calls, 1)
+	if m.release != nil {
+		<-m.release
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	geo, ok := m.rows[ip]
+	if !ok {
+		return nil, router.NewHttpError("data not found with given ip", 404)
+	}
+	return geo, nil
+}
+
+// FindDataByIPs counts a batch as one lookup
+func (m *countingManager) FindDataByIPs(ctx context.Context, ips []string) ([]*IPLookup, error) {
+	atomic.AddInt32(&m.calls, 1)
+	if m.err != nil {
+		return nil, m.err
+	}
+	lookups := make([]*IPLookup, len(ips))
+	for i, ip := range ips {
+		lookups[i] = &IPLookup{IP: ip, Geolocation: m.rows[ip]}
+	}
+	notFound(lookups)
+	return lookups, nil
+}
+
+func (m *countingManager) BulkInsert(ctx context.Context, geolocation []*Geolocation) error {
+	return nil
+}
+
+func (m *countingManager) List(ctx context.Context, query *GeolocationQuery) (*GeolocationPage, error) {
+	atomic.AddInt32(&m.calls, 1)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &GeolocationPage{}, nil
+}
+
+func TestCachedFindDataByIP(t *testing.T) {
+	rows := map[string]*Geolocation{
+		"10.0.0.1": {IP: "10.0.0.1", City: "Pune"},
+		"10.0.0.2": {IP: "10.0.0.2", City: "Surat"},
+		"10.0.0.3": {IP: "10.0.0.3", City: "Agra"},
+	}
+
+	cases := []struct {
+		Name          string
+		Lookups       []string
+		Advance       time.Duration // how long after the lookups the last one is repeated
+		Err           error
+		ExpectedCalls int32
+		ExpectedStats CacheStats
+	}{
+		{
+			Name:          "hit",
+			Lookups:       []string{"10.0.0.1", "10.0.0.1"},
+			ExpectedCalls: 1,
+			ExpectedStats: CacheStats{Hits: 2, Misses: 1, Entries: 1},
+		},
+		{
+			Name:          "expired",
+			Lookups:       []string{"10.0.0.1"},
+			Advance:       time.Hour,
+			ExpectedCalls: 2,
+			ExpectedStats: CacheStats{Misses: 2, Entries: 1},
+		},
+		{
+			Name:          "negative hit",
+			Lookups:       []string{"10.0.0.9", "10.0.0.9"},
+			ExpectedCalls: 1,
+			ExpectedStats: CacheStats{Hits: 2, NegativeHits: 2, Misses: 1, Entries: 1},
+		},
+		{
+			Name:          "negative entry expires first",
+			Lookups:       []string{"10.0.0.9"},
+			Advance:       2 * time.Second,
+			ExpectedCalls: 2,
+			ExpectedStats: CacheStats{Misses: 2, Entries: 1},
+		},
+		{
+			Name:          "least recently used evicted",
+			Lookups:       []string{"10.0.0.1", "10.0.0.2", "10.0.0.1", "10.0.0.3", "10.0.0.2"},
+			ExpectedCalls: 4,
+			ExpectedStats: CacheStats{Hits: 2, Misses: 4, Evictions: 2, Entries: 2},
+		},
+		{
+			Name:          "errors aren't cached",
+			Lookups:       []string{"10.0.0.1", "10.0.0.1"},
+			Err:           router.NewHttpError("custom error", 500),
+			ExpectedCalls: 3,
+			ExpectedStats: CacheStats{Misses: 3},
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			next := &countingManager{rows: rows, err: tt.Err}
+			cache := NewCachedGeoLocationManager(next, CacheOptions{MaxEntries: 2, TTL: time.Minute, NegativeTTL: time.Second}).(*cachedManager)
+			now := time.Now()
+			cache.now = func() time.Time { return now }
+
+			find := func(ip string) {
+				geo, err := cache.FindDataByIP(context.TODO(), ip, time.Time{})
+				expected, ok := rows[ip]
+				switch {
+				case tt.Err != nil:
+					assert.Equal(tt.Err, err)
+				case !ok:
+					assert.Equal(router.NewHttpError("data not found with given ip", 404), err)
+				default:
+					assert.Nil(err)
+					assert.Equal(expected, geo)
+				}
+			}
+			for _, ip := range tt.Lookups {
+				find(ip)
+			}
+			now = now.Add(tt.Advance)
+			find(tt.Lookups[len(tt.Lookups)-1])
+
+			assert.Equal(tt.ExpectedCalls, atomic.LoadInt32(&next.calls))
+			assert.Equal(tt.ExpectedStats, cache.Stats())
+		})
+	}
+}
+
+func TestCachedCoalescesMisses(t *testing.T) {
+	assert := assert.New(t)
+	next := &countingManager{
+		rows:    map[string]*Geolocation{"10.0.0.1": {IP: "10.0.0.1", City: "Pune"}},
+		release: make(chan struct{}),
+	}
+	cache := NewCachedGeoLocationManager(next, CacheOptions{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			geo, err := cache.FindDataByIP(context.TODO(), "10.0.0.1", time.Time{})
+			assert.Nil(err)
+			assert.Equal("Pune", geo.City)
+		}()
+	}
+
+	// let every caller reach the lookup before it returns
+	assert.Eventually(func() bool { return cache.Stats().Misses == 10 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	close(next.release)
+	wg.Wait()
+
+	assert.Equal(int32(1), atomic.LoadInt32(&next.calls))
+	assert.Equal(int64(9), cache.Stats().Coalesced)
+}
+
+func TestCachedCallerCancelled(t *testing.T) {
+	assert := assert.New(t)
+	next := &countingManager{
+		rows:    map[string]*Geolocation{"10.0.0.1": {IP: "10.0.0.1", City: "Pune"}},
+		release: make(chan struct{}),
+	}
+	cache := NewCachedGeoLocationManager(next, CacheOptions{})
+
+	// the caller starting the lookup gives up, the one waiting for it still gets the geolocation
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := cache.FindDataByIP(ctx, "10.0.0.1", time.Time{})
+		first <- err
+	}()
+	assert.Eventually(func() bool { return atomic.LoadInt32(&next.calls) == 1 }, time.Second, time.Millisecond)
+
+	second := make(chan *Geolocation)
+	go func() {
+		geo, _ := cache.FindDataByIP(context.TODO(), "10.0.0.1", time.Time{})
+		second <- geo
+	}()
+	assert.Eventually(func() bool { return cache.Stats().Misses == 2 }, time.Second, time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(<-first, context.Canceled)
+	close(next.release)
+	geo := <-second
+	if assert.NotNil(geo) {
+		assert.Equal("Pune", geo.City)
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&next.calls))
+	assert.Equal(1, cache.Stats().Entries)
+}
+
+func TestCachedInvalidate(t *testing.T) {
+	assert := assert.New(t)
+	next := &countingManager{
+		rows:    map[string]*Geolocation{"10.0.0.1": {IP: "10.0.0.1", City: "Pune"}},
+		release: make(chan struct{}),
+	}
+	cache := NewCachedGeoLocationManager(next, CacheOptions{})
+
+	// a lookup running while the cache is invalidated may return old data, so it isn't cached
+	done := make(chan struct{})
+	go func() {
+		_, _ = cache.FindDataByIP(context.TODO(), "10.0.0.1", time.Time{})
+		close(done)
+	}()
+	assert.Eventually(func() bool { return atomic.LoadInt32(&next.calls) == 1 }, time.Second, time.Millisecond)
+	cache.Invalidate()
+	close(next.release)
+	<-done
+	assert.Equal(0, cache.Stats().Entries)
+
+	_, _ = cache.FindDataByIP(context.TODO(), "10.0.0.1", time.Time{})
+	assert.Equal(1, cache.Stats().Entries)
+	cache.Invalidate()
+	assert.Equal(CacheStats{Misses: 2, Invalidations: 2}, cache.Stats())
+}
+
+func TestCachedAsOf(t *testing.T) {
+	assert := assert.New(t)
+	next := &countingManager{rows: map[string]*Geolocation{"10.0.0.1": {IP: "10.0.0.1", City: "Pune"}}}
+	cache := NewCachedGeoLocationManager(next, CacheOptions{})
+
+	// as-of lookups aren't cached and don't touch the cached current geolocations
+	for i := 0; i < 2; i++ {
+		geo, err := cache.FindDataByIP(context.TODO(), "10.0.0.1", time.Now())
+		assert.Nil(err)
+		assert.Equal("Pune", geo.City)
+	}
+	assert.Equal(int32(2), next.calls)
+	assert.Equal(CacheStats{}, cache.Stats())
+}
@@ -3,15 +3,24 @@ package model
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/ohmpatel1997/findhotel/internal/common"
 	"github.com/ohmpatel1997/findhotel/lib/router"
 )
 
 //go:generate mockery --name GeoLocationManager --output=mocks
 type GeoLocationManager interface {
-	FindDataByIP(ctx context.Context, ip string) (*Geolocation, error)
+	// FindDataByIP returns the most specific geolocation of ip, as it was at asOf unless that is zero
+	FindDataByIP(ctx context.Context, ip string, asOf time.Time) (*Geolocation, error)
+	// FindDataByIPs returns the current geolocations of ips in one query, in the order of ips. An invalid or not found
+	// ip fails its own lookup only, the error is for the batch as a whole.
+	FindDataByIPs(ctx context.Context, ips []string) ([]*IPLookup, error)
 	BulkInsert(ctx context.Context, geolocation []*Geolocation) error
+	// List returns a page of the geolocations matching query
+	List(ctx context.Context, query *GeolocationQuery) (*GeolocationPage, error)
 }
 
 type manager struct {
@@ -24,14 +33,25 @@ func NewGeoLocationManager(db *pg.DB) GeoLocationManager {
 	}
 }
 
-func (m *manager) FindDataByIP(ctx context.Context, ip string) (*Geolocation, error) {
+func (m *manager) FindDataByIP(ctx context.Context, ip string, asOf time.Time) (*Geolocation, error) {
 	var resp Geolocation
 
-	if len(ip) == 0 {
+	addr := common.ParseIP(ip)
+	if addr == nil {
 		return nil, router.NewHttpError("invalid ip", 400)
 	}
+	// the addresses are stored as the importer notes them, an ipv4-mapped ipv6 address being an ipv4 one
+	ip = addr.String()
+	if !asOf.IsZero() {
+		return m.findVersion(ctx, ip, asOf)
+	}
 
-	err := m.db.ModelContext(ctx, &resp).Where("ip = ?", ip).Select()
+	// of the ranges containing the ip, the most specific one starts last and ends first
+	err := m.db.ModelContext(ctx, &resp).
+		Where("inetrange(start_ip, end_ip, '[]') @> ?::inet", ip).
+		Order("start_ip DESC", "end_ip ASC").
+		Limit(1).
+		Select()
 	switch {
 	case errors.Is(err, pg.ErrNoRows):
 		return nil, router.NewHttpError("data not found with given ip", 404)
@@ -41,9 +61,167 @@ func (m *manager) FindDataByIP(ctx context.Context, ip string) (*Geolocation, er
 	return &resp, nil
 }
 
+// batchLookup is a geolocation found by FindDataByIPs, with the ordinal of its ip among the valid ones, from 1
+type batchLookup struct {
+	Ordinal int `pg:"ordinal"`
+	Geolocation
+}
+
+func (m *manager) FindDataByIPs(ctx context.Context, ips []string) ([]*IPLookup, error) {
+	lookups, addrs := newLookups(ips)
+	var valid []string
+	var positions []int
+	for i, addr := range addrs {
+		if addr != nil {
+			valid = append(valid, addr.String())
+			positions = append(positions, i)
+		}
+	}
+	if len(valid) == 0 {
+		return lookups, nil
+	}
+
+	// the lateral subquery is the query of FindDataByIP, run for every ip of the array
+	var rows []*batchLookup
+	_, err := m.db.QueryContext(ctx, &rows, `SELECT q.ordinal, g.*
+		FROM unnest(?::inet[]) WITH ORDINALITY AS q(ip, ordinal)
+		JOIN LATERAL (
+			SELECT * FROM geolocations
+			WHERE inetrange(start_ip, end_ip, '[]') @> q.ip
+			ORDER BY start_ip DESC, end_ip ASC
+			LIMIT 1
+		) g ON true`, pg.Array(valid))
+	if err != nil {
+		return nil, router.NewHttpError(err.Error(), 500)
+	}
+	for _, row := range rows {
+		geo := row.Geolocation
+		lookups[positions[row.Ordinal-1]].Geolocation = &geo
+	}
+	notFound(lookups)
+	return lookups, nil
+}
+
+func (m *manager) List(ctx context.Context, query *GeolocationQuery) (*GeolocationPage, error) {
+	q, err := normalizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := parseCursor(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []*Geolocation
+	sel := m.db.ModelContext(ctx, &rows)
+	if filters, args := listFilters(q); filters != "" {
+		sel.Where(filters, args...)
+	}
+
+	var columns []string
+	switch q.Sort {
+	case SortIP:
+		columns = []string{"start_ip", "end_ip", "id"}
+		if cursor != nil {
+			sel.Where(keysetCondition(columns, []string{"?::inet", "?::inet", "?"}, q.Desc), cursor.StartIP, cursor.EndIP, cursor.ID)
+		}
+	case SortCountry, SortCity, SortLatitude, SortLongitude:
+		columns = []string{q.Sort, "id"}
+		if cursor != nil {
+			var value interface{} = cursor.Text
+			if q.Sort == SortLatitude || q.Sort == SortLongitude {
+				value = cursor.Number
+			}
+			sel.Where(keysetCondition(columns, []string{"?", "?"}, q.Desc), value, cursor.ID)
+		}
+	}
+
+	err = sel.Order(sortOrder(columns, q.Desc)...).Limit(q.Limit + 1).Select()
+	if err != nil {
+		return nil, router.NewHttpError(err.Error(), 500)
+	}
+	return newPage(q, rows), nil
+}
+
+// geolocationVersion is a row of geolocation_history, written by a trigger whenever a geolocation changes
+type geolocationVersion struct {
+	tableName struct{} `pg:"geolocation_history"`
+
+	ID            int64     `pg:"id,pk"`
+	GeolocationID uuid.UUID `pg:"geolocation_id,type:uuid"`
+	IP            string    `pg:"ip"`
+	StartIP       string    `pg:"start_ip,type:inet"`
+	EndIP         string    `pg:"end_ip,type:inet"`
+	Country       string    `pg:"country"`
+	CountryCode   string    `pg:"country_code"`
+	City          string    `pg:"city"`
+	Latitude      float64   `pg:"latitude,use_zero"`
+	Longitude     float64   `pg:"longitude,use_zero"`
+	MysteryValue  string    `pg:"mystery_value"`
+	CountryID     int64     `pg:"country_id"`
+	CityID        int64     `pg:"city_id"`
+	ValidFrom     time.Time `pg:"valid_from"`
+	ValidTo       time.Time `pg:"valid_to"`
+}
+
+// findVersion returns the most specific of the versions current at asOf
+func (m *manager) findVersion(ctx context.Context, ip string, asOf time.Time) (*Geolocation, error) {
+	var version geolocationVersion
+	err := m.db.ModelContext(ctx, &version).
+		Where("inetrange(start_ip, end_ip, '[]') @> ?::inet", ip).
+		Where("tstzrange(valid_from, valid_to, '[)') @> ?::timestamptz", asOf).
+		Order("start_ip DESC", "end_ip ASC").
+		Limit(1).
+		Select()
+	switch {
+	case errors.Is(err, pg.ErrNoRows):
+		return nil, router.NewHttpError("data not found with given ip", 404)
+	case err != nil:
+		return nil, router.NewHttpError(err.Error(), 500)
+	}
+
+	return &Geolocation{
+		ID:           version.GeolocationID,
+		IP:           version.IP,
+		StartIP:      version.StartIP,
+		EndIP:        version.EndIP,
+		Country:      version.Country,
+		CountryCode:  version.CountryCode,
+		City:         version.City,
+		Latitude:     version.Latitude,
+		Longitude:    version.Longitude,
+		MysteryValue: version.MysteryValue,
+		CountryID:    version.CountryID,
+		CityID:       version.CityID,
+		ValidFrom:    version.ValidFrom,
+		ValidTo:      version.ValidTo,
+	}, nil
+}
+
+// BulkInsert upserts the rows by ip. When ctx carries an import run the overwritten rows are recorded first,
+// so the run can be rolled back later.
 func (m *manager) BulkInsert(ctx context.Context, geolocation []*Geolocation) error {
 	return m.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
-		_, err := tx.Model(&geolocation).Insert()
+		if err := resolveReferences(ctx, tx, geolocation); err != nil {
+			return err
+		}
+		if runID, ok := importRunFromContext(ctx); ok {
+			if err := recordChanges(ctx, tx, runID, geolocation); err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.ModelContext(ctx, &geolocation).
+			OnConflict("(ip) DO UPDATE").
+			Set("country_code = EXCLUDED.country_code").
+			Set("country = EXCLUDED.country").
+			Set("city = EXCLUDED.city").
+			Set("latitude = EXCLUDED.latitude").
+			Set("longitude = EXCLUDED.longitude").
+			Set("mystery_value = EXCLUDED.mystery_value").
+			Set("country_id = EXCLUDED.country_id").
+			Set("city_id = EXCLUDED.city_id").
+			Insert()
 		return err
 	})
 }
@@ -22,15 +22,17 @@ func TestFindDataByIP(t *testing.T) {
 	}{
 		{
 			Name: "success",
-			Ip:   "123",
+			Ip:   "70.95.73.73",
 			PopulateDb: func(db *pg.DB) error {
 				geo := &Geolocation{
-					IP:           "123",
+					IP:           "70.95.73.73",
+					StartIP:      "70.95.73.73",
+					EndIP:        "70.95.73.73",
 					Country:      "India",
 					CountryCode:  "IN",
 					City:         "Mumbai",
-					Latitude:     "15.323",
-					Longitude:    "145.244",
+					Latitude:     15.323,
+					Longitude:    145.244,
 					MysteryValue: "Mumbai",
 					CreatedAt:    time.Now(),
 					ModifiedAt:   time.Now(),
@@ -39,23 +41,70 @@ func TestFindDataByIP(t *testing.T) {
 				return err
 			},
 			Resp: &Geolocation{
-				IP:           "123",
+				IP:           "70.95.73.73",
 				Country:      "India",
 				CountryCode:  "IN",
 				City:         "Mumbai",
-				Latitude:     "15.323",
-				Longitude:    "145.244",
+				Latitude:     15.323,
+				Longitude:    145.244,
 				MysteryValue: "Mumbai",
 			},
 			ExpectedError: nil,
 		},
 		{
-			Name: "not found",
+			Name: "ipv4-mapped ipv6 address",
+			Ip:   "::ffff:70.95.73.73",
+			PopulateDb: func(db *pg.DB) error {
+				return nil
+			},
+			Resp: &Geolocation{
+				IP:           "70.95.73.73",
+				Country:      "India",
+				CountryCode:  "IN",
+				City:         "Mumbai",
+				Latitude:     15.323,
+				Longitude:    145.244,
+				MysteryValue: "Mumbai",
+			},
+			ExpectedError: nil,
+		},
+		{
+			Name: "most specific network",
+			Ip:   "10.1.2.3",
+			PopulateDb: func(db *pg.DB) error {
+				geos := []*Geolocation{
+					{IP: "10.0.0.0/8", StartIP: "10.0.0.0", EndIP: "10.255.255.255", Country: "India", CountryCode: "IN", City: "Delhi", MysteryValue: "1"},
+					{IP: "10.1.0.0/16", StartIP: "10.1.0.0", EndIP: "10.1.255.255", Country: "India", CountryCode: "IN", City: "Pune", MysteryValue: "2"},
+					{IP: "10.2.0.0/16", StartIP: "10.2.0.0", EndIP: "10.2.255.255", Country: "India", CountryCode: "IN", City: "Surat", MysteryValue: "3"},
+				}
+				_, err := db.Model(&geos).Insert()
+				return err
+			},
+			Resp: &Geolocation{
+				IP:           "10.1.0.0/16",
+				Country:      "India",
+				CountryCode:  "IN",
+				City:         "Pune",
+				MysteryValue: "2",
+			},
+			ExpectedError: nil,
+		},
+		{
+			Name: "invalid ip",
 			Ip:   "1235",
 			PopulateDb: func(db *pg.DB) error {
 				return nil
 			},
 			Resp:          nil,
+			ExpectedError: router.NewHttpError("invalid ip", 400),
+		},
+		{
+			Name: "not found",
+			Ip:   "192.0.2.1",
+			PopulateDb: func(db *pg.DB) error {
+				return nil
+			},
+			Resp:          nil,
 			ExpectedError: router.NewHttpError("data not found with given ip", 404),
 		},
 	}
@@ -66,6 +115,9 @@ func TestFindDataByIP(t *testing.T) {
 	defer db.Close()
 	var geo Geolocation
 
+	if _, err := db.Exec("CREATE TYPE inetrange AS RANGE (subtype = inet)"); err != nil {
+		t.Fatalf("Error creating range type %v", err)
+	}
 	err := db.Model(&geo).CreateTable(&orm.CreateTableOptions{FKConstraints: true})
 	if err != nil {
 		t.Fatalf("Error creating schema %v", err)
@@ -78,7 +130,7 @@ func TestFindDataByIP(t *testing.T) {
 			assert.Equal(nil, err)
 
 			modelManager := NewGeoLocationManager(db)
-			resp, err := modelManager.FindDataByIP(context.TODO(), tt.Ip)
+			resp, err := modelManager.FindDataByIP(context.TODO(), tt.Ip, time.Time{})
 			if tt.ExpectedError != nil {
 				assert.Equal(tt.ExpectedError, err)
 				return
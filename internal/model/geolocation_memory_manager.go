@@ -0,0 +1,420 @@
+package model
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ohmpatel1997/findhotel/internal/common"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+)
+
+var (
+	ErrReadOnly = errors.New("the in-memory geolocations are read only")
+)
+
+// MemoryGeoLocationManager answers lookups from an index of the whole dataset, loaded from a GeoLocationSource
+type MemoryGeoLocationManager interface {
+	GeoLocationManager
+	// Reload loads the dataset again and swaps the index once it is built, lookups keep using the old one meanwhile
+	Reload(ctx context.Context) error
+	// Loaded returns the number of rows in the index and when it was loaded
+	Loaded() (int, time.Time)
+}
+
+type memoryManager struct {
+	src GeoLocationSource
+
+	mu       sync.RWMutex
+	index    *ipIndex
+	loadedAt time.Time
+}
+
+// NewMemoryGeoLocationManager loads src into memory and returns a manager serving it
+func NewMemoryGeoLocationManager(ctx context.Context, src GeoLocationSource) (MemoryGeoLocationManager, error) {
+	m := &memoryManager{src: src}
+	if err := m.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *memoryManager) Reload(ctx context.Context) error {
+	b := newIPIndexBuilder()
+	if err := m.src(ctx, b.Add); err != nil {
+		return err
+	}
+	index := b.Build()
+
+	m.mu.Lock()
+	m.index = index
+	m.loadedAt = time.Now()
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memoryManager) Loaded() (int, time.Time) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.index.ranges), m.loadedAt
+}
+
+// FindDataByIP only knows the current geolocations, the history is kept in the database
+func (m *memoryManager) FindDataByIP(ctx context.Context, ip string, asOf time.Time) (*Geolocation, error) {
+	addr := common.ParseIP(ip)
+	if addr == nil {
+		return nil, router.NewHttpError("invalid ip", 400)
+	}
+	if !asOf.IsZero() {
+		return nil, router.NewHttpError("as-of lookups are not supported by the memory lookup backend", 501)
+	}
+
+	m.mu.RLock()
+	index := m.index
+	m.mu.RUnlock()
+
+	geo, ok := index.Find(addr)
+	if !ok {
+		return nil, router.NewHttpError("data not found with given ip", 404)
+	}
+	return geo, nil
+}
+
+func (m *memoryManager) FindDataByIPs(ctx context.Context, ips []string) ([]*IPLookup, error) {
+	return lookupEach(ctx, ips, m.FindDataByIP)
+}
+
+// List filters and sorts the whole index for every page, it is meant for browsing rather than for bulk reads
+func (m *memoryManager) List(ctx context.Context, query *GeolocationQuery) (*GeolocationPage, error) {
+	q, err := normalizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := parseCursor(q)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	index := m.index
+	m.mu.RUnlock()
+
+	var rows []*Geolocation
+	for i := range index.ranges {
+		r := &index.ranges[i]
+		if attrs := index.locations[r.location].geolocation(); !q.matches(&attrs) {
+			continue
+		}
+		if g := index.geolocation(r); cursor == nil || q.afterCursor(cursor, g) {
+			rows = append(rows, g)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if q.Desc {
+			return q.compare(rows[i], rows[j]) > 0
+		}
+		return q.compare(rows[i], rows[j]) < 0
+	})
+	if len(rows) > q.Limit+1 {
+		rows = rows[:q.Limit+1]
+	}
+	return newPage(q, rows), nil
+}
+
+func (m *memoryManager) BulkInsert(ctx context.Context, geolocation []*Geolocation) error {
+	return ErrReadOnly
+}
+
+// ipKey is an address in its 16 byte form, ipv4 and ipv6 addresses are kept in separate tables
+type ipKey [net.IPv6len]byte
+
+func toKey(ip net.IP) (ipKey, bool) {
+	var k ipKey
+	ip16 := ip.To16()
+	copy(k[:], ip16)
+	return k, ip.To4() != nil
+}
+
+// next returns the address after k, and false if k is the last address
+func (k ipKey) next(v4 bool) (ipKey, bool) {
+	last := 0
+	if v4 {
+		last = net.IPv6len - net.IPv4len
+	}
+	for i := net.IPv6len - 1; i >= last; i-- {
+		k[i]++
+		if k[i] != 0 {
+			return k, true
+		}
+	}
+	return k, false
+}
+
+// ipTable splits the address space of one family into consecutive segments. Segment i covers the addresses
+// from starts[i] up to the next start and resolves to ranges[i], or to nothing when that is -1.
+type ipTable struct {
+	starts []ipKey
+	ranges []int32
+}
+
+func (t *ipTable) find(k ipKey) int32 {
+	i := sort.Search(len(t.starts), func(i int) bool {
+		return bytes.Compare(t.starts[i][:], k[:]) > 0
+	}) - 1
+	if i < 0 {
+		return -1
+	}
+	return t.ranges[i]
+}
+
+// location is what the rows of a city have in common, it is kept once for all of them
+type location struct {
+	Country      string
+	CountryCode  string
+	City         string
+	Latitude     float64
+	Longitude    float64
+	MysteryValue string
+	CountryID    int64
+	CityID       int64
+}
+
+func (l *location) geolocation() Geolocation {
+	return Geolocation{
+		Country:      l.Country,
+		CountryCode:  l.CountryCode,
+		City:         l.City,
+		Latitude:     l.Latitude,
+		Longitude:    l.Longitude,
+		MysteryValue: l.MysteryValue,
+		CountryID:    l.CountryID,
+		CityID:       l.CityID,
+	}
+}
+
+// the notations the ip of a row was imported in
+const (
+	notationAddress = iota
+	notationNetwork
+	notationRange
+)
+
+// indexedRange is a row of the index: its range, its id and the offset of its location
+type indexedRange struct {
+	start, end ipKey
+	id         uuid.UUID
+	location   int32
+	notation   uint8
+}
+
+// ipIndex finds the most specific row covering an address, like the range query of the database manager. It only
+// keeps the range bounds of the rows and points them into a table of the distinct locations.
+type ipIndex struct {
+	ranges    []indexedRange
+	locations []location
+	v4        ipTable
+	v6        ipTable
+}
+
+func (x *ipIndex) Find(ip net.IP) (*Geolocation, bool) {
+	k, v4 := toKey(ip)
+	table := &x.v6
+	if v4 {
+		table = &x.v4
+	}
+	i := table.find(k)
+	if i < 0 {
+		return nil, false
+	}
+	return x.geolocation(&x.ranges[i]), true
+}
+
+// geolocation builds the row of r back, the timestamps aside
+func (x *ipIndex) geolocation(r *indexedRange) *Geolocation {
+	g := x.locations[r.location].geolocation()
+	g.ID = r.id
+	g.StartIP, g.EndIP = net.IP(r.start[:]).String(), net.IP(r.end[:]).String()
+	switch r.notation {
+	case notationAddress:
+		g.IP = g.StartIP
+	case notationNetwork:
+		g.IP = fmt.Sprintf("%s/%d", g.StartIP, prefixLen(r.start, r.end))
+	default:
+		g.IP = g.StartIP + "-" + g.EndIP
+	}
+	return &g
+}
+
+// prefixLen returns the prefix length of the network from start to end
+func prefixLen(start, end ipKey) int {
+	size := 8 * net.IPv6len
+	if net.IP(start[:]).To4() != nil {
+		size = 8 * net.IPv4len
+	}
+	host := 0
+	for i := range start {
+		host += bits.OnesCount8(start[i] ^ end[i])
+	}
+	return size - host
+}
+
+type ipRange struct {
+	start, end ipKey
+	row        int32
+}
+
+type ipIndexBuilder struct {
+	ranges    []indexedRange
+	locations []location
+	offsets   map[location]int32
+	v4        []ipRange
+	v6        []ipRange
+}
+
+func newIPIndexBuilder() *ipIndexBuilder {
+	return &ipIndexBuilder{offsets: make(map[location]int32)}
+}
+
+func (b *ipIndexBuilder) Add(g *Geolocation) error {
+	start, end := net.ParseIP(g.StartIP), net.ParseIP(g.EndIP)
+	if start == nil || end == nil {
+		return fmt.Errorf("geolocation %s has an invalid range %q-%q", g.IP, g.StartIP, g.EndIP)
+	}
+	startKey, startV4 := toKey(start)
+	endKey, endV4 := toKey(end)
+	if startV4 != endV4 || bytes.Compare(startKey[:], endKey[:]) > 0 {
+		return fmt.Errorf("geolocation %s has an invalid range %q-%q", g.IP, g.StartIP, g.EndIP)
+	}
+
+	r := ipRange{start: startKey, end: endKey, row: int32(len(b.ranges))}
+	b.ranges = append(b.ranges, indexedRange{
+		start:    startKey,
+		end:      endKey,
+		id:       g.ID,
+		location: b.location(g),
+		notation: notation(g, startKey, endKey),
+	})
+	if startV4 {
+		b.v4 = append(b.v4, r)
+	} else {
+		b.v6 = append(b.v6, r)
+	}
+	return nil
+}
+
+// location returns the offset of the location of g, adding it when it is the first row there
+func (b *ipIndexBuilder) location(g *Geolocation) int32 {
+	l := location{
+		Country:      g.Country,
+		CountryCode:  g.CountryCode,
+		City:         g.City,
+		Latitude:     g.Latitude,
+		Longitude:    g.Longitude,
+		MysteryValue: g.MysteryValue,
+		CountryID:    g.CountryID,
+		CityID:       g.CityID,
+	}
+	if offset, ok := b.offsets[l]; ok {
+		return offset
+	}
+	offset := int32(len(b.locations))
+	b.locations = append(b.locations, l)
+	b.offsets[l] = offset
+	return offset
+}
+
+func notation(g *Geolocation, start, end ipKey) uint8 {
+	switch {
+	case start == end:
+		return notationAddress
+	case strings.Contains(g.IP, "/"):
+		return notationNetwork
+	default:
+		return notationRange
+	}
+}
+
+func (b *ipIndexBuilder) Build() *ipIndex {
+	return &ipIndex{
+		ranges:    b.ranges,
+		locations: b.locations,
+		v4:        buildTable(b.v4, true),
+		v6:        buildTable(b.v6, false),
+	}
+}
+
+// buildTable flattens possibly overlapping ranges into segments. The winner of a segment is the most specific
+// range covering it: the one starting last, and of those the one ending first.
+func buildTable(ranges []ipRange, v4 bool) ipTable {
+	// the winner can only change where a range starts or right after one ends
+	bounds := make([]ipKey, 0, 2*len(ranges))
+	for _, r := range ranges {
+		bounds = append(bounds, r.start)
+		if next, ok := r.end.next(v4); ok {
+			bounds = append(bounds, next)
+		}
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bytes.Compare(bounds[i][:], bounds[j][:]) < 0 })
+	sort.Slice(ranges, func(i, j int) bool { return bytes.Compare(ranges[i].start[:], ranges[j].start[:]) < 0 })
+
+	var table ipTable
+	active := &rangeHeap{}
+	next := 0
+	for i, bound := range bounds {
+		if i > 0 && bound == bounds[i-1] {
+			continue
+		}
+		for next < len(ranges) && bytes.Compare(ranges[next].start[:], bound[:]) <= 0 {
+			heap.Push(active, ranges[next])
+			next++
+		}
+		// ranges that ended before bound can't win anymore
+		for active.Len() > 0 && bytes.Compare((*active)[0].end[:], bound[:]) < 0 {
+			heap.Pop(active)
+		}
+
+		row := int32(-1)
+		if active.Len() > 0 {
+			row = (*active)[0].row
+		}
+		if n := len(table.ranges); n > 0 && table.ranges[n-1] == row {
+			continue // same winner as the previous segment
+		}
+		table.starts = append(table.starts, bound)
+		table.ranges = append(table.ranges, row)
+	}
+	return table
+}
+
+// rangeHeap orders the ranges by specificity, the most specific one first
+type rangeHeap []ipRange
+
+func (h rangeHeap) Len() int { return len(h) }
+
+func (h rangeHeap) Less(i, j int) bool {
+	if c := bytes.Compare(h[i].start[:], h[j].start[:]); c != 0 {
+		return c > 0
+	}
+	return bytes.Compare(h[i].end[:], h[j].end[:]) < 0
+}
+
+func (h rangeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *rangeHeap) Push(x interface{}) { *h = append(*h, x.(ipRange)) }
+
+func (h *rangeHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
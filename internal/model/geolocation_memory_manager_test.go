@@ -0,0 +1,211 @@
+package model
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func sliceSource(rows []*Geolocation) GeoLocationSource {
+	return func(ctx context.Context, fn func(*Geolocation) error) error {
+		for _, g := range rows {
+			if err := fn(g); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestMemoryFindDataByIP(t *testing.T) {
+	rows := []*Geolocation{
+		{IP: "10.0.0.0/8", StartIP: "10.0.0.0", EndIP: "10.255.255.255", City: "Delhi"},
+		{IP: "10.1.0.0/16", StartIP: "10.1.0.0", EndIP: "10.1.255.255", City: "Pune"},
+		{IP: "10.1.2.3", StartIP: "10.1.2.3", EndIP: "10.1.2.3", City: "Surat"},
+		{IP: "10.1.0.0-10.3.0.0", StartIP: "10.1.0.0", EndIP: "10.3.0.0", City: "Agra"},
+		{IP: "255.255.255.0/24", StartIP: "255.255.255.0", EndIP: "255.255.255.255", City: "Last"},
+		{IP: "2001:db8::/32", StartIP: "2001:db8::", EndIP: "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", City: "Berlin"},
+	}
+
+	cases := []struct {
+		Name          string
+		Ip            string
+		ExpectedCity  string
+		ExpectedError error
+	}{
+		{
+			Name:         "single ip",
+			Ip:           "10.1.2.3",
+			ExpectedCity: "Surat",
+		},
+		{
+			Name:         "next to a single ip",
+			Ip:           "10.1.2.4",
+			ExpectedCity: "Pune",
+		},
+		{
+			Name:         "network starting with an equal range",
+			Ip:           "10.1.0.0",
+			ExpectedCity: "Pune",
+		},
+		{
+			Name:         "range after the nested network",
+			Ip:           "10.2.0.1",
+			ExpectedCity: "Agra",
+		},
+		{
+			Name:         "outer network after the range",
+			Ip:           "10.3.0.1",
+			ExpectedCity: "Delhi",
+		},
+		{
+			Name:         "last address",
+			Ip:           "255.255.255.255",
+			ExpectedCity: "Last",
+		},
+		{
+			Name:         "ipv6",
+			Ip:           "2001:db8::1",
+			ExpectedCity: "Berlin",
+		},
+		{
+			Name:          "gap",
+			Ip:            "11.0.0.1",
+			ExpectedError: router.NewHttpError("data not found with given ip", 404),
+		},
+		{
+			Name:          "before every range",
+			Ip:            "1.0.0.1",
+			ExpectedError: router.NewHttpError("data not found with given ip", 404),
+		},
+		{
+			Name:          "ipv6 gap",
+			Ip:            "2001:db9::1",
+			ExpectedError: router.NewHttpError("data not found with given ip", 404),
+		},
+		{
+			Name:          "invalid ip",
+			Ip:            "10.1.2",
+			ExpectedError: router.NewHttpError("invalid ip", 400),
+		},
+	}
+
+	manager, err := NewMemoryGeoLocationManager(context.TODO(), sliceSource(rows))
+	if err != nil {
+		t.Fatalf("Error loading the index %v", err)
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			resp, err := manager.FindDataByIP(context.TODO(), tt.Ip, time.Time{})
+			if tt.ExpectedError != nil {
+				assert.Equal(tt.ExpectedError, err)
+				return
+			}
+			assert.Nil(err)
+			assert.Equal(tt.ExpectedCity, resp.City)
+		})
+	}
+}
+
+func TestMemoryReload(t *testing.T) {
+	assert := assert.New(t)
+	rows := []*Geolocation{
+		{IP: "10.1.2.3", StartIP: "10.1.2.3", EndIP: "10.1.2.3", City: "Surat"},
+	}
+
+	manager, err := NewMemoryGeoLocationManager(context.TODO(), func(ctx context.Context, fn func(*Geolocation) error) error {
+		return sliceSource(rows)(ctx, fn)
+	})
+	assert.Nil(err)
+	count, _ := manager.Loaded()
+	assert.Equal(1, count)
+
+	rows = append(rows, &Geolocation{IP: "10.1.2.4", StartIP: "10.1.2.4", EndIP: "10.1.2.4", City: "Pune"})
+	_, err = manager.FindDataByIP(context.TODO(), "10.1.2.4", time.Time{})
+	assert.Equal(router.NewHttpError("data not found with given ip", 404), err)
+
+	assert.Nil(manager.Reload(context.TODO()))
+	count, _ = manager.Loaded()
+	assert.Equal(2, count)
+	resp, err := manager.FindDataByIP(context.TODO(), "10.1.2.4", time.Time{})
+	assert.Nil(err)
+	assert.Equal("Pune", resp.City)
+
+	// a failing reload keeps the loaded index
+	rows = append(rows, &Geolocation{IP: "broken", StartIP: "10.1.2.9", EndIP: "10.1.2.5"})
+	assert.NotNil(manager.Reload(context.TODO()))
+	count, _ = manager.Loaded()
+	assert.Equal(2, count)
+
+	assert.ErrorIs(manager.BulkInsert(context.TODO(), nil), ErrReadOnly)
+
+	// the history is only kept in the database
+	_, err = manager.FindDataByIP(context.TODO(), "10.1.2.4", time.Now())
+	assert.Equal(router.NewHttpError("as-of lookups are not supported by the memory lookup backend", 501), err)
+}
+
+func TestSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	rows := []*Geolocation{
+		{IP: "10.1.0.0/16", StartIP: "10.1.0.0", EndIP: "10.1.255.255", Country: "India", CountryCode: "IN", City: "Pune", Latitude: 18.52, Longitude: 73.85, MysteryValue: "1"},
+		{IP: "2001:db8::/32", StartIP: "2001:db8::", EndIP: "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", City: "Berlin"},
+	}
+	path := filepath.Join(t.TempDir(), "geolocations.snapshot")
+
+	n, err := WriteSnapshot(context.TODO(), sliceSource(rows), path)
+	assert.Nil(err)
+	assert.Equal(int64(2), n)
+
+	var read []*Geolocation
+	err = NewSnapshotSource(path)(context.TODO(), func(g *Geolocation) error {
+		read = append(read, g)
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(rows, read)
+
+	assert.Nil(os.WriteFile(path, []byte("not a snapshot"), 0o644))
+	err = NewSnapshotSource(path)(context.TODO(), func(g *Geolocation) error { return nil })
+	assert.ErrorIs(err, ErrInvalidSnapshot)
+}
+
+func TestMemoryIndexSharesLocations(t *testing.T) {
+	assert := assert.New(t)
+	rows := []*Geolocation{
+		{IP: "10.1.0.0/16", StartIP: "10.1.0.0", EndIP: "10.1.255.255", Country: "India", CountryCode: "IN", City: "Pune", Latitude: 18.52, Longitude: 73.85, MysteryValue: "1"},
+		{IP: "10.2.0.0-10.2.0.9", StartIP: "10.2.0.0", EndIP: "10.2.0.9", Country: "India", CountryCode: "IN", City: "Pune", Latitude: 18.52, Longitude: 73.85, MysteryValue: "1"},
+		{IP: "10.3.0.1", StartIP: "10.3.0.1", EndIP: "10.3.0.1", Country: "India", CountryCode: "IN", City: "Surat", Latitude: 21.17, Longitude: 72.83, MysteryValue: "2"},
+		{IP: "2001:db8::/32", StartIP: "2001:db8::", EndIP: "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", City: "Berlin"},
+	}
+	for _, g := range rows {
+		g.ID = uuid.New()
+	}
+
+	b := newIPIndexBuilder()
+	for _, g := range rows {
+		if err := b.Add(g); err != nil {
+			t.Fatalf("Error adding %s %v", g.IP, err)
+		}
+	}
+	index := b.Build()
+	assert.Len(index.ranges, 4)
+	assert.Len(index.locations, 3)
+
+	// the rows are built back as they were loaded
+	for _, g := range rows {
+		found, ok := index.Find(net.ParseIP(g.StartIP))
+		assert.True(ok)
+		assert.Equal(g, found)
+	}
+}
@@ -0,0 +1,278 @@
+package model
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ohmpatel1997/findhotel/internal/common"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+)
+
+const (
+	SortIP        = "ip"
+	SortCountry   = "country"
+	SortCity      = "city"
+	SortLatitude  = "latitude"
+	SortLongitude = "longitude"
+
+	DefaultListLimit = 100
+	MaxListLimit     = 1000
+)
+
+// BoundingBox is an area of coordinates, it crosses the antimeridian when MinLongitude is greater than MaxLongitude
+type BoundingBox struct {
+	MinLatitude  float64
+	MinLongitude float64
+	MaxLatitude  float64
+	MaxLongitude float64
+}
+
+// GeolocationQuery selects a page of the stored geolocations. The filters that are set must all match.
+type GeolocationQuery struct {
+	CountryCode string
+	Country     string // compared case insensitively, like City
+	City        string
+	BoundingBox *BoundingBox
+
+	Sort   string // one of the Sort constants, SortIP by default; ties are broken by id
+	Desc   bool
+	Limit  int    // DefaultListLimit when zero
+	Cursor string // the NextCursor of the previous page, empty for the first one
+}
+
+type GeolocationPage struct {
+	Geolocations []*Geolocation
+	NextCursor   string // empty on the last page
+}
+
+// listCursor is the position after the last row of a page, in the order of the query. Pages are read by
+// comparing with it rather than by offset, so they stay stable while rows are inserted before them.
+type listCursor struct {
+	Sort    string    `json:"s"`
+	Desc    bool      `json:"d,omitempty"`
+	Text    string    `json:"t,omitempty"`
+	Number  float64   `json:"n,omitempty"`
+	StartIP string    `json:"a,omitempty"`
+	EndIP   string    `json:"b,omitempty"`
+	ID      uuid.UUID `json:"i"`
+}
+
+func errInvalidCursor() error {
+	return router.NewHttpError("invalid cursor", 400)
+}
+
+// normalizeQuery fills in the defaults of q, and rejects a sort order the backends don't know
+func normalizeQuery(q *GeolocationQuery) (*GeolocationQuery, error) {
+	norm := *q
+	if norm.Sort == "" {
+		norm.Sort = SortIP
+	}
+	switch norm.Sort {
+	case SortIP, SortCountry, SortCity, SortLatitude, SortLongitude:
+	default:
+		return nil, router.NewHttpError("unknown sort "+norm.Sort, 400)
+	}
+	if norm.Limit <= 0 {
+		norm.Limit = DefaultListLimit
+	}
+	if norm.Limit > MaxListLimit {
+		norm.Limit = MaxListLimit
+	}
+	norm.CountryCode = countryKey(norm.CountryCode)
+	norm.Country = strings.TrimSpace(norm.Country)
+	norm.City = strings.Join(strings.Fields(norm.City), " ")
+	return &norm, nil
+}
+
+// parseCursor returns the cursor of q, nil on the first page. A cursor of another sort order is rejected.
+func parseCursor(q *GeolocationQuery) (*listCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, errInvalidCursor()
+	}
+	c := new(listCursor)
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, errInvalidCursor()
+	}
+	if c.Sort != q.Sort || c.Desc != q.Desc {
+		return nil, errInvalidCursor()
+	}
+	if c.Sort == SortIP && (net.ParseIP(c.StartIP) == nil || net.ParseIP(c.EndIP) == nil) {
+		return nil, errInvalidCursor()
+	}
+	return c, nil
+}
+
+// cursorAfter returns the cursor of the page following last
+func cursorAfter(q *GeolocationQuery, last *Geolocation) string {
+	c := listCursor{Sort: q.Sort, Desc: q.Desc, ID: last.ID}
+	switch q.Sort {
+	case SortIP:
+		c.StartIP, c.EndIP = last.StartIP, last.EndIP
+	case SortCountry:
+		c.Text = last.Country
+	case SortCity:
+		c.Text = last.City
+	case SortLatitude:
+		c.Number = last.Latitude
+	case SortLongitude:
+		c.Number = last.Longitude
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// newPage returns the page of rows, which were read with one row more than the limit to know if another page follows
+func newPage(q *GeolocationQuery, rows []*Geolocation) *GeolocationPage {
+	page := &GeolocationPage{Geolocations: rows}
+	if len(rows) > q.Limit {
+		page.Geolocations = rows[:q.Limit]
+		page.NextCursor = cursorAfter(q, rows[q.Limit-1])
+	}
+	return page
+}
+
+// listFilters returns the conditions of the filters of q with ? placeholders, the same for every backend. The country
+// code and city filters go through the reference tables, so that they are read from the country_id and city_id
+// indexes. The country filter compares the country of the rows, which is what they are listed with, rather than the
+// name of the reference, which is the one of the first import.
+func listFilters(q *GeolocationQuery) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if q.CountryCode != "" {
+		conds = append(conds, "country_id = (SELECT id FROM countries WHERE code = ?)")
+		args = append(args, q.CountryCode)
+	}
+	if q.Country != "" {
+		conds = append(conds, "lower(trim(country)) = lower(?)")
+		args = append(args, strings.TrimSpace(q.Country))
+	}
+	if q.City != "" {
+		conds = append(conds, "city_id IN (SELECT id FROM cities WHERE name_key = ?)")
+		args = append(args, common.CityKey(q.City))
+	}
+	if box := q.BoundingBox; box != nil {
+		conds = append(conds, "latitude BETWEEN ? AND ?")
+		args = append(args, box.MinLatitude, box.MaxLatitude)
+		if box.MinLongitude <= box.MaxLongitude {
+			conds = append(conds, "longitude BETWEEN ? AND ?")
+		} else {
+			conds = append(conds, "(longitude >= ? OR longitude <= ?)")
+		}
+		args = append(args, box.MinLongitude, box.MaxLongitude)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// sortOrder returns the ORDER BY of the sort columns, all in the direction of q
+func sortOrder(columns []string, desc bool) []string {
+	order := make([]string, 0, len(columns))
+	for _, col := range columns {
+		if desc {
+			order = append(order, col+" DESC")
+			continue
+		}
+		order = append(order, col+" ASC")
+	}
+	return order
+}
+
+// keysetCondition returns the condition of the rows after the cursor, comparing the row of columns with values
+func keysetCondition(columns, values []string, desc bool) string {
+	op := " > "
+	if desc {
+		op = " < "
+	}
+	return "(" + strings.Join(columns, ", ") + ")" + op + "(" + strings.Join(values, ", ") + ")"
+}
+
+// matches tells whether g passes the filters of q, like listFilters does in the databases
+func (q *GeolocationQuery) matches(g *Geolocation) bool {
+	if q.CountryCode != "" && countryKey(g.CountryCode) != q.CountryCode {
+		return false
+	}
+	// trim() of the databases only trims spaces
+	if q.Country != "" && !strings.EqualFold(strings.Trim(g.Country, " "), strings.TrimSpace(q.Country)) {
+		return false
+	}
+	// the cities are those of a country, the rows without a country code have none
+	if q.City != "" && (countryKey(g.CountryCode) == "" || common.CityKey(g.City) != common.CityKey(q.City)) {
+		return false
+	}
+	if box := q.BoundingBox; box != nil {
+		if g.Latitude < box.MinLatitude || g.Latitude > box.MaxLatitude {
+			return false
+		}
+		if box.MinLongitude <= box.MaxLongitude {
+			return g.Longitude >= box.MinLongitude && g.Longitude <= box.MaxLongitude
+		}
+		return g.Longitude >= box.MinLongitude || g.Longitude <= box.MaxLongitude
+	}
+	return true
+}
+
+// compare orders a and b by the sort of q, ascending, ties broken by id
+func (q *GeolocationQuery) compare(a, b *Geolocation) int {
+	var c int
+	switch q.Sort {
+	case SortIP:
+		c = compareRanges(a.StartIP, a.EndIP, b.StartIP, b.EndIP)
+	case SortCountry:
+		c = strings.Compare(a.Country, b.Country)
+	case SortCity:
+		c = strings.Compare(a.City, b.City)
+	case SortLatitude:
+		c = compareFloats(a.Latitude, b.Latitude)
+	case SortLongitude:
+		c = compareFloats(a.Longitude, b.Longitude)
+	}
+	if c != 0 {
+		return c
+	}
+	return bytes.Compare(a.ID[:], b.ID[:])
+}
+
+// afterCursor tells whether g comes after the cursor in the order of q
+func (q *GeolocationQuery) afterCursor(c *listCursor, g *Geolocation) bool {
+	last := &Geolocation{ID: c.ID, StartIP: c.StartIP, EndIP: c.EndIP, Country: c.Text, City: c.Text, Latitude: c.Number, Longitude: c.Number}
+	cmp := q.compare(g, last)
+	if q.Desc {
+		return cmp < 0
+	}
+	return cmp > 0
+}
+
+// compareRanges orders ranges like the databases do: ipv4 first, then by start and end
+func compareRanges(aStart, aEnd, bStart, bEnd string) int {
+	as, av4 := toKey(net.ParseIP(aStart))
+	bs, bv4 := toKey(net.ParseIP(bStart))
+	if av4 != bv4 {
+		if av4 {
+			return -1
+		}
+		return 1
+	}
+	if c := bytes.Compare(as[:], bs[:]); c != 0 {
+		return c
+	}
+	ae, _ := toKey(net.ParseIP(aEnd))
+	be, _ := toKey(net.ParseIP(bEnd))
+	return bytes.Compare(ae[:], be[:])
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,208 @@
+package model
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/stretchr/testify/assert"
+)
+
+// listCities pages through every geolocation matching q and returns their cities in order
+func listCities(manager GeoLocationManager, q GeolocationQuery) ([]string, error) {
+	var cities []string
+	for {
+		page, err := manager.List(context.TODO(), &q)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range page.Geolocations {
+			cities = append(cities, g.City)
+		}
+		if page.NextCursor == "" {
+			return cities, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+func TestList(t *testing.T) {
+	rows := []*Geolocation{
+		{IP: "2001:db8::/32", StartIP: "2001:db8::", EndIP: "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", CountryCode: "DE", Country: "Germany", City: "Berlin", Latitude: 52.5, Longitude: 13.4},
+		{IP: "192.168.0.1", StartIP: "192.168.0.1", EndIP: "192.168.0.1", CountryCode: "DE", Country: "Germany", City: "Munich", Latitude: 48.1, Longitude: 11.6},
+		{IP: "10.1.2.3", StartIP: "10.1.2.3", EndIP: "10.1.2.3", CountryCode: "IN", Country: "India", City: "Surat", Latitude: 21.2, Longitude: 72.8},
+		{IP: "10.1.0.0/16", StartIP: "10.1.0.0", EndIP: "10.1.255.255", CountryCode: "IN", Country: "India", City: "Pune", Latitude: 18.5, Longitude: 73.8},
+		{IP: "10.0.0.0/8", StartIP: "10.0.0.0", EndIP: "10.255.255.255", CountryCode: "IN", Country: "India", City: "Delhi", Latitude: 28.6, Longitude: 77.2},
+		{IP: "172.16.0.1", StartIP: "172.16.0.1", EndIP: "172.16.0.1", CountryCode: "FJ", Country: "Fiji", City: "Suva", Latitude: -18.1, Longitude: 178.4},
+		{IP: "172.16.0.2", StartIP: "172.16.0.2", EndIP: "172.16.0.2", CountryCode: "WS", Country: "Samoa", City: "Apia", Latitude: -13.8, Longitude: -171.8},
+	}
+	for _, g := range rows {
+		g.ID = uuid.New()
+	}
+
+	memory, err := NewMemoryGeoLocationManager(context.TODO(), sliceSource(rows))
+	if err != nil {
+		t.Fatalf("Error loading the index %v", err)
+	}
+	sqlite := NewSQLiteGeoLocationManager(newSQLiteDB(t))
+	if err := sqlite.BulkInsert(context.TODO(), rows); err != nil {
+		t.Fatalf("Error inserting rows %v", err)
+	}
+	managers := map[string]GeoLocationManager{"memory": memory, "sqlite": sqlite}
+
+	cases := []struct {
+		Name           string
+		Query          GeolocationQuery
+		ExpectedCities []string
+		ExpectedError  error
+	}{
+		{
+			Name:           "by ip, ipv4 first",
+			Query:          GeolocationQuery{Limit: 2},
+			ExpectedCities: []string{"Delhi", "Pune", "Surat", "Suva", "Apia", "Munich", "Berlin"},
+		},
+		{
+			Name:           "by ip descending",
+			Query:          GeolocationQuery{Desc: true, Limit: 3},
+			ExpectedCities: []string{"Berlin", "Munich", "Apia", "Suva", "Surat", "Pune", "Delhi"},
+		},
+		{
+			Name:           "by latitude descending",
+			Query:          GeolocationQuery{Sort: SortLatitude, Desc: true, Limit: 2},
+			ExpectedCities: []string{"Berlin", "Munich", "Delhi", "Surat", "Pune", "Apia", "Suva"},
+		},
+		{
+			Name:           "by city",
+			Query:          GeolocationQuery{Sort: SortCity, Limit: 4},
+			ExpectedCities: []string{"Apia", "Berlin", "Delhi", "Munich", "Pune", "Surat", "Suva"},
+		},
+		{
+			Name:           "country code",
+			Query:          GeolocationQuery{CountryCode: "de", Limit: 1},
+			ExpectedCities: []string{"Munich", "Berlin"},
+		},
+		{
+			Name:           "country and city case insensitive",
+			Query:          GeolocationQuery{Country: "INDIA", City: " pune "},
+			ExpectedCities: []string{"Pune"},
+		},
+		{
+			Name:           "bounding box",
+			Query:          GeolocationQuery{BoundingBox: &BoundingBox{MinLatitude: 15, MinLongitude: 70, MaxLatitude: 30, MaxLongitude: 80}, Limit: 2},
+			ExpectedCities: []string{"Delhi", "Pune", "Surat"},
+		},
+		{
+			Name:           "bounding box across the antimeridian",
+			Query:          GeolocationQuery{BoundingBox: &BoundingBox{MinLatitude: -20, MinLongitude: 170, MaxLatitude: -10, MaxLongitude: -170}},
+			ExpectedCities: []string{"Suva", "Apia"},
+		},
+		{
+			Name:  "no match",
+			Query: GeolocationQuery{CountryCode: "FR"},
+		},
+		{
+			Name:          "unknown sort",
+			Query:         GeolocationQuery{Sort: "mystery_value"},
+			ExpectedError: router.NewHttpError("unknown sort mystery_value", 400),
+		},
+		{
+			Name:          "cursor of another sort",
+			Query:         GeolocationQuery{Sort: SortCity, Cursor: cursorAfter(&GeolocationQuery{Sort: SortIP}, rows[0])},
+			ExpectedError: router.NewHttpError("invalid cursor", 400),
+		},
+		{
+			Name:          "garbled cursor",
+			Query:         GeolocationQuery{Cursor: "not a cursor"},
+			ExpectedError: router.NewHttpError("invalid cursor", 400),
+		},
+	}
+
+	for name, manager := range managers {
+		for _, tt := range cases {
+			tt := tt
+			manager := manager
+			t.Run(name+"/"+tt.Name, func(t *testing.T) {
+				t.Parallel()
+				assert := assert.New(t)
+				cities, err := listCities(manager, tt.Query)
+				if tt.ExpectedError != nil {
+					assert.Equal(tt.ExpectedError, err)
+					return
+				}
+				assert.Nil(err)
+				assert.Equal(tt.ExpectedCities, cities)
+			})
+		}
+	}
+}
+
+// TestListCountryAsImported filters by the country of the rows, which may differ from the name of their reference
+func TestListCountryAsImported(t *testing.T) {
+	rows := []*Geolocation{
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", CountryCode: "DE", Country: "Germany", City: "Berlin", Latitude: 52.5, Longitude: 13.4},
+		{IP: "10.0.0.2", StartIP: "10.0.0.2", EndIP: "10.0.0.2", CountryCode: "DE", Country: "Deutschland ", City: "Munich", Latitude: 48.1, Longitude: 11.6},
+	}
+	for _, g := range rows {
+		g.ID = uuid.New()
+	}
+
+	memory, err := NewMemoryGeoLocationManager(context.TODO(), sliceSource(rows))
+	if err != nil {
+		t.Fatalf("Error loading the index %v", err)
+	}
+	sqlite := NewSQLiteGeoLocationManager(newSQLiteDB(t))
+	if err := sqlite.BulkInsert(context.TODO(), rows); err != nil {
+		t.Fatalf("Error inserting rows %v", err)
+	}
+	managers := map[string]GeoLocationManager{"memory": memory, "sqlite": sqlite}
+
+	cases := []struct {
+		Name           string
+		Country        string
+		ExpectedCities []string
+	}{
+		{Name: "name of the reference", Country: "germany", ExpectedCities: []string{"Berlin"}},
+		{Name: "name of a later row", Country: " DEUTSCHLAND", ExpectedCities: []string{"Munich"}},
+	}
+
+	for name, manager := range managers {
+		for _, tt := range cases {
+			tt := tt
+			manager := manager
+			t.Run(name+"/"+tt.Name, func(t *testing.T) {
+				t.Parallel()
+				assert := assert.New(t)
+				cities, err := listCities(manager, GeolocationQuery{Country: tt.Country})
+				assert.Nil(err)
+				assert.Equal(tt.ExpectedCities, cities)
+			})
+		}
+	}
+}
+
+func TestListStableAcrossInserts(t *testing.T) {
+	assert := assert.New(t)
+	manager := NewSQLiteGeoLocationManager(newSQLiteDB(t))
+	ctx := context.TODO()
+
+	assert.Nil(manager.BulkInsert(ctx, []*Geolocation{
+		{IP: "10.0.0.2", StartIP: "10.0.0.2", EndIP: "10.0.0.2", City: "Pune"},
+		{IP: "10.0.0.4", StartIP: "10.0.0.4", EndIP: "10.0.0.4", City: "Surat"},
+		{IP: "10.0.0.6", StartIP: "10.0.0.6", EndIP: "10.0.0.6", City: "Agra"},
+	}))
+	page, err := manager.List(ctx, &GeolocationQuery{Limit: 2})
+	assert.Nil(err)
+	assert.Len(page.Geolocations, 2)
+
+	// rows inserted before the cursor neither shift the next page nor show up in it
+	assert.Nil(manager.BulkInsert(ctx, []*Geolocation{
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", City: "Delhi"},
+		{IP: "10.0.0.5", StartIP: "10.0.0.5", EndIP: "10.0.0.5", City: "Goa"},
+	}))
+	page, err = manager.List(ctx, &GeolocationQuery{Limit: 2, Cursor: page.NextCursor})
+	assert.Nil(err)
+	assert.Equal("Goa", page.Geolocations[0].City)
+	assert.Equal("Agra", page.Geolocations[1].City)
+	assert.Empty(page.NextCursor)
+}
@@ -0,0 +1,253 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+)
+
+const (
+	defaultReplicaMaxLag        = 30 * time.Second
+	defaultReplicaCheckInterval = 5 * time.Second
+	defaultReplicaCheckTimeout  = 2 * time.Second
+)
+
+// ReplicationLag returns how far a replica is behind its primary, it fails when the replica can't be reached
+type ReplicationLag func(ctx context.Context) (time.Duration, error)
+
+// Replica is a read-only copy of the database the lookups can be sent to
+type Replica struct {
+	Name         string
+	GeoLocations GeoLocationManager
+	Lag          ReplicationLag
+}
+
+type ReplicaOptions struct {
+	MaxLag        time.Duration // replicas further behind are taken out of rotation
+	CheckInterval time.Duration // how often Run checks the replicas
+	CheckTimeout  time.Duration
+}
+
+type ReplicaStatus struct {
+	Name      string
+	Healthy   bool
+	Lag       time.Duration
+	Err       error // why the replica is out of rotation
+	CheckedAt time.Time
+}
+
+// ReplicatedGeoLocationManager is a GeoLocationManager sending the lookups to replicas of the database
+type ReplicatedGeoLocationManager interface {
+	GeoLocationManager
+	// Check updates the health of every replica once
+	Check(ctx context.Context)
+	// Run checks the replicas every CheckInterval until ctx is done
+	Run(ctx context.Context)
+	Status() []ReplicaStatus
+}
+
+type replicaState struct {
+	replica *Replica
+
+	mu     sync.Mutex
+	status ReplicaStatus
+}
+
+type replicatedManager struct {
+	primary  GeoLocationManager
+	replicas []*replicaState
+	opts     ReplicaOptions
+	next     uint32 // round robin over the healthy replicas
+}
+
+// NewReplicatedGeoLocationManager balances the lookups across the healthy replicas and falls back to primary when
+// there is none. A replica is out of rotation until its first check, when it lags more than MaxLag, and after a
+// lookup on it failed. Writes always go to primary.
+func NewReplicatedGeoLocationManager(primary GeoLocationManager, replicas []*Replica, opts ReplicaOptions) ReplicatedGeoLocationManager {
+	if opts.MaxLag <= 0 {
+		opts.MaxLag = defaultReplicaMaxLag
+	}
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = defaultReplicaCheckInterval
+	}
+	if opts.CheckTimeout <= 0 {
+		opts.CheckTimeout = defaultReplicaCheckTimeout
+	}
+
+	m := &replicatedManager{
+		primary: primary,
+		opts:    opts,
+	}
+	for _, r := range replicas {
+		m.replicas = append(m.replicas, &replicaState{
+			replica: r,
+			status:  ReplicaStatus{Name: r.Name, Err: errors.New("not checked yet")},
+		})
+	}
+	return m
+}
+
+func (m *replicatedManager) FindDataByIP(ctx context.Context, ip string, asOf time.Time) (*Geolocation, error) {
+	var geo *Geolocation
+	err := m.read(ctx, func(manager GeoLocationManager) error {
+		var err error
+		geo, err = manager.FindDataByIP(ctx, ip, asOf)
+		return err
+	})
+	return geo, err
+}
+
+func (m *replicatedManager) FindDataByIPs(ctx context.Context, ips []string) ([]*IPLookup, error) {
+	var lookups []*IPLookup
+	err := m.read(ctx, func(manager GeoLocationManager) error {
+		var err error
+		lookups, err = manager.FindDataByIPs(ctx, ips)
+		return err
+	})
+	return lookups, err
+}
+
+func (m *replicatedManager) List(ctx context.Context, query *GeolocationQuery) (*GeolocationPage, error) {
+	var page *GeolocationPage
+	err := m.read(ctx, func(manager GeoLocationManager) error {
+		var err error
+		page, err = manager.List(ctx, query)
+		return err
+	})
+	return page, err
+}
+
+// read runs fn on the next healthy replica, on the following ones while it fails, and on the primary if none is left
+func (m *replicatedManager) read(ctx context.Context, fn func(GeoLocationManager) error) error {
+	healthy := m.healthy()
+	if len(healthy) > 0 {
+		start := int(atomic.AddUint32(&m.next, 1))
+		for i := range healthy {
+			r := healthy[(start+i)%len(healthy)]
+			err := fn(r.replica.GeoLocations)
+			if !isServerError(err) {
+				return err
+			}
+			if ctx.Err() != nil {
+				return err
+			}
+			r.fail(err)
+		}
+	}
+	return fn(m.primary)
+}
+
+func (m *replicatedManager) BulkInsert(ctx context.Context, geolocation []*Geolocation) error {
+	return m.primary.BulkInsert(ctx, geolocation)
+}
+
+func (m *replicatedManager) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range m.replicas {
+		wg.Add(1)
+		go func(r *replicaState) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, m.opts.CheckTimeout)
+			defer cancel()
+
+			lag, err := r.replica.Lag(checkCtx)
+			if err == nil && lag > m.opts.MaxLag {
+				err = fmt.Errorf("replica is %s behind, more than %s", lag, m.opts.MaxLag)
+			}
+			r.mu.Lock()
+			r.status = ReplicaStatus{Name: r.replica.Name, Healthy: err == nil, Lag: lag, Err: err, CheckedAt: time.Now()}
+			r.mu.Unlock()
+		}(r)
+	}
+	wg.Wait()
+}
+
+func (m *replicatedManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		m.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *replicatedManager) Status() []ReplicaStatus {
+	status := make([]ReplicaStatus, 0, len(m.replicas))
+	for _, r := range m.replicas {
+		r.mu.Lock()
+		status = append(status, r.status)
+		r.mu.Unlock()
+	}
+	return status
+}
+
+func (m *replicatedManager) healthy() []*replicaState {
+	var healthy []*replicaState
+	for _, r := range m.replicas {
+		r.mu.Lock()
+		if r.status.Healthy {
+			healthy = append(healthy, r)
+		}
+		r.mu.Unlock()
+	}
+	return healthy
+}
+
+// fail takes the replica out of rotation until the next check
+func (r *replicaState) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Healthy = false
+	r.status.Err = err
+}
+
+// isServerError tells whether err is a failure of the backend rather than an answer, like a not found ip
+func isServerError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var httpErr *router.HttpError
+	if errors.As(err, &httpErr) {
+		return httpErr.Status >= 500
+	}
+	return true
+}
+
+// errReplicaNotStreaming is the check of a replica without a WAL receiver streaming from the primary, which replayed
+// everything it received but may be arbitrarily behind
+var errReplicaNotStreaming = errors.New("replica isn't streaming from the primary")
+
+// NewPostgresReplicationLag measures the lag of a postgres streaming replica. A replica that replayed everything it
+// received isn't behind, however long ago the primary last wrote, as long as its WAL receiver is streaming: one whose
+// receiver is down fails the check. The status of the receiver is only visible with pg_read_all_stats, without it a
+// running receiver is taken to be streaming.
+func NewPostgresReplicationLag(db *pg.DB) ReplicationLag {
+	return func(ctx context.Context) (time.Duration, error) {
+		var inRecovery, streaming bool
+		var lagSeconds float64
+		_, err := db.QueryOneContext(ctx, pg.Scan(&inRecovery, &streaming, &lagSeconds), `
+			SELECT pg_is_in_recovery(),
+				EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE COALESCE(status, 'streaming') = 'streaming'),
+				CASE WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+				ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+				END`)
+		if err != nil {
+			return 0, err
+		}
+		if inRecovery && !streaming {
+			return 0, errReplicaNotStreaming
+		}
+		return time.Duration(lagSeconds * float64(time.Second)), nil
+	}
+}
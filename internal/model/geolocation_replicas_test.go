@@ -0,0 +1,115 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func fixedLag(lag time.Duration, err error) ReplicationLag {
+	return func(ctx context.Context) (time.Duration, error) {
+		return lag, err
+	}
+}
+
+func TestReplicatedFindDataByIP(t *testing.T) {
+	rows := map[string]*Geolocation{"10.0.0.1": {IP: "10.0.0.1", City: "Pune"}}
+
+	cases := []struct {
+		Name            string
+		Lags            []ReplicationLag
+		ReplicaErr      error
+		Lookups         int
+		ExpectedPrimary int32
+		ExpectedReplica []int32
+		ExpectedHealthy []bool
+	}{
+		{
+			Name:            "balanced across replicas",
+			Lags:            []ReplicationLag{fixedLag(0, nil), fixedLag(time.Second, nil)},
+			Lookups:         4,
+			ExpectedReplica: []int32{2, 2},
+			ExpectedHealthy: []bool{true, true},
+		},
+		{
+			Name:            "lagging replica out of rotation",
+			Lags:            []ReplicationLag{fixedLag(0, nil), fixedLag(time.Minute, nil)},
+			Lookups:         3,
+			ExpectedReplica: []int32{3, 0},
+			ExpectedHealthy: []bool{true, false},
+		},
+		{
+			Name:            "down replicas fall back to the primary",
+			Lags:            []ReplicationLag{fixedLag(0, errors.New("connection refused")), fixedLag(0, errors.New("connection refused"))},
+			Lookups:         2,
+			ExpectedPrimary: 2,
+			ExpectedReplica: []int32{0, 0},
+			ExpectedHealthy: []bool{false, false},
+		},
+		{
+			Name:            "failing lookups take replicas out of rotation",
+			Lags:            []ReplicationLag{fixedLag(0, nil), fixedLag(0, nil)},
+			ReplicaErr:      router.NewHttpError("connection reset", 500),
+			Lookups:         2,
+			ExpectedPrimary: 2,
+			ExpectedReplica: []int32{1, 1},
+			ExpectedHealthy: []bool{false, false},
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			primary := &countingManager{rows: rows}
+			var replicas []*Replica
+			var managers []*countingManager
+			for i, lag := range tt.Lags {
+				manager := &countingManager{rows: rows, err: tt.ReplicaErr}
+				managers = append(managers, manager)
+				replicas = append(replicas, &Replica{Name: string(rune('a' + i)), GeoLocations: manager, Lag: lag})
+			}
+			m := NewReplicatedGeoLocationManager(primary, replicas, ReplicaOptions{MaxLag: 10 * time.Second})
+			m.Check(context.TODO())
+
+			for i := 0; i < tt.Lookups; i++ {
+				geo, err := m.FindDataByIP(context.TODO(), "10.0.0.1", time.Time{})
+				assert.Nil(err)
+				assert.Equal("Pune", geo.City)
+			}
+
+			assert.Equal(tt.ExpectedPrimary, primary.calls)
+			for i, manager := range managers {
+				assert.Equal(tt.ExpectedReplica[i], manager.calls)
+			}
+			for i, status := range m.Status() {
+				assert.Equal(tt.ExpectedHealthy[i], status.Healthy)
+			}
+		})
+	}
+}
+
+func TestReplicatedNotChecked(t *testing.T) {
+	assert := assert.New(t)
+	primary := &countingManager{rows: map[string]*Geolocation{}}
+	replica := &countingManager{rows: map[string]*Geolocation{}}
+	m := NewReplicatedGeoLocationManager(primary, []*Replica{{Name: "a", GeoLocations: replica, Lag: fixedLag(0, nil)}}, ReplicaOptions{})
+
+	// not found is an answer, not a failure of the replica
+	_, err := m.FindDataByIP(context.TODO(), "10.0.0.1", time.Time{})
+	assert.Equal(router.NewHttpError("data not found with given ip", 404), err)
+	assert.Equal(int32(1), primary.calls)
+
+	m.Check(context.TODO())
+	_, err = m.FindDataByIP(context.TODO(), "10.0.0.1", time.Time{})
+	assert.Equal(router.NewHttpError("data not found with given ip", 404), err)
+	assert.Equal(int32(1), replica.calls)
+	assert.True(m.Status()[0].Healthy)
+
+	assert.Nil(m.BulkInsert(context.TODO(), nil))
+}
@@ -0,0 +1,140 @@
+package model
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+const snapshotVersion = 1
+
+var (
+	ErrInvalidSnapshot = errors.New("invalid geolocation snapshot")
+)
+
+// GeoLocationSource calls fn for every geolocation of the dataset
+type GeoLocationSource func(ctx context.Context, fn func(*Geolocation) error) error
+
+// NewDBSource returns a source reading the geolocations table
+func NewDBSource(db *pg.DB) GeoLocationSource {
+	return func(ctx context.Context, fn func(*Geolocation) error) error {
+		var geo Geolocation
+		return db.ModelContext(ctx, &geo).ForEach(func(g *Geolocation) error {
+			return fn(g)
+		})
+	}
+}
+
+// snapshotHeader is the first value of a snapshot file, the rows follow it one by one
+type snapshotHeader struct {
+	Version   int
+	Rows      int64
+	CreatedAt time.Time
+}
+
+// NewSnapshotSource returns a source reading a snapshot file written by WriteSnapshot
+func NewSnapshotSource(path string) GeoLocationSource {
+	return func(ctx context.Context, fn func(*Geolocation) error) error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return readSnapshot(ctx, f, fn)
+	}
+}
+
+func readSnapshot(ctx context.Context, r io.Reader, fn func(*Geolocation) error) error {
+	zr, err := gzip.NewReader(bufio.NewReader(r))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	defer zr.Close()
+
+	dec := gob.NewDecoder(zr)
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	if header.Version != snapshotVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidSnapshot, header.Version)
+	}
+
+	for i := int64(0); i < header.Rows; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var geo Geolocation
+		if err := dec.Decode(&geo); err != nil {
+			return fmt.Errorf("%w: row %d: %v", ErrInvalidSnapshot, i, err)
+		}
+		if err := fn(&geo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteSnapshot writes every geolocation of src to path. The file is replaced atomically, so a running
+// api reloading it never sees a partial snapshot. It returns the number of rows written.
+func WriteSnapshot(ctx context.Context, src GeoLocationSource, path string) (int64, error) {
+	// the rows are counted before writing, the header has to come first
+	var rows []*Geolocation
+	err := src(ctx, func(g *Geolocation) error {
+		geo := *g
+		rows = append(rows, &geo)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name()) // fails once the file was renamed
+
+	if err := writeSnapshot(tmp, rows); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, err
+	}
+	return int64(len(rows)), nil
+}
+
+func writeSnapshot(w io.Writer, rows []*Geolocation) error {
+	bw := bufio.NewWriter(w)
+	zw := gzip.NewWriter(bw)
+	enc := gob.NewEncoder(zw)
+
+	header := snapshotHeader{Version: snapshotVersion, Rows: int64(len(rows)), CreatedAt: time.Now()}
+	if err := enc.Encode(&header); err != nil {
+		return err
+	}
+	for _, geo := range rows {
+		if err := enc.Encode(geo); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImportLedgerEntry records one input file of an import run, identified by its SHA-256
+type ImportLedgerEntry struct {
+	tableName struct{} `pg:"import_ledger"`
+
+	ID              uuid.UUID `pg:"id,pk,type:uuid,default:gen_random_uuid()"`
+	RunID           uuid.UUID `pg:"run_id,type:uuid"`
+	FileName        string    `pg:"file_name"`
+	SHA256          string    `pg:"sha256"`
+	SizeBytes       int64     `pg:"size_bytes"`
+	Status          string    `pg:"status"` // same values as ImportRun.Status
+	ValidCount      int64     `pg:"valid_count"`
+	InvalidCount    int64     `pg:"invalid_count"`
+	DurationSeconds float64   `pg:"duration_seconds"`
+	CreatedAt       time.Time `pg:"created_at,default:now()"`
+	FinishedAt      time.Time `pg:"finished_at"`
+}
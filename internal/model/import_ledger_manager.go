@@ -0,0 +1,77 @@
+package model
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-pg/pg/v10"
+)
+
+//go:generate mockery --name ImportLedgerManager --output=mocks
+type ImportLedgerManager interface {
+	// FindImported returns the completed entry with the given checksum, or nil if the file was never imported
+	FindImported(ctx context.Context, sha256 string) (*ImportLedgerEntry, error)
+	Create(ctx context.Context, entry *ImportLedgerEntry) error
+	Finish(ctx context.Context, entry *ImportLedgerEntry) error
+	List(ctx context.Context, limit int) ([]*ImportLedgerEntry, error)
+}
+
+type importLedgerManager struct {
+	db *pg.DB
+}
+
+func NewImportLedgerManager(db *pg.DB) ImportLedgerManager {
+	return &importLedgerManager{
+		db: db,
+	}
+}
+
+func (m *importLedgerManager) FindImported(ctx context.Context, sha256 string) (*ImportLedgerEntry, error) {
+	var entry ImportLedgerEntry
+
+	err := m.db.ModelContext(ctx, &entry).
+		Where("sha256 = ?", sha256).
+		Where("status = ?", ImportRunCompleted).
+		Order("created_at DESC").
+		Limit(1).
+		Select()
+	switch {
+	case errors.Is(err, pg.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (m *importLedgerManager) Create(ctx context.Context, entry *ImportLedgerEntry) error {
+	_, err := m.db.ModelContext(ctx, entry).Returning("*").Insert()
+	return err
+}
+
+func (m *importLedgerManager) Finish(ctx context.Context, entry *ImportLedgerEntry) error {
+	_, err := m.db.ModelContext(ctx, entry).
+		Set("status = ?status").
+		Set("valid_count = ?valid_count").
+		Set("invalid_count = ?invalid_count").
+		Set("duration_seconds = ?duration_seconds").
+		Set("finished_at = now()").
+		WherePK().
+		Returning("finished_at").
+		Update()
+	return err
+}
+
+func (m *importLedgerManager) List(ctx context.Context, limit int) ([]*ImportLedgerEntry, error) {
+	var entries []*ImportLedgerEntry
+
+	q := m.db.ModelContext(ctx, &entries).Order("created_at DESC")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+
+	if err := q.Select(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
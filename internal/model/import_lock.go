@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImportLock is the lock row held by an importer for the duration of a run
+type ImportLock struct {
+	Name        string    `pg:"name,pk"`
+	Owner       uuid.UUID `pg:"owner,type:uuid"`
+	Hostname    string    `pg:"hostname"`
+	PID         int       `pg:"pid"`
+	AcquiredAt  time.Time `sql:"DEFAULT:current_timestamp"`
+	HeartbeatAt time.Time `sql:"DEFAULT:current_timestamp"`
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+var (
+	// ErrImportLockLost is returned when the lock row no longer belongs to the caller,
+	// e.g. because it was taken over as stale by another importer
+	ErrImportLockLost = errors.New("import lock is no longer held by this run")
+)
+
+//go:generate mockery --name ImportLockManager --output=mocks
+type ImportLockManager interface {
+	// Acquire takes the lock, or takes it over if the current holder has not sent a heartbeat within staleAfter.
+	// When the lock is held by somebody else it returns false together with the current holder.
+	Acquire(ctx context.Context, lock *ImportLock, staleAfter time.Duration) (bool, *ImportLock, error)
+	Heartbeat(ctx context.Context, lock *ImportLock) error
+	Release(ctx context.Context, lock *ImportLock) error
+}
+
+type importLockManager struct {
+	db *pg.DB
+}
+
+func NewImportLockManager(db *pg.DB) ImportLockManager {
+	return &importLockManager{
+		db: db,
+	}
+}
+
+func (m *importLockManager) Acquire(ctx context.Context, lock *ImportLock, staleAfter time.Duration) (bool, *ImportLock, error) {
+	// the conditional upsert is a single statement, so two importers racing for the lock can't both win
+	res, err := m.db.QueryContext(ctx, lock, `
+		INSERT INTO import_locks (name, owner, hostname, pid, acquired_at, heartbeat_at)
+		VALUES (?, ?, ?, ?, now(), now())
+		ON CONFLICT (name) DO UPDATE
+		SET owner = EXCLUDED.owner, hostname = EXCLUDED.hostname, pid = EXCLUDED.pid, acquired_at = now(), heartbeat_at = now()
+		WHERE import_locks.heartbeat_at < now() - make_interval(secs => ?)
+		RETURNING *`,
+		lock.Name, lock.Owner, lock.Hostname, lock.PID, staleAfter.Seconds())
+	if err != nil {
+		return false, nil, err
+	}
+
+	if res.RowsReturned() > 0 {
+		return true, lock, nil
+	}
+
+	holder := &ImportLock{Name: lock.Name}
+	err = m.db.ModelContext(ctx, holder).WherePK().Select()
+	switch {
+	case errors.Is(err, pg.ErrNoRows): // released in between, the caller may simply retry
+		return false, nil, nil
+	case err != nil:
+		return false, nil, err
+	}
+
+	return false, holder, nil
+}
+
+func (m *importLockManager) Heartbeat(ctx context.Context, lock *ImportLock) error {
+	res, err := m.db.ExecContext(ctx, "UPDATE import_locks SET heartbeat_at = now() WHERE name = ? AND owner = ?", lock.Name, lock.Owner)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return ErrImportLockLost
+	}
+	return nil
+}
+
+func (m *importLockManager) Release(ctx context.Context, lock *ImportLock) error {
+	_, err := m.db.ExecContext(ctx, "DELETE FROM import_locks WHERE name = ? AND owner = ?", lock.Name, lock.Owner)
+	return err
+}
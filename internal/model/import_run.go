@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ImportRunRunning    = "running"
+	ImportRunCompleted  = "completed"
+	ImportRunFailed     = "failed"
+	ImportRunRolledBack = "rolled_back"
+)
+
+type ImportRun struct {
+	ID           uuid.UUID `pg:"id,pk,type:uuid,default:gen_random_uuid()"`
+	Source       string    `pg:"source"`
+	Status       string    `pg:"status"`
+	StartedAt    time.Time `pg:"started_at,default:now()"`
+	FinishedAt   time.Time `pg:"finished_at"`
+	RolledBackAt time.Time `pg:"rolled_back_at"`
+}
+
+// RollbackResult is the outcome of undoing an import run
+type RollbackResult struct {
+	Deleted  int // rows the run inserted
+	Restored int // rows the run overwrote
+}
@@ -0,0 +1,186 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrImportRunNotFound = errors.New("import run not found")
+)
+
+type importRunKey struct{}
+
+// WithImportRun marks ctx as belonging to the import run, so that BulkInsert records what the run changed
+func WithImportRun(ctx context.Context, runID uuid.UUID) context.Context {
+	return context.WithValue(ctx, importRunKey{}, runID)
+}
+
+func importRunFromContext(ctx context.Context) (uuid.UUID, bool) {
+	runID, ok := ctx.Value(importRunKey{}).(uuid.UUID)
+	return runID, ok
+}
+
+//go:generate mockery --name ImportRunManager --output=mocks
+type ImportRunManager interface {
+	Start(ctx context.Context, source string) (*ImportRun, error)
+	Finish(ctx context.Context, runID uuid.UUID, status string) error
+	FindByID(ctx context.Context, runID uuid.UUID) (*ImportRun, error)
+	// Rollback restores geolocations to the state before the run, using the changes recorded by BulkInsert
+	Rollback(ctx context.Context, runID uuid.UUID) (*RollbackResult, error)
+	// LastChange returns when the last run finished or was rolled back, the zero time if none did
+	LastChange(ctx context.Context) (time.Time, error)
+}
+
+type importRunManager struct {
+	db *pg.DB
+}
+
+func NewImportRunManager(db *pg.DB) ImportRunManager {
+	return &importRunManager{
+		db: db,
+	}
+}
+
+func (m *importRunManager) Start(ctx context.Context, source string) (*ImportRun, error) {
+	run := &ImportRun{
+		Source: source,
+		Status: ImportRunRunning,
+	}
+
+	_, err := m.db.ModelContext(ctx, run).Returning("*").Insert()
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func (m *importRunManager) Finish(ctx context.Context, runID uuid.UUID, status string) error {
+	_, err := m.db.ExecContext(ctx, "UPDATE import_runs SET status = ?, finished_at = now() WHERE id = ?", status, runID)
+	return err
+}
+
+func (m *importRunManager) FindByID(ctx context.Context, runID uuid.UUID) (*ImportRun, error) {
+	run := &ImportRun{ID: runID}
+
+	err := m.db.ModelContext(ctx, run).WherePK().Select()
+	switch {
+	case errors.Is(err, pg.ErrNoRows):
+		return nil, ErrImportRunNotFound
+	case err != nil:
+		return nil, err
+	}
+	return run, nil
+}
+
+func (m *importRunManager) LastChange(ctx context.Context) (time.Time, error) {
+	var last time.Time
+	_, err := m.db.QueryOneContext(ctx, pg.Scan(&last),
+		"SELECT coalesce(max(greatest(finished_at, rolled_back_at)), 'epoch') FROM import_runs")
+	if err != nil {
+		return time.Time{}, err
+	}
+	if last.Unix() == 0 {
+		return time.Time{}, nil
+	}
+	return last, nil
+}
+
+func (m *importRunManager) Rollback(ctx context.Context, runID uuid.UUID) (*RollbackResult, error) {
+	result := new(RollbackResult)
+
+	err := m.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		run := &ImportRun{ID: runID}
+		err := tx.ModelContext(ctx, run).WherePK().For("UPDATE").Select()
+		switch {
+		case errors.Is(err, pg.ErrNoRows):
+			return ErrImportRunNotFound
+		case err != nil:
+			return err
+		}
+
+		switch run.Status {
+		case ImportRunRunning:
+			return fmt.Errorf("import run %s is still running", runID)
+		case ImportRunRolledBack:
+			return fmt.Errorf("import run %s was already rolled back", runID)
+		}
+
+		// restoring an older run would silently undo whatever the later runs wrote
+		var later []ImportRun
+		err = tx.ModelContext(ctx, &later).
+			Where("started_at > ?", run.StartedAt).
+			Where("status != ?", ImportRunRolledBack).
+			Order("started_at DESC").
+			Select()
+		if err != nil {
+			return err
+		}
+		if len(later) > 0 {
+			return fmt.Errorf("import run %s was imported after %s, roll it back first", later[0].ID, runID)
+		}
+
+		res, err := tx.ExecContext(ctx, `
+			DELETE FROM geolocations g
+			USING import_run_changes c
+			WHERE c.run_id = ? AND NOT c.existed AND g.ip = c.ip`, runID)
+		if err != nil {
+			return err
+		}
+		result.Deleted = res.RowsAffected()
+
+		res, err = tx.ExecContext(ctx, `
+			UPDATE geolocations g
+			SET country_code = c.previous_country_code,
+				country = c.previous_country,
+				city = c.previous_city,
+				latitude = c.previous_latitude,
+				longitude = c.previous_longitude,
+				mystery_value = c.previous_mystery_value,
+				country_id = c.previous_country_id,
+				city_id = c.previous_city_id
+			FROM import_run_changes c
+			WHERE c.run_id = ? AND c.existed AND g.ip = c.ip`, runID)
+		if err != nil {
+			return err
+		}
+		result.Restored = res.RowsAffected()
+
+		_, err = tx.ExecContext(ctx, "UPDATE import_runs SET status = ?, rolled_back_at = now() WHERE id = ?", ImportRunRolledBack, runID)
+		if err != nil {
+			return err
+		}
+
+		// the files of a rolled back run may be imported again
+		_, err = tx.ExecContext(ctx, "UPDATE import_ledger SET status = ? WHERE run_id = ?", ImportRunRolledBack, runID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// recordChanges snapshots the current rows of the given ips before the run overwrites them
+func recordChanges(ctx context.Context, tx *pg.Tx, runID uuid.UUID, geolocation []*Geolocation) error {
+	ips := make([]string, 0, len(geolocation))
+	for _, g := range geolocation {
+		ips = append(ips, g.IP)
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO import_run_changes (run_id, ip, existed, previous_country_code, previous_country, previous_city,
+			previous_latitude, previous_longitude, previous_mystery_value, previous_country_id, previous_city_id)
+		SELECT ?, v.ip, g.ip IS NOT NULL, g.country_code, g.country, g.city, g.latitude, g.longitude, g.mystery_value,
+			g.country_id, g.city_id
+		FROM unnest(?::text[]) AS v(ip)
+		LEFT JOIN geolocations g ON g.ip = v.ip
+		ON CONFLICT (run_id, ip) DO NOTHING`, runID, pg.Array(ips))
+	return err
+}
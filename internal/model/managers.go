@@ -0,0 +1,58 @@
+package model
+
+import (
+	"database/sql"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// Managers holds the managers of one database backend
+type Managers struct {
+	GeoLocations GeoLocationManager
+	ImportRuns   ImportRunManager
+	ImportLedger ImportLedgerManager
+	ImportLocks  ImportLockManager
+	Source       GeoLocationSource // the whole dataset, for the in-memory lookups
+	Cities       CitySource        // the cities with geolocations, for the city search
+	Stats        StatsManager
+	APIKeys      APIKeyManager
+}
+
+func NewPostgresManagers(db *pg.DB) *Managers {
+	return &Managers{
+		GeoLocations: NewGeoLocationManager(db),
+		ImportRuns:   NewImportRunManager(db),
+		ImportLedger: NewImportLedgerManager(db),
+		ImportLocks:  NewImportLockManager(db),
+		Source:       NewDBSource(db),
+		Cities:       NewPostgresCitySource(db),
+		Stats:        NewStatsManager(db),
+		APIKeys:      NewAPIKeyManager(db),
+	}
+}
+
+func NewSQLiteManagers(db *sql.DB) *Managers {
+	return &Managers{
+		GeoLocations: NewSQLiteGeoLocationManager(db),
+		ImportRuns:   NewSQLiteImportRunManager(db),
+		ImportLedger: NewSQLiteImportLedgerManager(db),
+		ImportLocks:  NewSQLiteImportLockManager(db),
+		Source:       NewSQLSource(db),
+		Cities:       NewSQLCitySource(db),
+		Stats:        NewSQLStatsManager(db),
+		APIKeys:      NewSQLAPIKeyManager(db),
+	}
+}
+
+func NewMySQLManagers(db *sql.DB) *Managers {
+	return &Managers{
+		GeoLocations: NewMySQLGeoLocationManager(db),
+		ImportRuns:   NewMySQLImportRunManager(db),
+		ImportLedger: NewMySQLImportLedgerManager(db),
+		ImportLocks:  NewMySQLImportLockManager(db),
+		Source:       NewSQLSource(db),
+		Cities:       NewSQLCitySource(db),
+		Stats:        NewSQLStatsManager(db),
+		APIKeys:      NewSQLAPIKeyManager(db),
+	}
+}
@@ -0,0 +1,264 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ohmpatel1997/findhotel/internal/common"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// MMDBDatabaseType is the database type of the exported files. Their records have the shape of the GeoIP2 City
+// records, so MaxMind readers decode them, and the fields of no GeoIP2 equivalent under "findhotel".
+const MMDBDatabaseType = "FindHotel-City"
+
+var (
+	ErrMMDBReadOnly = errors.New("the mmdb geolocations are read only")
+)
+
+// mmdbRecord is the part of a GeoIP2 City record the lookups use
+type mmdbRecord struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+	FindHotel struct {
+		CountryID    uint64 `maxminddb:"country_id"`
+		CityID       uint64 `maxminddb:"city_id"`
+		MysteryValue string `maxminddb:"mystery_value"`
+	} `maxminddb:"findhotel"`
+}
+
+// newMMDBRecord returns the record of g, leaving out the empty fields like GeoIP2 databases do
+func newMMDBRecord(g *Geolocation) mmdbMap {
+	record := mmdbMap{
+		"location": mmdbMap{
+			"latitude":  g.Latitude,
+			"longitude": g.Longitude,
+		},
+	}
+	if g.City != "" {
+		record["city"] = mmdbMap{"names": mmdbMap{"en": g.City}}
+	}
+	if g.CountryCode != "" || g.Country != "" {
+		country := mmdbMap{}
+		if g.CountryCode != "" {
+			country["iso_code"] = g.CountryCode
+		}
+		if g.Country != "" {
+			country["names"] = mmdbMap{"en": g.Country}
+		}
+		record["country"] = country
+	}
+
+	findhotel := mmdbMap{}
+	if g.CountryID != 0 {
+		findhotel["country_id"] = uint64(g.CountryID)
+	}
+	if g.CityID != 0 {
+		findhotel["city_id"] = uint64(g.CityID)
+	}
+	if g.MysteryValue != "" {
+		findhotel["mystery_value"] = g.MysteryValue
+	}
+	if len(findhotel) > 0 {
+		record["findhotel"] = findhotel
+	}
+	return record
+}
+
+// WriteMMDB compiles the geolocations of src into a MaxMind DB file at path, replacing it atomically. Where ranges
+// overlap, the addresses resolve to the most specific one like in the lookups of the databases. It returns the
+// number of rows written.
+func WriteMMDB(ctx context.Context, src GeoLocationSource, path string) (int64, error) {
+	type row struct {
+		geo        *Geolocation
+		start, end ipKey
+	}
+	var rows []row
+	err := src(ctx, func(g *Geolocation) error {
+		start, end := net.ParseIP(g.StartIP), net.ParseIP(g.EndIP)
+		if start == nil || end == nil {
+			return errors.New("invalid range " + g.IP)
+		}
+		geo := *g
+		rows = append(rows, row{geo: &geo, start: mmdbKey(toKey(start)), end: mmdbKey(toKey(end))})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// the lookups take the range of the greatest start holding an address, then of the lowest end. The ranges go in
+	// the other way around, so that those a lookup prefers are inserted last and replace their part of the others.
+	sort.SliceStable(rows, func(i, j int) bool {
+		if c := bytes.Compare(rows[i].start[:], rows[j].start[:]); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(rows[i].end[:], rows[j].end[:]) > 0
+	})
+
+	// the private networks of the dumps are kept, unlike in the MaxMind databases
+	tree := newMMDBTree(MMDBDatabaseType, []string{"en"}, map[string]string{"en": "FindHotel geolocations"})
+	for _, r := range rows {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if err := tree.insertRange(r.start, r.end, newMMDBRecord(r.geo)); err != nil {
+			return 0, err
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name()) // fails once the file was renamed
+
+	w := bufio.NewWriter(tmp)
+	if _, err := tree.WriteTo(w); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, err
+	}
+	return int64(len(rows)), nil
+}
+
+// MMDBGeoLocationManager answers lookups from a MaxMind DB file, either exported from the geolocations or a
+// GeoIP2 City compatible database
+type MMDBGeoLocationManager interface {
+	GeoLocationManager
+	// Reload reads the file again and swaps it in, lookups keep using the previous one meanwhile
+	Reload(ctx context.Context) error
+	// Loaded returns the metadata of the file and when it was loaded
+	Loaded() (maxminddb.Metadata, time.Time)
+}
+
+type mmdbManager struct {
+	path string
+
+	mu       sync.RWMutex
+	reader   *maxminddb.Reader
+	loadedAt time.Time
+}
+
+// NewMMDBGeoLocationManager reads the MaxMind DB file at path and returns a manager serving it
+func NewMMDBGeoLocationManager(ctx context.Context, path string) (MMDBGeoLocationManager, error) {
+	m := &mmdbManager{path: path}
+	if err := m.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *mmdbManager) Reload(ctx context.Context) error {
+	// the file is read rather than mapped, so that replacing it can't break the lookups still using it
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		return err
+	}
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.reader = reader
+	m.loadedAt = time.Now()
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *mmdbManager) Loaded() (maxminddb.Metadata, time.Time) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.reader.Metadata, m.loadedAt
+}
+
+// FindDataByIP only knows the geolocations of the file, there is no history
+func (m *mmdbManager) FindDataByIP(ctx context.Context, ip string, asOf time.Time) (*Geolocation, error) {
+	addr := common.ParseIP(ip)
+	if addr == nil {
+		return nil, router.NewHttpError("invalid ip", 400)
+	}
+	if !asOf.IsZero() {
+		return nil, router.NewHttpError("as-of lookups are not supported by the mmdb lookup backend", 501)
+	}
+
+	m.mu.RLock()
+	reader := m.reader
+	m.mu.RUnlock()
+
+	var record mmdbRecord
+	network, ok, err := reader.LookupNetwork(addr, &record)
+	if err != nil {
+		return nil, router.NewHttpError(err.Error(), 500)
+	}
+	if !ok {
+		return nil, router.NewHttpError("data not found with given ip", 404)
+	}
+
+	geo := &Geolocation{
+		CountryCode:  record.Country.ISOCode,
+		Country:      record.Country.Names["en"],
+		CountryID:    int64(record.FindHotel.CountryID),
+		City:         record.City.Names["en"],
+		CityID:       int64(record.FindHotel.CityID),
+		Latitude:     record.Location.Latitude,
+		Longitude:    record.Location.Longitude,
+		MysteryValue: record.FindHotel.MysteryValue,
+	}
+	// the network is the one of the file, ranges are stored as the networks covering them
+	ones, bits := network.Mask.Size()
+	geo.StartIP, geo.EndIP = network.IP.String(), lastIP(network).String()
+	geo.IP = network.String()
+	if ones == bits {
+		geo.IP = geo.StartIP
+	}
+	return geo, nil
+}
+
+func (m *mmdbManager) FindDataByIPs(ctx context.Context, ips []string) ([]*IPLookup, error) {
+	return lookupEach(ctx, ips, m.FindDataByIP)
+}
+
+func lastIP(network *net.IPNet) net.IP {
+	last := make(net.IP, len(network.IP))
+	for i := range network.IP {
+		last[i] = network.IP[i] | ^network.Mask[i]
+	}
+	return last
+}
+
+func (m *mmdbManager) BulkInsert(ctx context.Context, geolocation []*Geolocation) error {
+	return ErrMMDBReadOnly
+}
+
+func (m *mmdbManager) List(ctx context.Context, query *GeolocationQuery) (*GeolocationPage, error) {
+	return nil, router.NewHttpError("listing is not supported by the mmdb lookup backend", 501)
+}
@@ -0,0 +1,144 @@
+package model
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMMDB(t *testing.T) {
+	rows := []*Geolocation{
+		{IP: "10.1.2.3", StartIP: "10.1.2.3", EndIP: "10.1.2.3", CountryCode: "IN", Country: "India", CountryID: 4, City: "Surat", CityID: 17, Latitude: 21.17, Longitude: 72.83, MysteryValue: "7"},
+		{IP: "10.0.0.0/8", StartIP: "10.0.0.0", EndIP: "10.255.255.255", CountryCode: "IN", Country: "India", City: "Delhi", Latitude: 28.61, Longitude: 77.2},
+		{IP: "10.1.0.0-10.3.0.0", StartIP: "10.1.0.0", EndIP: "10.3.0.0", CountryCode: "IN", Country: "India", City: "Agra"},
+		{IP: "2001:db8::/32", StartIP: "2001:db8::", EndIP: "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", CountryCode: "DE", Country: "Germany", City: "Berlin"},
+		{IP: "8.8.8.8", StartIP: "8.8.8.8", EndIP: "8.8.8.8"},
+	}
+	path := filepath.Join(t.TempDir(), "geolocations.mmdb")
+
+	n, err := WriteMMDB(context.TODO(), sliceSource(rows), path)
+	if err != nil {
+		t.Fatalf("Error writing the mmdb file %v", err)
+	}
+	assert.Equal(t, int64(5), n)
+
+	manager, err := NewMMDBGeoLocationManager(context.TODO(), path)
+	if err != nil {
+		t.Fatalf("Error reading the mmdb file %v", err)
+	}
+	metadata, _ := manager.Loaded()
+	assert.Equal(t, MMDBDatabaseType, metadata.DatabaseType)
+
+	cases := []struct {
+		Name          string
+		Ip            string
+		Expected      *Geolocation
+		ExpectedError error
+	}{
+		{
+			Name:     "single ip",
+			Ip:       "10.1.2.3",
+			Expected: &Geolocation{IP: "10.1.2.3", StartIP: "10.1.2.3", EndIP: "10.1.2.3", CountryCode: "IN", Country: "India", CountryID: 4, City: "Surat", CityID: 17, Latitude: 21.17, Longitude: 72.83, MysteryValue: "7"},
+		},
+		{
+			Name:     "range inside a network",
+			Ip:       "10.2.0.1",
+			Expected: &Geolocation{IP: "10.2.0.0/16", StartIP: "10.2.0.0", EndIP: "10.2.255.255", CountryCode: "IN", Country: "India", City: "Agra"},
+		},
+		{
+			Name:     "network around the range",
+			Ip:       "10.3.0.1",
+			Expected: &Geolocation{IP: "10.3.0.1", StartIP: "10.3.0.1", EndIP: "10.3.0.1", CountryCode: "IN", Country: "India", City: "Delhi", Latitude: 28.61, Longitude: 77.2},
+		},
+		{
+			Name:     "ipv6",
+			Ip:       "2001:db8::1",
+			Expected: &Geolocation{IP: "2001:db8::/32", StartIP: "2001:db8::", EndIP: "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", CountryCode: "DE", Country: "Germany", City: "Berlin"},
+		},
+		{
+			Name:     "no fields",
+			Ip:       "8.8.8.8",
+			Expected: &Geolocation{IP: "8.8.8.8", StartIP: "8.8.8.8", EndIP: "8.8.8.8"},
+		},
+		{
+			Name:          "gap",
+			Ip:            "11.0.0.1",
+			ExpectedError: router.NewHttpError("data not found with given ip", 404),
+		},
+		{
+			Name:          "invalid ip",
+			Ip:            "10.1.2",
+			ExpectedError: router.NewHttpError("invalid ip", 400),
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			geo, err := manager.FindDataByIP(context.TODO(), tt.Ip, time.Time{})
+			if tt.ExpectedError != nil {
+				assert.Equal(tt.ExpectedError, err)
+				return
+			}
+			assert.Nil(err)
+			assert.Equal(tt.Expected, geo)
+		})
+	}
+
+	_, err = manager.FindDataByIP(context.TODO(), "10.1.2.3", time.Now())
+	assert.Equal(t, router.NewHttpError("as-of lookups are not supported by the mmdb lookup backend", 501), err)
+	assert.ErrorIs(t, manager.BulkInsert(context.TODO(), nil), ErrMMDBReadOnly)
+}
+
+func TestMMDBOverlappingRanges(t *testing.T) {
+	// the wider range starts later, so it is the one the databases find where both hold an address
+	rows := []*Geolocation{
+		{IP: "172.16.0.50-172.16.0.200", StartIP: "172.16.0.50", EndIP: "172.16.0.200", CountryCode: "IN", Country: "India", City: "Mumbai"},
+		{IP: "172.16.0.0-172.16.0.100", StartIP: "172.16.0.0", EndIP: "172.16.0.100", CountryCode: "IN", Country: "India", City: "Pune"},
+	}
+	path := filepath.Join(t.TempDir(), "geolocations.mmdb")
+	if _, err := WriteMMDB(context.TODO(), sliceSource(rows), path); err != nil {
+		t.Fatalf("Error writing the mmdb file %v", err)
+	}
+	manager, err := NewMMDBGeoLocationManager(context.TODO(), path)
+	if err != nil {
+		t.Fatalf("Error reading the mmdb file %v", err)
+	}
+	db := newSQLiteDB(t)
+	if err := NewSQLiteGeoLocationManager(db).BulkInsert(context.TODO(), rows); err != nil {
+		t.Fatalf("Error inserting rows %v", err)
+	}
+	sqlite := NewSQLiteGeoLocationManager(db)
+
+	cases := []struct {
+		Name     string
+		Ip       string
+		Expected string
+	}{
+		{Name: "first range only", Ip: "172.16.0.10", Expected: "Pune"},
+		{Name: "both ranges", Ip: "172.16.0.60", Expected: "Mumbai"},
+		{Name: "end of both ranges", Ip: "172.16.0.100", Expected: "Mumbai"},
+		{Name: "second range only", Ip: "172.16.0.150", Expected: "Mumbai"},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			geo, err := manager.FindDataByIP(context.TODO(), tt.Ip, time.Time{})
+			assert.Nil(err)
+			assert.Equal(tt.Expected, geo.City)
+
+			stored, err := sqlite.FindDataByIP(context.TODO(), tt.Ip, time.Time{})
+			assert.Nil(err)
+			assert.Equal(tt.Expected, stored.City)
+		})
+	}
+}
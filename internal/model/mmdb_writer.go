@@ -0,0 +1,284 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// the MaxMind DB format, see https://maxmind.github.io/MaxMind-DB/
+const (
+	mmdbRecordSize         = 32 // bits, so that a record is a big endian uint32
+	mmdbDataSeparatorSize  = 16
+	mmdbFormatMajorVersion = 2
+
+	mmdbTypeString  = 2
+	mmdbTypeFloat64 = 3
+	mmdbTypeUint16  = 5
+	mmdbTypeUint32  = 6
+	mmdbTypeMap     = 7
+	mmdbTypeUint64  = 9
+	mmdbTypeArray   = 11
+)
+
+var mmdbMetadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// mmdbMap is a map of the data section. Its values are strings, float64s, uint16s, uint32s, uint64s, string slices
+// and mmdbMaps.
+type mmdbMap map[string]interface{}
+
+// mmdbRecordValue is what a record of the search tree holds: a node, data or nothing
+type mmdbRecordValue struct {
+	node *mmdbNode
+	data int // index of the data of the tree, -1 for none
+}
+
+type mmdbNode struct {
+	records [2]mmdbRecordValue
+}
+
+// mmdbTree is the search tree of a MaxMind DB file being built, over ipv6 addresses with the ipv4 ones at ::/96.
+// Ranges inserted later replace the part of the earlier ones they overlap.
+type mmdbTree struct {
+	databaseType string
+	languages    []string          // of the names of the records
+	description  map[string]string // by language
+
+	root     mmdbRecordValue
+	data     [][]byte       // the encoded data, each once
+	dataKeys map[string]int // index of the encoded data
+}
+
+func newMMDBTree(databaseType string, languages []string, description map[string]string) *mmdbTree {
+	return &mmdbTree{
+		databaseType: databaseType,
+		languages:    languages,
+		description:  description,
+		root:         mmdbRecordValue{data: -1},
+		dataKeys:     make(map[string]int),
+	}
+}
+
+// mmdbKey returns the key of ip in the search tree, the ipv4 addresses being at ::/96 rather than ::ffff:0:0/96
+func mmdbKey(k ipKey, v4 bool) ipKey {
+	if v4 {
+		k[10], k[11] = 0, 0
+	}
+	return k
+}
+
+// insertRange stores data for the addresses from start to end, as the networks covering them
+func (t *mmdbTree) insertRange(start, end ipKey, data mmdbMap) error {
+	var buf bytes.Buffer
+	if err := encodeMMDBValue(&buf, data); err != nil {
+		return err
+	}
+	index, ok := t.dataKeys[buf.String()]
+	if !ok {
+		index = len(t.data)
+		t.data = append(t.data, buf.Bytes())
+		t.dataKeys[buf.String()] = index
+	}
+	var prefix ipKey
+	t.insert(&t.root, prefix, 0, start, end, index)
+	return nil
+}
+
+// insert stores data in the part of the network of record, of prefix and depth bits, from start to end
+func (t *mmdbTree) insert(record *mmdbRecordValue, prefix ipKey, depth int, start, end ipKey, data int) {
+	first, last := prefix, prefix
+	for bit := depth; bit < 8*len(last); bit++ {
+		last[bit/8] |= 0x80 >> (bit % 8)
+	}
+	if bytes.Compare(last[:], start[:]) < 0 || bytes.Compare(first[:], end[:]) > 0 {
+		return
+	}
+	if bytes.Compare(first[:], start[:]) >= 0 && bytes.Compare(last[:], end[:]) <= 0 {
+		*record = mmdbRecordValue{data: data}
+		return
+	}
+
+	if record.node == nil {
+		// the network is split, both halves keeping what it held so far
+		record.node = &mmdbNode{records: [2]mmdbRecordValue{{data: record.data}, {data: record.data}}}
+		record.data = -1
+	}
+	t.insert(&record.node.records[0], prefix, depth+1, start, end, data)
+	right := prefix
+	right[depth/8] |= 0x80 >> (depth % 8)
+	t.insert(&record.node.records[1], right, depth+1, start, end, data)
+
+	// halves holding the same data are one network again
+	left, r := record.node.records[0], record.node.records[1]
+	if left.node == nil && r.node == nil && left.data == r.data {
+		*record = mmdbRecordValue{data: left.data}
+	}
+}
+
+// WriteTo writes the file: the search tree, the data section and the metadata
+func (t *mmdbTree) WriteTo(w io.Writer) (int64, error) {
+	// the ipv4 subtree is reached by the readers through the left records of the first 96 nodes, which a tree
+	// without ipv6 networks doesn't have
+	root := t.root
+	if root.node == nil {
+		root.node = &mmdbNode{records: [2]mmdbRecordValue{{data: root.data}, {data: root.data}}}
+	}
+
+	// the nodes are numbered breadth first, the root being 0
+	nodes := []*mmdbNode{root.node}
+	numbers := map[*mmdbNode]uint32{root.node: 0}
+	for i := 0; i < len(nodes); i++ {
+		for _, record := range nodes[i].records {
+			if record.node != nil {
+				numbers[record.node] = uint32(len(nodes))
+				nodes = append(nodes, record.node)
+			}
+		}
+	}
+	nodeCount := uint32(len(nodes))
+
+	offsets := make([]uint32, len(t.data))
+	var dataSize uint32
+	for i, data := range t.data {
+		offsets[i] = dataSize
+		dataSize += uint32(len(data))
+	}
+	if uint64(nodeCount)+mmdbDataSeparatorSize+uint64(dataSize) > math.MaxUint32 {
+		return 0, errors.New("the geolocations don't fit in a MaxMind DB file")
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, int(nodeCount)*2*mmdbRecordSize/8+mmdbDataSeparatorSize+int(dataSize)))
+	var record [mmdbRecordSize / 8]byte
+	for _, node := range nodes {
+		for _, r := range node.records {
+			value := nodeCount // no data
+			switch {
+			case r.node != nil:
+				value = numbers[r.node]
+			case r.data >= 0:
+				value = nodeCount + mmdbDataSeparatorSize + offsets[r.data]
+			}
+			binary.BigEndian.PutUint32(record[:], value)
+			buf.Write(record[:])
+		}
+	}
+	buf.Write(make([]byte, mmdbDataSeparatorSize))
+	for _, data := range t.data {
+		buf.Write(data)
+	}
+
+	description := mmdbMap{}
+	for lang, text := range t.description {
+		description[lang] = text
+	}
+	buf.Write(mmdbMetadataMarker)
+	err := encodeMMDBValue(buf, mmdbMap{
+		"binary_format_major_version": uint16(mmdbFormatMajorVersion),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(time.Now().Unix()),
+		"database_type":               t.databaseType,
+		"description":                 description,
+		"ip_version":                  uint16(6),
+		"languages":                   t.languages,
+		"node_count":                  nodeCount,
+		"record_size":                 uint16(mmdbRecordSize),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return buf.WriteTo(w)
+}
+
+// encodeMMDBValue writes v in the format of the data section, the keys of the maps sorted so that equal data
+// encodes the same
+func encodeMMDBValue(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case string:
+		writeMMDBControl(buf, mmdbTypeString, len(v))
+		buf.WriteString(v)
+	case float64:
+		writeMMDBControl(buf, mmdbTypeFloat64, 8)
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(v))
+		buf.Write(b[:])
+	case uint16:
+		writeMMDBUint(buf, mmdbTypeUint16, uint64(v))
+	case uint32:
+		writeMMDBUint(buf, mmdbTypeUint32, uint64(v))
+	case uint64:
+		writeMMDBUint(buf, mmdbTypeUint64, v)
+	case []string:
+		writeMMDBControl(buf, mmdbTypeArray, len(v))
+		for _, s := range v {
+			if err := encodeMMDBValue(buf, s); err != nil {
+				return err
+			}
+		}
+	case mmdbMap:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		writeMMDBControl(buf, mmdbTypeMap, len(v))
+		for _, key := range keys {
+			if err := encodeMMDBValue(buf, key); err != nil {
+				return err
+			}
+			if err := encodeMMDBValue(buf, v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New("unsupported mmdb value")
+	}
+	return nil
+}
+
+// writeMMDBUint writes v in as few bytes as it needs
+func writeMMDBUint(buf *bytes.Buffer, typ int, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	n := 8
+	for n > 0 && b[8-n] == 0 {
+		n--
+	}
+	writeMMDBControl(buf, typ, n)
+	buf.Write(b[8-n:])
+}
+
+// writeMMDBControl writes the control byte of a value of typ and size, followed by the bytes the type and size
+// don't fit in it
+func writeMMDBControl(buf *bytes.Buffer, typ, size int) {
+	var ctrl, extended []byte
+	if typ > 7 {
+		ctrl = []byte{0}
+		extended = []byte{byte(typ - 7)}
+	} else {
+		ctrl = []byte{byte(typ << 5)}
+	}
+
+	var sizeBytes []byte
+	switch {
+	case size < 29:
+		ctrl[0] |= byte(size)
+	case size < 285:
+		ctrl[0] |= 29
+		sizeBytes = []byte{byte(size - 29)}
+	case size < 65821:
+		ctrl[0] |= 30
+		s := size - 285
+		sizeBytes = []byte{byte(s >> 8), byte(s)}
+	default:
+		ctrl[0] |= 31
+		s := size - 65821
+		sizeBytes = []byte{byte(s >> 16), byte(s >> 8), byte(s)}
+	}
+	buf.Write(ctrl)
+	buf.Write(extended)
+	buf.Write(sizeBytes)
+}
@@ -0,0 +1,171 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ohmpatel1997/findhotel/lib/db/mocks"
+	"github.com/ohmpatel1997/findhotel/lib/db/mysql"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/pressly/goose"
+	"github.com/stretchr/testify/assert"
+)
+
+const mysqlMigrations = "../../migration/geolocation/mysql"
+
+// TestMySQL runs the mysql managers against a migrated server in a container, the cases sharing it in order
+func TestMySQL(t *testing.T) {
+	pool, resource := mocks.NewMySQLContainer(t)
+	defer mocks.CloseContainer(t, pool, resource)
+
+	db := mocks.NewMySQLDB(t, pool, resource)
+	defer db.Close()
+
+	if err := goose.SetDialect(mysql.DriverName); err != nil {
+		t.Fatalf("Error setting dialect %v", err)
+	}
+	if err := goose.Up(db, mysqlMigrations); err != nil {
+		t.Fatalf("Error creating schema %v", err)
+	}
+
+	cases := []struct {
+		Name string
+		Test func(t *testing.T, db *sql.DB)
+	}{
+		{Name: "migrations", Test: testMySQLMigrations},
+		{Name: "upsert", Test: testMySQLUpsert},
+		{Name: "rollback", Test: testMySQLRollback},
+		{Name: "import lock", Test: testMySQLImportLock},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			if _, err := db.Exec("DELETE FROM geolocations"); err != nil {
+				t.Fatalf("Error emptying geolocations %v", err)
+			}
+			tt.Test(t, db)
+		})
+	}
+}
+
+// testMySQLMigrations migrates down to nothing and up again, so that every down migration runs
+func testMySQLMigrations(t *testing.T, db *sql.DB) {
+	assert := assert.New(t)
+
+	version, err := goose.GetDBVersion(db)
+	assert.Nil(err)
+	assert.Nil(goose.DownTo(db, mysqlMigrations, 0))
+	var tables int
+	assert.Nil(db.QueryRow("SELECT count(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'geolocations'").Scan(&tables))
+	assert.Equal(0, tables)
+
+	assert.Nil(goose.Up(db, mysqlMigrations))
+	again, err := goose.GetDBVersion(db)
+	assert.Nil(err)
+	assert.Equal(version, again)
+}
+
+// testMySQLUpsert writes ips again, which ON DUPLICATE KEY UPDATE updates in place with the VALUES() of the new row
+func testMySQLUpsert(t *testing.T, db *sql.DB) {
+	assert := assert.New(t)
+	geolocations := NewMySQLGeoLocationManager(db)
+	ctx := context.TODO()
+
+	assert.Nil(geolocations.BulkInsert(ctx, []*Geolocation{
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", CountryCode: "IN", Country: "India", City: "Pune", Latitude: 18.52, Longitude: 73.85, MysteryValue: "1"},
+		{IP: "10.1.0.0/16", StartIP: "10.1.0.0", EndIP: "10.1.255.255", CountryCode: "IN", Country: "India", City: "Delhi", MysteryValue: "2"},
+	}))
+	first, err := geolocations.FindDataByIP(ctx, "10.0.0.1", time.Time{})
+	assert.Nil(err)
+
+	assert.Nil(geolocations.BulkInsert(ctx, []*Geolocation{
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", CountryCode: "IN", Country: "India", City: "Surat", Latitude: 21.17, Longitude: 72.83, MysteryValue: "3"},
+		{IP: "10.1.2.3", StartIP: "10.1.2.3", EndIP: "10.1.2.3", CountryCode: "IN", Country: "India", City: "Agra", MysteryValue: "4"},
+	}))
+
+	updated, err := geolocations.FindDataByIP(ctx, "10.0.0.1", time.Time{})
+	assert.Nil(err)
+	assert.Equal(first.ID, updated.ID)
+	assert.Equal("Surat", updated.City)
+	assert.Equal(21.17, updated.Latitude)
+	assert.Equal("3", updated.MysteryValue)
+	assert.True(updated.CreatedAt.Equal(first.CreatedAt))
+
+	nested, err := geolocations.FindDataByIP(ctx, "10.1.2.3", time.Time{})
+	assert.Nil(err)
+	assert.Equal("Agra", nested.City)
+	network, err := geolocations.FindDataByIP(ctx, "10.1.2.4", time.Time{})
+	assert.Nil(err)
+	assert.Equal("Delhi", network.City)
+
+	var count int
+	assert.Nil(db.QueryRow("SELECT count(*) FROM geolocations").Scan(&count))
+	assert.Equal(3, count)
+}
+
+func testMySQLRollback(t *testing.T, db *sql.DB) {
+	assert := assert.New(t)
+	geolocations := NewMySQLGeoLocationManager(db)
+	runs := NewMySQLImportRunManager(db)
+	ctx := context.TODO()
+
+	assert.Nil(geolocations.BulkInsert(ctx, []*Geolocation{
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", CountryCode: "IN", Country: "India", City: "Pune", Latitude: 18.52, Longitude: 73.85, MysteryValue: "1"},
+	}))
+
+	run, err := runs.Start(ctx, "dump.csv")
+	assert.Nil(err)
+	runCtx := WithImportRun(ctx, run.ID)
+	assert.Nil(geolocations.BulkInsert(runCtx, []*Geolocation{
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", CountryCode: "IN", Country: "India", City: "Surat", MysteryValue: "2"},
+		{IP: "10.0.0.2", StartIP: "10.0.0.2", EndIP: "10.0.0.2", CountryCode: "IN", Country: "India", City: "Agra", MysteryValue: "3"},
+	}))
+	// a later batch writing the ip again keeps the first snapshot
+	assert.Nil(geolocations.BulkInsert(runCtx, []*Geolocation{
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", CountryCode: "IN", Country: "India", City: "Delhi", MysteryValue: "4"},
+	}))
+	assert.Nil(runs.Finish(ctx, run.ID, ImportRunCompleted))
+
+	result, err := runs.Rollback(ctx, run.ID)
+	assert.Nil(err)
+	assert.Equal(&RollbackResult{Deleted: 1, Restored: 1}, result)
+
+	geo, err := geolocations.FindDataByIP(ctx, "10.0.0.1", time.Time{})
+	assert.Nil(err)
+	assert.Equal("Pune", geo.City)
+	assert.Equal(18.52, geo.Latitude)
+	assert.Equal("1", geo.MysteryValue)
+	_, err = geolocations.FindDataByIP(ctx, "10.0.0.2", time.Time{})
+	assert.Equal(router.NewHttpError("data not found with given ip", 404), err)
+
+	_, err = runs.Rollback(ctx, run.ID)
+	assert.EqualError(err, "import run "+run.ID.String()+" was already rolled back")
+}
+
+func testMySQLImportLock(t *testing.T, db *sql.DB) {
+	assert := assert.New(t)
+	locks := NewMySQLImportLockManager(db)
+	ctx := context.TODO()
+
+	first := &ImportLock{Name: "geolocations", Owner: uuid.New(), Hostname: "importer-1", PID: 1}
+	second := &ImportLock{Name: "geolocations", Owner: uuid.New(), Hostname: "importer-2", PID: 2}
+
+	acquired, _, err := locks.Acquire(ctx, first, time.Minute)
+	assert.Nil(err)
+	assert.True(acquired)
+
+	acquired, holder, err := locks.Acquire(ctx, second, time.Minute)
+	assert.Nil(err)
+	assert.False(acquired)
+	assert.Equal(first.Owner, holder.Owner)
+
+	assert.Nil(locks.Release(ctx, first))
+	acquired, _, err = locks.Acquire(ctx, second, time.Minute)
+	assert.Nil(err)
+	assert.True(acquired)
+	assert.Nil(locks.Release(ctx, second))
+}
@@ -0,0 +1,171 @@
+package model
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/ohmpatel1997/findhotel/internal/common"
+)
+
+// Country is the reference row of a country code, the geolocations of the country point to it
+type Country struct {
+	tableName struct{} `pg:"countries"`
+
+	ID        int64     `pg:"id,pk"`
+	Code      string    `pg:"code"`
+	Name      string    `pg:"name"` // as first imported
+	CreatedAt time.Time `pg:"created_at,default:now()"`
+}
+
+// City is the reference row of a city of a country. Names differing only in case and whitespace are the same city.
+type City struct {
+	tableName struct{} `pg:"cities"`
+
+	ID        int64     `pg:"id,pk"`
+	CountryID int64     `pg:"country_id"`
+	Name      string    `pg:"name"` // as first imported
+	NameKey   string    `pg:"name_key"`
+	CreatedAt time.Time `pg:"created_at,default:now()"`
+}
+
+// countryKey is the form country codes are compared in
+func countryKey(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+type cityRef struct {
+	countryID int64
+	key       string
+}
+
+// references holds the countries and cities a batch of geolocations refers to
+type references struct {
+	countries map[string]*Country // by code
+	cities    map[cityRef]*City
+}
+
+// collectCountries returns the countries rows refer to, sorted by code so that concurrent imports lock them in
+// the same order. A row without a country code refers to no country, and then to no city either.
+func collectCountries(rows []*Geolocation) []*Country {
+	seen := make(map[string]bool)
+	var countries []*Country
+	for _, g := range rows {
+		code := countryKey(g.CountryCode)
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+		countries = append(countries, &Country{Code: code, Name: strings.TrimSpace(g.Country)})
+	}
+	sort.Slice(countries, func(i, j int) bool { return countries[i].Code < countries[j].Code })
+	return countries
+}
+
+// collectCities returns the cities rows refer to, given their countries, sorted like collectCountries
+func collectCities(rows []*Geolocation, countries map[string]*Country) []*City {
+	seen := make(map[cityRef]bool)
+	var cities []*City
+	for _, g := range rows {
+		country, ok := countries[countryKey(g.CountryCode)]
+		key := common.CityKey(g.City)
+		if !ok || key == "" {
+			continue
+		}
+		ref := cityRef{country.ID, key}
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		cities = append(cities, &City{CountryID: country.ID, Name: strings.Join(strings.Fields(g.City), " "), NameKey: key})
+	}
+	sort.Slice(cities, func(i, j int) bool {
+		if cities[i].CountryID != cities[j].CountryID {
+			return cities[i].CountryID < cities[j].CountryID
+		}
+		return cities[i].NameKey < cities[j].NameKey
+	})
+	return cities
+}
+
+// apply points rows to their reference rows, their text is kept as imported
+func (r *references) apply(rows []*Geolocation) {
+	for _, g := range rows {
+		g.CountryID, g.CityID = 0, 0
+		country, ok := r.countries[countryKey(g.CountryCode)]
+		if !ok {
+			continue
+		}
+		g.CountryID = country.ID
+
+		city, ok := r.cities[cityRef{country.ID, common.CityKey(g.City)}]
+		if !ok {
+			continue
+		}
+		g.CityID = city.ID
+	}
+}
+
+func newReferences() *references {
+	return &references{
+		countries: make(map[string]*Country),
+		cities:    make(map[cityRef]*City),
+	}
+}
+
+// resolveReferences creates the countries and cities rows refer to that don't exist yet, and points rows to them
+func resolveReferences(ctx context.Context, tx *pg.Tx, rows []*Geolocation) error {
+	refs := newReferences()
+
+	countries := collectCountries(rows)
+	if len(countries) > 0 {
+		// of two imports creating a country at the same time, the second one reads it below
+		_, err := tx.ModelContext(ctx, &countries).OnConflict("(code) DO NOTHING").Insert()
+		if err != nil {
+			return err
+		}
+
+		codes := make([]string, 0, len(countries))
+		for _, c := range countries {
+			codes = append(codes, c.Code)
+		}
+		var existing []*Country
+		if err := tx.ModelContext(ctx, &existing).WhereIn("code IN (?)", codes).Select(); err != nil {
+			return err
+		}
+		for _, c := range existing {
+			refs.countries[c.Code] = c
+		}
+	}
+
+	cities := collectCities(rows, refs.countries)
+	if len(cities) > 0 {
+		_, err := tx.ModelContext(ctx, &cities).OnConflict("(country_id, name_key) DO NOTHING").Insert()
+		if err != nil {
+			return err
+		}
+
+		countryIDs := make([]int64, 0, len(cities))
+		keys := make([]string, 0, len(cities))
+		for _, c := range cities {
+			countryIDs = append(countryIDs, c.CountryID)
+			keys = append(keys, c.NameKey)
+		}
+		var existing []*City
+		err = tx.ModelContext(ctx, &existing).
+			WhereIn("country_id IN (?)", countryIDs).
+			WhereIn("name_key IN (?)", keys).
+			Select()
+		if err != nil {
+			return err
+		}
+		for _, c := range existing {
+			refs.cities[cityRef{c.CountryID, c.NameKey}] = c
+		}
+	}
+
+	refs.apply(rows)
+	return nil
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const apiKeyColumns = "id, name, hash, prefix, rate_limit, burst, created_at, revoked_at"
+
+type sqlAPIKeyManager struct {
+	db *sql.DB
+}
+
+// NewSQLAPIKeyManager returns the api keys of a sqlite or mysql database, the queries are the same for both
+func NewSQLAPIKeyManager(db *sql.DB) APIKeyManager {
+	return &sqlAPIKeyManager{
+		db: db,
+	}
+}
+
+func (m *sqlAPIKeyManager) Create(ctx context.Context, key *APIKey) error {
+	if key.ID == uuid.Nil {
+		key.ID = uuid.New()
+	}
+	key.CreatedAt = time.Now().UTC()
+
+	_, err := m.db.ExecContext(ctx, "INSERT INTO api_keys (id, name, hash, prefix, rate_limit, burst, created_at) VALUES "+valuesList(1, 7),
+		key.ID, key.Name, key.Hash, key.Prefix, key.RateLimit, key.Burst, key.CreatedAt)
+	return err
+}
+
+func (m *sqlAPIKeyManager) FindByHash(ctx context.Context, hash string) (*APIKey, error) {
+	return scanAPIKey(m.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE hash = ?", hash))
+}
+
+func (m *sqlAPIKeyManager) Revoke(ctx context.Context, id uuid.UUID) (*APIKey, error) {
+	_, err := m.db.ExecContext(ctx, "UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now().UTC(), id)
+	if err != nil {
+		return nil, err
+	}
+	return scanAPIKey(m.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ?", id))
+}
+
+func (m *sqlAPIKeyManager) List(ctx context.Context) ([]*APIKey, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	key := new(APIKey)
+	var revokedAt sql.NullTime
+
+	err := row.Scan(&key.ID, &key.Name, &key.Hash, &key.Prefix, &key.RateLimit, &key.Burst, &key.CreatedAt, &revokedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrAPIKeyNotFound
+	case err != nil:
+		return nil, err
+	}
+
+	key.RevokedAt = revokedAt.Time
+	return key, nil
+}
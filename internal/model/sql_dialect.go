@@ -0,0 +1,113 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+)
+
+// sqlDialect holds what differs between the database/sql backends, the queries are written for ? placeholders
+type sqlDialect interface {
+	// InsertIgnore starts an INSERT that skips the rows whose key already exists
+	InsertIgnore(table string) string
+	// Upsert is the clause after VALUES updating columns of the rows whose key already exists
+	Upsert(key string, columns ...string) string
+	// MaxParams is the number of placeholders a single statement may have
+	MaxParams() int
+	// ValuesTable is a derived table of rows rows of placeholders, with the given column names
+	ValuesTable(rows int, columns ...string) string
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) InsertIgnore(table string) string {
+	return "INSERT OR IGNORE INTO " + table
+}
+
+func (sqliteDialect) Upsert(key string, columns ...string) string {
+	set := make([]string, 0, len(columns))
+	for _, col := range columns {
+		set = append(set, col+" = excluded."+col)
+	}
+	return "ON CONFLICT (" + key + ") DO UPDATE SET " + strings.Join(set, ", ")
+}
+
+func (sqliteDialect) MaxParams() int {
+	return 32766
+}
+
+func (sqliteDialect) ValuesTable(rows int, columns ...string) string {
+	// sqlite names the columns of a VALUES list column1, column2 and so on
+	names := make([]string, 0, len(columns))
+	for i, col := range columns {
+		names = append(names, "column"+strconv.Itoa(i+1)+" AS "+col)
+	}
+	return "(SELECT " + strings.Join(names, ", ") + " FROM (VALUES " + valuesList(rows, len(columns)) + "))"
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) InsertIgnore(table string) string {
+	return "INSERT IGNORE INTO " + table
+}
+
+func (mysqlDialect) Upsert(key string, columns ...string) string {
+	// VALUES() rather than a row alias, which needs MySQL 8.0.19
+	set := make([]string, 0, len(columns))
+	for _, col := range columns {
+		set = append(set, col+" = VALUES("+col+")")
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+}
+
+func (mysqlDialect) MaxParams() int {
+	return 65535
+}
+
+func (mysqlDialect) ValuesTable(rows int, columns ...string) string {
+	// unions of selects rather than VALUES, which needs MySQL 8.0.19 too
+	first := make([]string, 0, len(columns))
+	for _, col := range columns {
+		first = append(first, "? AS "+col)
+	}
+	selects := make([]string, 0, rows)
+	for i := 0; i < rows; i++ {
+		if i == 0 {
+			selects = append(selects, "SELECT "+strings.Join(first, ", "))
+			continue
+		}
+		selects = append(selects, "SELECT "+placeholders(len(columns)))
+	}
+	return "(" + strings.Join(selects, " UNION ALL ") + ")"
+}
+
+// valuesList returns the VALUES list of rows rows with columns placeholders each
+func valuesList(rows, columns int) string {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", columns), ", ") + ")"
+
+	var b strings.Builder
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(row)
+	}
+	return b.String()
+}
+
+// placeholders returns n comma separated placeholders, for IN lists
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// chunks splits items into slices of at most size items
+func chunks[T any](items []T, size int) [][]T {
+	var out [][]T
+	for size < len(items) {
+		out = append(out, items[:size])
+		items = items[size:]
+	}
+	if len(items) > 0 {
+		out = append(out, items)
+	}
+	return out
+}
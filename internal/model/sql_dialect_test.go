@@ -0,0 +1,60 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLDialect(t *testing.T) {
+	cases := []struct {
+		Name         string
+		Dialect      sqlDialect
+		InsertIgnore string
+		Upsert       string
+		ValuesTable  string
+	}{
+		{
+			Name:         "sqlite",
+			Dialect:      sqliteDialect{},
+			InsertIgnore: "INSERT OR IGNORE INTO import_locks",
+			Upsert:       "ON CONFLICT (ip) DO UPDATE SET city = excluded.city, modified_at = excluded.modified_at",
+			ValuesTable:  "(SELECT column1 AS n, column2 AS ip FROM (VALUES (?, ?), (?, ?)))",
+		},
+		{
+			Name:         "mysql",
+			Dialect:      mysqlDialect{},
+			InsertIgnore: "INSERT IGNORE INTO import_locks",
+			Upsert:       "ON DUPLICATE KEY UPDATE city = VALUES(city), modified_at = VALUES(modified_at)",
+			ValuesTable:  "(SELECT ? AS n, ? AS ip UNION ALL SELECT ?, ?)",
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			assert.Equal(tt.InsertIgnore, tt.Dialect.InsertIgnore("import_locks"))
+			assert.Equal(tt.Upsert, tt.Dialect.Upsert("ip", "city", "modified_at"))
+			assert.Equal(tt.ValuesTable, tt.Dialect.ValuesTable(2, "n", "ip"))
+			// a full chunk of rows stays within the placeholders of a statement
+			rows := len(chunks(make([]int, 100000), tt.Dialect.MaxParams()/geolocationParams)[0])
+			assert.LessOrEqual(rows*geolocationParams, tt.Dialect.MaxParams())
+		})
+	}
+}
+
+func TestValuesList(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("(?, ?)", valuesList(1, 2))
+	assert.Equal("(?, ?, ?), (?, ?, ?)", valuesList(2, 3))
+	assert.Equal("?, ?, ?", placeholders(3))
+}
+
+func TestChunks(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([][]int{{1, 2}, {3, 4}, {5}}, chunks([]int{1, 2, 3, 4, 5}, 2))
+	assert.Equal([][]int{{1, 2}}, chunks([]int{1, 2}, 2))
+	assert.Nil(chunks([]int{}, 2))
+}
@@ -0,0 +1,489 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ohmpatel1997/findhotel/internal/common"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+)
+
+const (
+	geolocationColumns = "id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, country_id, city_id, created_at, modified_at"
+	geolocationParams  = 15
+
+	versionColumns = "geolocation_id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, country_id, city_id, valid_from, valid_to"
+
+	changeColumns = "run_id, ip, existed, previous_country_code, previous_country, previous_city, previous_latitude, previous_longitude, previous_mystery_value, previous_country_id, previous_city_id"
+	changeParams  = 11
+)
+
+// sqlGeoLocationManager stores the geolocations through database/sql. The ranges are kept as 16 byte addresses,
+// ipv4 ones mapped into ipv6, together with their family.
+type sqlGeoLocationManager struct {
+	db      *sql.DB
+	dialect sqlDialect
+}
+
+// NewSQLiteGeoLocationManager returns a GeoLocationManager for a sqlite database migrated with migration/geolocation/sqlite
+func NewSQLiteGeoLocationManager(db *sql.DB) GeoLocationManager {
+	return &sqlGeoLocationManager{
+		db:      db,
+		dialect: sqliteDialect{},
+	}
+}
+
+// NewMySQLGeoLocationManager returns a GeoLocationManager for a mysql database migrated with migration/geolocation/mysql
+func NewMySQLGeoLocationManager(db *sql.DB) GeoLocationManager {
+	return &sqlGeoLocationManager{
+		db:      db,
+		dialect: mysqlDialect{},
+	}
+}
+
+func (m *sqlGeoLocationManager) FindDataByIP(ctx context.Context, ip string, asOf time.Time) (*Geolocation, error) {
+	addr := common.ParseIP(ip)
+	if addr == nil {
+		return nil, router.NewHttpError("invalid ip", 400)
+	}
+	key, v4 := toKey(addr)
+	family := familyOf(v4)
+
+	if !asOf.IsZero() {
+		return m.findVersion(ctx, family, key, asOf)
+	}
+
+	// a row for the ip alone is always the most specific one
+	geo, err := scanGeolocation(m.db.QueryRowContext(ctx,
+		"SELECT "+geolocationColumns+" FROM geolocations WHERE family = ? AND start_ip = ? AND end_ip = ?",
+		family, key[:], key[:]))
+	if errors.Is(err, sql.ErrNoRows) {
+		// of the ranges containing the ip, the most specific one starts last and ends first
+		geo, err = scanGeolocation(m.db.QueryRowContext(ctx,
+			"SELECT "+geolocationColumns+" FROM geolocations WHERE family = ? AND start_ip <= ? AND end_ip >= ? ORDER BY start_ip DESC, end_ip ASC LIMIT 1",
+			family, key[:], key[:]))
+	}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, router.NewHttpError("data not found with given ip", 404)
+	case err != nil:
+		return nil, router.NewHttpError(err.Error(), 500)
+	}
+	return geo, nil
+}
+
+func (m *sqlGeoLocationManager) FindDataByIPs(ctx context.Context, ips []string) ([]*IPLookup, error) {
+	lookups, addrs := newLookups(ips)
+	var positions []int
+	for i, addr := range addrs {
+		if addr != nil {
+			positions = append(positions, i)
+		}
+	}
+
+	// the subquery is the query of FindDataByIP, run for every ip of the derived table
+	const lookupParams = 3
+	for _, chunk := range chunks(positions, m.dialect.MaxParams()/lookupParams) {
+		args := make([]interface{}, 0, len(chunk)*lookupParams)
+		for _, i := range chunk {
+			key, v4 := toKey(addrs[i])
+			args = append(args, i, familyOf(v4), key[:])
+		}
+
+		rows, err := m.db.QueryContext(ctx,
+			"SELECT q.n, "+prefixColumns("g", geolocationColumns)+" FROM "+m.dialect.ValuesTable(len(chunk), "n", "family", "ip")+" q"+
+				" JOIN geolocations g ON g.id = (SELECT c.id FROM geolocations c WHERE c.family = q.family AND c.start_ip <= q.ip AND c.end_ip >= q.ip ORDER BY c.start_ip DESC, c.end_ip ASC LIMIT 1)",
+			args...)
+		if err != nil {
+			return nil, router.NewHttpError(err.Error(), 500)
+		}
+		for rows.Next() {
+			var n int
+			geo, err := scanGeolocation(positionScanner{rows, &n})
+			if err != nil {
+				rows.Close()
+				return nil, router.NewHttpError(err.Error(), 500)
+			}
+			lookups[n].Geolocation = geo
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, router.NewHttpError(err.Error(), 500)
+		}
+	}
+	notFound(lookups)
+	return lookups, nil
+}
+
+func (m *sqlGeoLocationManager) List(ctx context.Context, query *GeolocationQuery) (*GeolocationPage, error) {
+	q, err := normalizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := parseCursor(q)
+	if err != nil {
+		return nil, err
+	}
+
+	filters, args := listFilters(q)
+	conds := []string{}
+	if filters != "" {
+		conds = append(conds, filters)
+	}
+
+	var columns []string
+	switch q.Sort {
+	case SortIP:
+		columns = []string{"family", "start_ip", "end_ip", "id"}
+		if cursor != nil {
+			start, _ := toKey(net.ParseIP(cursor.StartIP))
+			end, v4 := toKey(net.ParseIP(cursor.EndIP))
+			conds = append(conds, keysetCondition(columns, []string{"?", "?", "?", "?"}, q.Desc))
+			args = append(args, familyOf(v4), start[:], end[:], cursor.ID)
+		}
+	case SortCountry, SortCity, SortLatitude, SortLongitude:
+		columns = []string{q.Sort, "id"}
+		if cursor != nil {
+			var value interface{} = cursor.Text
+			if q.Sort == SortLatitude || q.Sort == SortLongitude {
+				value = cursor.Number
+			}
+			conds = append(conds, keysetCondition(columns, []string{"?", "?"}, q.Desc))
+			args = append(args, value, cursor.ID)
+		}
+	}
+
+	stmt := "SELECT " + geolocationColumns + " FROM geolocations"
+	if len(conds) > 0 {
+		stmt += " WHERE " + strings.Join(conds, " AND ")
+	}
+	stmt += " ORDER BY " + strings.Join(sortOrder(columns, q.Desc), ", ") + " LIMIT ?"
+	args = append(args, q.Limit+1)
+
+	result, err := m.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, router.NewHttpError(err.Error(), 500)
+	}
+	defer result.Close()
+
+	var rows []*Geolocation
+	for result.Next() {
+		geo, err := scanGeolocation(result)
+		if err != nil {
+			return nil, router.NewHttpError(err.Error(), 500)
+		}
+		rows = append(rows, geo)
+	}
+	if err := result.Err(); err != nil {
+		return nil, router.NewHttpError(err.Error(), 500)
+	}
+	return newPage(q, rows), nil
+}
+
+// findVersion returns the most specific of the versions current at asOf
+func (m *sqlGeoLocationManager) findVersion(ctx context.Context, family int, key ipKey, asOf time.Time) (*Geolocation, error) {
+	geo, err := scanVersion(m.db.QueryRowContext(ctx,
+		"SELECT "+versionColumns+" FROM geolocation_history WHERE family = ? AND start_ip <= ? AND end_ip >= ? AND valid_from <= ? AND (valid_to IS NULL OR valid_to > ?) ORDER BY start_ip DESC, end_ip ASC LIMIT 1",
+		family, key[:], key[:], asOf.UTC(), asOf.UTC()))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, router.NewHttpError("data not found with given ip", 404)
+	case err != nil:
+		return nil, router.NewHttpError(err.Error(), 500)
+	}
+	return geo, nil
+}
+
+// BulkInsert upserts the rows by ip. When ctx carries an import run the overwritten rows are recorded first,
+// so the run can be rolled back later.
+func (m *sqlGeoLocationManager) BulkInsert(ctx context.Context, geolocation []*Geolocation) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op after commit
+
+	if err := m.resolveReferences(ctx, tx, geolocation); err != nil {
+		return err
+	}
+	if runID, ok := importRunFromContext(ctx); ok {
+		if err := m.recordChanges(ctx, tx, runID, geolocation); err != nil {
+			return err
+		}
+	}
+
+	upsert := m.dialect.Upsert("ip", "family", "start_ip", "end_ip", "country_code", "country", "city", "latitude", "longitude", "mystery_value",
+		"country_id", "city_id", "modified_at")
+	now := time.Now().UTC()
+	for _, chunk := range chunks(geolocation, m.dialect.MaxParams()/geolocationParams) {
+		args := make([]interface{}, 0, len(chunk)*geolocationParams)
+		for _, g := range chunk {
+			start, end, family, err := rangeKeys(g)
+			if err != nil {
+				return err
+			}
+			if g.ID == uuid.Nil {
+				g.ID = uuid.New()
+			}
+			args = append(args, g.ID, g.IP, family, start, end, g.CountryCode, g.Country, g.City, g.Latitude, g.Longitude, g.MysteryValue,
+				nullID(g.CountryID), nullID(g.CityID), now, now)
+		}
+
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO geolocations ("+geolocationColumns+") VALUES "+valuesList(len(chunk), geolocationParams)+" "+upsert,
+			args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// recordChanges snapshots the current rows of the given ips before the run overwrites them
+func (m *sqlGeoLocationManager) recordChanges(ctx context.Context, tx *sql.Tx, runID uuid.UUID, geolocation []*Geolocation) error {
+	existing := make(map[string]*Geolocation)
+	for _, chunk := range chunks(geolocation, m.dialect.MaxParams()) {
+		args := make([]interface{}, 0, len(chunk))
+		for _, g := range chunk {
+			args = append(args, g.IP)
+		}
+
+		rows, err := tx.QueryContext(ctx, "SELECT "+geolocationColumns+" FROM geolocations WHERE ip IN ("+placeholders(len(chunk))+")", args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			geo, err := scanGeolocation(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			existing[geo.IP] = geo
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	for _, chunk := range chunks(geolocation, m.dialect.MaxParams()/changeParams) {
+		args := make([]interface{}, 0, len(chunk)*changeParams)
+		for _, g := range chunk {
+			prev, ok := existing[g.IP]
+			if !ok {
+				args = append(args, runID, g.IP, false, nil, nil, nil, nil, nil, nil, nil, nil)
+				continue
+			}
+			args = append(args, runID, g.IP, true, prev.CountryCode, prev.Country, prev.City, prev.Latitude, prev.Longitude, prev.MysteryValue,
+				nullID(prev.CountryID), nullID(prev.CityID))
+		}
+
+		// a later batch of the run may write the same ip again, the first snapshot is the one to restore
+		_, err := tx.ExecContext(ctx,
+			m.dialect.InsertIgnore("import_run_changes")+" ("+changeColumns+") VALUES "+valuesList(len(chunk), changeParams),
+			args...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveReferences creates the countries and cities rows refer to that don't exist yet, and points rows to them
+func (m *sqlGeoLocationManager) resolveReferences(ctx context.Context, tx *sql.Tx, geolocation []*Geolocation) error {
+	refs := newReferences()
+	now := time.Now().UTC()
+
+	countries := collectCountries(geolocation)
+	for _, chunk := range chunks(countries, m.dialect.MaxParams()/3) {
+		args := make([]interface{}, 0, len(chunk)*3)
+		codes := make([]interface{}, 0, len(chunk))
+		for _, c := range chunk {
+			args = append(args, c.Code, c.Name, now)
+			codes = append(codes, c.Code)
+		}
+		// of two imports creating a country at the same time, the second one reads it below
+		_, err := tx.ExecContext(ctx, m.dialect.InsertIgnore("countries")+" (code, name, created_at) VALUES "+valuesList(len(chunk), 3), args...)
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, "SELECT id, code, name, created_at FROM countries WHERE code IN ("+placeholders(len(codes))+")", codes...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			c := new(Country)
+			if err := rows.Scan(&c.ID, &c.Code, &c.Name, &c.CreatedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			refs.countries[c.Code] = c
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	cities := collectCities(geolocation, refs.countries)
+	for _, chunk := range chunks(cities, m.dialect.MaxParams()/4) {
+		args := make([]interface{}, 0, len(chunk)*4)
+		filter := make([]interface{}, 0, len(chunk)*2)
+		for _, c := range chunk {
+			args = append(args, c.CountryID, c.Name, c.NameKey, now)
+		}
+		for _, c := range chunk {
+			filter = append(filter, c.CountryID)
+		}
+		for _, c := range chunk {
+			filter = append(filter, c.NameKey)
+		}
+		_, err := tx.ExecContext(ctx, m.dialect.InsertIgnore("cities")+" (country_id, name, name_key, created_at) VALUES "+valuesList(len(chunk), 4), args...)
+		if err != nil {
+			return err
+		}
+
+		// reads a few more cities than it needs, the ones of the batch are those both lists match
+		rows, err := tx.QueryContext(ctx,
+			"SELECT id, country_id, name, name_key, created_at FROM cities WHERE country_id IN ("+placeholders(len(chunk))+") AND name_key IN ("+placeholders(len(chunk))+")",
+			filter...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			c := new(City)
+			if err := rows.Scan(&c.ID, &c.CountryID, &c.Name, &c.NameKey, &c.CreatedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			refs.cities[cityRef{c.CountryID, c.NameKey}] = c
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	refs.apply(geolocation)
+	return nil
+}
+
+// NewSQLSource returns a source reading the geolocations table of a database/sql backend
+func NewSQLSource(db *sql.DB) GeoLocationSource {
+	return func(ctx context.Context, fn func(*Geolocation) error) error {
+		rows, err := db.QueryContext(ctx, "SELECT "+geolocationColumns+" FROM geolocations")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			geo, err := scanGeolocation(rows)
+			if err != nil {
+				return err
+			}
+			if err := fn(geo); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanGeolocation(row rowScanner) (*Geolocation, error) {
+	var geo Geolocation
+	var family int
+	var start, end []byte
+	var countryID, cityID sql.NullInt64
+
+	err := row.Scan(&geo.ID, &geo.IP, &family, &start, &end, &geo.CountryCode, &geo.Country, &geo.City,
+		&geo.Latitude, &geo.Longitude, &geo.MysteryValue, &countryID, &cityID, &geo.CreatedAt, &geo.ModifiedAt)
+	if err != nil {
+		return nil, err
+	}
+	geo.CountryID, geo.CityID = countryID.Int64, cityID.Int64
+	if len(start) != net.IPv6len || len(end) != net.IPv6len {
+		return nil, fmt.Errorf("geolocation %s has an invalid range", geo.IP)
+	}
+
+	geo.StartIP = net.IP(start).String()
+	geo.EndIP = net.IP(end).String()
+	return &geo, nil
+}
+
+// positionScanner scans the position of a row in a batch before its other columns
+type positionScanner struct {
+	row      rowScanner
+	position *int
+}
+
+func (s positionScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append([]interface{}{s.position}, dest...)...)
+}
+
+// prefixColumns qualifies the comma separated columns with table
+func prefixColumns(table, columns string) string {
+	cols := strings.Split(columns, ", ")
+	for i, col := range cols {
+		cols[i] = table + "." + col
+	}
+	return strings.Join(cols, ", ")
+}
+
+func scanVersion(row rowScanner) (*Geolocation, error) {
+	var geo Geolocation
+	var family int
+	var start, end []byte
+	var countryID, cityID sql.NullInt64
+	var validTo sql.NullTime
+
+	err := row.Scan(&geo.ID, &geo.IP, &family, &start, &end, &geo.CountryCode, &geo.Country, &geo.City,
+		&geo.Latitude, &geo.Longitude, &geo.MysteryValue, &countryID, &cityID, &geo.ValidFrom, &validTo)
+	if err != nil {
+		return nil, err
+	}
+	geo.CountryID, geo.CityID = countryID.Int64, cityID.Int64
+	if len(start) != net.IPv6len || len(end) != net.IPv6len {
+		return nil, fmt.Errorf("geolocation %s has an invalid range", geo.IP)
+	}
+
+	geo.StartIP = net.IP(start).String()
+	geo.EndIP = net.IP(end).String()
+	geo.ValidTo = validTo.Time
+	return &geo, nil
+}
+
+// rangeKeys returns the stored form of the range of g
+func rangeKeys(g *Geolocation) ([]byte, []byte, int, error) {
+	start, end := net.ParseIP(g.StartIP), net.ParseIP(g.EndIP)
+	if start == nil || end == nil {
+		return nil, nil, 0, fmt.Errorf("geolocation %s has an invalid range %q-%q", g.IP, g.StartIP, g.EndIP)
+	}
+	startKey, v4 := toKey(start)
+	endKey, _ := toKey(end)
+	return startKey[:], endKey[:], familyOf(v4), nil
+}
+
+// nullID stores a zero reference id as NULL
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+func familyOf(v4 bool) int {
+	if v4 {
+		return 4
+	}
+	return 6
+}
@@ -0,0 +1,558 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ohmpatel1997/findhotel/internal/common"
+	"github.com/ohmpatel1997/findhotel/lib/config"
+	"github.com/ohmpatel1997/findhotel/lib/db/sqlite"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/pressly/goose"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSQLiteDB returns a migrated sqlite database in a temporary directory
+func newSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sqlite.New(&config.Database{Dialect: sqlite.DriverName, Path: filepath.Join(t.TempDir(), "geolocation.db")})
+	if err != nil {
+		t.Fatalf("Error opening database %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := goose.SetDialect(sqlite.DriverName); err != nil {
+		t.Fatalf("Error setting dialect %v", err)
+	}
+	if err := goose.Up(db, "../../migration/geolocation/sqlite"); err != nil {
+		t.Fatalf("Error creating schema %v", err)
+	}
+	return db
+}
+
+func TestSQLiteFindDataByIP(t *testing.T) {
+	cases := []struct {
+		Name          string
+		Ip            string
+		Resp          *Geolocation
+		ExpectedError error
+	}{
+		{
+			Name: "single ip",
+			Ip:   "70.95.73.73",
+			Resp: &Geolocation{
+				IP:           "70.95.73.73",
+				StartIP:      "70.95.73.73",
+				EndIP:        "70.95.73.73",
+				Country:      "India",
+				CountryCode:  "IN",
+				City:         "Mumbai",
+				Latitude:     15.323,
+				Longitude:    145.244,
+				MysteryValue: "Mumbai",
+			},
+		},
+		{
+			Name: "most specific network",
+			Ip:   "10.1.2.3",
+			Resp: &Geolocation{
+				IP:           "10.1.0.0/16",
+				StartIP:      "10.1.0.0",
+				EndIP:        "10.1.255.255",
+				Country:      "India",
+				CountryCode:  "IN",
+				City:         "Pune",
+				MysteryValue: "2",
+			},
+		},
+		{
+			Name: "outer network",
+			Ip:   "10.3.0.1",
+			Resp: &Geolocation{
+				IP:           "10.0.0.0/8",
+				StartIP:      "10.0.0.0",
+				EndIP:        "10.255.255.255",
+				Country:      "India",
+				CountryCode:  "IN",
+				City:         "Delhi",
+				MysteryValue: "1",
+			},
+		},
+		{
+			Name: "ipv6",
+			Ip:   "2001:db8::1",
+			Resp: &Geolocation{
+				IP:           "2001:db8::/32",
+				StartIP:      "2001:db8::",
+				EndIP:        "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff",
+				Country:      "Germany",
+				CountryCode:  "DE",
+				City:         "Berlin",
+				Latitude:     52.52,
+				Longitude:    13.405,
+				MysteryValue: "3",
+			},
+		},
+		{
+			Name:          "not found",
+			Ip:            "192.0.2.1",
+			ExpectedError: router.NewHttpError("data not found with given ip", 404),
+		},
+		{
+			Name:          "invalid ip",
+			Ip:            "1235",
+			ExpectedError: router.NewHttpError("invalid ip", 400),
+		},
+	}
+
+	db := newSQLiteDB(t)
+	manager := NewSQLiteGeoLocationManager(db)
+	err := manager.BulkInsert(context.TODO(), []*Geolocation{
+		{IP: "70.95.73.73", StartIP: "70.95.73.73", EndIP: "70.95.73.73", Country: "India", CountryCode: "IN", City: "Mumbai", Latitude: 15.323, Longitude: 145.244, MysteryValue: "Mumbai"},
+		{IP: "10.0.0.0/8", StartIP: "10.0.0.0", EndIP: "10.255.255.255", Country: "India", CountryCode: "IN", City: "Delhi", MysteryValue: "1"},
+		{IP: "10.1.0.0/16", StartIP: "10.1.0.0", EndIP: "10.1.255.255", Country: "India", CountryCode: "IN", City: "Surat", MysteryValue: "2"},
+		{IP: "2001:db8::/32", StartIP: "2001:db8::", EndIP: "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", Country: "Germany", CountryCode: "DE", City: "Berlin", Latitude: 52.52, Longitude: 13.405, MysteryValue: "3"},
+	})
+	if err != nil {
+		t.Fatalf("Error inserting %v", err)
+	}
+	// upserted by ip
+	err = manager.BulkInsert(context.TODO(), []*Geolocation{
+		{IP: "10.1.0.0/16", StartIP: "10.1.0.0", EndIP: "10.1.255.255", Country: "India", CountryCode: "IN", City: "Pune", MysteryValue: "2"},
+	})
+	if err != nil {
+		t.Fatalf("Error upserting %v", err)
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			resp, err := manager.FindDataByIP(context.TODO(), tt.Ip, time.Time{})
+			if tt.ExpectedError != nil {
+				assert.Equal(tt.ExpectedError, err)
+				return
+			}
+			assert.Nil(err)
+			assert.NotEqual(uuid.Nil, resp.ID)
+			assert.False(resp.CreatedAt.IsZero())
+			assert.NotZero(resp.CountryID)
+			assert.NotZero(resp.CityID)
+			resp.ID, resp.CreatedAt, resp.ModifiedAt = uuid.Nil, time.Time{}, time.Time{}
+			resp.CountryID, resp.CityID = 0, 0
+			assert.Equal(tt.Resp, resp)
+		})
+	}
+}
+
+func TestSQLiteRollback(t *testing.T) {
+	assert := assert.New(t)
+	db := newSQLiteDB(t)
+	geolocations := NewSQLiteGeoLocationManager(db)
+	runs := NewSQLiteImportRunManager(db)
+	ledger := NewSQLiteImportLedgerManager(db)
+	ctx := context.TODO()
+
+	last, err := runs.LastChange(ctx)
+	assert.Nil(err)
+	assert.True(last.IsZero())
+
+	assert.Nil(geolocations.BulkInsert(ctx, []*Geolocation{
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", CountryCode: "IN", Country: "India", City: "Pune", Latitude: 18.52, Longitude: 73.85, MysteryValue: "1"},
+	}))
+
+	run, err := runs.Start(ctx, "dump.csv")
+	assert.Nil(err)
+	entry := &ImportLedgerEntry{RunID: run.ID, FileName: "dump.csv", SHA256: "abc", SizeBytes: 10}
+	assert.Nil(ledger.Create(ctx, entry))
+
+	runCtx := WithImportRun(ctx, run.ID)
+	assert.Nil(geolocations.BulkInsert(runCtx, []*Geolocation{
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", CountryCode: "IN", Country: "India", City: "Surat", MysteryValue: "2"},
+		{IP: "10.0.0.2", StartIP: "10.0.0.2", EndIP: "10.0.0.2", CountryCode: "IN", Country: "India", City: "Agra", MysteryValue: "3"},
+	}))
+	// a later batch writing the ip again keeps the first snapshot
+	assert.Nil(geolocations.BulkInsert(runCtx, []*Geolocation{
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", CountryCode: "IN", Country: "India", City: "Delhi", MysteryValue: "4"},
+	}))
+
+	_, err = runs.Rollback(ctx, run.ID)
+	assert.EqualError(err, "import run "+run.ID.String()+" is still running")
+
+	assert.Nil(runs.Finish(ctx, run.ID, ImportRunCompleted))
+	entry.Status = ImportRunCompleted
+	assert.Nil(ledger.Finish(ctx, entry))
+	imported, err := ledger.FindImported(ctx, "abc")
+	assert.Nil(err)
+	assert.Equal(entry.ID, imported.ID)
+
+	last, err = runs.LastChange(ctx)
+	assert.Nil(err)
+	assert.False(last.IsZero())
+
+	result, err := runs.Rollback(ctx, run.ID)
+	assert.Nil(err)
+	assert.Equal(&RollbackResult{Deleted: 1, Restored: 1}, result)
+
+	geo, err := geolocations.FindDataByIP(ctx, "10.0.0.1", time.Time{})
+	assert.Nil(err)
+	assert.Equal("Pune", geo.City)
+	assert.Equal(18.52, geo.Latitude)
+	assert.Equal("1", geo.MysteryValue)
+	_, err = geolocations.FindDataByIP(ctx, "10.0.0.2", time.Time{})
+	assert.Equal(router.NewHttpError("data not found with given ip", 404), err)
+
+	rolledBack, err := runs.FindByID(ctx, run.ID)
+	assert.Nil(err)
+	assert.Equal(ImportRunRolledBack, rolledBack.Status)
+	assert.False(rolledBack.RolledBackAt.IsZero())
+
+	// the file may be imported again
+	imported, err = ledger.FindImported(ctx, "abc")
+	assert.Nil(err)
+	assert.Nil(imported)
+	entries, err := ledger.List(ctx, 10)
+	assert.Nil(err)
+	assert.Len(entries, 1)
+	assert.Equal(ImportRunRolledBack, entries[0].Status)
+
+	_, err = runs.Rollback(ctx, run.ID)
+	assert.EqualError(err, "import run "+run.ID.String()+" was already rolled back")
+	_, err = runs.Rollback(ctx, uuid.New())
+	assert.ErrorIs(err, ErrImportRunNotFound)
+}
+
+func TestSQLiteImportLock(t *testing.T) {
+	assert := assert.New(t)
+	db := newSQLiteDB(t)
+	locks := NewSQLiteImportLockManager(db)
+	ctx := context.TODO()
+
+	first := &ImportLock{Name: "geolocations", Owner: uuid.New(), Hostname: "importer-1", PID: 1}
+	second := &ImportLock{Name: "geolocations", Owner: uuid.New(), Hostname: "importer-2", PID: 2}
+
+	acquired, _, err := locks.Acquire(ctx, first, time.Minute)
+	assert.Nil(err)
+	assert.True(acquired)
+	assert.Nil(locks.Heartbeat(ctx, first))
+
+	acquired, holder, err := locks.Acquire(ctx, second, time.Minute)
+	assert.Nil(err)
+	assert.False(acquired)
+	assert.Equal(first.Owner, holder.Owner)
+	assert.Equal("importer-1", holder.Hostname)
+
+	// taken over once the heartbeat is older than staleAfter
+	time.Sleep(10 * time.Millisecond)
+	acquired, _, err = locks.Acquire(ctx, second, time.Millisecond)
+	assert.Nil(err)
+	assert.True(acquired)
+	assert.ErrorIs(locks.Heartbeat(ctx, first), ErrImportLockLost)
+
+	assert.Nil(locks.Release(ctx, first)) // doesn't release somebody else's lock
+	acquired, _, err = locks.Acquire(ctx, first, time.Minute)
+	assert.Nil(err)
+	assert.False(acquired)
+
+	assert.Nil(locks.Release(ctx, second))
+	acquired, _, err = locks.Acquire(ctx, first, time.Minute)
+	assert.Nil(err)
+	assert.True(acquired)
+}
+
+func TestSQLiteAPIKeys(t *testing.T) {
+	assert := assert.New(t)
+	keys := NewSQLAPIKeyManager(newSQLiteDB(t))
+	ctx := context.TODO()
+
+	first := &APIKey{Name: "frontend", Hash: "hash-1", Prefix: "fh_abc", RateLimit: 600, Burst: 60}
+	assert.Nil(keys.Create(ctx, first))
+	time.Sleep(10 * time.Millisecond)
+	second := &APIKey{Name: "partner", Hash: "hash-2", Prefix: "fh_def", RateLimit: 60, Burst: 10}
+	assert.Nil(keys.Create(ctx, second))
+	assert.NotNil(keys.Create(ctx, &APIKey{Name: "duplicate", Hash: "hash-1"}))
+
+	found, err := keys.FindByHash(ctx, "hash-1")
+	assert.Nil(err)
+	assert.Equal(first.ID, found.ID)
+	assert.Equal("frontend", found.Name)
+	assert.Equal(600, found.RateLimit)
+	assert.False(found.Revoked())
+
+	_, err = keys.FindByHash(ctx, "hash-3")
+	assert.ErrorIs(err, ErrAPIKeyNotFound)
+
+	revoked, err := keys.Revoke(ctx, first.ID)
+	assert.Nil(err)
+	assert.True(revoked.Revoked())
+	again, err := keys.Revoke(ctx, first.ID)
+	assert.Nil(err)
+	assert.True(revoked.RevokedAt.Equal(again.RevokedAt))
+	_, err = keys.Revoke(ctx, uuid.New())
+	assert.ErrorIs(err, ErrAPIKeyNotFound)
+
+	list, err := keys.List(ctx)
+	assert.Nil(err)
+	if assert.Len(list, 2) {
+		assert.Equal(second.ID, list[0].ID)
+		assert.True(list[1].Revoked())
+	}
+}
+
+func TestSQLiteBulkInsertBatch(t *testing.T) {
+	assert := assert.New(t)
+	db := newSQLiteDB(t)
+	geolocations := NewSQLiteGeoLocationManager(db)
+	runs := NewSQLiteImportRunManager(db)
+
+	// a full batch of the parser needs more placeholders than a statement may have
+	batch := make([]*Geolocation, 0, 8191)
+	for i := 0; i < 8191; i++ {
+		ip := net.IPv4(10, 0, byte(i>>8), byte(i)).String()
+		batch = append(batch, &Geolocation{IP: ip, StartIP: ip, EndIP: ip, City: "Pune"})
+	}
+
+	run, err := runs.Start(context.TODO(), "dump.csv")
+	assert.Nil(err)
+	assert.Nil(geolocations.BulkInsert(WithImportRun(context.TODO(), run.ID), batch))
+
+	var count, changes int
+	assert.Nil(db.QueryRow("SELECT count(*) FROM geolocations").Scan(&count))
+	assert.Nil(db.QueryRow("SELECT count(*) FROM import_run_changes").Scan(&changes))
+	assert.Equal(8191, count)
+	assert.Equal(8191, changes)
+}
+
+func TestSQLiteFindDataByIPAsOf(t *testing.T) {
+	db := newSQLiteDB(t)
+	geolocations := NewSQLiteGeoLocationManager(db)
+	runs := NewSQLiteImportRunManager(db)
+	ctx := context.TODO()
+
+	// the writes are apart, so that each of them has its own point in time
+	tick := func() time.Time {
+		time.Sleep(5 * time.Millisecond)
+		at := time.Now()
+		time.Sleep(5 * time.Millisecond)
+		return at
+	}
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("Error writing %v", err)
+		}
+	}
+
+	beforeInsert := tick()
+	check(geolocations.BulkInsert(ctx, []*Geolocation{
+		{IP: "10.0.0.0/8", StartIP: "10.0.0.0", EndIP: "10.255.255.255", CountryCode: "IN", Country: "India", City: "Delhi", MysteryValue: "1"},
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", CountryCode: "IN", Country: "India", City: "Pune", MysteryValue: "2"},
+	}))
+	afterInsert := tick()
+
+	run, err := runs.Start(ctx, "dump.csv")
+	check(err)
+	check(geolocations.BulkInsert(WithImportRun(ctx, run.ID), []*Geolocation{
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", CountryCode: "IN", Country: "India", City: "Surat", MysteryValue: "3"},
+	}))
+	check(runs.Finish(ctx, run.ID, ImportRunCompleted))
+	afterUpdate := tick()
+
+	// writing the same values again is no new version
+	check(geolocations.BulkInsert(ctx, []*Geolocation{
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", CountryCode: "IN", Country: "India", City: "Surat", MysteryValue: "3"},
+	}))
+	var versions int
+	check(db.QueryRow("SELECT count(*) FROM geolocation_history").Scan(&versions))
+	assert.Equal(t, 3, versions)
+
+	// the rollback restores the ip as it was, which is a version of its own
+	_, err = runs.Rollback(ctx, run.ID)
+	check(err)
+	afterRollback := tick()
+
+	cases := []struct {
+		Name          string
+		Ip            string
+		AsOf          time.Time
+		ExpectedCity  string
+		ExpectedOpen  bool // the version found is still current
+		ExpectedError error
+	}{
+		{
+			Name:          "before the first import",
+			Ip:            "10.0.0.1",
+			AsOf:          beforeInsert,
+			ExpectedError: router.NewHttpError("data not found with given ip", 404),
+		},
+		{
+			Name:         "first version",
+			Ip:           "10.0.0.1",
+			AsOf:         afterInsert,
+			ExpectedCity: "Pune",
+		},
+		{
+			Name:         "updated by the run",
+			Ip:           "10.0.0.1",
+			AsOf:         afterUpdate,
+			ExpectedCity: "Surat",
+		},
+		{
+			Name:         "restored by the rollback",
+			Ip:           "10.0.0.1",
+			AsOf:         afterRollback,
+			ExpectedCity: "Pune",
+			ExpectedOpen: true,
+		},
+		{
+			Name:         "network",
+			Ip:           "10.0.0.2",
+			AsOf:         afterUpdate,
+			ExpectedCity: "Delhi",
+			ExpectedOpen: true,
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+			geo, err := geolocations.FindDataByIP(ctx, tt.Ip, tt.AsOf)
+			if tt.ExpectedError != nil {
+				assert.Equal(tt.ExpectedError, err)
+				return
+			}
+			assert.Nil(err)
+			assert.Equal(tt.ExpectedCity, geo.City)
+			assert.NotEqual(uuid.Nil, geo.ID)
+			assert.False(geo.ValidFrom.After(tt.AsOf))
+			assert.Equal(tt.ExpectedOpen, geo.ValidTo.IsZero())
+			if !tt.ExpectedOpen {
+				assert.True(geo.ValidTo.After(tt.AsOf))
+			}
+		})
+	}
+}
+
+func TestSQLiteReferences(t *testing.T) {
+	assert := assert.New(t)
+	db := newSQLiteDB(t)
+	geolocations := NewSQLiteGeoLocationManager(db)
+	runs := NewSQLiteImportRunManager(db)
+	ctx := context.TODO()
+
+	assert.Nil(geolocations.BulkInsert(ctx, []*Geolocation{
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", CountryCode: "IN", Country: "India", City: "New Delhi"},
+		{IP: "10.0.0.2", StartIP: "10.0.0.2", EndIP: "10.0.0.2", CountryCode: "in ", Country: "india", City: " new  delhi"},
+		{IP: "10.0.0.3", StartIP: "10.0.0.3", EndIP: "10.0.0.3", CountryCode: "DE", Country: "Germany", City: "Berlin"},
+		{IP: "10.0.0.4", StartIP: "10.0.0.4", EndIP: "10.0.0.4", CountryCode: "DE", Country: "Germany"},
+		{IP: "10.0.0.5", StartIP: "10.0.0.5", EndIP: "10.0.0.5", City: "Nowhere"},
+	}))
+
+	first, err := geolocations.FindDataByIP(ctx, "10.0.0.1", time.Time{})
+	assert.Nil(err)
+	second, err := geolocations.FindDataByIP(ctx, "10.0.0.2", time.Time{})
+	assert.Nil(err)
+	assert.Equal(first.CountryID, second.CountryID)
+	assert.Equal(first.CityID, second.CityID)
+	// the text is kept as imported
+	assert.Equal("in ", second.CountryCode)
+	assert.Equal("india", second.Country)
+	assert.Equal(" new  delhi", second.City)
+
+	berlin, err := geolocations.FindDataByIP(ctx, "10.0.0.3", time.Time{})
+	assert.Nil(err)
+	assert.NotEqual(first.CountryID, berlin.CountryID)
+	noCity, err := geolocations.FindDataByIP(ctx, "10.0.0.4", time.Time{})
+	assert.Nil(err)
+	assert.Equal(berlin.CountryID, noCity.CountryID)
+	assert.Zero(noCity.CityID)
+	noCountry, err := geolocations.FindDataByIP(ctx, "10.0.0.5", time.Time{})
+	assert.Nil(err)
+	assert.Zero(noCountry.CountryID)
+	assert.Zero(noCountry.CityID)
+
+	var countries, cities int
+	assert.Nil(db.QueryRow("SELECT count(*) FROM countries").Scan(&countries))
+	assert.Nil(db.QueryRow("SELECT count(*) FROM cities").Scan(&cities))
+	assert.Equal(2, countries)
+	assert.Equal(2, cities)
+
+	// a rollback restores the references with the rest of the row
+	run, err := runs.Start(ctx, "dump.csv")
+	assert.Nil(err)
+	assert.Nil(geolocations.BulkInsert(WithImportRun(ctx, run.ID), []*Geolocation{
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", CountryCode: "DE", Country: "Germany", City: "Munich"},
+	}))
+	moved, err := geolocations.FindDataByIP(ctx, "10.0.0.1", time.Time{})
+	assert.Nil(err)
+	assert.Equal(berlin.CountryID, moved.CountryID)
+	assert.NotEqual(berlin.CityID, moved.CityID)
+
+	assert.Nil(runs.Finish(ctx, run.ID, ImportRunCompleted))
+	_, err = runs.Rollback(ctx, run.ID)
+	assert.Nil(err)
+	restored, err := geolocations.FindDataByIP(ctx, "10.0.0.1", time.Time{})
+	assert.Nil(err)
+	assert.Equal(first.CountryID, restored.CountryID)
+	assert.Equal(first.CityID, restored.CityID)
+
+	// the versions keep the references of their time
+	version, err := geolocations.FindDataByIP(ctx, "10.0.0.1", moved.ModifiedAt)
+	assert.Nil(err)
+	assert.Equal("Munich", version.City)
+	assert.Equal(moved.CountryID, version.CountryID)
+	assert.Equal(moved.CityID, version.CityID)
+}
+
+func TestSQLiteReferencesBackfill(t *testing.T) {
+	assert := assert.New(t)
+	db, err := sqlite.New(&config.Database{Dialect: sqlite.DriverName, Path: filepath.Join(t.TempDir(), "geolocation.db")})
+	if err != nil {
+		t.Fatalf("Error opening database %v", err)
+	}
+	defer db.Close()
+	if err := goose.SetDialect(sqlite.DriverName); err != nil {
+		t.Fatalf("Error setting dialect %v", err)
+	}
+	if err := goose.UpTo(db, "../../migration/geolocation/sqlite", 2); err != nil {
+		t.Fatalf("Error creating schema %v", err)
+	}
+
+	// rows imported before the references, their city names differing in case and whitespace only
+	_, err = db.Exec(`INSERT INTO geolocations (id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, created_at, modified_at) VALUES
+		('1', '10.0.0.1', 4, zeroblob(16), zeroblob(16), 'IN', 'India', 'New Delhi', 0, 0, '', '2020-01-01', '2020-01-01'),
+		('2', '10.0.0.2', 4, zeroblob(16), zeroblob(16), 'in ', 'India', '  new ' || char(9) || 'DELHI', 0, 0, '', '2020-01-01', '2020-01-01'),
+		('3', '10.0.0.3', 4, zeroblob(16), zeroblob(16), 'IN', 'India', 'ČAČAK', 0, 0, '', '2020-01-01', '2020-01-01'),
+		('4', '10.0.0.4', 4, zeroblob(16), zeroblob(16), 'IN', 'India', 'Čačak', 0, 0, '', '2020-01-01', '2020-01-01')`)
+	if err != nil {
+		t.Fatalf("Error inserting rows %v", err)
+	}
+	if err := goose.Up(db, "../../migration/geolocation/sqlite"); err != nil {
+		t.Fatalf("Error migrating %v", err)
+	}
+
+	// the cities are keyed like the importer keys them
+	keys := make(map[string]bool)
+	rows, err := db.Query("SELECT name_key FROM cities")
+	assert.Nil(err)
+	for rows.Next() {
+		var key string
+		assert.Nil(rows.Scan(&key))
+		keys[key] = true
+	}
+	assert.Nil(rows.Err())
+	assert.Equal(map[string]bool{common.CityKey("New Delhi"): true, common.CityKey("Čačak"): true}, keys)
+
+	var cities, versions int
+	assert.Nil(db.QueryRow("SELECT count(DISTINCT city_id) FROM geolocations").Scan(&cities))
+	assert.Nil(db.QueryRow("SELECT count(*) FROM geolocation_history h JOIN geolocations g ON g.ip = h.ip WHERE h.city_id = g.city_id").Scan(&versions))
+	assert.Equal(2, cities)
+	assert.Equal(4, versions)
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const ledgerColumns = "id, run_id, file_name, sha256, size_bytes, status, valid_count, invalid_count, duration_seconds, created_at, finished_at"
+
+type sqlImportLedgerManager struct {
+	db *sql.DB
+}
+
+func NewSQLiteImportLedgerManager(db *sql.DB) ImportLedgerManager {
+	return &sqlImportLedgerManager{
+		db: db,
+	}
+}
+
+func NewMySQLImportLedgerManager(db *sql.DB) ImportLedgerManager {
+	return &sqlImportLedgerManager{
+		db: db,
+	}
+}
+
+func (m *sqlImportLedgerManager) FindImported(ctx context.Context, sha256 string) (*ImportLedgerEntry, error) {
+	entry, err := scanLedgerEntry(m.db.QueryRowContext(ctx,
+		"SELECT "+ledgerColumns+" FROM import_ledger WHERE sha256 = ? AND status = ? ORDER BY created_at DESC LIMIT 1",
+		sha256, ImportRunCompleted))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (m *sqlImportLedgerManager) Create(ctx context.Context, entry *ImportLedgerEntry) error {
+	entry.ID = uuid.New()
+	entry.CreatedAt = time.Now().UTC()
+	if entry.Status == "" {
+		entry.Status = ImportRunRunning
+	}
+
+	_, err := m.db.ExecContext(ctx,
+		"INSERT INTO import_ledger (id, run_id, file_name, sha256, size_bytes, status, valid_count, invalid_count, duration_seconds, created_at) VALUES "+valuesList(1, 10),
+		entry.ID, entry.RunID, entry.FileName, entry.SHA256, entry.SizeBytes, entry.Status, entry.ValidCount, entry.InvalidCount, entry.DurationSeconds, entry.CreatedAt)
+	return err
+}
+
+func (m *sqlImportLedgerManager) Finish(ctx context.Context, entry *ImportLedgerEntry) error {
+	finishedAt := time.Now().UTC()
+
+	_, err := m.db.ExecContext(ctx,
+		"UPDATE import_ledger SET status = ?, valid_count = ?, invalid_count = ?, duration_seconds = ?, finished_at = ? WHERE id = ?",
+		entry.Status, entry.ValidCount, entry.InvalidCount, entry.DurationSeconds, finishedAt, entry.ID)
+	if err != nil {
+		return err
+	}
+	entry.FinishedAt = finishedAt
+	return nil
+}
+
+func (m *sqlImportLedgerManager) List(ctx context.Context, limit int) ([]*ImportLedgerEntry, error) {
+	query := "SELECT " + ledgerColumns + " FROM import_ledger ORDER BY created_at DESC"
+	var args []interface{}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*ImportLedgerEntry
+	for rows.Next() {
+		entry, err := scanLedgerEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func scanLedgerEntry(row rowScanner) (*ImportLedgerEntry, error) {
+	var entry ImportLedgerEntry
+	var finishedAt sql.NullTime
+
+	err := row.Scan(&entry.ID, &entry.RunID, &entry.FileName, &entry.SHA256, &entry.SizeBytes, &entry.Status,
+		&entry.ValidCount, &entry.InvalidCount, &entry.DurationSeconds, &entry.CreatedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	entry.FinishedAt = finishedAt.Time
+	return &entry, nil
+}
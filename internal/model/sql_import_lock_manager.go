@@ -0,0 +1,85 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type sqlImportLockManager struct {
+	db      *sql.DB
+	dialect sqlDialect
+}
+
+func NewSQLiteImportLockManager(db *sql.DB) ImportLockManager {
+	return &sqlImportLockManager{
+		db:      db,
+		dialect: sqliteDialect{},
+	}
+}
+
+func NewMySQLImportLockManager(db *sql.DB) ImportLockManager {
+	return &sqlImportLockManager{
+		db:      db,
+		dialect: mysqlDialect{},
+	}
+}
+
+func (m *sqlImportLockManager) Acquire(ctx context.Context, lock *ImportLock, staleAfter time.Duration) (bool, *ImportLock, error) {
+	now := time.Now().UTC()
+
+	// a stale lock is dropped first; of two importers racing for the lock afterwards, only one insert succeeds
+	_, err := m.db.ExecContext(ctx, "DELETE FROM import_locks WHERE name = ? AND heartbeat_at < ?", lock.Name, now.Add(-staleAfter))
+	if err != nil {
+		return false, nil, err
+	}
+
+	res, err := m.db.ExecContext(ctx,
+		m.dialect.InsertIgnore("import_locks")+" (name, owner, hostname, pid, acquired_at, heartbeat_at) VALUES "+valuesList(1, 6),
+		lock.Name, lock.Owner, lock.Hostname, lock.PID, now, now)
+	if err != nil {
+		return false, nil, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, nil, err
+	}
+	if inserted > 0 {
+		lock.AcquiredAt, lock.HeartbeatAt = now, now
+		return true, lock, nil
+	}
+
+	holder := new(ImportLock)
+	err = m.db.QueryRowContext(ctx, "SELECT name, owner, hostname, pid, acquired_at, heartbeat_at FROM import_locks WHERE name = ?", lock.Name).
+		Scan(&holder.Name, &holder.Owner, &holder.Hostname, &holder.PID, &holder.AcquiredAt, &holder.HeartbeatAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows): // released in between, the caller may simply retry
+		return false, nil, nil
+	case err != nil:
+		return false, nil, err
+	}
+
+	return false, holder, nil
+}
+
+func (m *sqlImportLockManager) Heartbeat(ctx context.Context, lock *ImportLock) error {
+	res, err := m.db.ExecContext(ctx, "UPDATE import_locks SET heartbeat_at = ? WHERE name = ? AND owner = ?", time.Now().UTC(), lock.Name, lock.Owner)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrImportLockLost
+	}
+	return nil
+}
+
+func (m *sqlImportLockManager) Release(ctx context.Context, lock *ImportLock) error {
+	_, err := m.db.ExecContext(ctx, "DELETE FROM import_locks WHERE name = ? AND owner = ?", lock.Name, lock.Owner)
+	return err
+}
@@ -0,0 +1,175 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type sqlImportRunManager struct {
+	db *sql.DB
+}
+
+func NewSQLiteImportRunManager(db *sql.DB) ImportRunManager {
+	return &sqlImportRunManager{
+		db: db,
+	}
+}
+
+func NewMySQLImportRunManager(db *sql.DB) ImportRunManager {
+	return &sqlImportRunManager{
+		db: db,
+	}
+}
+
+func (m *sqlImportRunManager) Start(ctx context.Context, source string) (*ImportRun, error) {
+	run := &ImportRun{
+		ID:        uuid.New(),
+		Source:    source,
+		Status:    ImportRunRunning,
+		StartedAt: time.Now().UTC(),
+	}
+
+	_, err := m.db.ExecContext(ctx, "INSERT INTO import_runs (id, source, status, started_at) VALUES (?, ?, ?, ?)",
+		run.ID, run.Source, run.Status, run.StartedAt)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func (m *sqlImportRunManager) Finish(ctx context.Context, runID uuid.UUID, status string) error {
+	_, err := m.db.ExecContext(ctx, "UPDATE import_runs SET status = ?, finished_at = ? WHERE id = ?", status, time.Now().UTC(), runID)
+	return err
+}
+
+func (m *sqlImportRunManager) FindByID(ctx context.Context, runID uuid.UUID) (*ImportRun, error) {
+	return findImportRun(ctx, m.db, runID)
+}
+
+func (m *sqlImportRunManager) LastChange(ctx context.Context) (time.Time, error) {
+	var last time.Time
+	// one column per query, so that the driver knows it reads timestamps
+	for _, col := range []string{"finished_at", "rolled_back_at"} {
+		var at sql.NullTime
+		err := m.db.QueryRowContext(ctx, "SELECT "+col+" FROM import_runs WHERE "+col+" IS NOT NULL ORDER BY "+col+" DESC LIMIT 1").Scan(&at)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			continue
+		case err != nil:
+			return time.Time{}, err
+		}
+		if at.Time.After(last) {
+			last = at.Time
+		}
+	}
+	return last, nil
+}
+
+func (m *sqlImportRunManager) Rollback(ctx context.Context, runID uuid.UUID) (*RollbackResult, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // no-op after commit
+
+	run, err := findImportRun(ctx, tx, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch run.Status {
+	case ImportRunRunning:
+		return nil, fmt.Errorf("import run %s is still running", runID)
+	case ImportRunRolledBack:
+		return nil, fmt.Errorf("import run %s was already rolled back", runID)
+	}
+
+	// restoring an older run would silently undo whatever the later runs wrote
+	var later uuid.UUID
+	err = tx.QueryRowContext(ctx, "SELECT id FROM import_runs WHERE started_at > ? AND status != ? ORDER BY started_at DESC LIMIT 1",
+		run.StartedAt, ImportRunRolledBack).Scan(&later)
+	switch {
+	case err == nil:
+		return nil, fmt.Errorf("import run %s was imported after %s, roll it back first", later, runID)
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+
+	result := new(RollbackResult)
+	res, err := tx.ExecContext(ctx, `
+		DELETE FROM geolocations
+		WHERE ip IN (SELECT ip FROM import_run_changes WHERE run_id = ? AND NOT existed)`, runID)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	result.Deleted = int(deleted)
+
+	res, err = tx.ExecContext(ctx, `
+		UPDATE geolocations
+		SET country_code = (SELECT c.previous_country_code FROM import_run_changes c WHERE c.run_id = ? AND c.ip = geolocations.ip),
+			country = (SELECT c.previous_country FROM import_run_changes c WHERE c.run_id = ? AND c.ip = geolocations.ip),
+			city = (SELECT c.previous_city FROM import_run_changes c WHERE c.run_id = ? AND c.ip = geolocations.ip),
+			latitude = (SELECT c.previous_latitude FROM import_run_changes c WHERE c.run_id = ? AND c.ip = geolocations.ip),
+			longitude = (SELECT c.previous_longitude FROM import_run_changes c WHERE c.run_id = ? AND c.ip = geolocations.ip),
+			mystery_value = (SELECT c.previous_mystery_value FROM import_run_changes c WHERE c.run_id = ? AND c.ip = geolocations.ip),
+			country_id = (SELECT c.previous_country_id FROM import_run_changes c WHERE c.run_id = ? AND c.ip = geolocations.ip),
+			city_id = (SELECT c.previous_city_id FROM import_run_changes c WHERE c.run_id = ? AND c.ip = geolocations.ip),
+			modified_at = ?
+		WHERE ip IN (SELECT ip FROM import_run_changes WHERE run_id = ? AND existed)`,
+		runID, runID, runID, runID, runID, runID, runID, runID, time.Now().UTC(), runID)
+	if err != nil {
+		return nil, err
+	}
+	restored, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	result.Restored = int(restored)
+
+	_, err = tx.ExecContext(ctx, "UPDATE import_runs SET status = ?, rolled_back_at = ? WHERE id = ?", ImportRunRolledBack, time.Now().UTC(), runID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the files of a rolled back run may be imported again
+	_, err = tx.ExecContext(ctx, "UPDATE import_ledger SET status = ? WHERE run_id = ?", ImportRunRolledBack, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func findImportRun(ctx context.Context, db queryRower, runID uuid.UUID) (*ImportRun, error) {
+	run := new(ImportRun)
+	var finishedAt, rolledBackAt sql.NullTime
+
+	err := db.QueryRowContext(ctx, "SELECT id, source, status, started_at, finished_at, rolled_back_at FROM import_runs WHERE id = ?", runID).
+		Scan(&run.ID, &run.Source, &run.Status, &run.StartedAt, &finishedAt, &rolledBackAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrImportRunNotFound
+	case err != nil:
+		return nil, err
+	}
+
+	run.FinishedAt = finishedAt.Time
+	run.RolledBackAt = rolledBackAt.Time
+	return run, nil
+}
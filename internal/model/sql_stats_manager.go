@@ -0,0 +1,79 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/ohmpatel1997/findhotel/lib/router"
+)
+
+type sqlStatsManager struct {
+	db *sql.DB
+}
+
+// NewSQLStatsManager returns the aggregations of a sqlite or mysql database, the queries are the same for both
+func NewSQLStatsManager(db *sql.DB) StatsManager {
+	return &sqlStatsManager{
+		db: db,
+	}
+}
+
+func (m *sqlStatsManager) CountryStats(ctx context.Context) ([]*CountryStats, error) {
+	rows, err := m.db.QueryContext(ctx, countryStatsQuery)
+	if err != nil {
+		return nil, router.NewHttpError(err.Error(), 500)
+	}
+	defer rows.Close()
+
+	stats := []*CountryStats{}
+	for rows.Next() {
+		s := new(countryStatsRow)
+		err := rows.Scan(&s.CountryID, &s.CountryCode, &s.Country, &s.IPs, &s.Cities,
+			&s.Latitude, &s.Longitude, &s.MinLatitude, &s.MinLongitude, &s.MaxLatitude, &s.MaxLongitude,
+			&s.ShiftedLongitude, &s.MinShiftedLongitude, &s.MaxShiftedLongitude)
+		if err != nil {
+			return nil, router.NewHttpError(err.Error(), 500)
+		}
+		s.resolve(&s.Extent)
+		stats = append(stats, &s.CountryStats)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, router.NewHttpError(err.Error(), 500)
+	}
+	return stats, nil
+}
+
+func (m *sqlStatsManager) CityStats(ctx context.Context, countryCode string) ([]*CityStats, error) {
+	var countryID int64
+	err := m.db.QueryRowContext(ctx, "SELECT id FROM countries WHERE code = ?", countryKey(countryCode)).Scan(&countryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errCountryNotFound()
+	}
+	if err != nil {
+		return nil, router.NewHttpError(err.Error(), 500)
+	}
+
+	rows, err := m.db.QueryContext(ctx, cityStatsQuery, countryID)
+	if err != nil {
+		return nil, router.NewHttpError(err.Error(), 500)
+	}
+	defer rows.Close()
+
+	stats := []*CityStats{}
+	for rows.Next() {
+		s := new(cityStatsRow)
+		err := rows.Scan(&s.CityID, &s.City, &s.IPs,
+			&s.Latitude, &s.Longitude, &s.MinLatitude, &s.MinLongitude, &s.MaxLatitude, &s.MaxLongitude,
+			&s.ShiftedLongitude, &s.MinShiftedLongitude, &s.MaxShiftedLongitude)
+		if err != nil {
+			return nil, router.NewHttpError(err.Error(), 500)
+		}
+		s.resolve(&s.Extent)
+		stats = append(stats, &s.CityStats)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, router.NewHttpError(err.Error(), 500)
+	}
+	return stats, nil
+}
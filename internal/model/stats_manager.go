@@ -0,0 +1,263 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+)
+
+// Extent is where the points of a country or city lie. The centroid is the mean of their coordinates. Points on both
+// sides of the antimeridian are taken around it rather than around the whole globe, MinLongitude being then greater
+// than MaxLongitude like for a BoundingBox.
+type Extent struct {
+	Latitude     float64 `pg:"latitude"`
+	Longitude    float64 `pg:"longitude"`
+	MinLatitude  float64 `pg:"min_latitude"`
+	MinLongitude float64 `pg:"min_longitude"`
+	MaxLatitude  float64 `pg:"max_latitude"`
+	MaxLongitude float64 `pg:"max_longitude"`
+}
+
+type CountryStats struct {
+	CountryID   int64  `pg:"country_id"`
+	CountryCode string `pg:"country_code"`
+	Country     string `pg:"country"`
+	IPs         int64  `pg:"ips"`    // networks and ranges count once
+	Cities      int64  `pg:"cities"` // distinct cities of the geolocations
+	Extent
+}
+
+type CityStats struct {
+	CityID int64  `pg:"city_id"`
+	City   string `pg:"city"`
+	IPs    int64  `pg:"ips"`
+	Extent
+}
+
+// shiftedExtent are the longitudes of an Extent aggregated once more, shifted from [-180, 180) to [0, 360), so that
+// the points on both sides of the antimeridian are next to each other
+type shiftedExtent struct {
+	ShiftedLongitude    float64 `pg:"shifted_longitude"`
+	MinShiftedLongitude float64 `pg:"min_shifted_longitude"`
+	MaxShiftedLongitude float64 `pg:"max_shifted_longitude"`
+}
+
+// resolve takes the longitudes of e from the shifted ones when the points spread less across the antimeridian than
+// across the prime meridian
+func (s *shiftedExtent) resolve(e *Extent) {
+	if s.MaxShiftedLongitude-s.MinShiftedLongitude >= e.MaxLongitude-e.MinLongitude {
+		return
+	}
+	e.Longitude = unshiftLongitude(s.ShiftedLongitude)
+	e.MinLongitude = unshiftLongitude(s.MinShiftedLongitude)
+	e.MaxLongitude = unshiftLongitude(s.MaxShiftedLongitude)
+}
+
+func shiftLongitude(longitude float64) float64 {
+	if longitude < 0 {
+		return longitude + 360
+	}
+	return longitude
+}
+
+func unshiftLongitude(longitude float64) float64 {
+	if longitude >= 180 {
+		return longitude - 360
+	}
+	return longitude
+}
+
+type countryStatsRow struct {
+	CountryStats
+	shiftedExtent
+}
+
+type cityStatsRow struct {
+	CityStats
+	shiftedExtent
+}
+
+//go:generate mockery --name StatsManager --output=mocks
+type StatsManager interface {
+	// CountryStats returns the countries with geolocations, those with the most first
+	CountryStats(ctx context.Context) ([]*CountryStats, error)
+	// CityStats returns the cities of the country with geolocations, those with the most first
+	CityStats(ctx context.Context, countryCode string) ([]*CityStats, error)
+}
+
+func errCountryNotFound() error {
+	return router.NewHttpError("country not found", 404)
+}
+
+const (
+	// statsTimeout is how long an aggregation shared by the callers of a cachedStatsManager may take
+	statsTimeout = time.Minute
+
+	shiftedLongitude = `CASE WHEN g.longitude < 0 THEN g.longitude + 360 ELSE g.longitude END`
+
+	extentColumns = `avg(g.latitude) AS latitude, avg(g.longitude) AS longitude,
+	min(g.latitude) AS min_latitude, min(g.longitude) AS min_longitude,
+	max(g.latitude) AS max_latitude, max(g.longitude) AS max_longitude,
+	avg(` + shiftedLongitude + `) AS shifted_longitude,
+	min(` + shiftedLongitude + `) AS min_shifted_longitude,
+	max(` + shiftedLongitude + `) AS max_shifted_longitude`
+
+	countryStatsQuery = `SELECT co.id AS country_id, co.code AS country_code, co.name AS country,
+	count(*) AS ips, count(DISTINCT g.city_id) AS cities, ` + extentColumns + `
+	FROM countries co
+	JOIN geolocations g ON g.country_id = co.id
+	GROUP BY co.id, co.code, co.name
+	ORDER BY ips DESC, co.code`
+
+	cityStatsQuery = `SELECT ci.id AS city_id, ci.name AS city, count(*) AS ips, ` + extentColumns + `
+	FROM cities ci
+	JOIN geolocations g ON g.city_id = ci.id
+	WHERE ci.country_id = ?
+	GROUP BY ci.id, ci.name
+	ORDER BY ips DESC, ci.name`
+)
+
+type statsManager struct {
+	db *pg.DB
+}
+
+func NewStatsManager(db *pg.DB) StatsManager {
+	return &statsManager{
+		db: db,
+	}
+}
+
+func (m *statsManager) CountryStats(ctx context.Context) ([]*CountryStats, error) {
+	var rows []*countryStatsRow
+	if _, err := m.db.QueryContext(ctx, &rows, countryStatsQuery); err != nil {
+		return nil, router.NewHttpError(err.Error(), 500)
+	}
+
+	stats := make([]*CountryStats, 0, len(rows))
+	for _, row := range rows {
+		row.resolve(&row.Extent)
+		stats = append(stats, &row.CountryStats)
+	}
+	return stats, nil
+}
+
+func (m *statsManager) CityStats(ctx context.Context, countryCode string) ([]*CityStats, error) {
+	country := new(Country)
+	err := m.db.ModelContext(ctx, country).Where("code = ?", countryKey(countryCode)).Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, errCountryNotFound()
+	}
+	if err != nil {
+		return nil, router.NewHttpError(err.Error(), 500)
+	}
+
+	var rows []*cityStatsRow
+	if _, err := m.db.QueryContext(ctx, &rows, cityStatsQuery, country.ID); err != nil {
+		return nil, router.NewHttpError(err.Error(), 500)
+	}
+
+	stats := make([]*CityStats, 0, len(rows))
+	for _, row := range rows {
+		row.resolve(&row.Extent)
+		stats = append(stats, &row.CityStats)
+	}
+	return stats, nil
+}
+
+// CachedStatsManager keeps the aggregations of a StatsManager until they are invalidated
+type CachedStatsManager interface {
+	StatsManager
+	// Invalidate drops the cached aggregations, after the data changed
+	Invalidate()
+}
+
+type cachedStatsManager struct {
+	next StatsManager
+
+	mu         sync.Mutex
+	countries  []*CountryStats
+	cities     map[string][]*CityStats // by country code
+	generation int64                   // bumped by Invalidate, so aggregations started before aren't kept
+
+	calls callGroup
+}
+
+// NewCachedStatsManager returns a cache in front of next. The aggregations scan the whole table, so they are kept
+// until Invalidate rather than for a ttl, and concurrent misses share one query, which runs until it is done or
+// statsTimeout passes even if the caller that started it gives up.
+func NewCachedStatsManager(next StatsManager) CachedStatsManager {
+	return &cachedStatsManager{
+		next:   next,
+		cities: make(map[string][]*CityStats),
+	}
+}
+
+func (m *cachedStatsManager) CountryStats(ctx context.Context) ([]*CountryStats, error) {
+	m.mu.Lock()
+	countries, generation := m.countries, m.generation
+	m.mu.Unlock()
+	if countries != nil {
+		return countries, nil
+	}
+
+	v, err, _ := m.calls.Do(ctx, "countries", func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+		defer cancel()
+		countries, err := m.next.CountryStats(ctx)
+		if err != nil {
+			return nil, err
+		}
+		m.mu.Lock()
+		if generation == m.generation {
+			m.countries = countries
+		}
+		m.mu.Unlock()
+		return countries, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]*CountryStats), nil
+}
+
+func (m *cachedStatsManager) CityStats(ctx context.Context, countryCode string) ([]*CityStats, error) {
+	code := countryKey(countryCode)
+	m.mu.Lock()
+	cities, ok := m.cities[code]
+	generation := m.generation
+	m.mu.Unlock()
+	if ok {
+		return cities, nil
+	}
+
+	v, err, _ := m.calls.Do(ctx, "cities "+code, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+		defer cancel()
+		cities, err := m.next.CityStats(ctx, code)
+		if err != nil {
+			return nil, err
+		}
+		m.mu.Lock()
+		if generation == m.generation {
+			m.cities[code] = cities
+		}
+		m.mu.Unlock()
+		return cities, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]*CityStats), nil
+}
+
+func (m *cachedStatsManager) Invalidate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.countries = nil
+	m.cities = make(map[string][]*CityStats)
+	m.generation++
+}
@@ -0,0 +1,208 @@
+package model
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	rows := []*Geolocation{
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", CountryCode: "DE", Country: "Germany", City: "Berlin", Latitude: 52, Longitude: 13},
+		{IP: "10.0.0.2", StartIP: "10.0.0.2", EndIP: "10.0.0.2", CountryCode: "DE", Country: "Germany", City: "Berlin", Latitude: 53, Longitude: 14},
+		{IP: "10.0.0.3", StartIP: "10.0.0.3", EndIP: "10.0.0.3", CountryCode: "DE", Country: "Germany", City: "Munich", Latitude: 48, Longitude: 12},
+		{IP: "10.0.0.4", StartIP: "10.0.0.4", EndIP: "10.0.0.4", CountryCode: "DE", Country: "Germany", Latitude: 51, Longitude: 9},
+		{IP: "10.0.0.5", StartIP: "10.0.0.5", EndIP: "10.0.0.5", CountryCode: "FR", Country: "France", City: "Paris", Latitude: 49, Longitude: 2},
+		{IP: "10.0.0.6", StartIP: "10.0.0.6", EndIP: "10.0.0.6", City: "Nowhere"},
+	}
+
+	db := newSQLiteDB(t)
+	if err := NewSQLiteGeoLocationManager(db).BulkInsert(context.TODO(), rows); err != nil {
+		t.Fatalf("Error inserting rows %v", err)
+	}
+	var stored []*Geolocation
+	err := NewSQLSource(db)(context.TODO(), func(g *Geolocation) error {
+		stored = append(stored, g)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error reading rows %v", err)
+	}
+	managers := map[string]StatsManager{"sqlite": NewSQLStatsManager(db), "memory": NewMemoryStatsManager(sliceSource(stored))}
+
+	for name, manager := range managers {
+		manager := manager
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			ctx := context.TODO()
+
+			countries, err := manager.CountryStats(ctx)
+			assert.Nil(err)
+			assert.Len(countries, 2)
+			de := countries[0]
+			assert.NotZero(de.CountryID)
+			de.CountryID = 0
+			assert.Equal(&CountryStats{
+				CountryCode: "DE",
+				Country:     "Germany",
+				IPs:         4,
+				Cities:      2,
+				Extent:      Extent{Latitude: 51, Longitude: 12, MinLatitude: 48, MinLongitude: 9, MaxLatitude: 53, MaxLongitude: 14},
+			}, de)
+			assert.Equal("FR", countries[1].CountryCode)
+			assert.Equal(int64(1), countries[1].Cities)
+
+			cities, err := manager.CityStats(ctx, "de")
+			assert.Nil(err)
+			assert.Len(cities, 2)
+			berlin := cities[0]
+			assert.NotZero(berlin.CityID)
+			berlin.CityID = 0
+			assert.Equal(&CityStats{
+				City:   "Berlin",
+				IPs:    2,
+				Extent: Extent{Latitude: 52.5, Longitude: 13.5, MinLatitude: 52, MinLongitude: 13, MaxLatitude: 53, MaxLongitude: 14},
+			}, berlin)
+			assert.Equal("Munich", cities[1].City)
+
+			_, err = manager.CityStats(ctx, "XX")
+			assert.Equal(router.NewHttpError("country not found", 404), err)
+		})
+	}
+}
+
+func TestStatsAntimeridian(t *testing.T) {
+	rows := []*Geolocation{
+		{IP: "10.0.0.1", StartIP: "10.0.0.1", EndIP: "10.0.0.1", CountryCode: "FJ", Country: "Fiji", City: "Suva", Latitude: -18, Longitude: 178},
+		{IP: "10.0.0.2", StartIP: "10.0.0.2", EndIP: "10.0.0.2", CountryCode: "FJ", Country: "Fiji", City: "Suva", Latitude: -17, Longitude: 179},
+		{IP: "10.0.0.3", StartIP: "10.0.0.3", EndIP: "10.0.0.3", CountryCode: "FJ", Country: "Fiji", City: "Lambasa", Latitude: -16, Longitude: -179},
+		{IP: "10.0.0.4", StartIP: "10.0.0.4", EndIP: "10.0.0.4", CountryCode: "FR", Country: "France", City: "Paris", Latitude: 49, Longitude: 2},
+		{IP: "10.0.0.5", StartIP: "10.0.0.5", EndIP: "10.0.0.5", CountryCode: "FR", Country: "France", City: "Brest", Latitude: 48, Longitude: -4},
+	}
+
+	db := newSQLiteDB(t)
+	if err := NewSQLiteGeoLocationManager(db).BulkInsert(context.TODO(), rows); err != nil {
+		t.Fatalf("Error inserting rows %v", err)
+	}
+	managers := map[string]StatsManager{"sqlite": NewSQLStatsManager(db), "memory": NewMemoryStatsManager(sliceSource(rows))}
+
+	for name, manager := range managers {
+		manager := manager
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			countries, err := manager.CountryStats(context.TODO())
+			assert.Nil(err)
+			assert.Len(countries, 2)
+			// the points of fiji lie across the antimeridian, those of france across the prime meridian
+			assert.Equal(Extent{Latitude: -17, Longitude: 179.33333333333334, MinLatitude: -18, MinLongitude: 178, MaxLatitude: -16, MaxLongitude: -179}, countries[0].Extent)
+			assert.Equal(Extent{Latitude: 48.5, Longitude: -1, MinLatitude: 48, MinLongitude: -4, MaxLatitude: 49, MaxLongitude: 2}, countries[1].Extent)
+
+			cities, err := manager.CityStats(context.TODO(), "FJ")
+			assert.Nil(err)
+			assert.Len(cities, 2)
+			assert.Equal(Extent{Latitude: -17.5, Longitude: 178.5, MinLatitude: -18, MinLongitude: 178, MaxLatitude: -17, MaxLongitude: 179}, cities[0].Extent)
+			assert.Equal(Extent{Latitude: -16, Longitude: -179, MinLatitude: -16, MinLongitude: -179, MaxLatitude: -16, MaxLongitude: -179}, cities[1].Extent)
+		})
+	}
+}
+
+type countingStats struct {
+	calls   int32
+	err     error
+	release chan struct{} // blocks the aggregations until closed, when set
+}
+
+func (m *countingStats) CountryStats(ctx context.Context) ([]*CountryStats, error) {
+	atomic.AddInt32(&m.calls, 1)
+	if m.release != nil {
+		<-m.release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	return []*CountryStats{{CountryCode: "DE"}}, nil
+}
+
+func (m *countingStats) CityStats(ctx context.Context, countryCode string) ([]*CityStats, error) {
+	atomic.AddInt32(&m.calls, 1)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return []*CityStats{{City: "Berlin"}}, nil
+}
+
+func TestCachedStats(t *testing.T) {
+	assert := assert.New(t)
+	next := &countingStats{}
+	stats := NewCachedStatsManager(next)
+	ctx := context.TODO()
+
+	for i := 0; i < 3; i++ {
+		countries, err := stats.CountryStats(ctx)
+		assert.Nil(err)
+		assert.Equal("DE", countries[0].CountryCode)
+		cities, err := stats.CityStats(ctx, "de")
+		assert.Nil(err)
+		assert.Equal("Berlin", cities[0].City)
+		_, err = stats.CityStats(ctx, "DE")
+		assert.Nil(err)
+	}
+	assert.Equal(int32(2), next.calls)
+
+	stats.Invalidate()
+	_, err := stats.CountryStats(ctx)
+	assert.Nil(err)
+	assert.Equal(int32(3), next.calls)
+
+	// errors aren't cached
+	next.err = router.NewHttpError("country not found", 404)
+	_, err = stats.CityStats(ctx, "XX")
+	assert.Equal(next.err, err)
+	_, err = stats.CityStats(ctx, "XX")
+	assert.Equal(next.err, err)
+	assert.Equal(int32(5), next.calls)
+}
+
+func TestCachedStatsCallerCancelled(t *testing.T) {
+	assert := assert.New(t)
+	next := &countingStats{release: make(chan struct{})}
+	stats := NewCachedStatsManager(next)
+
+	// the caller starting the aggregation gives up, the one waiting for it still gets the stats
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := stats.CountryStats(ctx)
+		first <- err
+	}()
+	assert.Eventually(func() bool { return atomic.LoadInt32(&next.calls) == 1 }, time.Second, time.Millisecond)
+
+	second := make(chan []*CountryStats)
+	go func() {
+		countries, _ := stats.CountryStats(context.TODO())
+		second <- countries
+	}()
+
+	cancel()
+	assert.ErrorIs(<-first, context.Canceled)
+	close(next.release)
+	if countries := <-second; assert.Len(countries, 1) {
+		assert.Equal("DE", countries[0].CountryCode)
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&next.calls))
+
+	// and it was cached
+	_, err := stats.CountryStats(context.TODO())
+	assert.Nil(err)
+	assert.Equal(int32(1), atomic.LoadInt32(&next.calls))
+}
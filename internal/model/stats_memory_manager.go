@@ -0,0 +1,157 @@
+package model
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ohmpatel1997/findhotel/internal/common"
+)
+
+type memoryStatsManager struct {
+	src GeoLocationSource
+}
+
+// NewMemoryStatsManager aggregates the geolocations of src, for the in-memory lookups. Every call reads the whole
+// source, so it is meant to be cached.
+func NewMemoryStatsManager(src GeoLocationSource) StatsManager {
+	return &memoryStatsManager{
+		src: src,
+	}
+}
+
+// extentBuilder adds up the points of an Extent
+type extentBuilder struct {
+	ips                                   int64
+	latitude, longitude, shiftedLongitude float64 // sums
+	extent                                Extent
+	shifted                               shiftedExtent
+}
+
+func (b *extentBuilder) add(g *Geolocation) {
+	shifted := shiftLongitude(g.Longitude)
+	if b.ips == 0 || g.Latitude < b.extent.MinLatitude {
+		b.extent.MinLatitude = g.Latitude
+	}
+	if b.ips == 0 || g.Longitude < b.extent.MinLongitude {
+		b.extent.MinLongitude = g.Longitude
+	}
+	if b.ips == 0 || shifted < b.shifted.MinShiftedLongitude {
+		b.shifted.MinShiftedLongitude = shifted
+	}
+	if b.ips == 0 || g.Latitude > b.extent.MaxLatitude {
+		b.extent.MaxLatitude = g.Latitude
+	}
+	if b.ips == 0 || g.Longitude > b.extent.MaxLongitude {
+		b.extent.MaxLongitude = g.Longitude
+	}
+	if b.ips == 0 || shifted > b.shifted.MaxShiftedLongitude {
+		b.shifted.MaxShiftedLongitude = shifted
+	}
+	b.ips++
+	b.latitude += g.Latitude
+	b.longitude += g.Longitude
+	b.shiftedLongitude += shifted
+}
+
+func (b *extentBuilder) build() Extent {
+	extent := b.extent
+	extent.Latitude = b.latitude / float64(b.ips)
+	extent.Longitude = b.longitude / float64(b.ips)
+	shifted := b.shifted
+	shifted.ShiftedLongitude = b.shiftedLongitude / float64(b.ips)
+	shifted.resolve(&extent)
+	return extent
+}
+
+func (m *memoryStatsManager) CountryStats(ctx context.Context) ([]*CountryStats, error) {
+	type country struct {
+		stats  *CountryStats
+		extent extentBuilder
+		cities map[string]bool
+	}
+	countries := make(map[string]*country)
+	err := m.src(ctx, func(g *Geolocation) error {
+		code := countryKey(g.CountryCode)
+		if code == "" {
+			return nil
+		}
+		c, ok := countries[code]
+		if !ok {
+			c = &country{
+				stats:  &CountryStats{CountryID: g.CountryID, CountryCode: code, Country: g.Country},
+				cities: make(map[string]bool),
+			}
+			countries[code] = c
+		}
+		c.extent.add(g)
+		if key := common.CityKey(g.City); key != "" {
+			c.cities[key] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]*CountryStats, 0, len(countries))
+	for _, c := range countries {
+		c.stats.IPs = c.extent.ips
+		c.stats.Cities = int64(len(c.cities))
+		c.stats.Extent = c.extent.build()
+		stats = append(stats, c.stats)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].IPs != stats[j].IPs {
+			return stats[i].IPs > stats[j].IPs
+		}
+		return stats[i].CountryCode < stats[j].CountryCode
+	})
+	return stats, nil
+}
+
+func (m *memoryStatsManager) CityStats(ctx context.Context, countryCode string) ([]*CityStats, error) {
+	type city struct {
+		stats  *CityStats
+		extent extentBuilder
+	}
+	code := countryKey(countryCode)
+	found := false
+	cities := make(map[string]*city)
+	err := m.src(ctx, func(g *Geolocation) error {
+		if code == "" || countryKey(g.CountryCode) != code {
+			return nil
+		}
+		found = true
+		key := common.CityKey(g.City)
+		if key == "" {
+			return nil
+		}
+		c, ok := cities[key]
+		if !ok {
+			c = &city{stats: &CityStats{CityID: g.CityID, City: g.City}}
+			cities[key] = c
+		}
+		c.extent.add(g)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errCountryNotFound()
+	}
+
+	stats := make([]*CityStats, 0, len(cities))
+	for _, c := range cities {
+		c.stats.IPs = c.extent.ips
+		c.stats.Extent = c.extent.build()
+		stats = append(stats, c.stats)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].IPs != stats[j].IPs {
+			return stats[i].IPs > stats[j].IPs
+		}
+		return stats[i].City < stats[j].City
+	})
+	return stats, nil
+}
@@ -0,0 +1,129 @@
+package rpc
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	metadataAuthorization = "authorization"
+	metadataAPIKey        = "x-api-key"
+	metadataRetryAfter    = "retry-after"
+
+	bearerPrefix = "bearer "
+)
+
+// APIKeyInterceptors returns the server options letting through the calls with a valid key, as an authorization:
+// Bearer or an x-api-key metadata, as long as the token bucket of the key has a token left, like the /v1 endpoints.
+// A unary call takes a token, and a stream one per message it receives. Calls without a valid key are
+// UNAUTHENTICATED and the ones over the limit RESOURCE_EXHAUSTED with a retry-after header in seconds. The health
+// checks pass without a key, so that the probes need none.
+func APIKeyInterceptors(limiter *router.APIKeyLimiter) []grpc.ServerOption {
+	a := &apiKeyInterceptor{limiter: limiter}
+	return []grpc.ServerOption{grpc.ChainUnaryInterceptor(a.unary), grpc.ChainStreamInterceptor(a.stream)}
+}
+
+type apiKeyInterceptor struct {
+	limiter *router.APIKeyLimiter
+}
+
+func (a *apiKeyInterceptor) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if isHealthCheck(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	key, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.take(key, func(md metadata.MD) error { return grpc.SetHeader(ctx, md) }); err != nil {
+		return nil, err
+	}
+	return handler(router.WithAPIKey(ctx, key), req)
+}
+
+func (a *apiKeyInterceptor) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isHealthCheck(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	key, err := a.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &limitedStream{ServerStream: ss, ctx: router.WithAPIKey(ss.Context(), key), interceptor: a, key: key})
+}
+
+// authenticate returns the key of the metadata of ctx, or the status of a call without a valid one
+func (a *apiKeyInterceptor) authenticate(ctx context.Context) (*router.APIKey, error) {
+	presented := presentedAPIKey(ctx)
+	if presented == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing api key, expected an authorization: Bearer or x-api-key metadata")
+	}
+	key, err := a.limiter.Authenticate(ctx, presented)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if key == nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid api key")
+	}
+	return key, nil
+}
+
+// take takes a token from the bucket of key, setting the retry-after header with setHeader when there is none left
+func (a *apiKeyInterceptor) take(key *router.APIKey, setHeader func(metadata.MD) error) error {
+	if key.Rate <= 0 {
+		return nil
+	}
+	limit := a.limiter.Take(key)
+	if limit.Allowed {
+		return nil
+	}
+	_ = setHeader(metadata.Pairs(metadataRetryAfter, strconv.Itoa(limit.RetryAfterSeconds())))
+	return statusError(router.RateLimitError(limit))
+}
+
+// limitedStream takes a token for every message received on a stream
+type limitedStream struct {
+	grpc.ServerStream
+	ctx         context.Context
+	interceptor *apiKeyInterceptor
+	key         *router.APIKey
+}
+
+func (s *limitedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *limitedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.interceptor.take(s.key, s.ServerStream.SetHeader)
+}
+
+// presentedAPIKey returns the key of the authorization metadata, or else of x-api-key
+func presentedAPIKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, auth := range md.Get(metadataAuthorization) {
+		if len(auth) > len(bearerPrefix) && strings.EqualFold(auth[:len(bearerPrefix)], bearerPrefix) {
+			return strings.TrimSpace(auth[len(bearerPrefix):])
+		}
+	}
+	for _, key := range md.Get(metadataAPIKey) {
+		if key = strings.TrimSpace(key); key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+func isHealthCheck(method string) bool {
+	return strings.HasPrefix(method, "/"+healthpb.Health_ServiceDesc.ServiceName+"/")
+}
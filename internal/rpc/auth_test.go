@@ -0,0 +1,121 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	geolocationv1 "github.com/ohmpatel1997/findhotel/api/geolocation/v1"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// testKeyStore knows the keys of its map
+type testKeyStore map[string]*router.APIKey
+
+func (s testKeyStore) Authenticate(ctx context.Context, key string) (*router.APIKey, error) {
+	return s[key], nil
+}
+
+func newAuthTestClient(t *testing.T) geolocationv1.GeoLocationClient {
+	limiter := router.NewAPIKeyLimiter(testKeyStore{
+		"fh_frontend": {ID: "1", Name: "frontend", Rate: 1.0 / 60, Burst: 2},
+		"fh_internal": {ID: "2", Name: "internal"},
+	}, router.APIKeyOptions{})
+	return geolocationv1.NewGeoLocationClient(newTestClient(t, testRows, APIKeyInterceptors(limiter)...))
+}
+
+func TestAPIKeyInterceptors(t *testing.T) {
+	cases := []struct {
+		Name         string
+		Metadata     metadata.MD
+		ExpectedCode codes.Code
+	}{
+		{
+			Name:     "bearer",
+			Metadata: metadata.Pairs("authorization", "Bearer fh_internal"),
+		},
+		{
+			Name:     "x-api-key",
+			Metadata: metadata.Pairs("x-api-key", "fh_internal"),
+		},
+		{
+			Name:         "missing",
+			ExpectedCode: codes.Unauthenticated,
+		},
+		{
+			Name:         "unknown",
+			Metadata:     metadata.Pairs("authorization", "bearer fh_unknown"),
+			ExpectedCode: codes.Unauthenticated,
+		},
+		{
+			Name:         "not a bearer",
+			Metadata:     metadata.Pairs("authorization", "Basic fh_internal"),
+			ExpectedCode: codes.Unauthenticated,
+		},
+	}
+
+	client := newAuthTestClient(t)
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			ctx := metadata.NewOutgoingContext(context.TODO(), tt.Metadata)
+			resp, err := client.Lookup(ctx, &geolocationv1.LookupRequest{IpAddress: "10.1.2.3"})
+			assert.Equal(tt.ExpectedCode, status.Code(err))
+			if tt.ExpectedCode == codes.OK {
+				assert.Equal("Surat", resp.GetGeolocation().GetCity())
+			}
+		})
+	}
+}
+
+func TestAPIKeyInterceptorsRateLimit(t *testing.T) {
+	assert := assert.New(t)
+	client := newAuthTestClient(t)
+	ctx := metadata.NewOutgoingContext(context.TODO(), metadata.Pairs("x-api-key", "fh_frontend"))
+
+	// the bucket holds 2 calls, and the messages of a stream take from it too
+	_, err := client.Lookup(ctx, &geolocationv1.LookupRequest{IpAddress: "10.1.2.3"})
+	assert.Nil(err)
+
+	stream, err := client.StreamLookup(ctx)
+	if err != nil {
+		t.Fatalf("Error opening the stream %v", err)
+	}
+	assert.Nil(stream.Send(&geolocationv1.LookupRequest{IpAddress: "10.1.2.3"}))
+	result, err := stream.Recv()
+	assert.Nil(err)
+	assert.Equal("Surat", result.GetGeolocation().GetCity())
+	assert.Nil(stream.Send(&geolocationv1.LookupRequest{IpAddress: "10.1.2.3"}))
+	_, err = stream.Recv()
+	assert.Equal(codes.ResourceExhausted, status.Code(err))
+
+	var header metadata.MD
+	_, err = client.Lookup(ctx, &geolocationv1.LookupRequest{IpAddress: "10.1.2.3"}, grpc.Header(&header))
+	assert.Equal(codes.ResourceExhausted, status.Code(err))
+	assert.Equal([]string{"60"}, header.Get("retry-after"))
+
+	// the keys without a limit aren't limited
+	ctx = metadata.NewOutgoingContext(context.TODO(), metadata.Pairs("x-api-key", "fh_internal"))
+	for i := 0; i < 5; i++ {
+		_, err = client.Lookup(ctx, &geolocationv1.LookupRequest{IpAddress: "10.1.2.3"})
+		assert.Nil(err)
+	}
+}
+
+func TestAPIKeyInterceptorsHealth(t *testing.T) {
+	assert := assert.New(t)
+	limiter := router.NewAPIKeyLimiter(testKeyStore{}, router.APIKeyOptions{})
+	conn := newTestClient(t, testRows, APIKeyInterceptors(limiter)...)
+
+	// the probes need no key
+	health, err := healthpb.NewHealthClient(conn).Check(context.TODO(), &healthpb.HealthCheckRequest{})
+	assert.Nil(err)
+	assert.Equal(healthpb.HealthCheckResponse_SERVING, health.GetStatus())
+}
@@ -0,0 +1,152 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	geolocationv1 "github.com/ohmpatel1997/findhotel/api/geolocation/v1"
+	"github.com/ohmpatel1997/findhotel/internal/service"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type geolocationServer struct {
+	geolocationv1.UnimplementedGeoLocationServer
+
+	geolocationSrv service.GeoLocationService
+}
+
+// NewGeoLocationServer returns the gRPC GeoLocation service, answering like the /v1/ip-info endpoints do
+func NewGeoLocationServer(geolocation service.GeoLocationService) geolocationv1.GeoLocationServer {
+	return &geolocationServer{
+		geolocationSrv: geolocation,
+	}
+}
+
+func (s *geolocationServer) Lookup(ctx context.Context, req *geolocationv1.LookupRequest) (*geolocationv1.LookupResponse, error) {
+	response, err := s.geolocationSrv.GetIPData(ctx, newGetRequest(req))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &geolocationv1.LookupResponse{Geolocation: newGeolocation(response)}, nil
+}
+
+func (s *geolocationServer) BatchLookup(ctx context.Context, req *geolocationv1.BatchLookupRequest) (*geolocationv1.BatchLookupResponse, error) {
+	response, err := s.geolocationSrv.GetIPDataBatch(ctx, &service.BatchRequest{IPs: req.IpAddresses})
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resp := &geolocationv1.BatchLookupResponse{Results: make([]*geolocationv1.LookupResult, 0, len(response.Results))}
+	for _, result := range response.Results {
+		if result.Error != nil {
+			resp.Results = append(resp.Results, newErrorResult(result.IP, result.Error))
+			continue
+		}
+		resp.Results = append(resp.Results, &geolocationv1.LookupResult{
+			IpAddress: result.IP,
+			Result:    &geolocationv1.LookupResult_Geolocation{Geolocation: newGeolocation(result.Geolocation)},
+		})
+	}
+	return resp, nil
+}
+
+// StreamLookup looks the requests up one after the other. A failed lookup is answered with its error and the stream
+// goes on, it ends when the client closes its side.
+func (s *geolocationServer) StreamLookup(stream geolocationv1.GeoLocation_StreamLookupServer) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		result := &geolocationv1.LookupResult{IpAddress: req.IpAddress}
+		response, err := s.geolocationSrv.GetIPData(stream.Context(), newGetRequest(req))
+		if err != nil {
+			result = newErrorResult(req.IpAddress, err)
+		} else {
+			result.Result = &geolocationv1.LookupResult_Geolocation{Geolocation: newGeolocation(response)}
+		}
+		if err := stream.Send(result); err != nil {
+			return err
+		}
+	}
+}
+
+func newGetRequest(req *geolocationv1.LookupRequest) *service.GetRequest {
+	getReq := &service.GetRequest{IP: req.IpAddress}
+	if req.AsOf != nil {
+		getReq.AsOf = req.AsOf.AsTime()
+	}
+	return getReq
+}
+
+func newGeolocation(response *service.GeoLocationResponse) *geolocationv1.Geolocation {
+	geo := &geolocationv1.Geolocation{
+		IpAddress:    response.IP,
+		Network:      response.Network,
+		Country:      response.Country,
+		CountryCode:  response.CountryCode,
+		CountryId:    response.CountryID,
+		City:         response.City,
+		CityId:       response.CityID,
+		Latitude:     response.Latitude,
+		Longitude:    response.Longitude,
+		MysteryValue: response.MysteryValue,
+	}
+	if response.ValidFrom != nil {
+		geo.ValidFrom = timestamppb.New(*response.ValidFrom)
+	}
+	if response.ValidTo != nil {
+		geo.ValidTo = timestamppb.New(*response.ValidTo)
+	}
+	return geo
+}
+
+func newErrorResult(ip string, err error) *geolocationv1.LookupResult {
+	st := status.Convert(statusError(err))
+	return &geolocationv1.LookupResult{
+		IpAddress: ip,
+		Result:    &geolocationv1.LookupResult_Error{Error: &geolocationv1.Error{Code: int32(st.Code()), Message: st.Message()}},
+	}
+}
+
+// statusError returns err as the gRPC status of the http status of the service errors
+func statusError(err error) error {
+	var httpErr *router.HttpError
+	if !errors.As(err, &httpErr) {
+		return status.Error(codes.Internal, "Internal Server Error")
+	}
+	return status.Error(codeOf(httpErr.Status), httpErr.Message)
+}
+
+func codeOf(httpStatus int) codes.Code {
+	switch httpStatus {
+	case 400:
+		return codes.InvalidArgument
+	case 401:
+		return codes.Unauthenticated
+	case 403:
+		return codes.PermissionDenied
+	case 404:
+		return codes.NotFound
+	case 429:
+		return codes.ResourceExhausted
+	case 501:
+		return codes.Unimplemented
+	case 503:
+		return codes.Unavailable
+	case 504:
+		return codes.DeadlineExceeded
+	}
+	if httpStatus >= 400 && httpStatus < 500 {
+		return codes.FailedPrecondition
+	}
+	return codes.Internal
+}
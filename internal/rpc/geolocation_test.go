@@ -0,0 +1,190 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	geolocationv1 "github.com/ohmpatel1997/findhotel/api/geolocation/v1"
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	"github.com/ohmpatel1997/findhotel/internal/service"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newTestClient serves the lookups of rows on an in-process listener with opts and returns a connection to them
+func newTestClient(t *testing.T, rows []*model.Geolocation, opts ...grpc.ServerOption) *grpc.ClientConn {
+	manager, err := model.NewMemoryGeoLocationManager(context.TODO(), func(ctx context.Context, fn func(*model.Geolocation) error) error {
+		for _, g := range rows {
+			if err := fn(g); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error loading the index %v", err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	server := NewServer(service.NewGeolocationService(manager), opts...)
+	go server.Serve(lis)
+	t.Cleanup(func() { server.Stop(context.TODO()) })
+
+	conn, err := grpc.DialContext(context.TODO(), "bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Error dialing the server %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+var testRows = []*model.Geolocation{
+	{IP: "10.1.0.0/16", StartIP: "10.1.0.0", EndIP: "10.1.255.255", CountryCode: "IN", Country: "India", City: "Pune", Latitude: 18.5, Longitude: 73.8},
+	{IP: "10.1.2.3", StartIP: "10.1.2.3", EndIP: "10.1.2.3", CountryCode: "IN", Country: "India", City: "Surat", Latitude: 21.2, Longitude: 72.8, MysteryValue: "7"},
+}
+
+var (
+	surat = &geolocationv1.Geolocation{IpAddress: "10.1.2.3", Country: "India", CountryCode: "IN", City: "Surat", Latitude: 21.2, Longitude: 72.8, MysteryValue: "7"}
+	pune  = &geolocationv1.Geolocation{IpAddress: "10.1.9.9", Network: "10.1.0.0/16", Country: "India", CountryCode: "IN", City: "Pune", Latitude: 18.5, Longitude: 73.8}
+)
+
+func TestLookup(t *testing.T) {
+	client := geolocationv1.NewGeoLocationClient(newTestClient(t, testRows))
+
+	cases := []struct {
+		Name         string
+		Req          *geolocationv1.LookupRequest
+		ExpectedResp *geolocationv1.Geolocation
+		ExpectedCode codes.Code
+	}{
+		{
+			Name:         "Success",
+			Req:          &geolocationv1.LookupRequest{IpAddress: "10.1.2.3"},
+			ExpectedResp: surat,
+		},
+		{
+			Name:         "found in a network",
+			Req:          &geolocationv1.LookupRequest{IpAddress: "10.1.9.9"},
+			ExpectedResp: pune,
+		},
+		{
+			Name:         "invalid ip",
+			Req:          &geolocationv1.LookupRequest{IpAddress: "ip1"},
+			ExpectedCode: codes.InvalidArgument,
+		},
+		{
+			Name:         "not found",
+			Req:          &geolocationv1.LookupRequest{IpAddress: "11.0.0.1"},
+			ExpectedCode: codes.NotFound,
+		},
+		{
+			Name:         "as-of unsupported by the backend",
+			Req:          &geolocationv1.LookupRequest{IpAddress: "10.1.2.3", AsOf: timestamppb.New(time.Date(2021, 3, 3, 0, 0, 0, 0, time.UTC))},
+			ExpectedCode: codes.Unimplemented,
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			resp, err := client.Lookup(context.TODO(), tt.Req)
+			assert.Equal(tt.ExpectedCode, status.Code(err))
+			if tt.ExpectedResp != nil {
+				assert.True(proto.Equal(tt.ExpectedResp, resp.GetGeolocation()), "got %v", resp.GetGeolocation())
+			}
+		})
+	}
+}
+
+func TestBatchLookup(t *testing.T) {
+	assert := assert.New(t)
+	client := geolocationv1.NewGeoLocationClient(newTestClient(t, testRows))
+
+	resp, err := client.BatchLookup(context.TODO(), &geolocationv1.BatchLookupRequest{IpAddresses: []string{"10.1.2.3", "ip1", "10.1.9.9"}})
+	assert.Nil(err)
+	expected := &geolocationv1.BatchLookupResponse{Results: []*geolocationv1.LookupResult{
+		{IpAddress: "10.1.2.3", Result: &geolocationv1.LookupResult_Geolocation{Geolocation: surat}},
+		{IpAddress: "ip1", Result: &geolocationv1.LookupResult_Error{Error: &geolocationv1.Error{Code: int32(codes.InvalidArgument), Message: "invalid ip"}}},
+		{IpAddress: "10.1.9.9", Result: &geolocationv1.LookupResult_Geolocation{Geolocation: pune}},
+	}}
+	assert.True(proto.Equal(expected, resp), "got %v", resp)
+
+	_, err = client.BatchLookup(context.TODO(), &geolocationv1.BatchLookupRequest{})
+	assert.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func TestStreamLookup(t *testing.T) {
+	assert := assert.New(t)
+	client := geolocationv1.NewGeoLocationClient(newTestClient(t, testRows))
+
+	stream, err := client.StreamLookup(context.TODO())
+	if err != nil {
+		t.Fatalf("Error opening the stream %v", err)
+	}
+
+	// a failed lookup doesn't end the stream
+	for _, ip := range []string{"10.1.2.3", "11.0.0.1", "10.1.9.9"} {
+		assert.Nil(stream.Send(&geolocationv1.LookupRequest{IpAddress: ip}))
+	}
+	assert.Nil(stream.CloseSend())
+
+	var results []*geolocationv1.LookupResult
+	for {
+		result, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if !assert.Nil(err) {
+			return
+		}
+		results = append(results, result)
+	}
+
+	if !assert.Len(results, 3) {
+		return
+	}
+	assert.True(proto.Equal(surat, results[0].GetGeolocation()))
+	assert.Equal(int32(codes.NotFound), results[1].GetError().GetCode())
+	assert.Equal("11.0.0.1", results[1].IpAddress)
+	assert.True(proto.Equal(pune, results[2].GetGeolocation()))
+}
+
+func TestHealthAndReflection(t *testing.T) {
+	assert := assert.New(t)
+	conn := newTestClient(t, testRows)
+
+	health, err := healthpb.NewHealthClient(conn).Check(context.TODO(), &healthpb.HealthCheckRequest{Service: "findhotel.geolocation.v1.GeoLocation"})
+	assert.Nil(err)
+	assert.Equal(healthpb.HealthCheckResponse_SERVING, health.GetStatus())
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.TODO())
+	if err != nil {
+		t.Fatalf("Error opening the reflection stream %v", err)
+	}
+	assert.Nil(stream.Send(&reflectionpb.ServerReflectionRequest{MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{}}))
+	resp, err := stream.Recv()
+	if !assert.Nil(err) {
+		return
+	}
+	var services []string
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		services = append(services, s.Name)
+	}
+	assert.Contains(services, "findhotel.geolocation.v1.GeoLocation")
+	assert.Contains(services, "grpc.health.v1.Health")
+}
@@ -0,0 +1,50 @@
+package rpc
+
+import (
+	"context"
+
+	geolocationv1 "github.com/ohmpatel1997/findhotel/api/geolocation/v1"
+	"github.com/ohmpatel1997/findhotel/internal/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// Server serves the GeoLocation service with the standard health checking and reflection services
+type Server struct {
+	*grpc.Server
+	health *health.Server
+}
+
+// NewServer returns a server of the GeoLocation service over geolocation. The health of the server and of the
+// service is serving until Stop.
+func NewServer(geolocation service.GeoLocationService, opts ...grpc.ServerOption) *Server {
+	s := &Server{
+		Server: grpc.NewServer(opts...),
+		health: health.NewServer(),
+	}
+	geolocationv1.RegisterGeoLocationServer(s.Server, NewGeoLocationServer(geolocation))
+	healthpb.RegisterHealthServer(s.Server, s.health)
+	reflection.Register(s.Server)
+
+	s.health.SetServingStatus(geolocationv1.GeoLocation_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	return s
+}
+
+// Stop reports the server as not serving to the health checks and lets the running calls finish until ctx is done,
+// when they are cancelled
+func (s *Server) Stop(ctx context.Context) {
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.Server.Stop()
+	}
+}
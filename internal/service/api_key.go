@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+)
+
+const (
+	// DefaultRateLimit and DefaultBurst are the limits of the keys issued without their own
+	DefaultRateLimit = 600 // requests per minute
+	DefaultBurst     = 60
+
+	// apiKeyPrefix starts every key, so that keys are recognized in configs and logs, and others not looked up
+	apiKeyPrefix = "fh_"
+	// apiKeyBytes is the randomness of a key, which makes a sha256 of it as good as a slow hash
+	apiKeyBytes = 32
+	// apiKeyShownPrefix is how much of a key is kept in clear to tell the keys apart
+	apiKeyShownPrefix = 10
+)
+
+//go:generate mockery --name APIKeyService --output=mocks
+type APIKeyService interface {
+	Issue(context.Context, *IssueKeyRequest) (*IssuedKeyResponse, error)
+	Revoke(context.Context, *RevokeKeyRequest) (*APIKeyResponse, error)
+	List(context.Context) (*APIKeyListResponse, error)
+
+	// Authenticate returns the limits of a valid key for router.NewAPIKeyAuth, nil for an unknown or revoked one
+	Authenticate(ctx context.Context, key string) (*router.APIKey, error)
+}
+
+// APIKeyLimits are the limits of the keys issued without their own, the package defaults where zero
+type APIKeyLimits struct {
+	RateLimit int // requests per minute
+	Burst     int
+}
+
+type apiKeys struct {
+	manager  model.APIKeyManager
+	defaults APIKeyLimits
+}
+
+func NewAPIKeyService(mn model.APIKeyManager, defaults APIKeyLimits) APIKeyService {
+	if defaults.RateLimit <= 0 {
+		defaults.RateLimit = DefaultRateLimit
+	}
+	if defaults.Burst <= 0 {
+		defaults.Burst = DefaultBurst
+	}
+	return &apiKeys{
+		manager:  mn,
+		defaults: defaults,
+	}
+}
+
+func (s *apiKeys) Issue(ctx context.Context, request *IssueKeyRequest) (*IssuedKeyResponse, error) {
+	name := strings.TrimSpace(request.Name)
+	if len(name) == 0 {
+		return nil, router.NewHttpError("name could not be found", 400)
+	}
+	if request.RateLimit < 0 {
+		return nil, router.NewHttpError("invalid rate_limit", 400)
+	}
+	if request.Burst < 0 {
+		return nil, router.NewHttpError("invalid burst", 400)
+	}
+
+	secret, err := newAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	key := &model.APIKey{
+		Name:      name,
+		Hash:      hashAPIKey(secret),
+		Prefix:    secret[:apiKeyShownPrefix],
+		RateLimit: request.RateLimit,
+		Burst:     request.Burst,
+	}
+	if key.RateLimit == 0 {
+		key.RateLimit = s.defaults.RateLimit
+	}
+	if key.Burst == 0 {
+		key.Burst = s.defaults.Burst
+	}
+
+	if err := s.manager.Create(ctx, key); err != nil {
+		return nil, err
+	}
+	return &IssuedKeyResponse{Key: secret, APIKeyResponse: newAPIKeyResponse(key)}, nil
+}
+
+func (s *apiKeys) Revoke(ctx context.Context, request *RevokeKeyRequest) (*APIKeyResponse, error) {
+	id, err := uuid.Parse(request.ID)
+	if err != nil {
+		return nil, router.NewHttpError("invalid api key id", 400)
+	}
+
+	key, err := s.manager.Revoke(ctx, id)
+	switch {
+	case errors.Is(err, model.ErrAPIKeyNotFound):
+		return nil, router.NewHttpError("api key not found", 404)
+	case err != nil:
+		return nil, err
+	}
+	return newAPIKeyResponse(key), nil
+}
+
+func (s *apiKeys) List(ctx context.Context) (*APIKeyListResponse, error) {
+	keys, err := s.manager.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &APIKeyListResponse{Keys: make([]*APIKeyResponse, 0, len(keys))}
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, newAPIKeyResponse(key))
+	}
+	return resp, nil
+}
+
+func (s *apiKeys) Authenticate(ctx context.Context, secret string) (*router.APIKey, error) {
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		return nil, nil
+	}
+
+	key, err := s.manager.FindByHash(ctx, hashAPIKey(secret))
+	switch {
+	case errors.Is(err, model.ErrAPIKeyNotFound):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	if key.Revoked() {
+		return nil, nil
+	}
+
+	return &router.APIKey{
+		ID:    key.ID.String(),
+		Name:  key.Name,
+		Rate:  float64(key.RateLimit) / 60,
+		Burst: key.Burst,
+	}, nil
+}
+
+// newAPIKey returns a random key, url safe so that it fits any header
+func newAPIKey() (string, error) {
+	b := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashAPIKey returns the hex sha256 of key, which is what is stored of it
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func newAPIKeyResponse(key *model.APIKey) *APIKeyResponse {
+	resp := &APIKeyResponse{
+		ID:        key.ID.String(),
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		RateLimit: key.RateLimit,
+		Burst:     key.Burst,
+		CreatedAt: key.CreatedAt,
+	}
+	if key.Revoked() {
+		revokedAt := key.RevokedAt
+		resp.RevokedAt = &revokedAt
+	}
+	return resp
+}
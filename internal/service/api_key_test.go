@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	modelMocks "github.com/ohmpatel1997/findhotel/internal/model/mocks"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIssueAPIKey(t *testing.T) {
+	cases := []struct {
+		Name              string
+		Req               *IssueKeyRequest
+		ExpectedRateLimit int
+		ExpectedBurst     int
+		ExpectedError     error
+	}{
+		{
+			Name:              "Success",
+			Req:               &IssueKeyRequest{Name: "partner", RateLimit: 60, Burst: 5},
+			ExpectedRateLimit: 60,
+			ExpectedBurst:     5,
+		},
+		{
+			Name:              "default limits",
+			Req:               &IssueKeyRequest{Name: " frontend "},
+			ExpectedRateLimit: 1200,
+			ExpectedBurst:     DefaultBurst,
+		},
+		{
+			Name:          "no name",
+			Req:           &IssueKeyRequest{RateLimit: 60},
+			ExpectedError: router.NewHttpError("name could not be found", 400),
+		},
+		{
+			Name:          "negative rate limit",
+			Req:           &IssueKeyRequest{Name: "partner", RateLimit: -1},
+			ExpectedError: router.NewHttpError("invalid rate_limit", 400),
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			manager := new(modelMocks.APIKeyManager)
+			var stored *model.APIKey
+			manager.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				stored = args.Get(1).(*model.APIKey)
+				stored.ID = uuid.New()
+			}).Return(nil)
+
+			resp, err := NewAPIKeyService(manager, APIKeyLimits{RateLimit: 1200}).Issue(context.TODO(), tt.Req)
+			assert.Equal(tt.ExpectedError, err)
+			if tt.ExpectedError != nil {
+				manager.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+				return
+			}
+
+			// only the hash of the key is stored
+			assert.True(strings.HasPrefix(resp.Key, "fh_"))
+			assert.Equal(hashAPIKey(resp.Key), stored.Hash)
+			assert.NotContains(stored.Hash, resp.Key[3:])
+			assert.Equal(resp.Key[:10], resp.Prefix)
+			assert.Equal(strings.TrimSpace(tt.Req.Name), resp.Name)
+			assert.Equal(tt.ExpectedRateLimit, resp.RateLimit)
+			assert.Equal(tt.ExpectedBurst, resp.Burst)
+			assert.Equal(stored.ID.String(), resp.ID)
+		})
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	id := uuid.New()
+	revokedAt := time.Date(2021, 3, 3, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		Name          string
+		Req           *RevokeKeyRequest
+		ExpectedResp  *APIKeyResponse
+		ExpectedError error
+		MocksInit     func() *modelMocks.APIKeyManager
+	}{
+		{
+			Name:         "Success",
+			Req:          &RevokeKeyRequest{ID: id.String()},
+			ExpectedResp: &APIKeyResponse{ID: id.String(), Name: "partner", Prefix: "fh_abcdefg", RateLimit: 60, Burst: 5, RevokedAt: &revokedAt},
+			MocksInit: func() *modelMocks.APIKeyManager {
+				manager := new(modelMocks.APIKeyManager)
+				manager.On("Revoke", mock.Anything, id).Return(&model.APIKey{
+					ID: id, Name: "partner", Prefix: "fh_abcdefg", RateLimit: 60, Burst: 5, RevokedAt: revokedAt,
+				}, nil)
+				return manager
+			},
+		},
+		{
+			Name:          "invalid id",
+			Req:           &RevokeKeyRequest{ID: "key-1"},
+			ExpectedError: router.NewHttpError("invalid api key id", 400),
+			MocksInit: func() *modelMocks.APIKeyManager {
+				return new(modelMocks.APIKeyManager)
+			},
+		},
+		{
+			Name:          "not found",
+			Req:           &RevokeKeyRequest{ID: id.String()},
+			ExpectedError: router.NewHttpError("api key not found", 404),
+			MocksInit: func() *modelMocks.APIKeyManager {
+				manager := new(modelMocks.APIKeyManager)
+				manager.On("Revoke", mock.Anything, id).Return(nil, model.ErrAPIKeyNotFound)
+				return manager
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			resp, err := NewAPIKeyService(tt.MocksInit(), APIKeyLimits{}).Revoke(context.TODO(), tt.Req)
+			assert.Equal(tt.ExpectedError, err)
+			assert.Equal(tt.ExpectedResp, resp)
+		})
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	id := uuid.New()
+	revoked := "fh_revoked"
+	failing := "fh_failing"
+
+	manager := new(modelMocks.APIKeyManager)
+	manager.On("FindByHash", mock.Anything, hashAPIKey("fh_valid")).Return(&model.APIKey{ID: id, Name: "partner", RateLimit: 120, Burst: 5}, nil)
+	manager.On("FindByHash", mock.Anything, hashAPIKey(revoked)).Return(&model.APIKey{ID: uuid.New(), RevokedAt: time.Now()}, nil)
+	manager.On("FindByHash", mock.Anything, hashAPIKey("fh_unknown")).Return(nil, model.ErrAPIKeyNotFound)
+	manager.On("FindByHash", mock.Anything, hashAPIKey(failing)).Return(nil, errors.New("connection refused"))
+	srv := NewAPIKeyService(manager, APIKeyLimits{})
+
+	cases := []struct {
+		Name          string
+		Key           string
+		ExpectedKey   *router.APIKey
+		ExpectedError bool
+	}{
+		{Name: "valid", Key: "fh_valid", ExpectedKey: &router.APIKey{ID: id.String(), Name: "partner", Rate: 2, Burst: 5}},
+		{Name: "revoked", Key: revoked},
+		{Name: "unknown", Key: "fh_unknown"},
+		{Name: "not a key", Key: "password"},
+		{Name: "store failing", Key: failing, ExpectedError: true},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			key, err := srv.Authenticate(context.TODO(), tt.Key)
+			assert.Equal(tt.ExpectedError, err != nil)
+			assert.Equal(tt.ExpectedKey, key)
+		})
+	}
+}
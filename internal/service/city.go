@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+)
+
+//go:generate mockery --name CityService --output=mocks
+type CityService interface {
+	SearchCities(context.Context, *CitySearchRequest) (*CitySearchResponse, error)
+}
+
+type city struct {
+	manager model.CitySearchManager
+}
+
+func NewCityService(mn model.CitySearchManager) CityService {
+	return &city{
+		mn,
+	}
+}
+
+func (c *city) SearchCities(ctx context.Context, request *CitySearchRequest) (*CitySearchResponse, error) {
+	if strings.TrimSpace(request.Query) == "" {
+		return nil, router.NewHttpError("invalid query", 400)
+	}
+	if request.Limit < 0 {
+		return nil, router.NewHttpError("invalid limit", 400)
+	}
+
+	matches, err := c.manager.SearchCities(ctx, &model.CitySearch{
+		Query:       request.Query,
+		CountryCode: request.CountryCode,
+		Limit:       request.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &CitySearchResponse{Cities: make([]*CityResponse, 0, len(matches))}
+	for _, m := range matches {
+		resp.Cities = append(resp.Cities, &CityResponse{
+			CityID:      m.CityID,
+			City:        m.City,
+			CountryID:   m.CountryID,
+			CountryCode: m.CountryCode,
+			Country:     m.Country,
+			IPs:         m.IPs,
+			Score:       m.Score,
+		})
+	}
+	return resp, nil
+}
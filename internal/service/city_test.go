@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	modelMocks "github.com/ohmpatel1997/findhotel/internal/model/mocks"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSearchCities(t *testing.T) {
+	cases := []struct {
+		Name          string
+		Req           *CitySearchRequest
+		ExpectedResp  *CitySearchResponse
+		ExpectedError error
+		MocksInit     func() *modelMocks.CitySearchManager
+	}{
+		{
+			Name: "Success",
+			Req:  &CitySearchRequest{Query: "munchen", CountryCode: "DE", Limit: 5},
+			ExpectedResp: &CitySearchResponse{Cities: []*CityResponse{
+				{CityID: 3, City: "München", CountryID: 2, CountryCode: "DE", Country: "Germany", IPs: 120, Score: 1},
+			}},
+			MocksInit: func() *modelMocks.CitySearchManager {
+				manager := new(modelMocks.CitySearchManager)
+				manager.On("SearchCities", mock.Anything, &model.CitySearch{Query: "munchen", CountryCode: "DE", Limit: 5}).Return([]*model.CityMatch{
+					{CityID: 3, City: "München", CountryID: 2, CountryCode: "DE", Country: "Germany", IPs: 120, Score: 1},
+				}, nil)
+				return manager
+			},
+		},
+		{
+			Name:         "no match",
+			Req:          &CitySearchRequest{Query: "atlantis"},
+			ExpectedResp: &CitySearchResponse{Cities: []*CityResponse{}},
+			MocksInit: func() *modelMocks.CitySearchManager {
+				manager := new(modelMocks.CitySearchManager)
+				manager.On("SearchCities", mock.Anything, &model.CitySearch{Query: "atlantis"}).Return([]*model.CityMatch{}, nil)
+				return manager
+			},
+		},
+		{
+			Name: "400 blank query",
+			Req:  &CitySearchRequest{Query: "  "},
+			MocksInit: func() *modelMocks.CitySearchManager {
+				return nil
+			},
+			ExpectedError: router.NewHttpError("invalid query", 400),
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			srv := NewCityService(tt.MocksInit())
+			resp, err := srv.SearchCities(context.TODO(), tt.Req)
+			assert.Equal(tt.ExpectedResp, resp)
+			assert.Equal(tt.ExpectedError, err)
+		})
+	}
+}
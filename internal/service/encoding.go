@@ -0,0 +1,248 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/transform"
+)
+
+const (
+	EncodingAuto        = "auto"
+	EncodingUTF8        = "utf-8"
+	EncodingLatin1      = "latin-1"
+	EncodingWindows1252 = "windows-1252"
+	EncodingUTF16LE     = "utf-16le"
+	EncodingUTF16BE     = "utf-16be"
+
+	sniffSize = 64 * 1024
+)
+
+// invalidByte stands for the invalid byte sequences in the decoded text. Being invalid UTF-8 itself, unlike
+// utf8.RuneError, it can't be mistaken for a U+FFFD of the input.
+const invalidByte = 0xFF
+
+var (
+	// decoders transcode to UTF-8, with invalidByte for the invalid byte sequences
+	decoders = map[string]func() transform.Transformer{
+		EncodingUTF8:        func() transform.Transformer { return transform.Nop }, // validated by the parser, the BOM is skipped
+		EncodingLatin1:      charmapDecoder(charmap.ISO8859_1),
+		EncodingWindows1252: charmapDecoder(charmap.Windows1252),
+		EncodingUTF16LE:     func() transform.Transformer { return &utf16Decoder{order: binary.LittleEndian} },
+		EncodingUTF16BE:     func() transform.Transformer { return &utf16Decoder{order: binary.BigEndian} },
+	}
+
+	encodingAliases = map[string]string{
+		"":           EncodingAuto,
+		"utf8":       EncodingUTF8,
+		"latin1":     EncodingLatin1,
+		"iso-8859-1": EncodingLatin1,
+		"iso8859-1":  EncodingLatin1,
+		"cp1252":     EncodingWindows1252,
+		"utf16le":    EncodingUTF16LE,
+		"utf16be":    EncodingUTF16BE,
+	}
+)
+
+// NormalizeEncoding returns the canonical name of the encoding, or an error if it isn't supported
+func NormalizeEncoding(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := encodingAliases[name]; ok {
+		name = alias
+	}
+
+	if _, ok := decoders[name]; !ok && name != EncodingAuto {
+		return "", fmt.Errorf("unsupported encoding %q", name)
+	}
+	return name, nil
+}
+
+// newUTF8Reader transcodes r from the declared encoding to UTF-8. With EncodingAuto the encoding is detected
+// from the BOM or the first bytes of the input. The text read isn't valid UTF-8 where the input has invalid byte
+// sequences.
+func newUTF8Reader(r io.Reader, declared string) (io.Reader, string, error) {
+	name, err := NormalizeEncoding(declared)
+	if err != nil {
+		return nil, "", err
+	}
+
+	br := bufio.NewReaderSize(r, sniffSize)
+	if name == EncodingAuto {
+		sample, err := br.Peek(sniffSize)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return nil, "", err
+		}
+		name = detectEncoding(sample)
+	}
+
+	if name == EncodingUTF8 {
+		if bom, _ := br.Peek(len(utf8BOM)); bytes.Equal(bom, utf8BOM) {
+			_, _ = br.Discard(len(utf8BOM))
+		}
+	}
+
+	return transform.NewReader(br, decoders[name]()), name, nil
+}
+
+const (
+	utf16BOM = 0xFEFF
+
+	// the UTF-16 surrogates, high ones first
+	surrogateHigh = 0xD800
+	surrogateLow  = 0xDC00
+	surrogateEnd  = 0xE000
+)
+
+var (
+	utf8BOM   = []byte{0xEF, 0xBB, 0xBF}
+	runeError = []byte(string(utf8.RuneError))
+)
+
+// charmapDecoder returns the decoder of a single byte encoding, whose utf8.RuneError only stands for the undefined
+// bytes, the encoding having no U+FFFD of its own
+func charmapDecoder(cm *charmap.Charmap) func() transform.Transformer {
+	return func() transform.Transformer {
+		return transform.Chain(cm.NewDecoder(), markInvalid{})
+	}
+}
+
+// markInvalid replaces utf8.RuneError with invalidByte
+type markInvalid struct {
+	transform.NopResetter
+}
+
+func (markInvalid) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		if nDst >= len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		rest := src[nSrc:]
+		switch {
+		case bytes.HasPrefix(rest, runeError):
+			dst[nDst] = invalidByte
+			nSrc += len(runeError)
+		case !atEOF && len(rest) < len(runeError) && bytes.HasPrefix(runeError, rest):
+			return nDst, nSrc, transform.ErrShortSrc
+		default:
+			dst[nDst] = src[nSrc]
+			nSrc++
+		}
+		nDst++
+	}
+	return nDst, nSrc, nil
+}
+
+// utf16Decoder decodes UTF-16 in the given byte order, skipping a leading BOM. Unpaired surrogates and a trailing odd
+// byte are invalidByte.
+type utf16Decoder struct {
+	order   binary.ByteOrder
+	started bool
+}
+
+func (d *utf16Decoder) Reset() {
+	d.started = false
+}
+
+func (d *utf16Decoder) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		rest := src[nSrc:]
+		if len(rest) < 2 {
+			if !atEOF {
+				return nDst, nSrc, transform.ErrShortSrc
+			}
+			if nDst >= len(dst) {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			dst[nDst] = invalidByte
+			return nDst + 1, nSrc + 1, nil
+		}
+
+		u := d.order.Uint16(rest)
+		if !d.started {
+			d.started = true
+			if u == utf16BOM {
+				nSrc += 2
+				continue
+			}
+		}
+
+		r, size, valid := rune(u), 2, true
+		switch {
+		case u >= surrogateHigh && u < surrogateLow: // a low one has to follow
+			if len(rest) < 4 && !atEOF {
+				return nDst, nSrc, transform.ErrShortSrc
+			}
+			valid = false
+			if len(rest) >= 4 {
+				if low := d.order.Uint16(rest[2:]); low >= surrogateLow && low < surrogateEnd {
+					r, size, valid = utf16.DecodeRune(rune(u), rune(low)), 4, true
+				}
+			}
+		case u >= surrogateLow && u < surrogateEnd: // without a high one
+			valid = false
+		}
+
+		if !valid {
+			if nDst >= len(dst) {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			dst[nDst] = invalidByte
+			nDst++
+			nSrc += size
+			continue
+		}
+		if nDst+utf8.RuneLen(r) > len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		nDst += utf8.EncodeRune(dst[nDst:], r)
+		nSrc += size
+	}
+	return nDst, nSrc, nil
+}
+
+func detectEncoding(sample []byte) string {
+	switch {
+	case bytes.HasPrefix(sample, utf8BOM):
+		return EncodingUTF8
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE
+	}
+
+	// ascii text in UTF-16 has a zero byte in every other position
+	var evenZeros, oddZeros int
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenZeros++
+		} else {
+			oddZeros++
+		}
+	}
+	switch {
+	case oddZeros > len(sample)/4 && evenZeros == 0:
+		return EncodingUTF16LE
+	case evenZeros > len(sample)/4 && oddZeros == 0:
+		return EncodingUTF16BE
+	}
+
+	// the sample may end in the middle of a multi byte character
+	for i := 0; i < utf8.UTFMax && len(sample) > 0 && !utf8.Valid(sample); i++ {
+		sample = sample[:len(sample)-1]
+	}
+	if utf8.Valid(sample) {
+		return EncodingUTF8
+	}
+
+	return EncodingWindows1252
+}
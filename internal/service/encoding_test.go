@@ -0,0 +1,94 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Sample   []byte
+		Expected string
+	}{
+		{Name: "ascii", Sample: []byte("ip_address,city\n1.2.3.4,Berlin\n"), Expected: EncodingUTF8},
+		{Name: "utf-8", Sample: []byte("1.2.3.4,São Paulo\n"), Expected: EncodingUTF8},
+		{Name: "utf-8 bom", Sample: []byte("\xef\xbb\xbfip_address\n"), Expected: EncodingUTF8},
+		{Name: "utf-8 cut in a character", Sample: []byte("1.2.3.4,S\xc3"), Expected: EncodingUTF8},
+		{Name: "latin-1", Sample: []byte("1.2.3.4,S\xe3o Paulo\n"), Expected: EncodingWindows1252},
+		{Name: "utf-16le bom", Sample: []byte("\xff\xfei\x00p\x00"), Expected: EncodingUTF16LE},
+		{Name: "utf-16be bom", Sample: []byte("\xfe\xff\x00i\x00p"), Expected: EncodingUTF16BE},
+		{Name: "utf-16le without bom", Sample: []byte("i\x00p\x00,\x00c\x00"), Expected: EncodingUTF16LE},
+		{Name: "utf-16be without bom", Sample: []byte("\x00i\x00p\x00,\x00c"), Expected: EncodingUTF16BE},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.Expected, detectEncoding(tt.Sample))
+		})
+	}
+}
+
+func TestNormalizeEncoding(t *testing.T) {
+	cases := []struct {
+		Name          string
+		Encoding      string
+		Expected      string
+		ExpectedError bool
+	}{
+		{Name: "empty", Encoding: "", Expected: EncodingAuto},
+		{Name: "canonical", Encoding: "utf-16le", Expected: EncodingUTF16LE},
+		{Name: "alias", Encoding: " ISO-8859-1 ", Expected: EncodingLatin1},
+		{Name: "unsupported", Encoding: "ebcdic", ExpectedError: true},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			encoding, err := NormalizeEncoding(tt.Encoding)
+			assert.Equal(t, tt.ExpectedError, err != nil)
+			assert.Equal(t, tt.Expected, encoding)
+		})
+	}
+}
+
+func TestUTF8Reader(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Encoding string
+		Input    []byte
+		Expected string
+	}{
+		{Name: "utf-8 with bom", Encoding: EncodingUTF8, Input: []byte("\xef\xbb\xbfSão Paulo"), Expected: "São Paulo"},
+		{Name: "utf-8 replacement character", Encoding: EncodingUTF8, Input: []byte("S�o Paulo"), Expected: "S�o Paulo"},
+		{Name: "utf-8 invalid", Encoding: EncodingUTF8, Input: []byte("S\xe3o Paulo"), Expected: "S\xe3o Paulo"},
+		{Name: "windows-1252", Encoding: EncodingWindows1252, Input: []byte("S\xe3o Paulo \x80"), Expected: "São Paulo €"},
+		{Name: "windows-1252 undefined byte", Encoding: EncodingWindows1252, Input: []byte("S\x81o Paulo"), Expected: "S\xffo Paulo"},
+		{Name: "latin-1", Encoding: EncodingLatin1, Input: []byte("S\xe3o Paulo"), Expected: "São Paulo"},
+		{Name: "utf-16le with bom and surrogate pair", Encoding: EncodingAuto, Input: []byte("\xff\xfeS\x00\xe3\x00o\x00\x3d\xd8\x00\xde"), Expected: "São😀"},
+		{Name: "utf-16be replacement character", Encoding: EncodingUTF16BE, Input: []byte("\x00S\xff\xfd\x00o"), Expected: "S�o"},
+		{Name: "utf-16le unpaired surrogates", Encoding: EncodingUTF16LE, Input: []byte("S\x00\x3d\xd8o\x00\x00\xdeo\x00\x3d\xd8"), Expected: "S\xffo\xffo\xff"},
+		{Name: "utf-16le odd length", Encoding: EncodingUTF16LE, Input: []byte("S\x00o"), Expected: "S\xff"},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			r, _, err := newUTF8Reader(bytes.NewReader(tt.Input), tt.Encoding)
+			if err != nil {
+				t.Fatalf("Error creating the reader %v", err)
+			}
+			decoded, err := io.ReadAll(r)
+			assert.Nil(err)
+			assert.Equal(tt.Expected, string(decoded))
+		})
+	}
+}
@@ -2,14 +2,21 @@ package service
 
 import (
 	"context"
+	"errors"
+	"strconv"
 
 	"github.com/ohmpatel1997/findhotel/internal/model"
 	"github.com/ohmpatel1997/findhotel/lib/router"
 )
 
+// MaxBatchSize is the number of ips a batch lookup may have
+const MaxBatchSize = 1000
+
 //go:generate mockery --name GeoLocationService --output=mocks
 type GeoLocationService interface {
 	GetIPData(context.Context, *GetRequest) (*GeoLocationResponse, error)
+	GetIPDataBatch(context.Context, *BatchRequest) (*BatchResponse, error)
+	ListGeolocations(context.Context, *ListRequest) (*ListResponse, error)
 }
 
 type geolocation struct {
@@ -27,18 +34,104 @@ func (g *geolocation) GetIPData(ctx context.Context, request *GetRequest) (*GeoL
 		return nil, router.NewHttpError("invalid ip", 400)
 	}
 
-	data, err := g.manager.FindDataByIP(ctx, request.IP)
+	data, err := g.manager.FindDataByIP(ctx, request.IP, request.AsOf)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := newGeoLocationResponse(request.IP, data)
+	if !request.AsOf.IsZero() {
+		validFrom := data.ValidFrom
+		resp.ValidFrom = &validFrom
+		if !data.ValidTo.IsZero() {
+			validTo := data.ValidTo
+			resp.ValidTo = &validTo
+		}
+	}
+
+	return resp, nil
+}
+
+func (g *geolocation) GetIPDataBatch(ctx context.Context, request *BatchRequest) (*BatchResponse, error) {
+	if len(request.IPs) == 0 {
+		return nil, router.NewHttpError("no ips", 400)
+	}
+	if len(request.IPs) > MaxBatchSize {
+		return nil, router.NewHttpError("too many ips, at most "+strconv.Itoa(MaxBatchSize)+" per batch", 400)
+	}
+
+	lookups, err := g.manager.FindDataByIPs(ctx, request.IPs)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &BatchResponse{Results: make([]*BatchResult, 0, len(lookups))}
+	for _, l := range lookups {
+		result := &BatchResult{IP: l.IP}
+		if l.Err != nil {
+			var httpErr *router.HttpError
+			if !errors.As(l.Err, &httpErr) {
+				httpErr = router.NewHttpError(l.Err.Error(), 500)
+			}
+			result.Error = httpErr
+		} else {
+			result.Geolocation = newGeoLocationResponse(l.IP, l.Geolocation)
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return resp, nil
+}
+
+func (g *geolocation) ListGeolocations(ctx context.Context, request *ListRequest) (*ListResponse, error) {
+	if request.Limit < 0 {
+		return nil, router.NewHttpError("invalid limit", 400)
+	}
+	if box := request.BoundingBox; box != nil {
+		if box.MinLatitude < -90 || box.MaxLatitude > 90 || box.MinLatitude > box.MaxLatitude ||
+			box.MinLongitude < -180 || box.MaxLongitude > 180 {
+			return nil, router.NewHttpError("invalid bbox", 400)
+		}
+	}
+
+	page, err := g.manager.List(ctx, &model.GeolocationQuery{
+		CountryCode: request.CountryCode,
+		Country:     request.Country,
+		City:        request.City,
+		BoundingBox: request.BoundingBox,
+		Sort:        request.Sort,
+		Desc:        request.Desc,
+		Limit:       request.Limit,
+		Cursor:      request.Cursor,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &GeoLocationResponse{
-		IP:           data.IP,
+	resp := &ListResponse{
+		Geolocations: make([]*GeoLocationResponse, 0, len(page.Geolocations)),
+		NextCursor:   page.NextCursor,
+	}
+	for _, data := range page.Geolocations {
+		resp.Geolocations = append(resp.Geolocations, newGeoLocationResponse(data.StartIP, data))
+	}
+	return resp, nil
+}
+
+// newGeoLocationResponse returns the response for ip found in data
+func newGeoLocationResponse(ip string, data *model.Geolocation) *GeoLocationResponse {
+	resp := &GeoLocationResponse{
+		IP:           ip,
 		CountryCode:  data.CountryCode,
 		Country:      data.Country,
+		CountryID:    data.CountryID,
 		City:         data.City,
+		CityID:       data.CityID,
 		Latitude:     data.Latitude,
 		Longitude:    data.Longitude,
 		MysteryValue: data.MysteryValue,
-	}, nil
+	}
+	if data.StartIP != data.EndIP {
+		resp.Network = data.IP
+	}
+	return resp
 }
@@ -2,7 +2,6 @@ package service
 
 import (
 	"context"
-	"errors"
 	"testing"
 	"time"
 
@@ -15,6 +14,10 @@ import (
 )
 
 func TestGeolocation(t *testing.T) {
+	asOf := time.Date(2021, 3, 3, 0, 0, 0, 0, time.UTC)
+	validFrom := time.Date(2021, 2, 1, 10, 0, 0, 0, time.UTC)
+	validTo := time.Date(2021, 4, 1, 10, 0, 0, 0, time.UTC)
+
 	cases := []struct {
 		Name          string
 		Req           *GetRequest
@@ -30,24 +33,89 @@ func TestGeolocation(t *testing.T) {
 				Country:      "india",
 				CountryCode:  "IN",
 				City:         "mumbai",
-				Latitude:     "12.2344",
-				Longitude:    "149.3123123",
+				Latitude:     12.2344,
+				Longitude:    149.3123123,
 				MysteryValue: "MUMbai",
 			},
 			MocksInit: func() *modelMocks.GeoLocationManager {
 				manager := new(modelMocks.GeoLocationManager)
-				manager.On("FindDataByIP", mock.Anything, "ip1").Return(&model.Geolocation{
+				manager.On("FindDataByIP", mock.Anything, "ip1", time.Time{}).Return(&model.Geolocation{
 					ID:           uuid.New(),
 					IP:           "ip1",
 					Country:      "india",
 					CountryCode:  "IN",
 					City:         "mumbai",
-					Latitude:     "12.2344",
-					Longitude:    "149.3123123",
+					Latitude:     12.2344,
+					Longitude:    149.3123123,
 					MysteryValue: "MUMbai",
 					CreatedAt:    time.Now(),
 					ModifiedAt:   time.Now(),
-				}, true, nil)
+				}, nil)
+				return manager
+			},
+			ExpectedError: nil,
+		},
+		{
+			Name: "found in a network",
+			Req:  &GetRequest{IP: "10.1.2.3"},
+			ExpectedResp: &GeoLocationResponse{
+				IP:           "10.1.2.3",
+				Network:      "10.1.0.0/16",
+				Country:      "india",
+				CountryCode:  "IN",
+				CountryID:    4,
+				City:         "mumbai",
+				CityID:       17,
+				Latitude:     12.2344,
+				Longitude:    149.3123123,
+				MysteryValue: "MUMbai",
+			},
+			MocksInit: func() *modelMocks.GeoLocationManager {
+				manager := new(modelMocks.GeoLocationManager)
+				manager.On("FindDataByIP", mock.Anything, "10.1.2.3", time.Time{}).Return(&model.Geolocation{
+					ID:           uuid.New(),
+					CountryID:    4,
+					CityID:       17,
+					IP:           "10.1.0.0/16",
+					StartIP:      "10.1.0.0",
+					EndIP:        "10.1.255.255",
+					Country:      "india",
+					CountryCode:  "IN",
+					City:         "mumbai",
+					Latitude:     12.2344,
+					Longitude:    149.3123123,
+					MysteryValue: "MUMbai",
+					CreatedAt:    time.Now(),
+					ModifiedAt:   time.Now(),
+				}, nil)
+				return manager
+			},
+			ExpectedError: nil,
+		},
+		{
+			Name: "as of",
+			Req:  &GetRequest{IP: "ip1", AsOf: asOf},
+			ExpectedResp: &GeoLocationResponse{
+				IP:           "ip1",
+				Country:      "india",
+				CountryCode:  "IN",
+				City:         "pune",
+				MysteryValue: "1",
+				ValidFrom:    &validFrom,
+				ValidTo:      &validTo,
+			},
+			MocksInit: func() *modelMocks.GeoLocationManager {
+				manager := new(modelMocks.GeoLocationManager)
+				manager.On("FindDataByIP", mock.Anything, "ip1", asOf).Return(&model.Geolocation{
+					ID:           uuid.New(),
+					IP:           "ip1",
+					Country:      "india",
+					CountryCode:  "IN",
+					City:         "pune",
+					MysteryValue: "1",
+					ValidFrom:    validFrom,
+					ValidTo:      validTo,
+				}, nil)
 				return manager
 			},
 			ExpectedError: nil,
@@ -67,7 +135,7 @@ func TestGeolocation(t *testing.T) {
 			ExpectedResp: nil,
 			MocksInit: func() *modelMocks.GeoLocationManager {
 				manager := new(modelMocks.GeoLocationManager)
-				manager.On("FindDataByIP", mock.Anything, "ip1").Return(nil, false, nil)
+				manager.On("FindDataByIP", mock.Anything, "ip1", time.Time{}).Return(nil, router.NewHttpError("not found", 404))
 				return manager
 			},
 			ExpectedError: router.NewHttpError("not found", 404),
@@ -78,7 +146,7 @@ func TestGeolocation(t *testing.T) {
 			ExpectedResp: nil,
 			MocksInit: func() *modelMocks.GeoLocationManager {
 				manager := new(modelMocks.GeoLocationManager)
-				manager.On("FindDataByIP", mock.Anything, "ip1").Return(nil, false, errors.New("custom error"))
+				manager.On("FindDataByIP", mock.Anything, "ip1", time.Time{}).Return(nil, router.NewHttpError("custom error", 500))
 				return manager
 			},
 			ExpectedError: router.NewHttpError("custom error", 500),
@@ -97,3 +165,174 @@ func TestGeolocation(t *testing.T) {
 		})
 	}
 }
+
+func TestGeoLocationResponseLegacy(t *testing.T) {
+	resp := &GeoLocationResponse{
+		IP:           "ip1",
+		Country:      "india",
+		CountryCode:  "IN",
+		City:         "mumbai",
+		Latitude:     -84.87503094689836,
+		Longitude:    7,
+		MysteryValue: "MUMbai",
+	}
+
+	assert.Equal(t, &LegacyGeoLocationResponse{
+		IP:           "ip1",
+		Country:      "india",
+		CountryCode:  "IN",
+		City:         "mumbai",
+		Latitude:     "-84.87503094689836",
+		Longitude:    "7",
+		MysteryValue: "MUMbai",
+	}, resp.Legacy())
+}
+
+func TestGetIPDataBatch(t *testing.T) {
+	tooMany := make([]string, MaxBatchSize+1)
+
+	cases := []struct {
+		Name          string
+		Req           *BatchRequest
+		ExpectedResp  *BatchResponse
+		ExpectedError error
+		MocksInit     func() *modelMocks.GeoLocationManager
+	}{
+		{
+			Name: "Success",
+			Req:  &BatchRequest{IPs: []string{"10.1.2.3", "ip2", "10.0.0.1"}},
+			ExpectedResp: &BatchResponse{Results: []*BatchResult{
+				{
+					IP:          "10.1.2.3",
+					Geolocation: &GeoLocationResponse{IP: "10.1.2.3", Network: "10.1.0.0/16", Country: "India", CountryCode: "IN", City: "Pune"},
+				},
+				{IP: "ip2", Error: router.NewHttpError("invalid ip", 400)},
+				{IP: "10.0.0.1", Error: router.NewHttpError("data not found with given ip", 404)},
+			}},
+			MocksInit: func() *modelMocks.GeoLocationManager {
+				manager := new(modelMocks.GeoLocationManager)
+				manager.On("FindDataByIPs", mock.Anything, []string{"10.1.2.3", "ip2", "10.0.0.1"}).Return([]*model.IPLookup{
+					{IP: "10.1.2.3", Geolocation: &model.Geolocation{IP: "10.1.0.0/16", StartIP: "10.1.0.0", EndIP: "10.1.255.255", Country: "India", CountryCode: "IN", City: "Pune"}},
+					{IP: "ip2", Err: router.NewHttpError("invalid ip", 400)},
+					{IP: "10.0.0.1", Err: router.NewHttpError("data not found with given ip", 404)},
+				}, nil)
+				return manager
+			},
+		},
+		{
+			Name: "400 no ips",
+			Req:  &BatchRequest{},
+			MocksInit: func() *modelMocks.GeoLocationManager {
+				return nil
+			},
+			ExpectedError: router.NewHttpError("no ips", 400),
+		},
+		{
+			Name: "400 too many ips",
+			Req:  &BatchRequest{IPs: tooMany},
+			MocksInit: func() *modelMocks.GeoLocationManager {
+				return nil
+			},
+			ExpectedError: router.NewHttpError("too many ips, at most 1000 per batch", 400),
+		},
+		{
+			Name: "500 batch failed",
+			Req:  &BatchRequest{IPs: []string{"10.0.0.1"}},
+			MocksInit: func() *modelMocks.GeoLocationManager {
+				manager := new(modelMocks.GeoLocationManager)
+				manager.On("FindDataByIPs", mock.Anything, []string{"10.0.0.1"}).Return(nil, router.NewHttpError("custom error", 500))
+				return manager
+			},
+			ExpectedError: router.NewHttpError("custom error", 500),
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			srv := NewGeolocationService(tt.MocksInit())
+			resp, err := srv.GetIPDataBatch(context.TODO(), tt.Req)
+			assert.Equal(tt.ExpectedResp, resp)
+			assert.Equal(tt.ExpectedError, err)
+		})
+	}
+}
+
+func TestListGeolocations(t *testing.T) {
+	box := &model.BoundingBox{MinLatitude: 15, MinLongitude: 70, MaxLatitude: 30, MaxLongitude: 80}
+
+	cases := []struct {
+		Name          string
+		Req           *ListRequest
+		ExpectedResp  *ListResponse
+		ExpectedError error
+		MocksInit     func() *modelMocks.GeoLocationManager
+	}{
+		{
+			Name: "Success",
+			Req:  &ListRequest{CountryCode: "IN", BoundingBox: box, Sort: model.SortCity, Desc: true, Limit: 2, Cursor: "c1"},
+			ExpectedResp: &ListResponse{
+				Geolocations: []*GeoLocationResponse{
+					{IP: "10.1.2.3", Country: "India", CountryCode: "IN", City: "Surat", Latitude: 21.2, Longitude: 72.8},
+					{IP: "10.1.0.0", Network: "10.1.0.0/16", Country: "India", CountryCode: "IN", City: "Pune", Latitude: 18.5, Longitude: 73.8},
+				},
+				NextCursor: "c2",
+			},
+			MocksInit: func() *modelMocks.GeoLocationManager {
+				manager := new(modelMocks.GeoLocationManager)
+				manager.On("List", mock.Anything, &model.GeolocationQuery{
+					CountryCode: "IN", BoundingBox: box, Sort: model.SortCity, Desc: true, Limit: 2, Cursor: "c1",
+				}).Return(&model.GeolocationPage{
+					Geolocations: []*model.Geolocation{
+						{IP: "10.1.2.3", StartIP: "10.1.2.3", EndIP: "10.1.2.3", Country: "India", CountryCode: "IN", City: "Surat", Latitude: 21.2, Longitude: 72.8},
+						{IP: "10.1.0.0/16", StartIP: "10.1.0.0", EndIP: "10.1.255.255", Country: "India", CountryCode: "IN", City: "Pune", Latitude: 18.5, Longitude: 73.8},
+					},
+					NextCursor: "c2",
+				}, nil)
+				return manager
+			},
+		},
+		{
+			Name:         "empty page",
+			Req:          &ListRequest{},
+			ExpectedResp: &ListResponse{Geolocations: []*GeoLocationResponse{}},
+			MocksInit: func() *modelMocks.GeoLocationManager {
+				manager := new(modelMocks.GeoLocationManager)
+				manager.On("List", mock.Anything, &model.GeolocationQuery{}).Return(&model.GeolocationPage{}, nil)
+				return manager
+			},
+		},
+		{
+			Name: "400 bbox out of range",
+			Req:  &ListRequest{BoundingBox: &model.BoundingBox{MinLatitude: -91, MaxLatitude: 10}},
+			MocksInit: func() *modelMocks.GeoLocationManager {
+				return nil
+			},
+			ExpectedError: router.NewHttpError("invalid bbox", 400),
+		},
+		{
+			Name: "400 invalid cursor",
+			Req:  &ListRequest{Cursor: "x"},
+			MocksInit: func() *modelMocks.GeoLocationManager {
+				manager := new(modelMocks.GeoLocationManager)
+				manager.On("List", mock.Anything, &model.GeolocationQuery{Cursor: "x"}).Return(nil, router.NewHttpError("invalid cursor", 400))
+				return manager
+			},
+			ExpectedError: router.NewHttpError("invalid cursor", 400),
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			srv := NewGeolocationService(tt.MocksInit())
+			resp, err := srv.ListGeolocations(context.TODO(), tt.Req)
+			assert.Equal(tt.ExpectedResp, resp)
+			assert.Equal(tt.ExpectedError, err)
+		})
+	}
+}
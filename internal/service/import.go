@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	zlog "github.com/ohmpatel1997/findhotel/lib/log"
+)
+
+const (
+	defaultImportParallelism = 4
+)
+
+type ImportService interface {
+	// Import parses and stores the files as a single import run, recording what it changes so it can be rolled back.
+	// Files that were already imported successfully are skipped unless opts.Force is set.
+	Import(ctx context.Context, files []string, opts ImportOptions) (*ImportResult, error)
+	Rollback(ctx context.Context, runID uuid.UUID) (*model.RollbackResult, error)
+	Ledger(ctx context.Context, limit int) ([]*model.ImportLedgerEntry, error)
+}
+
+type importService struct {
+	runs    model.ImportRunManager
+	ledger  model.ImportLedgerManager
+	manager model.GeoLocationManager
+}
+
+func NewImportService(runs model.ImportRunManager, ledger model.ImportLedgerManager, mn model.GeoLocationManager) ImportService {
+	return &importService{
+		runs:    runs,
+		ledger:  ledger,
+		manager: mn,
+	}
+}
+
+// ResolveInputs expands the given paths, globs and directories into the list of files to import.
+// Directories contribute the csv files directly inside them.
+func ResolveInputs(inputs []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+
+	add := func(matches ...string) {
+		sort.Strings(matches)
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				files = append(files, m)
+			}
+		}
+	}
+
+	for _, input := range inputs {
+		if strings.ContainsAny(input, "*?[") {
+			matches, err := filepath.Glob(input)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", input, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", input)
+			}
+			add(matches...)
+			continue
+		}
+
+		info, err := os.Stat(input)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			add(input)
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(input, "*.csv"))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no csv files in directory %q", input)
+		}
+		add(matches...)
+	}
+
+	return files, nil
+}
+
+func (s *importService) Import(ctx context.Context, files []string, opts ImportOptions) (*ImportResult, error) {
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = defaultImportParallelism
+	}
+
+	if _, err := NormalizeEncoding(opts.Encoding); err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{
+		Files:   make([]*FileImportResult, len(files)),
+		Skipped: true,
+	}
+
+	// checksums first, the run is only started if there is something left to import
+	err := forEachFile(ctx, files, parallelism, func(i int, file string) error {
+		checksum, size, err := hashFile(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		result.Files[i] = &FileImportResult{File: file, SHA256: checksum, SizeBytes: size}
+
+		if opts.Force {
+			return nil
+		}
+
+		imported, err := s.ledger.FindImported(ctx, checksum)
+		if err != nil {
+			return err
+		}
+		if imported != nil {
+			result.Files[i].Skipped = true
+			result.Files[i].RunID = imported.RunID
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var toImport []int
+	for i, f := range result.Files {
+		if !f.Skipped {
+			toImport = append(toImport, i)
+		}
+	}
+	if len(toImport) == 0 {
+		return result, nil
+	}
+	result.Skipped = false
+
+	run, err := s.runs.Start(ctx, strings.Join(files, ","))
+	if err != nil {
+		return nil, err
+	}
+	result.RunID = run.ID
+
+	started := time.Now()
+	runCtx := model.WithImportRun(ctx, run.ID)
+	visitedIP := newIPSet() // dedup across the files of the run
+
+	var mu sync.Mutex
+	var failed []string
+	var firstErr error
+
+	_ = forEachFile(ctx, toImport, parallelism, func(_ int, i int) error {
+		fileResult := result.Files[i]
+		fileResult.RunID = run.ID
+
+		err := s.importFile(runCtx, fileResult, opts.Encoding, visitedIP)
+		if err != nil {
+			zlog.Logger().Error("error importing file", err, zlog.ParamsType{"Run ID": run.ID.String(), "File": fileResult.File})
+
+			mu.Lock()
+			failed = append(failed, fileResult.File)
+			if firstErr == nil {
+				firstErr = err
+			}
+			mu.Unlock()
+		}
+		return nil
+	})
+
+	result.TimeTaken = time.Since(started).Seconds()
+	for _, i := range toImport {
+		result.Valid += result.Files[i].Valid
+		result.Invalid += result.Files[i].Invalid
+	}
+
+	status := model.ImportRunCompleted
+	if len(failed) > 0 {
+		status = model.ImportRunFailed
+		err = fmt.Errorf("%d of %d files failed to import, first error: %w", len(failed), len(toImport), firstErr)
+	}
+
+	// the run context may already be cancelled, the status has to be stored regardless
+	if finishErr := s.runs.Finish(context.Background(), run.ID, status); finishErr != nil {
+		zlog.Logger().Error("error finishing import run", finishErr, zlog.ParamsType{"Run ID": run.ID.String()})
+	}
+
+	return result, err
+}
+
+func (s *importService) importFile(ctx context.Context, fileResult *FileImportResult, encoding string, visitedIP *ipSet) error {
+	entry := &model.ImportLedgerEntry{
+		RunID:     fileResult.RunID,
+		FileName:  fileResult.File,
+		SHA256:    fileResult.SHA256,
+		SizeBytes: fileResult.SizeBytes,
+		Status:    model.ImportRunRunning,
+	}
+	if err := s.ledger.Create(ctx, entry); err != nil {
+		return err
+	}
+
+	var err error
+	defer func() {
+		entry.Status = model.ImportRunCompleted
+		if err != nil {
+			entry.Status = model.ImportRunFailed
+		}
+		entry.ValidCount = fileResult.Valid
+		entry.InvalidCount = fileResult.Invalid
+		entry.DurationSeconds = fileResult.TimeTaken
+
+		if finishErr := s.ledger.Finish(context.Background(), entry); finishErr != nil {
+			zlog.Logger().Error("error finishing import ledger entry", finishErr, zlog.ParamsType{"Run ID": entry.RunID.String(), "File": entry.FileName})
+		}
+	}()
+
+	f, err := os.Open(fileResult.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fileIPs := visitedIP.child()
+	parsed, err := newParser(f, encoding, s.manager, fileIPs).ParseAndStore(ctx)
+	if parsed != nil {
+		fileResult.ParseResult = *parsed
+	}
+	if err != nil {
+		fileIPs.Release()
+	}
+	return err
+}
+
+func (s *importService) Rollback(ctx context.Context, runID uuid.UUID) (*model.RollbackResult, error) {
+	return s.runs.Rollback(ctx, runID)
+}
+
+func (s *importService) Ledger(ctx context.Context, limit int) ([]*model.ImportLedgerEntry, error) {
+	return s.ledger.List(ctx, limit)
+}
+
+// forEachFile calls fn for every item with at most parallelism calls at a time and returns the first error
+func forEachFile[T any](ctx context.Context, items []T, parallelism int, fn func(int, T) error) error {
+	sem := make(chan struct{}, parallelism)
+	errs := make(chan error, len(items))
+	var wg sync.WaitGroup
+
+	for i, item := range items {
+		if ctx.Err() != nil {
+			errs <- ctx.Err()
+			break
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, item T) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := fn(i, item); err != nil {
+				errs <- err
+			}
+		}(i, item)
+	}
+
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// hashFile returns the SHA-256 and the size of the file
+func hashFile(file string) (string, int64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
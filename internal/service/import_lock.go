@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	zlog "github.com/ohmpatel1997/findhotel/lib/log"
+)
+
+const (
+	importLockName = "geolocations"
+
+	defaultLockStaleAfter        = 60 * time.Second
+	defaultLockHeartbeatInterval = 15 * time.Second
+	defaultLockPollInterval      = 5 * time.Second
+)
+
+var (
+	ErrImportLocked = errors.New("another import is already running")
+)
+
+type ImportLockService interface {
+	// Lock acquires the import lock and keeps it alive until unlock is called.
+	// The returned context is cancelled as soon as the lock is lost, so the run can stop writing.
+	Lock(ctx context.Context) (context.Context, func(), error)
+}
+
+type ImportLockOptions struct {
+	Wait              bool          // wait for the running import instead of failing fast
+	WaitTimeout       time.Duration // zero means wait forever
+	StaleAfter        time.Duration // a lock without heartbeat for this long belongs to a crashed run
+	HeartbeatInterval time.Duration
+	PollInterval      time.Duration
+}
+
+type importLock struct {
+	manager model.ImportLockManager
+	opts    ImportLockOptions
+}
+
+func NewImportLock(mn model.ImportLockManager, opts ImportLockOptions) ImportLockService {
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = defaultLockStaleAfter
+	}
+	if opts.HeartbeatInterval <= 0 {
+		opts.HeartbeatInterval = defaultLockHeartbeatInterval
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultLockPollInterval
+	}
+
+	return &importLock{
+		manager: mn,
+		opts:    opts,
+	}
+}
+
+func (l *importLock) Lock(ctx context.Context) (context.Context, func(), error) {
+	hostname, _ := os.Hostname()
+	lock := &model.ImportLock{
+		Name:     importLockName,
+		Owner:    uuid.New(),
+		Hostname: hostname,
+		PID:      os.Getpid(),
+	}
+
+	waitCtx := ctx
+	if l.opts.Wait && l.opts.WaitTimeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, l.opts.WaitTimeout)
+		defer cancel()
+	}
+
+	var holder *model.ImportLock
+	for {
+		acquired, current, err := l.manager.Acquire(waitCtx, lock, l.opts.StaleAfter)
+		if err != nil {
+			if waitCtx.Err() != nil { // the wait ran out during the query, the lock is still held as far as we know
+				return nil, nil, fmt.Errorf("%w: %v", lockedError(holder), waitCtx.Err())
+			}
+			return nil, nil, err
+		}
+		if acquired {
+			break
+		}
+		holder = current
+
+		if !l.opts.Wait {
+			return nil, nil, lockedError(holder)
+		}
+
+		zlog.Logger().Info("waiting for the running import to finish", lockParams(holder))
+
+		select {
+		case <-waitCtx.Done():
+			return nil, nil, fmt.Errorf("%w: %v", lockedError(holder), waitCtx.Err())
+		case <-time.After(l.opts.PollInterval):
+		}
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(l.opts.HeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				err := l.manager.Heartbeat(runCtx, lock)
+				switch {
+				case errors.Is(err, model.ErrImportLockLost):
+					zlog.Logger().Error("import lock lost, aborting the run", err, lockParams(lock))
+					cancel()
+					return
+				case err != nil: // transient, the lock only goes stale after StaleAfter
+					zlog.Logger().Warn("error sending import lock heartbeat", zlog.ParamsType{"Error": err.Error()})
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	unlock := func() {
+		once.Do(func() {
+			close(stop)
+			<-stopped
+			cancel()
+
+			err := l.manager.Release(context.Background(), lock)
+			if err != nil {
+				zlog.Logger().Warn("error releasing import lock", zlog.ParamsType{"Error": err.Error()})
+			}
+		})
+	}
+
+	return runCtx, unlock, nil
+}
+
+func lockedError(holder *model.ImportLock) error {
+	if holder == nil {
+		return ErrImportLocked
+	}
+	return fmt.Errorf("%w: held by %s (pid %d) since %s", ErrImportLocked, holder.Hostname, holder.PID, holder.AcquiredAt.Format(time.RFC3339))
+}
+
+func lockParams(lock *model.ImportLock) zlog.ParamsType {
+	if lock == nil {
+		return nil
+	}
+	return zlog.ParamsType{
+		"Owner":     lock.Owner.String(),
+		"Hostname":  lock.Hostname,
+		"PID":       lock.PID,
+		"Heartbeat": lock.HeartbeatAt,
+	}
+}
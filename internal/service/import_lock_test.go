@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	"github.com/ohmpatel1997/findhotel/internal/model/mocks"
+	zlog "github.com/ohmpatel1997/findhotel/lib/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestImportLock(t *testing.T) {
+	_ = zlog.New()
+
+	holder := &model.ImportLock{Name: importLockName, Hostname: "importer-1", PID: 42, AcquiredAt: time.Now()}
+
+	cases := []struct {
+		Name          string
+		Opts          ImportLockOptions
+		MocksInit     func() *mocks.ImportLockManager
+		ExpectedError error
+	}{
+		{
+			Name: "acquired",
+			Opts: ImportLockOptions{HeartbeatInterval: time.Hour},
+			MocksInit: func() *mocks.ImportLockManager {
+				manager := new(mocks.ImportLockManager)
+				manager.On("Acquire", mock.Anything, mock.Anything, defaultLockStaleAfter).Return(true, nil, nil)
+				manager.On("Release", mock.Anything, mock.Anything).Return(nil)
+				return manager
+			},
+		},
+		{
+			Name: "held, fail fast",
+			Opts: ImportLockOptions{},
+			MocksInit: func() *mocks.ImportLockManager {
+				manager := new(mocks.ImportLockManager)
+				manager.On("Acquire", mock.Anything, mock.Anything, defaultLockStaleAfter).Return(false, holder, nil)
+				return manager
+			},
+			ExpectedError: ErrImportLocked,
+		},
+		{
+			Name: "held, wait until released",
+			Opts: ImportLockOptions{Wait: true, PollInterval: time.Millisecond, HeartbeatInterval: time.Hour},
+			MocksInit: func() *mocks.ImportLockManager {
+				manager := new(mocks.ImportLockManager)
+				manager.On("Acquire", mock.Anything, mock.Anything, defaultLockStaleAfter).Return(false, holder, nil).Twice()
+				manager.On("Acquire", mock.Anything, mock.Anything, defaultLockStaleAfter).Return(true, nil, nil).Once()
+				manager.On("Release", mock.Anything, mock.Anything).Return(nil)
+				return manager
+			},
+		},
+		{
+			Name: "held, wait times out",
+			Opts: ImportLockOptions{Wait: true, WaitTimeout: 20 * time.Millisecond, PollInterval: time.Millisecond},
+			MocksInit: func() *mocks.ImportLockManager {
+				manager := new(mocks.ImportLockManager)
+				manager.On("Acquire", mock.Anything, mock.Anything, defaultLockStaleAfter).Return(false, holder, nil)
+				return manager
+			},
+			ExpectedError: ErrImportLocked,
+		},
+		{
+			Name: "wait times out during a query",
+			Opts: ImportLockOptions{Wait: true, WaitTimeout: 20 * time.Millisecond, PollInterval: time.Millisecond},
+			MocksInit: func() *mocks.ImportLockManager {
+				manager := new(mocks.ImportLockManager)
+				manager.On("Acquire", mock.Anything, mock.Anything, defaultLockStaleAfter).Return(false, holder, nil).Once()
+				manager.On("Acquire", mock.Anything, mock.Anything, defaultLockStaleAfter).Return(false, nil, context.DeadlineExceeded).
+					WaitUntil(time.After(30 * time.Millisecond))
+				return manager
+			},
+			ExpectedError: ErrImportLocked,
+		},
+		{
+			Name: "database error",
+			Opts: ImportLockOptions{},
+			MocksInit: func() *mocks.ImportLockManager {
+				manager := new(mocks.ImportLockManager)
+				manager.On("Acquire", mock.Anything, mock.Anything, defaultLockStaleAfter).Return(false, nil, errors.New("custom error"))
+				return manager
+			},
+			ExpectedError: errors.New("custom error"),
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			manager := tt.MocksInit()
+
+			ctx, unlock, err := NewImportLock(manager, tt.Opts).Lock(context.TODO())
+			if tt.ExpectedError != nil {
+				if !errors.Is(err, tt.ExpectedError) {
+					assert.EqualError(err, tt.ExpectedError.Error())
+				}
+				assert.Nil(unlock)
+				return
+			}
+
+			assert.Nil(err)
+			assert.Nil(ctx.Err())
+			unlock()
+			assert.NotNil(ctx.Err())
+			manager.AssertExpectations(t)
+		})
+	}
+}
+
+func TestImportLockLost(t *testing.T) {
+	_ = zlog.New()
+	assert := assert.New(t)
+
+	manager := new(mocks.ImportLockManager)
+	manager.On("Acquire", mock.Anything, mock.Anything, defaultLockStaleAfter).Return(true, nil, nil)
+	manager.On("Heartbeat", mock.Anything, mock.Anything).Return(model.ErrImportLockLost)
+	manager.On("Release", mock.Anything, mock.Anything).Return(nil)
+
+	ctx, unlock, err := NewImportLock(manager, ImportLockOptions{HeartbeatInterval: time.Millisecond}).Lock(context.TODO())
+	assert.Nil(err)
+	defer unlock()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		assert.Fail("run context was not cancelled after the lock was lost")
+	}
+}
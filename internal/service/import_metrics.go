@@ -0,0 +1,48 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the reasons rows are rejected for
+const (
+	rejectEmptyLine        = "empty_line"
+	rejectFieldCount       = "field_count"
+	rejectEmptyValue       = "empty_value"
+	rejectUnknownColumn    = "unknown_column"
+	rejectInvalidIP        = "invalid_ip"
+	rejectInvalidNetwork   = "invalid_network"
+	rejectInvalidRange     = "invalid_range"
+	rejectInvalidLatitude  = "invalid_latitude"
+	rejectInvalidLongitude = "invalid_longitude"
+	rejectDuplicateIP      = "duplicate_ip"
+	rejectInvalidEncoding  = "invalid_encoding"
+)
+
+var (
+	importRowsParsed = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "import_rows_parsed_total",
+		Help: "The rows of the data dumps parsed, the rejected ones included.",
+	})
+
+	importRowsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "import_rows_rejected_total",
+		Help: "The rows of the data dumps rejected, by reason.",
+	}, []string{"reason"})
+
+	importBatchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "import_batch_insert_duration_seconds",
+		Help:    "The duration of the batch inserts of the parsed rows, failed ones included.",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 10), // 50ms to 25.6s
+	})
+
+	importBatchFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "import_batch_insert_failures_total",
+		Help: "The batch inserts of the parsed rows that failed.",
+	})
+)
+
+// ImportMetrics returns the counters of the importer, for the processes running imports to register
+func ImportMetrics() []prometheus.Collector {
+	return []prometheus.Collector{importRowsParsed, importRowsRejected, importBatchDuration, importBatchFailures}
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	"github.com/ohmpatel1997/findhotel/internal/model/mocks"
+	zlog "github.com/ohmpatel1997/findhotel/lib/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	test1File     = "./test_data/test1.csv"
+	test1Checksum = "f5e3baa297147ab7a925d6bd4b4b1a9ac5dd258f18c4b48f13bcfc52b7119721"
+	part1File     = "test_data/parts/part-0001.csv"
+	part2File     = "test_data/parts/part-0002.csv"
+	part1Checksum = "5eb9d369dcc2524d2e05d45e0837cb5b611aaec00f86d04a66e5435b85f87054"
+	part2Checksum = "b9d980b9a605dced43472ac5a55033762e21efaa64fe6c790af97b992cd0f1e0"
+)
+
+func TestImport(t *testing.T) {
+	_ = zlog.New()
+	runID := uuid.New()
+	previousRunID := uuid.New()
+
+	cases := []struct {
+		Name           string
+		Files          []string
+		Opts           ImportOptions
+		Imported       map[string]*model.ImportLedgerEntry
+		InsertError    error
+		ExpectedStatus string
+		ExpectedResult *ImportResult
+		ExpectedError  bool
+	}{
+		{
+			Name:           "completed",
+			Files:          []string{test1File},
+			ExpectedStatus: model.ImportRunCompleted,
+			ExpectedResult: &ImportResult{RunID: runID, Valid: 3, Invalid: 2, Files: []*FileImportResult{
+				{File: test1File, SHA256: test1Checksum, SizeBytes: 487, RunID: runID, ParseResult: ParseResult{Encoding: EncodingUTF8, Valid: 3, Invalid: 2}},
+			}},
+		},
+		{
+			Name:           "failed batch",
+			Files:          []string{test1File},
+			InsertError:    errors.New("custom error"),
+			ExpectedStatus: model.ImportRunFailed,
+			ExpectedResult: &ImportResult{RunID: runID, Valid: 3, Invalid: 2, Files: []*FileImportResult{
+				{File: test1File, SHA256: test1Checksum, SizeBytes: 487, RunID: runID, ParseResult: ParseResult{Encoding: EncodingUTF8, Valid: 3, Invalid: 2}},
+			}},
+			ExpectedError: true,
+		},
+		{
+			Name:     "already imported",
+			Files:    []string{test1File},
+			Imported: map[string]*model.ImportLedgerEntry{test1Checksum: {RunID: previousRunID}},
+			ExpectedResult: &ImportResult{Skipped: true, Files: []*FileImportResult{
+				{File: test1File, SHA256: test1Checksum, SizeBytes: 487, RunID: previousRunID, Skipped: true},
+			}},
+		},
+		{
+			Name:           "already imported, forced",
+			Files:          []string{test1File},
+			Opts:           ImportOptions{Force: true},
+			Imported:       map[string]*model.ImportLedgerEntry{test1Checksum: {RunID: previousRunID}},
+			ExpectedStatus: model.ImportRunCompleted,
+			ExpectedResult: &ImportResult{RunID: runID, Valid: 3, Invalid: 2, Files: []*FileImportResult{
+				{File: test1File, SHA256: test1Checksum, SizeBytes: 487, RunID: runID, ParseResult: ParseResult{Encoding: EncodingUTF8, Valid: 3, Invalid: 2}},
+			}},
+		},
+		{
+			Name:           "parts deduplicated across files",
+			Files:          []string{part1File, part2File},
+			Opts:           ImportOptions{Parallelism: 1},
+			ExpectedStatus: model.ImportRunCompleted,
+			ExpectedResult: &ImportResult{RunID: runID, Valid: 3, Invalid: 1},
+		},
+		{
+			Name:           "one part already imported",
+			Files:          []string{part1File, part2File},
+			Imported:       map[string]*model.ImportLedgerEntry{part1Checksum: {RunID: previousRunID}},
+			ExpectedStatus: model.ImportRunCompleted,
+			ExpectedResult: &ImportResult{RunID: runID, Valid: 2, Invalid: 0, Files: []*FileImportResult{
+				{File: part1File, SHA256: part1Checksum, SizeBytes: 240, RunID: previousRunID, Skipped: true},
+				{File: part2File, SHA256: part2Checksum, SizeBytes: 243, RunID: runID, ParseResult: ParseResult{Encoding: EncodingUTF8, Valid: 2, Invalid: 0}},
+			}},
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			ledger := new(mocks.ImportLedgerManager)
+			ledger.On("FindImported", mock.Anything, mock.Anything).Return(func(_ context.Context, checksum string) *model.ImportLedgerEntry {
+				return tt.Imported[checksum]
+			}, nil)
+			ledger.On("Create", mock.Anything, mock.Anything).Return(nil)
+			ledger.On("Finish", mock.Anything, mock.MatchedBy(func(e *model.ImportLedgerEntry) bool {
+				return e.Status == tt.ExpectedStatus && e.RunID == runID
+			})).Return(nil)
+
+			runs := new(mocks.ImportRunManager)
+			runs.On("Start", mock.Anything, mock.Anything).Return(&model.ImportRun{ID: runID, Status: model.ImportRunRunning}, nil)
+			runs.On("Finish", mock.Anything, runID, tt.ExpectedStatus).Return(nil)
+
+			locationManager := new(mocks.GeoLocationManager)
+			locationManager.On("BulkInsert", mock.Anything, mock.Anything).Return(tt.InsertError)
+
+			result, err := NewImportService(runs, ledger, locationManager).Import(context.TODO(), tt.Files, tt.Opts)
+			assert.Equal(tt.ExpectedError, err != nil)
+
+			assert.Equal(tt.ExpectedResult.RunID, result.RunID)
+			assert.Equal(tt.ExpectedResult.Skipped, result.Skipped)
+			assert.Equal(tt.ExpectedResult.Valid, result.Valid)
+			assert.Equal(tt.ExpectedResult.Invalid, result.Invalid)
+			assert.Len(result.Files, len(tt.Files))
+			for i, f := range tt.ExpectedResult.Files {
+				result.Files[i].TimeTaken = 0
+				assert.Equal(f, result.Files[i])
+			}
+
+			if tt.ExpectedStatus != "" {
+				runs.AssertExpectations(t)
+				ledger.AssertCalled(t, "Finish", mock.Anything, mock.Anything)
+			} else {
+				runs.AssertNotCalled(t, "Start", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestImportFailedFileNotDeduplicated(t *testing.T) {
+	_ = zlog.New()
+	assert := assert.New(t)
+	runID := uuid.New()
+
+	ledger := new(mocks.ImportLedgerManager)
+	ledger.On("FindImported", mock.Anything, mock.Anything).Return(nil, nil)
+	ledger.On("Create", mock.Anything, mock.Anything).Return(nil)
+	ledger.On("Finish", mock.Anything, mock.Anything).Return(nil)
+
+	runs := new(mocks.ImportRunManager)
+	runs.On("Start", mock.Anything, mock.Anything).Return(&model.ImportRun{ID: runID, Status: model.ImportRunRunning}, nil)
+	runs.On("Finish", mock.Anything, runID, model.ImportRunFailed).Return(nil)
+
+	// the batch of the first part fails, its ips are still free for the second part
+	var stored []string
+	locationManager := new(mocks.GeoLocationManager)
+	locationManager.On("BulkInsert", mock.Anything, mock.Anything).Return(func(_ context.Context, rows []*model.Geolocation) error {
+		for _, g := range rows {
+			if g.IP == "160.103.7.140" {
+				return errors.New("custom error")
+			}
+		}
+		for _, g := range rows {
+			stored = append(stored, g.IP)
+		}
+		return nil
+	})
+
+	result, err := NewImportService(runs, ledger, locationManager).Import(context.TODO(), []string{part1File, part2File}, ImportOptions{Parallelism: 1})
+	assert.NotNil(err)
+	assert.Equal(int64(4), result.Valid)
+	assert.Equal(int64(0), result.Invalid)
+	assert.Equal([]string{"70.95.73.73", "200.106.141.15"}, stored)
+	runs.AssertExpectations(t)
+}
+
+func TestImportParallelDeduplicated(t *testing.T) {
+	_ = zlog.New()
+	assert := assert.New(t)
+	runID := uuid.New()
+
+	// the parts share an ip, which one of them has to reject whichever is parsed first
+	for i := 0; i < 20; i++ {
+		ledger := new(mocks.ImportLedgerManager)
+		ledger.On("FindImported", mock.Anything, mock.Anything).Return(nil, nil)
+		ledger.On("Create", mock.Anything, mock.Anything).Return(nil)
+		ledger.On("Finish", mock.Anything, mock.Anything).Return(nil)
+
+		runs := new(mocks.ImportRunManager)
+		runs.On("Start", mock.Anything, mock.Anything).Return(&model.ImportRun{ID: runID, Status: model.ImportRunRunning}, nil)
+		runs.On("Finish", mock.Anything, runID, model.ImportRunCompleted).Return(nil)
+
+		var mu sync.Mutex
+		stored := make(map[string]int)
+		locationManager := new(mocks.GeoLocationManager)
+		locationManager.On("BulkInsert", mock.Anything, mock.Anything).Return(func(_ context.Context, rows []*model.Geolocation) error {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, g := range rows {
+				stored[g.IP]++
+			}
+			return nil
+		})
+
+		duplicates := testutil.ToFloat64(importRowsRejected.WithLabelValues(rejectDuplicateIP))
+		result, err := NewImportService(runs, ledger, locationManager).Import(context.TODO(), []string{part1File, part2File}, ImportOptions{Parallelism: 2})
+		assert.Nil(err)
+		assert.Equal(int64(3), result.Valid)
+		assert.Equal(int64(1), result.Invalid)
+		assert.Equal(float64(1), testutil.ToFloat64(importRowsRejected.WithLabelValues(rejectDuplicateIP))-duplicates)
+		assert.Equal(map[string]int{"70.95.73.73": 1, "200.106.141.15": 1, "160.103.7.140": 1}, stored)
+	}
+}
+
+func TestIPSet(t *testing.T) {
+	_ = zlog.New()
+	assert := assert.New(t)
+	run := &ipSet{ips: make(map[string]bool), limit: 2}
+
+	file := run.child()
+	assert.True(file.Visit("10.0.0.1"))
+	assert.False(file.Visit("10.0.0.1"))
+	assert.True(run.Visited("10.0.0.1")) // reserved for the other files at once
+
+	other := run.child()
+	assert.False(other.Visit("10.0.0.1"))
+	assert.True(other.Visit("10.0.0.2"))
+	assert.True(file.Visit("10.0.0.3"))
+	assert.True(file.Visit("10.0.0.3")) // past the limit, no longer remembered
+	assert.Len(run.ips, 2)
+
+	// the reservations of a failed file are dropped, the ones of the others kept
+	file.Release()
+	assert.False(run.Visited("10.0.0.1"))
+	assert.True(run.Visited("10.0.0.2"))
+	assert.True(other.Visit("10.0.0.1"))
+	assert.Len(run.ips, 2)
+}
+
+func TestResolveInputs(t *testing.T) {
+	cases := []struct {
+		Name          string
+		Inputs        []string
+		ExpectedFiles []string
+		ExpectedError bool
+	}{
+		{
+			Name:          "single file",
+			Inputs:        []string{test1File},
+			ExpectedFiles: []string{test1File},
+		},
+		{
+			Name:          "glob",
+			Inputs:        []string{"test_data/parts/part-*.csv"},
+			ExpectedFiles: []string{part1File, part2File},
+		},
+		{
+			Name:          "directory",
+			Inputs:        []string{"test_data/parts"},
+			ExpectedFiles: []string{part1File, part2File},
+		},
+		{
+			Name:          "duplicates are imported once",
+			Inputs:        []string{part2File, "test_data/parts"},
+			ExpectedFiles: []string{part2File, part1File},
+		},
+		{
+			Name:          "glob without matches",
+			Inputs:        []string{"test_data/parts/missing-*.csv"},
+			ExpectedError: true,
+		},
+		{
+			Name:          "missing file",
+			Inputs:        []string{"test_data/missing.csv"},
+			ExpectedError: true,
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			files, err := ResolveInputs(tt.Inputs)
+			assert.Equal(tt.ExpectedError, err != nil)
+			assert.Equal(tt.ExpectedFiles, files)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	zlog "github.com/ohmpatel1997/findhotel/lib/log"
+)
+
+const defaultImportWatchInterval = 10 * time.Second
+
+// WatchImports polls the import runs every interval and calls onChange whenever a run finished or was rolled back
+// since the previous poll, so the api can drop what it derived from the old data. It returns when ctx is done.
+func WatchImports(ctx context.Context, runs model.ImportRunManager, interval time.Duration, onChange func()) {
+	if interval <= 0 {
+		interval = defaultImportWatchInterval
+	}
+
+	last, err := runs.LastChange(ctx)
+	if err != nil {
+		zlog.Logger().Warn("error checking for finished imports", zlog.ParamsType{"Error": err.Error()})
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		change, err := runs.LastChange(ctx)
+		if err != nil {
+			zlog.Logger().Warn("error checking for finished imports", zlog.ParamsType{"Error": err.Error()})
+			continue
+		}
+		if change.After(last) {
+			last = change
+			onChange()
+		}
+	}
+}
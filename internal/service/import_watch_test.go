@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ohmpatel1997/findhotel/internal/model/mocks"
+	zlog "github.com/ohmpatel1997/findhotel/lib/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWatchImports(t *testing.T) {
+	_ = zlog.New()
+	assert := assert.New(t)
+
+	t0 := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	runs := new(mocks.ImportRunManager)
+	runs.On("LastChange", mock.Anything).Return(t0, nil).Twice()
+	runs.On("LastChange", mock.Anything).Return(time.Time{}, errors.New("custom error")).Once()
+	runs.On("LastChange", mock.Anything).Return(t0.Add(time.Minute), nil)
+
+	var changes int32
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		WatchImports(ctx, runs, time.Millisecond, func() { atomic.AddInt32(&changes, 1) })
+		close(done)
+	}()
+
+	assert.Eventually(func() bool { return atomic.LoadInt32(&changes) == 1 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	<-done
+
+	// the same change is only reported once
+	assert.Equal(int32(1), atomic.LoadInt32(&changes))
+}
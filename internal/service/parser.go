@@ -3,10 +3,14 @@ package service
 import (
 	"bufio"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/ohmpatel1997/findhotel/internal/common"
 	"github.com/ohmpatel1997/findhotel/internal/model"
@@ -15,117 +19,337 @@ import (
 
 const (
 	bulkSize = 8191
+
+	maxReportedLines = 10
+
+	// maxVisitedIPs bounds the ips remembered to reject the duplicates of a run, at about 100 bytes each. Past it the
+	// rows of an ip are all stored, the last one written winning.
+	maxVisitedIPs = 10000000
+)
+
+var (
+	ErrUnsupportedHeader = errors.New("unsupported csv header")
 )
 
 type ParserService interface {
-	ParseAndStore() (float64, int64, int64, error)
+	ParseAndStore(ctx context.Context) (*ParseResult, error)
 }
 
 type parser struct {
-	f       io.Reader
-	manager model.GeoLocationManager
+	f         io.Reader
+	encoding  string
+	manager   model.GeoLocationManager
+	visitedIP *ipSet
+}
+
+// NewParser returns a parser for f in the given encoding, EncodingAuto detects it
+func NewParser(f io.Reader, encoding string, mn model.GeoLocationManager) ParserService {
+	return newParser(f, encoding, mn, newIPSet())
 }
 
-func NewParser(f io.Reader, mn model.GeoLocationManager) ParserService {
+// newParser returns a parser sharing visitedIP with the other files of the same import run
+func newParser(f io.Reader, encoding string, mn model.GeoLocationManager, visitedIP *ipSet) ParserService {
 	return &parser{
-		f:       f,
-		manager: mn,
+		f:         f,
+		encoding:  encoding,
+		manager:   mn,
+		visitedIP: visitedIP,
+	}
+}
+
+// ipSet keeps track of the already visited ip addresses, it is safe for concurrent use. The set of a file has the one
+// of its run as parent, which it reserves its ips in as it visits them, so that the files imported at the same time
+// dedup against each other too. The reservations of a failed file are dropped with Release, so that its rows don't
+// reject the ones of the other files as duplicates.
+type ipSet struct {
+	mu     sync.Mutex
+	ips    map[string]bool // the ips reserved in the parent, for the set of a file
+	parent *ipSet
+	limit  int  // the ips remembered at most, past it the set stops growing and the duplicates go undetected
+	full   bool // the limit was reached
+}
+
+func newIPSet() *ipSet {
+	return &ipSet{ips: make(map[string]bool), limit: maxVisitedIPs}
+}
+
+// child returns an empty set of a file of the run of s
+func (s *ipSet) child() *ipSet {
+	return &ipSet{ips: make(map[string]bool), parent: s, limit: s.limit}
+}
+
+func (s *ipSet) Visited(ip string) bool {
+	if s.parent != nil {
+		return s.parent.Visited(ip)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ips[ip]
+}
+
+// Visit marks ip as visited and reports whether it was visited for the first time, checking and reserving it in the
+// parent at once
+func (s *ipSet) Visit(ip string) bool {
+	if s.parent == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.ips[ip] {
+			return false
+		}
+		s.add(ip)
+		return true
+	}
+
+	s.parent.mu.Lock()
+	defer s.parent.mu.Unlock()
+	if s.parent.ips[ip] {
+		return false
+	}
+	if s.parent.add(ip) {
+		s.mu.Lock()
+		s.ips[ip] = true
+		s.mu.Unlock()
 	}
+	return true
 }
 
-func (p *parser) ParseAndStore() (float64, int64, int64, error) {
+// Release drops the ips reserved by s from its parent
+func (s *ipSet) Release() {
+	s.parent.mu.Lock()
+	defer s.parent.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ip := range s.ips {
+		delete(s.parent.ips, ip)
+	}
+	s.ips = make(map[string]bool)
+}
+
+// add adds ip unless the set is full and reports whether it did, s.mu is held
+func (s *ipSet) add(ip string) bool {
+	if s.limit > 0 && len(s.ips) >= s.limit {
+		if !s.full {
+			s.full = true
+			zlog.Logger().Warn("too many ips to remember, the duplicates that follow are no longer rejected", zlog.ParamsType{"Limit": s.limit})
+		}
+		return false
+	}
+	s.ips[ip] = true
+	return true
+}
+
+func (p *parser) ParseAndStore(ctx context.Context) (*ParseResult, error) {
 	timeThen := time.Now()
-	r := bufio.NewReader(p.f)
+
+	utf8Reader, encoding, err := newUTF8Reader(p.f, p.encoding)
+	if err != nil {
+		return nil, err
+	}
+	result := &ParseResult{Encoding: encoding}
+
+	r := bufio.NewReader(utf8Reader)
 	firstLine, _, err := r.ReadLine()
 	if err != nil {
-		return 0, 0, 0, err
+		return nil, err
 	}
-	firstLineSlice := strings.Split(string(firstLine), ",")
+	firstLineSlice := strings.Split(strings.TrimSuffix(string(firstLine), "\r"), ",")
 	positions := make(map[int]string)
 
 	//map the positions
 	for i, header := range firstLineSlice {
 		positions[i] = header
 	}
+	if err := validateHeader(positions); err != nil {
+		return nil, err
+	}
 
 	outPutChan := make(chan *model.Geolocation, 10000)
-	go p.saveToDB(outPutChan)
+	saved := make(chan error, 1)
+	go func() {
+		saved <- p.saveToDB(ctx, outPutChan)
+	}()
 
-	var validDataCount int64 = 0
-	var inValidDataCount int64 = 0
-	visitedIP := make(map[string]bool) // will keep track of already visited ip address
+	var lines int64 = 1 // the header
+	var readErr error
 
-	for {
+	for ctx.Err() == nil {
 		buf := make([]byte, 500*1024)
 		n, err := r.Read(buf)
 		buf = buf[:n]
 
+		if err != nil && err != io.EOF {
+			readErr = err
+			break
+		}
 		if n == 0 {
 			break
 		}
 
-		nextUntillNewline, err := r.ReadBytes('\n')
-		if err != io.EOF {
-			buf = append(buf, nextUntillNewline...)
-		}
+		// on EOF this is the rest of the last line, if the file doesn't end with a newline
+		nextUntillNewline, _ := r.ReadBytes('\n')
+		buf = append(buf, nextUntillNewline...)
 
-		c1, c2 := processChunk(buf, positions, outPutChan, visitedIP)
-		inValidDataCount += c1
-		validDataCount += c2
+		chunk := processChunk(buf, lines+1, positions, outPutChan, p.visitedIP)
+		lines += chunk.lines
+		importRowsParsed.Add(float64(chunk.valid + chunk.invalid))
+		for reason, n := range chunk.rejected {
+			importRowsRejected.WithLabelValues(reason).Add(float64(n))
+		}
+		result.Invalid += chunk.invalid
+		result.Valid += chunk.valid
+		result.InvalidEncoding += int64(len(chunk.invalidEncodingLines))
+		for _, line := range chunk.invalidEncodingLines {
+			if len(result.InvalidEncodingLines) < maxReportedLines {
+				result.InvalidEncodingLines = append(result.InvalidEncodingLines, line)
+			}
+		}
 	}
 
 	close(outPutChan)
+	saveErr := <-saved // the run is only done once every batch has been written
+	result.TimeTaken = time.Since(timeThen).Seconds()
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	if readErr != nil {
+		return result, readErr
+	}
+	if saveErr != nil {
+		return result, saveErr
+	}
+
+	return result, nil
+}
 
-	return time.Since(timeThen).Seconds(), inValidDataCount, validDataCount, nil
+type chunkResult struct {
+	lines                int64
+	valid                int64
+	invalid              int64
+	invalidEncodingLines []int64          // line numbers of the rows with invalid byte sequences, counted in invalid
+	rejected             map[string]int64 // the invalid rows by reason
 }
 
-func processChunk(chunk []byte, positions map[int]string, outPutChan chan<- *model.Geolocation, visitedIP map[string]bool) (int64, int64) {
-	var invalidCount int64 = 0
-	var validCount int64 = 0
+// processChunk validates the lines of chunk, the first of them being line number firstLine of the file
+func processChunk(chunk []byte, firstLine int64, positions map[int]string, outPutChan chan<- *model.Geolocation, visitedIP *ipSet) chunkResult {
+	result := chunkResult{rejected: make(map[string]int64)}
 	logs := string(chunk)
 
 	logsSlice := strings.Split(logs, "\n")
 	n := len(logsSlice)
+	if n > 0 && len(logsSlice[n-1]) == 0 { // chunks end with a newline, that is not a line gap
+		n--
+	}
+	result.lines = int64(n)
 
 	for i := 0; i < n; i++ {
-		geoloc, valid := isValidLine(positions, logsSlice[i], visitedIP)
-		if !valid {
-			invalidCount++
+		line := strings.TrimSuffix(logsSlice[i], "\r")
+
+		// the rows with invalid byte sequences are never stored, a U+FFFD of the input being a character like any other
+		if !utf8.ValidString(line) {
+			result.invalid++
+			result.rejected[rejectInvalidEncoding]++
+			result.invalidEncodingLines = append(result.invalidEncodingLines, firstLine+int64(i))
+			continue
+		}
+
+		geoloc, reason := isValidLine(positions, line, visitedIP)
+		if reason == "" && !visitedIP.Visit(geoloc.IP) { // a file of the run imported at the same time may have visited it
+			reason = rejectDuplicateIP
+		}
+		if reason != "" {
+			result.invalid++
+			result.rejected[reason]++
 		} else {
 			outPutChan <- geoloc
-			visitedIP[geoloc.IP] = true
-			validCount++
+			result.valid++
 		}
 	}
 
-	return invalidCount, validCount //return the invalid data count
+	return result
 }
 
-func isValidLine(positions map[int]string, text string, visitedIP map[string]bool) (*model.Geolocation, bool) {
+// validateHeader checks that the header has every column once, with the addresses in one of the supported layouts:
+// ip_address, network or start_ip and end_ip
+func validateHeader(positions map[int]string) error {
+	columns := make(map[string]bool)
+	for _, col := range positions {
+		switch col {
+		case common.IP, common.Network, common.StartIP, common.EndIP, common.CountryCode, common.Country,
+			common.City, common.Latitude, common.Longitude, common.MysteryValue:
+		default:
+			return fmt.Errorf("%w: unknown column %q", ErrUnsupportedHeader, col)
+		}
+		if columns[col] {
+			return fmt.Errorf("%w: duplicate column %q", ErrUnsupportedHeader, col)
+		}
+		columns[col] = true
+	}
+
+	for _, col := range []string{common.CountryCode, common.Country, common.City, common.Latitude, common.Longitude, common.MysteryValue} {
+		if !columns[col] {
+			return fmt.Errorf("%w: missing column %q", ErrUnsupportedHeader, col)
+		}
+	}
+
+	layouts := 0
+	if columns[common.IP] {
+		layouts++
+	}
+	if columns[common.Network] {
+		layouts++
+	}
+	if columns[common.StartIP] || columns[common.EndIP] {
+		if !columns[common.StartIP] || !columns[common.EndIP] {
+			return fmt.Errorf("%w: %q and %q go together", ErrUnsupportedHeader, common.StartIP, common.EndIP)
+		}
+		layouts++
+	}
+	if layouts != 1 {
+		return fmt.Errorf("%w: expected exactly one of %q, %q or %q and %q", ErrUnsupportedHeader, common.IP, common.Network, common.StartIP, common.EndIP)
+	}
+
+	return nil
+}
+
+// isValidLine parses text, returning the reason it is rejected for when it isn't valid, empty when it is
+func isValidLine(positions map[int]string, text string, visitedIP *ipSet) (*model.Geolocation, string) {
 	if len(text) == 0 { //in case there is line gap
-		return nil, false
+		return nil, rejectEmptyLine
 	}
 	logSlice := strings.Split(text, ",")
-	if len(logSlice) != 7 { //if not valid number of fields
-		return nil, false
+	if len(logSlice) != len(positions) { //if not valid number of fields
+		return nil, rejectFieldCount
 	}
 
 	geoloc := model.Geolocation{}
+	var startIP, endIP string
 	for i, value := range logSlice {
 		if len(value) == 0 { //if empty value
-			return nil, false
+			return nil, rejectEmptyValue
 		}
 		col := positions[i]
 		switch col {
 		case common.IP:
-			IPValid := common.IsIpv4Regex(value)
-			if !IPValid {
-				return nil, false
+			addr := common.ParseIP(value)
+			if addr == nil {
+				return nil, rejectInvalidIP
 			}
-			if ok := visitedIP[value]; ok {
-				return nil, false
+			geoloc.IP = addr.String()
+			geoloc.StartIP = geoloc.IP
+			geoloc.EndIP = geoloc.IP
+		case common.Network:
+			start, end, key, err := common.ParseNetwork(value)
+			if err != nil {
+				return nil, rejectInvalidNetwork
 			}
-			geoloc.IP = value
+			geoloc.IP = key
+			geoloc.StartIP = start.String()
+			geoloc.EndIP = end.String()
+		case common.StartIP:
+			startIP = value
+		case common.EndIP:
+			endIP = value
 		case common.CountryCode:
 			geoloc.CountryCode = value
 		case common.Country:
@@ -133,48 +357,96 @@ func isValidLine(positions map[int]string, text string, visitedIP map[string]boo
 		case common.Longitude:
 			longitude, err := strconv.ParseFloat(value, 64)
 			if err != nil {
-				return nil, false
+				return nil, rejectInvalidLongitude
 			}
 			if longitude > 180 || longitude < -180 { //invalid longitude coordinates
-				return nil, false
+				return nil, rejectInvalidLongitude
 			}
-			geoloc.Longitude = value
+			geoloc.Longitude = longitude
 		case common.Latitude:
 			latitude, err := strconv.ParseFloat(value, 64)
 			if err != nil {
-				return nil, false
+				return nil, rejectInvalidLatitude
 			}
 			if latitude > 90 || latitude < -90 { //invalid latitude coordinates
-				return nil, false
+				return nil, rejectInvalidLatitude
 			}
 
-			geoloc.Latitude = value
+			geoloc.Latitude = latitude
 		case common.MysteryValue:
 			geoloc.MysteryValue = value
 		case common.City:
 			geoloc.City = value
 		default: //if some other columns come in
-			return nil, false
+			return nil, rejectUnknownColumn
+		}
+	}
+
+	if len(startIP) > 0 || len(endIP) > 0 {
+		start, end, key, err := common.ParseRange(startIP, endIP)
+		if err != nil {
+			return nil, rejectInvalidRange
 		}
+		geoloc.IP = key
+		geoloc.StartIP = start.String()
+		geoloc.EndIP = end.String()
 	}
-	return &geoloc, true
+
+	if ok := visitedIP.Visited(geoloc.IP); ok {
+		return nil, rejectDuplicateIP
+	}
+
+	return &geoloc, ""
 }
 
-func (p *parser) saveToDB(savChan <-chan *model.Geolocation) {
+// saveToDB writes the rows in batches and returns an error describing the failed batches, if any
+func (p *parser) saveToDB(ctx context.Context, savChan <-chan *model.Geolocation) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var batches, failed int
+	var firstErr error
+
+	insert := func(data []*model.Geolocation) {
+		batches++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := p.manager.BulkInsert(ctx, data)
+			importBatchDuration.Observe(time.Since(start).Seconds())
+			if err != nil {
+				zlog.Logger().Warn("Error occurred while bulk insert", zlog.ParamsType{"Error": err.Error()})
+				importBatchFailures.Inc()
+				mu.Lock()
+				failed++
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+		}()
+	}
+
 	resultSlice := make([]*model.Geolocation, 0, bulkSize) //8191, coz postgres supports 65535 parameters in bulk
 	for data := range savChan {
 		resultSlice = append(resultSlice, data)
 		if len(resultSlice) == bulkSize {
 			var local_data []*model.Geolocation
 			local_data = append(local_data, resultSlice...)
-			go func() {
-				err := p.manager.BulkInsert(context.Background(), local_data)
-				if err != nil {
-					zlog.Logger().Warn("Error occurred while bulk insert", zlog.ParamsType{"Error": err.Error()})
-					return
-				}
-			}()
-			resultSlice = nil
+			insert(local_data)
+			resultSlice = resultSlice[:0]
 		}
 	}
+
+	if len(resultSlice) > 0 {
+		insert(resultSlice)
+	}
+
+	wg.Wait()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d batches failed to insert: %w", failed, batches, firstErr)
+	}
+	return nil
 }
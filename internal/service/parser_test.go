@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
@@ -14,22 +15,78 @@ import (
 
 func TestParseAndStore(t *testing.T) {
 	cases := []struct {
-		Name         string
-		fileName     string
-		ValidCount   int64
-		InvalidCount int64
+		Name                 string
+		fileName             string
+		Encoding             string
+		ValidCount           int64
+		InvalidCount         int64
+		ExpectedEncoding     string
+		ExpectedCities       []string
+		InvalidEncodingLines []int64
 	}{
 		{
-			Name:         "csv1",
-			fileName:     "test1.csv",
-			ValidCount:   3,
-			InvalidCount: 2,
+			Name:             "csv1",
+			fileName:         "test1.csv",
+			Encoding:         EncodingAuto,
+			ValidCount:       3,
+			InvalidCount:     2,
+			ExpectedEncoding: EncodingUTF8,
+			ExpectedCities:   []string{"DuBuquemouth", "New Neva", "Gradymouth"},
+		},
+		{
+			Name:             "latin-1 detected",
+			fileName:         "latin1.csv",
+			Encoding:         EncodingAuto,
+			ValidCount:       2,
+			ExpectedEncoding: EncodingWindows1252,
+			ExpectedCities:   []string{"São Paulo", "München"},
+		},
+		{
+			Name:             "latin-1 declared",
+			fileName:         "latin1.csv",
+			Encoding:         "ISO-8859-1",
+			ValidCount:       2,
+			ExpectedEncoding: EncodingLatin1,
+			ExpectedCities:   []string{"São Paulo", "München"},
+		},
+		{
+			Name:             "utf-16le with bom and crlf detected",
+			fileName:         "utf16le_bom.csv",
+			Encoding:         EncodingAuto,
+			ValidCount:       2,
+			ExpectedEncoding: EncodingUTF16LE,
+			ExpectedCities:   []string{"São Paulo", "München"},
+		},
+		{
+			Name:                 "invalid byte sequence rejected",
+			fileName:             "invalid_utf8.csv",
+			Encoding:             EncodingUTF8,
+			ValidCount:           2,
+			InvalidCount:         1,
+			ExpectedEncoding:     EncodingUTF8,
+			ExpectedCities:       []string{"São Paulo", "München"},
+			InvalidEncodingLines: []int64{4},
+		},
+		{
+			Name:             "cidr networks",
+			fileName:         "networks.csv",
+			Encoding:         EncodingAuto,
+			ValidCount:       3,
+			InvalidCount:     2,
+			ExpectedEncoding: EncodingUTF8,
+			ExpectedCities:   []string{"Sydney", "Brisbane", "Berlin"},
+		},
+		{
+			Name:             "start and end ranges",
+			fileName:         "ranges.csv",
+			Encoding:         EncodingAuto,
+			ValidCount:       2,
+			InvalidCount:     2,
+			ExpectedEncoding: EncodingUTF8,
+			ExpectedCities:   []string{"Sydney", "Melbourne"},
 		},
 	}
 
-	locationManager := new(mocks.GeoLocationManager)
-	locationManager.On("BulkInsert", mock.Anything, mock.Anything).Return(nil)
-
 	for _, tt := range cases {
 		tt := tt
 		t.Run(tt.Name, func(t *testing.T) {
@@ -39,81 +96,171 @@ func TestParseAndStore(t *testing.T) {
 			if err != nil {
 				assert.Fail("error opening file", err)
 			}
-			_, invalid, valid, err := NewParser(f, locationManager).ParseAndStore()
+
+			var cities []string
+			locationManager := new(mocks.GeoLocationManager)
+			locationManager.On("BulkInsert", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				for _, g := range args.Get(1).([]*model.Geolocation) {
+					cities = append(cities, g.City)
+				}
+			}).Return(nil)
+
+			result, err := NewParser(f, tt.Encoding, locationManager).ParseAndStore(context.TODO())
 			if err != nil {
 				assert.Fail("error parsing file", err)
 			}
 
-			assert.Equal(tt.InvalidCount, invalid)
-			assert.Equal(tt.ValidCount, valid)
+			assert.Equal(tt.InvalidCount, result.Invalid)
+			assert.Equal(tt.ValidCount, result.Valid)
+			assert.Equal(tt.ExpectedEncoding, result.Encoding)
+			assert.Equal(tt.ExpectedCities, cities)
+			assert.Equal(int64(len(tt.InvalidEncodingLines)), result.InvalidEncoding)
+			assert.Equal(tt.InvalidEncodingLines, result.InvalidEncodingLines)
 		})
 	}
 }
 
-func TestIsValidLine(t *testing.T) {
+func TestValidateHeader(t *testing.T) {
 	cases := []struct {
 		Name          string
-		visitedIP     map[string]bool
-		Text          string
-		ExpectedValid bool
-		ExpectedResp  *model.Geolocation
+		Header        []string
+		ExpectedError bool
 	}{
 		{
-			Name:          "missing ip in line",
-			visitedIP:     map[string]bool{},
-			Text:          ",SI,Nepal,DuBuquemouth,-84.87503094689836,7.206435933364332,7823011346",
-			ExpectedValid: false,
-			ExpectedResp:  nil,
+			Name:   "ip address",
+			Header: []string{common.IP, common.CountryCode, common.Country, common.City, common.Latitude, common.Longitude, common.MysteryValue},
+		},
+		{
+			Name:   "network",
+			Header: []string{common.Network, common.CountryCode, common.Country, common.City, common.Latitude, common.Longitude, common.MysteryValue},
+		},
+		{
+			Name:   "start and end",
+			Header: []string{common.StartIP, common.EndIP, common.CountryCode, common.Country, common.City, common.Latitude, common.Longitude, common.MysteryValue},
+		},
+		{
+			Name:          "start without end",
+			Header:        []string{common.StartIP, common.CountryCode, common.Country, common.City, common.Latitude, common.Longitude, common.MysteryValue},
+			ExpectedError: true,
 		},
 		{
-			Name:          "extra field",
-			visitedIP:     map[string]bool{},
-			Text:          "70.95.73.73,SI,Nepal,DuBuquemouth,-84.87503094689836,7.206435933364332,7823011346,70.95.73.73",
-			ExpectedValid: false,
-			ExpectedResp:  nil,
+			Name:          "two layouts",
+			Header:        []string{common.IP, common.Network, common.CountryCode, common.Country, common.City, common.Latitude, common.Longitude, common.MysteryValue},
+			ExpectedError: true,
 		},
 		{
-			Name:          "invalid ip field",
-			visitedIP:     map[string]bool{},
-			Text:          "7012.95.73.73,SI,Nepal,DuBuquemouth,-84.87503094689836,7.206435933364332,7823011346",
-			ExpectedValid: false,
-			ExpectedResp:  nil,
+			Name:          "missing column",
+			Header:        []string{common.IP, common.CountryCode, common.Country, common.City, common.Latitude, common.Longitude},
+			ExpectedError: true,
+		},
+		{
+			Name:          "unknown column",
+			Header:        []string{common.IP, common.CountryCode, common.Country, common.City, common.Latitude, common.Longitude, common.MysteryValue, "region"},
+			ExpectedError: true,
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			positions := make(map[int]string)
+			for i, col := range tt.Header {
+				positions[i] = col
+			}
+			err := validateHeader(positions)
+			assert.Equal(tt.ExpectedError, err != nil)
+			if err != nil {
+				assert.ErrorIs(err, ErrUnsupportedHeader)
+			}
+		})
+	}
+}
+
+func TestIsValidLine(t *testing.T) {
+	cases := []struct {
+		Name           string
+		visitedIP      map[string]bool
+		Text           string
+		ExpectedReason string
+		ExpectedResp   *model.Geolocation
+	}{
+		{
+			Name:           "missing ip in line",
+			visitedIP:      map[string]bool{},
+			Text:           ",SI,Nepal,DuBuquemouth,-84.87503094689836,7.206435933364332,7823011346",
+			ExpectedReason: rejectEmptyValue,
+			ExpectedResp:   nil,
+		},
+		{
+			Name:           "extra field",
+			visitedIP:      map[string]bool{},
+			Text:           "70.95.73.73,SI,Nepal,DuBuquemouth,-84.87503094689836,7.206435933364332,7823011346,70.95.73.73",
+			ExpectedReason: rejectFieldCount,
+			ExpectedResp:   nil,
+		},
+		{
+			Name:           "invalid ip field",
+			visitedIP:      map[string]bool{},
+			Text:           "7012.95.73.73,SI,Nepal,DuBuquemouth,-84.87503094689836,7.206435933364332,7823011346",
+			ExpectedReason: rejectInvalidIP,
+			ExpectedResp:   nil,
 		},
 		{
 			Name: "already visited ip field",
 			visitedIP: map[string]bool{
 				"70.95.73.73": true,
 			},
-			Text:          "70.95.73.73,SI,Nepal,DuBuquemouth,-84.87503094689836,7.206435933364332,7823011346",
-			ExpectedValid: false,
-			ExpectedResp:  nil,
+			Text:           "70.95.73.73,SI,Nepal,DuBuquemouth,-84.87503094689836,7.206435933364332,7823011346",
+			ExpectedReason: rejectDuplicateIP,
+			ExpectedResp:   nil,
 		},
 		{
-			Name:          "already longitude field",
-			visitedIP:     map[string]bool{},
-			Text:          "7012.95.73.73,SI,Nepal,DuBuquemouth,-84.87503094689836,1027.206435933364332,7823011346",
-			ExpectedValid: false,
-			ExpectedResp:  nil,
+			Name:           "already longitude field",
+			visitedIP:      map[string]bool{},
+			Text:           "70.95.73.73,SI,Nepal,DuBuquemouth,-84.87503094689836,1027.206435933364332,7823011346",
+			ExpectedReason: rejectInvalidLongitude,
+			ExpectedResp:   nil,
 		},
 		{
-			Name:          "already latitude field",
-			visitedIP:     map[string]bool{},
-			Text:          "7012.95.73.73,SI,Nepal,DuBuquemouth,-154.87503094689836,150.206435933364332,7823011346",
-			ExpectedValid: false,
-			ExpectedResp:  nil,
+			Name:           "already latitude field",
+			visitedIP:      map[string]bool{},
+			Text:           "70.95.73.73,SI,Nepal,DuBuquemouth,-154.87503094689836,150.206435933364332,7823011346",
+			ExpectedReason: rejectInvalidLatitude,
+			ExpectedResp:   nil,
 		},
 		{
-			Name:          "valid line",
-			visitedIP:     map[string]bool{},
-			Text:          "70.95.73.73,SI,Nepal,DuBuquemouth,-84.87503094689836,7.206435933364332,7823011346",
-			ExpectedValid: true,
+			Name:           "ipv6 line",
+			visitedIP:      map[string]bool{},
+			Text:           "2001:0db8::0001,SI,Nepal,DuBuquemouth,-84.87503094689836,7.206435933364332,7823011346",
+			ExpectedReason: "",
+			ExpectedResp: &model.Geolocation{
+				IP:           "2001:db8::1",
+				StartIP:      "2001:db8::1",
+				EndIP:        "2001:db8::1",
+				Country:      "Nepal",
+				CountryCode:  "SI",
+				City:         "DuBuquemouth",
+				Latitude:     -84.87503094689836,
+				Longitude:    7.206435933364332,
+				MysteryValue: "7823011346",
+			},
+		},
+		{
+			Name:           "valid line",
+			visitedIP:      map[string]bool{},
+			Text:           "70.95.73.73,SI,Nepal,DuBuquemouth,-84.87503094689836,7.206435933364332,7823011346",
+			ExpectedReason: "",
 			ExpectedResp: &model.Geolocation{
 				IP:           "70.95.73.73",
+				StartIP:      "70.95.73.73",
+				EndIP:        "70.95.73.73",
 				Country:      "Nepal",
 				CountryCode:  "SI",
 				City:         "DuBuquemouth",
-				Latitude:     "-84.87503094689836",
-				Longitude:    "7.206435933364332",
+				Latitude:     -84.87503094689836,
+				Longitude:    7.206435933364332,
 				MysteryValue: "7823011346",
 			},
 		},
@@ -134,9 +281,43 @@ func TestIsValidLine(t *testing.T) {
 			assert := assert.New(t)
 			t.Parallel()
 
-			resp, isValid := isValidLine(positions, tt.Text, tt.visitedIP)
+			resp, reason := isValidLine(positions, tt.Text, &ipSet{ips: tt.visitedIP})
 			assert.Equal(resp, tt.ExpectedResp)
-			assert.Equal(isValid, tt.ExpectedValid)
+			assert.Equal(tt.ExpectedReason, reason)
 		})
 	}
 }
+
+func TestProcessChunkRejections(t *testing.T) {
+	assert := assert.New(t)
+	positions := map[int]string{
+		0: common.IP,
+		1: common.CountryCode,
+		2: common.Country,
+		3: common.City,
+		4: common.Latitude,
+		5: common.Longitude,
+		6: common.MysteryValue,
+	}
+	chunk := "70.95.73.73,SI,Nepal,DuBuquemouth,-84.8,7.2,7823011346\n" +
+		"70.95.73.73,SI,Nepal,DuBuquemouth,-84.8,7.2,7823011346\n" +
+		"\n" +
+		"70.95.73.74,SI,Nepal,DuBuquemouth,-84.8\n" +
+		"70.95.73.75,SI,Nepal,DuBuquemouth,-184.8,7.2,7823011346\n" +
+		"70.95.73.76,SI,Nepal,S\xffo Paulo,-84.8,7.2,7823011346\n" +
+		"70.95.73.77,SI,Nepal,S�o Paulo,-84.8,7.2,7823011346\n"
+
+	out := make(chan *model.Geolocation, 10)
+	result := processChunk([]byte(chunk), 2, positions, out, newIPSet())
+	assert.Equal(int64(7), result.lines)
+	assert.Equal(int64(2), result.valid)
+	assert.Equal(int64(5), result.invalid)
+	assert.Equal(map[string]int64{
+		rejectDuplicateIP:     1,
+		rejectEmptyLine:       1,
+		rejectFieldCount:      1,
+		rejectInvalidLatitude: 1,
+		rejectInvalidEncoding: 1,
+	}, result.rejected)
+	assert.Equal([]int64{7}, result.invalidEncodingLines)
+}
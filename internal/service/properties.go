@@ -1,15 +1,226 @@
 package service
 
+import (
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+)
+
 type GetRequest struct {
-	IP string `json:"ip_address"`
+	IP   string    `json:"ip_address"`
+	AsOf time.Time `json:"as_of,omitempty"` // look the ip up as it resolved then, zero for now
+}
+
+type BatchRequest struct {
+	IPs []string `json:"ips"`
+}
+
+type BatchResponse struct {
+	Results []*BatchResult `json:"results"` // in the order of the ips of the request
+}
+
+// BatchResult is the geolocation of one ip of a batch, or why it couldn't be looked up
+type BatchResult struct {
+	IP          string               `json:"ip_address"`
+	Geolocation *GeoLocationResponse `json:"geolocation,omitempty"`
+	Error       *router.HttpError    `json:"error,omitempty"`
+}
+
+// ListRequest selects a page of the geolocations, the zero values don't filter
+type ListRequest struct {
+	CountryCode string
+	Country     string
+	City        string
+	BoundingBox *model.BoundingBox
+
+	Sort   string // one of the model Sort constants, by ip when empty
+	Desc   bool
+	Limit  int
+	Cursor string // next_cursor of the previous page
+}
+
+type ListResponse struct {
+	Geolocations []*GeoLocationResponse `json:"geolocations"` // rows of networks are listed by their first ip
+	NextCursor   string                 `json:"next_cursor,omitempty"`
+}
+
+type CitySearchRequest struct {
+	Query       string
+	CountryCode string
+	Limit       int
+}
+
+type CitySearchResponse struct {
+	Cities []*CityResponse `json:"cities"`
+}
+
+type CityResponse struct {
+	CityID      int64   `json:"city_id"`
+	City        string  `json:"city"`
+	CountryID   int64   `json:"country_id"`
+	CountryCode string  `json:"country_code"`
+	Country     string  `json:"country"`
+	IPs         int64   `json:"ips"`   // geolocations in the city, a network counting once
+	Score       float64 `json:"score"` // from 0 to 1, 1 for a name equal to the query apart from accents and case
+}
+
+type CityStatsRequest struct {
+	CountryCode string
+}
+
+type CountryStatsResponse struct {
+	Countries []*CountryStatsItem `json:"countries"` // the countries with the most ips first
+}
+
+type CountryStatsItem struct {
+	CountryID   int64       `json:"country_id"`
+	CountryCode string      `json:"country_code"`
+	Country     string      `json:"country"`
+	IPs         int64       `json:"ips"`    // geolocations in the country, a network counting once
+	Cities      int64       `json:"cities"` // distinct cities
+	Centroid    Centroid    `json:"centroid"`
+	BoundingBox BoundingBox `json:"bbox"`
+}
+
+type CityStatsResponse struct {
+	CountryCode string           `json:"country_code"`
+	Cities      []*CityStatsItem `json:"cities"`
+}
+
+type CityStatsItem struct {
+	CityID      int64       `json:"city_id"`
+	City        string      `json:"city"`
+	IPs         int64       `json:"ips"`
+	Centroid    Centroid    `json:"centroid"`
+	BoundingBox BoundingBox `json:"bbox"`
+}
+
+// Centroid is the mean of the coordinates of the points
+type Centroid struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type BoundingBox struct {
+	MinLatitude  float64 `json:"min_latitude"`
+	MinLongitude float64 `json:"min_longitude"`
+	MaxLatitude  float64 `json:"max_latitude"`
+	MaxLongitude float64 `json:"max_longitude"`
 }
 
 type GeoLocationResponse struct {
+	IP           string  `json:"ip_address"`
+	Network      string  `json:"network,omitempty"` // the network or range the ip was found in, unless the row is for the ip alone
+	Country      string  `json:"country"`
+	CountryCode  string  `json:"country_code"`
+	CountryID    int64   `json:"country_id,omitempty"` // stable across imports, unless the row has no country code
+	City         string  `json:"city"`
+	CityID       int64   `json:"city_id,omitempty"`
+	Latitude     float64 `json:"latitude"`
+	Longitude    float64 `json:"longitude"`
+	MysteryValue string  `json:"mystery_value"`
+
+	// set for as-of lookups, when the geolocation found was current; ValidTo is nil if it still is
+	ValidFrom *time.Time `json:"valid_from,omitempty"`
+	ValidTo   *time.Time `json:"valid_to,omitempty"`
+}
+
+// LegacyGeoLocationResponse is the response shape from before the coordinates were numeric
+type LegacyGeoLocationResponse struct {
 	IP           string `json:"ip_address"`
+	Network      string `json:"network,omitempty"`
 	Country      string `json:"country"`
 	CountryCode  string `json:"country_code"`
+	CountryID    int64  `json:"country_id,omitempty"`
 	City         string `json:"city"`
+	CityID       int64  `json:"city_id,omitempty"`
 	Latitude     string `json:"latitude"`
 	Longitude    string `json:"longitude"`
 	MysteryValue string `json:"mystery_value"`
+
+	ValidFrom *time.Time `json:"valid_from,omitempty"`
+	ValidTo   *time.Time `json:"valid_to,omitempty"`
+}
+
+func (r *GeoLocationResponse) Legacy() *LegacyGeoLocationResponse {
+	return &LegacyGeoLocationResponse{
+		IP:           r.IP,
+		Network:      r.Network,
+		Country:      r.Country,
+		CountryCode:  r.CountryCode,
+		CountryID:    r.CountryID,
+		City:         r.City,
+		CityID:       r.CityID,
+		Latitude:     strconv.FormatFloat(r.Latitude, 'f', -1, 64),
+		Longitude:    strconv.FormatFloat(r.Longitude, 'f', -1, 64),
+		MysteryValue: r.MysteryValue,
+		ValidFrom:    r.ValidFrom,
+		ValidTo:      r.ValidTo,
+	}
+}
+
+type IssueKeyRequest struct {
+	Name      string `json:"name"`
+	RateLimit int    `json:"rate_limit,omitempty"` // requests per minute, the default limit when zero
+	Burst     int    `json:"burst,omitempty"`
+}
+
+type RevokeKeyRequest struct {
+	ID string
+}
+
+// APIKeyResponse describes a key, the key itself is only in the response issuing it
+type APIKeyResponse struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	RateLimit int        `json:"rate_limit"`
+	Burst     int        `json:"burst"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type IssuedKeyResponse struct {
+	Key string `json:"key"`
+	*APIKeyResponse
+}
+
+type APIKeyListResponse struct {
+	Keys []*APIKeyResponse `json:"keys"` // the newest first
+}
+
+type ParseResult struct {
+	TimeTaken            float64
+	Encoding             string // the declared or detected encoding of the input
+	Valid                int64
+	Invalid              int64   // includes the rows with invalid byte sequences
+	InvalidEncoding      int64   // rows with invalid byte sequences for the encoding
+	InvalidEncodingLines []int64 // the first few line numbers of such rows
+}
+
+type ImportOptions struct {
+	Force       bool   // import even if the ledger says the file was already imported
+	Parallelism int    // number of files imported at the same time
+	Encoding    string // encoding of the files, EncodingAuto detects it per file
+}
+
+type ImportResult struct {
+	RunID     uuid.UUID // zero if every file was skipped
+	Skipped   bool      // every file was already imported
+	TimeTaken float64
+	Valid     int64
+	Invalid   int64
+	Files     []*FileImportResult
+}
+
+type FileImportResult struct {
+	File      string
+	SHA256    string
+	SizeBytes int64
+	RunID     uuid.UUID // the run that imported the file, for skipped files the earlier run
+	Skipped   bool
+	ParseResult
 }
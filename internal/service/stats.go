@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+)
+
+//go:generate mockery --name StatsService --output=mocks
+type StatsService interface {
+	CountryStats(context.Context) (*CountryStatsResponse, error)
+	CityStats(context.Context, *CityStatsRequest) (*CityStatsResponse, error)
+}
+
+type stats struct {
+	manager model.StatsManager
+}
+
+func NewStatsService(mn model.StatsManager) StatsService {
+	return &stats{
+		mn,
+	}
+}
+
+func (s *stats) CountryStats(ctx context.Context) (*CountryStatsResponse, error) {
+	countries, err := s.manager.CountryStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &CountryStatsResponse{Countries: make([]*CountryStatsItem, 0, len(countries))}
+	for _, c := range countries {
+		resp.Countries = append(resp.Countries, &CountryStatsItem{
+			CountryID:   c.CountryID,
+			CountryCode: c.CountryCode,
+			Country:     c.Country,
+			IPs:         c.IPs,
+			Cities:      c.Cities,
+			Centroid:    newCentroid(c.Extent),
+			BoundingBox: newBoundingBox(c.Extent),
+		})
+	}
+	return resp, nil
+}
+
+func (s *stats) CityStats(ctx context.Context, request *CityStatsRequest) (*CityStatsResponse, error) {
+	code := strings.ToUpper(strings.TrimSpace(request.CountryCode))
+	if len(code) == 0 {
+		return nil, router.NewHttpError("invalid country code", 400)
+	}
+
+	cities, err := s.manager.CityStats(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &CityStatsResponse{CountryCode: code, Cities: make([]*CityStatsItem, 0, len(cities))}
+	for _, c := range cities {
+		resp.Cities = append(resp.Cities, &CityStatsItem{
+			CityID:      c.CityID,
+			City:        c.City,
+			IPs:         c.IPs,
+			Centroid:    newCentroid(c.Extent),
+			BoundingBox: newBoundingBox(c.Extent),
+		})
+	}
+	return resp, nil
+}
+
+func newCentroid(e model.Extent) Centroid {
+	return Centroid{Latitude: e.Latitude, Longitude: e.Longitude}
+}
+
+func newBoundingBox(e model.Extent) BoundingBox {
+	return BoundingBox{
+		MinLatitude:  e.MinLatitude,
+		MinLongitude: e.MinLongitude,
+		MaxLatitude:  e.MaxLatitude,
+		MaxLongitude: e.MaxLongitude,
+	}
+}
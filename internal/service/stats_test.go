@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	modelMocks "github.com/ohmpatel1997/findhotel/internal/model/mocks"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCountryStats(t *testing.T) {
+	assert := assert.New(t)
+	manager := new(modelMocks.StatsManager)
+	manager.On("CountryStats", mock.Anything).Return([]*model.CountryStats{
+		{
+			CountryID:   2,
+			CountryCode: "DE",
+			Country:     "Germany",
+			IPs:         4,
+			Cities:      2,
+			Extent:      model.Extent{Latitude: 51, Longitude: 12, MinLatitude: 48, MinLongitude: 9, MaxLatitude: 53, MaxLongitude: 14},
+		},
+	}, nil)
+
+	resp, err := NewStatsService(manager).CountryStats(context.TODO())
+	assert.Nil(err)
+	assert.Equal(&CountryStatsResponse{Countries: []*CountryStatsItem{
+		{
+			CountryID:   2,
+			CountryCode: "DE",
+			Country:     "Germany",
+			IPs:         4,
+			Cities:      2,
+			Centroid:    Centroid{Latitude: 51, Longitude: 12},
+			BoundingBox: BoundingBox{MinLatitude: 48, MinLongitude: 9, MaxLatitude: 53, MaxLongitude: 14},
+		},
+	}}, resp)
+}
+
+func TestCityStats(t *testing.T) {
+	cases := []struct {
+		Name          string
+		Req           *CityStatsRequest
+		ExpectedResp  *CityStatsResponse
+		ExpectedError error
+		MocksInit     func() *modelMocks.StatsManager
+	}{
+		{
+			Name: "Success",
+			Req:  &CityStatsRequest{CountryCode: "de"},
+			ExpectedResp: &CityStatsResponse{CountryCode: "DE", Cities: []*CityStatsItem{
+				{
+					CityID:      7,
+					City:        "Berlin",
+					IPs:         2,
+					Centroid:    Centroid{Latitude: 52.5, Longitude: 13.5},
+					BoundingBox: BoundingBox{MinLatitude: 52, MinLongitude: 13, MaxLatitude: 53, MaxLongitude: 14},
+				},
+			}},
+			MocksInit: func() *modelMocks.StatsManager {
+				manager := new(modelMocks.StatsManager)
+				manager.On("CityStats", mock.Anything, "DE").Return([]*model.CityStats{
+					{
+						CityID: 7,
+						City:   "Berlin",
+						IPs:    2,
+						Extent: model.Extent{Latitude: 52.5, Longitude: 13.5, MinLatitude: 52, MinLongitude: 13, MaxLatitude: 53, MaxLongitude: 14},
+					},
+				}, nil)
+				return manager
+			},
+		},
+		{
+			Name: "404 unknown country",
+			Req:  &CityStatsRequest{CountryCode: "XX"},
+			MocksInit: func() *modelMocks.StatsManager {
+				manager := new(modelMocks.StatsManager)
+				manager.On("CityStats", mock.Anything, "XX").Return(nil, router.NewHttpError("country not found", 404))
+				return manager
+			},
+			ExpectedError: router.NewHttpError("country not found", 404),
+		},
+		{
+			Name: "400 bad request",
+			Req:  &CityStatsRequest{CountryCode: " "},
+			MocksInit: func() *modelMocks.StatsManager {
+				return nil
+			},
+			ExpectedError: router.NewHttpError("invalid country code", 400),
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			srv := NewStatsService(tt.MocksInit())
+			resp, err := srv.CityStats(context.TODO(), tt.Req)
+			assert.Equal(tt.ExpectedResp, resp)
+			assert.Equal(tt.ExpectedError, err)
+		})
+	}
+}
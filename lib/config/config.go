@@ -9,8 +9,21 @@ import (
 
 // Database holds data necessary for database configuration
 type Database struct {
-	Timeout int  `yaml:"timeout_seconds,omitempty"`
-	SSLMode bool `yaml:"sslmode,omitempty"`
+	Dialect string `yaml:"dialect,omitempty"` // postgres, mysql or sqlite3, postgres by default
+	Path    string `yaml:"path,omitempty"`    // the database file of sqlite3
+	Timeout int    `yaml:"timeout_seconds,omitempty"`
+	SSLMode bool   `yaml:"sslmode,omitempty"`
+
+	Replicas             []*Replica `yaml:"replicas,omitempty"`                // postgres only, the api sends its lookups to them
+	ReplicaMaxLagSeconds int        `yaml:"replica_max_lag_seconds,omitempty"` // replicas further behind are taken out of rotation
+	ReplicaCheckSeconds  int        `yaml:"replica_check_seconds,omitempty"`
+}
+
+// Replica is a read-only copy of the database, reached with the credentials of the primary
+type Replica struct {
+	Name string `yaml:"name,omitempty"`
+	Host string `yaml:"host"`
+	Port string `yaml:"port,omitempty"`
 }
 
 // Server holds data necessary for server configuration
@@ -18,12 +31,58 @@ type Server struct {
 	Port         string `yaml:"port,omitempty"`
 	ReadTimeout  int    `yaml:"read_timeout_seconds,omitempty"`
 	WriteTimeout int    `yaml:"write_timeout_seconds,omitempty"`
+	// GRPCPort is the port of the gRPC lookups, which are off when it is empty
+	GRPCPort string `yaml:"grpc_port,omitempty"`
+	// MetricsPort is the port of the Prometheus metrics, apart from the public endpoints, which are off when it is empty
+	MetricsPort string `yaml:"metrics_port,omitempty"`
+	// TrustedProxies are the networks, or addresses, of the proxies whose forwarding headers are believed
+	TrustedProxies []string `yaml:"trusted_proxies,omitempty"`
+}
+
+// Import holds data necessary for the importer runs
+type Import struct {
+	LockStaleSeconds     int    `yaml:"lock_stale_seconds,omitempty"`
+	LockHeartbeatSeconds int    `yaml:"lock_heartbeat_seconds,omitempty"`
+	LockPollSeconds      int    `yaml:"lock_poll_seconds,omitempty"`
+	Parallelism          int    `yaml:"parallelism,omitempty"`
+	Encoding             string `yaml:"encoding,omitempty"`
+	MetricsAddress       string `yaml:"metrics_address,omitempty"` // where the counters are served during a run, empty turns it off
+}
+
+// Lookup holds data necessary for the geolocation lookups of the api
+type Lookup struct {
+	Backend       string `yaml:"backend,omitempty"`        // database, or postgres its alias, memory or mmdb
+	SnapshotFile  string `yaml:"snapshot_file,omitempty"`  // the memory backend loads this file instead of the database
+	MMDBFile      string `yaml:"mmdb_file,omitempty"`      // the MaxMind DB file of the mmdb backend
+	ReloadSeconds int    `yaml:"reload_seconds,omitempty"` // how often the memory and mmdb backends reload, 0 only reloads on SIGHUP
+	Cache         *Cache `yaml:"cache,omitempty"`
+}
+
+// Cache holds data necessary for the cache in front of the lookup backend
+type Cache struct {
+	Enabled              bool `yaml:"enabled,omitempty"`
+	MaxEntries           int  `yaml:"max_entries,omitempty"`
+	TTLSeconds           int  `yaml:"ttl_seconds,omitempty"`
+	NegativeTTLSeconds   int  `yaml:"negative_ttl_seconds,omitempty"`   // how long not found ips are cached
+	ImportPollSeconds    int  `yaml:"import_poll_seconds,omitempty"`    // how often to check for finished imports, which invalidate the cache
+	LookupTimeoutSeconds int  `yaml:"lookup_timeout_seconds,omitempty"` // how long the lookup of a miss shared by its callers may take
+}
+
+// Auth holds data necessary for the api key authentication of the api
+type Auth struct {
+	Enabled          bool `yaml:"enabled,omitempty"`            // the /v1 endpoints and the gRPC lookups need a key, off only with ENV=development
+	KeyCacheSeconds  int  `yaml:"key_cache_seconds,omitempty"`  // how long keys are remembered, so revoked ones pass until then
+	DefaultRateLimit int  `yaml:"default_rate_limit,omitempty"` // requests per minute of the keys issued without a limit
+	DefaultBurst     int  `yaml:"default_burst,omitempty"`
 }
 
 type Configuration struct {
 	Server   *Server   `yaml:"server,omitempty"`
 	DB       *Database `yaml:"database,omitempty"`
 	DataDump *DataDump `yaml:"data_dump"`
+	Import   *Import   `yaml:"import,omitempty"`
+	Lookup   *Lookup   `yaml:"lookup,omitempty"`
+	Auth     *Auth     `yaml:"auth,omitempty"`
 }
 
 type DataDump struct {
@@ -0,0 +1,108 @@
+package pgsql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	queryOperationOther = "other"
+
+	queryStatusOK    = "ok"
+	queryStatusError = "error"
+)
+
+var (
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "The duration of the postgres queries, by database, operation and status.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"db", "operation", "status"})
+
+	// queryOperations are the statements the queries are told apart by, the others are counted as other
+	queryOperations = []string{"select", "insert", "update", "delete", "copy", "with"}
+)
+
+// queryMetrics is a query hook observing the duration of the queries of the database named db
+type queryMetrics struct {
+	db string
+}
+
+func (queryMetrics) BeforeQuery(ctx context.Context, _ *pg.QueryEvent) (context.Context, error) {
+	return ctx, nil
+}
+
+func (m queryMetrics) AfterQuery(_ context.Context, e *pg.QueryEvent) error {
+	status := queryStatusOK
+	if e.Err != nil && !errors.Is(e.Err, pg.ErrNoRows) {
+		status = queryStatusError
+	}
+	query, _ := e.FormattedQuery()
+	queryDuration.WithLabelValues(m.db, queryOperation(query), status).Observe(time.Since(e.StartTime).Seconds())
+	return nil
+}
+
+// queryOperation returns the statement query starts with, the formatted query being at hand unlike the template
+func queryOperation(query []byte) string {
+	query = bytes.TrimSpace(query)
+	if i := bytes.IndexAny(query, " \t\r\n("); i >= 0 {
+		query = query[:i]
+	}
+	for _, op := range queryOperations {
+		if bytes.EqualFold(query, []byte(op)) {
+			return op
+		}
+	}
+	return queryOperationOther
+}
+
+// poolCollector exposes the stats of the connection pool of a database
+type poolCollector struct {
+	db *pg.DB
+
+	hits, misses, timeouts        *prometheus.Desc
+	totalConns, idleConns, stales *prometheus.Desc
+}
+
+// NewPoolCollector returns a collector of the connection pool stats of db, labelled with its name, e.g. primary or
+// the name of a replica
+func NewPoolCollector(db *pg.DB, name string) prometheus.Collector {
+	labels := prometheus.Labels{"db": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc("db_pool_"+metric, help, nil, labels)
+	}
+	return &poolCollector{
+		db:         db,
+		hits:       desc("hits_total", "Times a free connection was found in the pool."),
+		misses:     desc("misses_total", "Times a free connection was not found in the pool."),
+		timeouts:   desc("timeouts_total", "Times waiting for a connection timed out."),
+		totalConns: desc("connections", "The connections of the pool."),
+		idleConns:  desc("idle_connections", "The idle connections of the pool."),
+		stales:     desc("stale_connections_total", "The stale connections removed from the pool."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.timeouts
+	ch <- c.totalConns
+	ch <- c.idleConns
+	ch <- c.stales
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.PoolStats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.stales, prometheus.CounterValue, float64(stats.StaleConns))
+}
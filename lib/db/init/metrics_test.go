@@ -0,0 +1,67 @@
+package pgsql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryOperation(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Query    string
+		Expected string
+	}{
+		{Name: "select", Query: `SELECT "geolocation"."ip" FROM "geolocations" AS "geolocation"`, Expected: "select"},
+		{Name: "insert", Query: `INSERT INTO "geolocations" ("ip") VALUES ('10.0.0.1')`, Expected: "insert"},
+		{Name: "leading space", Query: "\n  update import_runs SET status = 'completed'", Expected: "update"},
+		{Name: "cte", Query: `WITH "deleted" AS (DELETE FROM "geolocations") SELECT count(*) FROM "deleted"`, Expected: "with"},
+		{Name: "parenthesis", Query: `(SELECT 1) UNION (SELECT 2)`, Expected: "other"},
+		{Name: "other", Query: `CREATE EXTENSION IF NOT EXISTS "pgcrypto"`, Expected: "other"},
+		{Name: "empty", Expected: "other"},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert.New(t).Equal(tt.Expected, queryOperation([]byte(tt.Query)))
+		})
+	}
+}
+
+// TestQueryMetrics observes queries of a replica, which are told apart from the ones of the primary by the db label
+func TestQueryMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	replica := queryMetrics{db: "replica-test"}
+	assert.Nil(replica.AfterQuery(context.TODO(), &pg.QueryEvent{StartTime: time.Now()}))
+	assert.Nil(replica.AfterQuery(context.TODO(), &pg.QueryEvent{StartTime: time.Now(), Err: pg.ErrNoRows}))
+	assert.Nil(replica.AfterQuery(context.TODO(), &pg.QueryEvent{StartTime: time.Now(), Err: errors.New("connection reset")}))
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Error gathering the metrics %v", err)
+	}
+	counts := map[string]uint64{}
+	for _, family := range families {
+		if family.GetName() != "db_query_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["db"] == replica.db {
+				counts[labels["operation"]+"/"+labels["status"]] += metric.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	assert.Equal(map[string]uint64{"other/ok": 2, "other/error": 1}, counts)
+}
@@ -2,30 +2,39 @@
 package pgsql
 
 import (
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/go-pg/pg/v10"
 	"github.com/ohmpatel1997/findhotel/lib/config"
 )
 
-// New database connection to a init database
+const DriverName = "postgres"
+
+// PrimaryName is the name the primary database is labelled with in the metrics
+const PrimaryName = "primary"
+
+// URL returns the url of the postgres server at host, with the credentials of the POSTGRES_* variables
+func URL(host, port string) string {
+	dbName := os.Getenv("POSTGRES_DB")
+	password := os.Getenv("POSTGRES_PASSWORD")
+	user := os.Getenv("POSTGRES_USER")
+	return fmt.Sprintf("postgres://%v:%v@%v:%v/%v", user, password, host, port, dbName)
+}
+
+// New database connection to a init database, the duration of its queries is observed in db_query_duration_seconds
+// as the primary
 func New(cfg *config.Database, psn string) (*pg.DB, error) {
 	timeout := cfg.Timeout
 
-	if !cfg.SSLMode {
-		psn += "?sslmode=disable"
-	}
-
-	u, err := pg.ParseURL(psn)
+	u, err := parseURL(cfg, psn)
 	if err != nil {
 		return nil, err
 	}
-	if timeout > 0 {
-		u.ReadTimeout = time.Second * time.Duration(timeout)
-		u.WriteTimeout = time.Second * time.Duration(timeout)
-	}
 
 	db := pg.Connect(u)
+	db.AddQueryHook(queryMetrics{db: PrimaryName})
 
 	_, err = db.Exec("SELECT 1")
 	if err != nil {
@@ -44,3 +53,31 @@ func New(cfg *config.Database, psn string) (*pg.DB, error) {
 
 	return db, nil
 }
+
+// NewReplica connects to a read-only replica, whose queries are labelled with name. Unlike New it doesn't wait for
+// the replica to be up, and creates no extensions, which only the primary can do.
+func NewReplica(cfg *config.Database, name, psn string) (*pg.DB, error) {
+	u, err := parseURL(cfg, psn)
+	if err != nil {
+		return nil, err
+	}
+	db := pg.Connect(u)
+	db.AddQueryHook(queryMetrics{db: name})
+	return db, nil
+}
+
+func parseURL(cfg *config.Database, psn string) (*pg.Options, error) {
+	if !cfg.SSLMode {
+		psn += "?sslmode=disable"
+	}
+
+	u, err := pg.ParseURL(psn)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Timeout > 0 {
+		u.ReadTimeout = time.Second * time.Duration(cfg.Timeout)
+		u.WriteTimeout = time.Second * time.Duration(cfg.Timeout)
+	}
+	return u, nil
+}
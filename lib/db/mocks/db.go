@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"os"
@@ -15,6 +16,7 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/ohmpatel1997/findhotel/lib/config"
 	pgsql "github.com/ohmpatel1997/findhotel/lib/db/init"
+	"github.com/ohmpatel1997/findhotel/lib/db/mysql"
 	"github.com/ory/dockertest/v3"
 	dc "github.com/ory/dockertest/v3/docker"
 )
@@ -45,6 +47,32 @@ func NewPGContainer(t *testing.T) (*dockertest.Pool, *dockertest.Resource) {
 	return pool, resource
 }
 
+// NewMySQLContainer instantiates new MySQL docker container
+func NewMySQLContainer(t *testing.T) (*dockertest.Pool, *dockertest.Resource) {
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		t.Fatalf("Could not connect to docker: %s", err)
+	}
+	pool.MaxWait = DockerTimeout(t)
+
+	runOpts := dockertest.RunOptions{
+		Repository: "mysql",
+		Tag:        "8.0",
+		Env: []string{
+			"MYSQL_ROOT_PASSWORD=secret",
+			"MYSQL_DATABASE=imploy",
+		},
+		Auth: *DockerHubAuth(t),
+	}
+
+	resource, err := pool.RunWithOptions(&runOpts)
+	if err != nil {
+		t.Fatalf("Could not start resource: %s", err)
+	}
+
+	return pool, resource
+}
+
 func DockerHubAuth(t *testing.T) *dc.AuthConfiguration {
 	err := loadEnvVars(t)
 	if err != nil {
@@ -118,6 +146,25 @@ func NewDB(t *testing.T, pool *dockertest.Pool, resource *dockertest.Resource, m
 	return NewDBArray(t, pool, resource, models)
 }
 
+// NewMySQLDB instantiates new mysql database connection via docker container, waiting for the server to be up
+func NewMySQLDB(t *testing.T, pool *dockertest.Pool, resource *dockertest.Resource) *sql.DB {
+	var db *sql.DB
+	if err := pool.Retry(func() error {
+		var err error
+		db, err = mysql.New(
+			&config.Database{
+				Dialect: mysql.DriverName,
+				Timeout: 10,
+				SSLMode: false,
+			}, fmt.Sprintf("root:secret@tcp(localhost:%s)/%s", resource.GetPort("3306/tcp"), "imploy"))
+		return err
+	}); err != nil {
+		t.Fatalf("Could not connect to docker: %s", err)
+	}
+
+	return db
+}
+
 // CloseContainer closes docker container
 func CloseContainer(t *testing.T, pool *dockertest.Pool, resource *dockertest.Resource) {
 	if err := pool.Purge(resource); err != nil {
@@ -0,0 +1,55 @@
+// Package mysql holds the init related functionality of mysql databases
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/ohmpatel1997/findhotel/lib/config"
+)
+
+const DriverName = "mysql"
+
+// DSN returns the dsn of the mysql server configured by the MYSQL_* variables
+func DSN() string {
+	host := os.Getenv("MYSQL_HOST")
+	dbName := os.Getenv("MYSQL_DATABASE")
+	password := os.Getenv("MYSQL_PASSWORD")
+	user := os.Getenv("MYSQL_USER")
+	dbPort := os.Getenv("MYSQL_PORT")
+	return fmt.Sprintf("%v:%v@tcp(%v:%v)/%v", user, password, host, dbPort, dbName)
+}
+
+// New connects to the mysql database of dsn. Timestamps are read as time.Time in UTC, the zone they are written in,
+// and updates report the matched rows rather than the changed ones, as the other dialects do.
+func New(cfg *config.Database, dsn string) (*sql.DB, error) {
+	mysqlCfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	mysqlCfg.ParseTime = true
+	mysqlCfg.Loc = time.UTC
+	mysqlCfg.ClientFoundRows = true
+	if !cfg.SSLMode {
+		mysqlCfg.TLSConfig = "false"
+	}
+	if cfg.Timeout > 0 {
+		mysqlCfg.Timeout = time.Duration(cfg.Timeout) * time.Second
+		mysqlCfg.ReadTimeout = time.Duration(cfg.Timeout) * time.Second
+		mysqlCfg.WriteTimeout = time.Duration(cfg.Timeout) * time.Second
+	}
+
+	db, err := sql.Open(DriverName, mysqlCfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
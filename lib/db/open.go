@@ -0,0 +1,47 @@
+package db
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ohmpatel1997/findhotel/internal/model"
+	"github.com/ohmpatel1997/findhotel/lib/config"
+	pgsql "github.com/ohmpatel1997/findhotel/lib/db/init"
+	"github.com/ohmpatel1997/findhotel/lib/db/mysql"
+	"github.com/ohmpatel1997/findhotel/lib/db/sqlite"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// PrimaryName is the name the connection pool of the primary database is labelled with in the metrics
+const PrimaryName = pgsql.PrimaryName
+
+// Open connects to the database of the configured dialect, postgres by default, and registers the collectors of its
+// connection pool. Postgres is configured by the POSTGRES_* variables, mysql by the MYSQL_* ones.
+func Open(cfg *config.Database) (*model.Managers, error) {
+	switch cfg.Dialect {
+	case "", pgsql.DriverName:
+		db, err := pgsql.New(cfg, pgsql.URL(os.Getenv("POSTGRES_HOST"), os.Getenv("POSTGRES_PORT")))
+		if err != nil {
+			return nil, err
+		}
+		prometheus.MustRegister(pgsql.NewPoolCollector(db, PrimaryName))
+		return model.NewPostgresManagers(db), nil
+	case mysql.DriverName:
+		db, err := mysql.New(cfg, mysql.DSN())
+		if err != nil {
+			return nil, err
+		}
+		prometheus.MustRegister(collectors.NewDBStatsCollector(db, mysql.DriverName))
+		return model.NewMySQLManagers(db), nil
+	case sqlite.DriverName:
+		db, err := sqlite.New(cfg)
+		if err != nil {
+			return nil, err
+		}
+		prometheus.MustRegister(collectors.NewDBStatsCollector(db, sqlite.DriverName))
+		return model.NewSQLiteManagers(db), nil
+	default:
+		return nil, fmt.Errorf("unknown database dialect %q", cfg.Dialect)
+	}
+}
@@ -0,0 +1,60 @@
+// Package sqlite holds the init related functionality of sqlite databases
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/ohmpatel1997/findhotel/internal/common"
+	"github.com/ohmpatel1997/findhotel/lib/config"
+)
+
+const (
+	DriverName = "sqlite3"
+	// FuncDriverName is the sqlite3 driver with the functions the migrations use, e.g. city_key
+	FuncDriverName = "sqlite3_geolocation"
+
+	defaultBusyTimeout = 30 * time.Second
+)
+
+func init() {
+	sql.Register(FuncDriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("city_key", common.CityKey, true)
+		},
+	})
+}
+
+// New opens the sqlite database file of cfg, with the functions of the migrations.
+// Transactions take the write lock when they begin, so that concurrent writers wait for each other
+// instead of failing when they upgrade from reading to writing.
+func New(cfg *config.Database) (*sql.DB, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("missing database path for %s", DriverName)
+	}
+
+	busyTimeout := defaultBusyTimeout
+	if cfg.Timeout > 0 {
+		busyTimeout = time.Duration(cfg.Timeout) * time.Second
+	}
+
+	params := url.Values{}
+	params.Set("_foreign_keys", "on")
+	params.Set("_journal_mode", "WAL")
+	params.Set("_busy_timeout", fmt.Sprint(busyTimeout.Milliseconds()))
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open(FuncDriverName, "file:"+cfg.Path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
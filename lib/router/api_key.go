@@ -0,0 +1,280 @@
+package router
+
+import (
+	"context"
+	"crypto/subtle"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	headerAPIKey             = "X-API-Key"
+	headerAuthorization      = "Authorization"
+	headerWWWAuthenticate    = "WWW-Authenticate"
+	headerRetryAfter         = "Retry-After"
+	headerRateLimitLimit     = "X-RateLimit-Limit"
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+	headerRateLimitReset     = "X-RateLimit-Reset"
+
+	bearerPrefix = "Bearer "
+
+	// maxCachedAPIKeys bounds the keys remembered, when it is full the expired ones are dropped, or else the oldest
+	maxCachedAPIKeys = 10000
+)
+
+// APIKey is a key requests are authenticated with, and its rate limit
+type APIKey struct {
+	ID    string
+	Name  string
+	Rate  float64 // requests per second the bucket of the key refills with, 0 for no limit
+	Burst int     // requests the bucket holds, the most the key can send at once
+}
+
+// APIKeyStore finds the keys the requests present
+type APIKeyStore interface {
+	// Authenticate returns the key, nil when it is unknown or revoked
+	Authenticate(ctx context.Context, key string) (*APIKey, error)
+}
+
+type APIKeyOptions struct {
+	// CacheTTL is how long the keys are remembered, and so how long a revoked key still passes. 0 asks the store on
+	// every request.
+	CacheTTL time.Duration
+}
+
+type apiKeyContextKey struct{}
+
+// APIKeyFromContext returns the key the request of ctx was authenticated with, nil outside of NewAPIKeyAuth
+func APIKeyFromContext(ctx context.Context) *APIKey {
+	key, _ := ctx.Value(apiKeyContextKey{}).(*APIKey)
+	return key
+}
+
+type cachedAPIKey struct {
+	key     *APIKey
+	expires time.Time
+}
+
+// tokenBucket holds the requests a key may still send, it refills at the rate of the key up to its burst
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimit is the outcome of taking a token from the bucket of a key
+type RateLimit struct {
+	Allowed    bool
+	Remaining  int           // the whole tokens left
+	RetryAfter time.Duration // until the next token, when there wasn't one
+	Reset      time.Duration // until the bucket is full
+}
+
+// RetryAfterSeconds returns RetryAfter rounded up to whole seconds, so that clients waiting that long don't come back
+// too early
+func (l RateLimit) RetryAfterSeconds() int {
+	return ceilSeconds(l.RetryAfter)
+}
+
+// APIKeyLimiter authenticates the keys presented and keeps their token buckets. The http middleware and the grpc
+// interceptors share one, so that a key has one limit across both. The buckets are kept in memory, so every
+// instance of the api limits the keys on its own.
+type APIKeyLimiter struct {
+	store APIKeyStore
+	opts  APIKeyOptions
+
+	mu      sync.Mutex
+	cache   map[string]cachedAPIKey
+	buckets map[string]*tokenBucket // by key id
+}
+
+func NewAPIKeyLimiter(store APIKeyStore, opts APIKeyOptions) *APIKeyLimiter {
+	return &APIKeyLimiter{
+		store:   store,
+		opts:    opts,
+		cache:   make(map[string]cachedAPIKey),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// NewAPIKeyAuth returns a middleware letting through the requests with a valid key, as an Authorization: Bearer or
+// an X-API-Key header, as long as the token bucket of the key has a token left. Requests without a valid key are a 401
+// and the ones over the limit a 429 with Retry-After. The responses to keys with a limit have X-RateLimit-Limit,
+// the size of the bucket, X-RateLimit-Remaining and X-RateLimit-Reset, the seconds until the bucket is full again.
+func NewAPIKeyAuth(store APIKeyStore, opts APIKeyOptions) func(http.Handler) http.Handler {
+	return NewAPIKeyLimiter(store, opts).Middleware
+}
+
+// Middleware is the middleware of NewAPIKeyAuth over the keys and buckets of a
+func (a *APIKeyLimiter) Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		presented := presentedAPIKey(r)
+		if presented == "" {
+			w.Header().Set(headerWWWAuthenticate, "Bearer")
+			RenderError(w, NewHttpError("missing api key, expected an Authorization: Bearer or X-API-Key header", 401))
+			return
+		}
+
+		key, err := a.Authenticate(r.Context(), presented)
+		if err != nil {
+			RenderError(w, err)
+			return
+		}
+		if key == nil {
+			w.Header().Set(headerWWWAuthenticate, `Bearer error="invalid_token"`)
+			RenderError(w, NewHttpError("invalid api key", 401))
+			return
+		}
+
+		if key.Rate > 0 {
+			limit := a.take(key, time.Now())
+			h := w.Header()
+			h.Set(headerRateLimitLimit, strconv.Itoa(key.Burst))
+			h.Set(headerRateLimitRemaining, strconv.Itoa(limit.Remaining))
+			h.Set(headerRateLimitReset, strconv.Itoa(ceilSeconds(limit.Reset)))
+			if !limit.Allowed {
+				h.Set(headerRetryAfter, strconv.Itoa(limit.RetryAfterSeconds()))
+				RenderError(w, RateLimitError(limit))
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithAPIKey(r.Context(), key)))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// WithAPIKey returns ctx carrying the key its request was authenticated with, for APIKeyFromContext
+func WithAPIKey(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, key)
+}
+
+// RateLimitError is the 429 of a request over the limit of its key
+func RateLimitError(limit RateLimit) error {
+	return NewHttpError("rate limit exceeded, retry in "+strconv.Itoa(limit.RetryAfterSeconds())+"s", 429)
+}
+
+// presentedAPIKey returns the key of the Authorization header, or else of X-API-Key
+func presentedAPIKey(r *http.Request) string {
+	if token := bearerToken(r); token != "" {
+		return token
+	}
+	return strings.TrimSpace(r.Header.Get(headerAPIKey))
+}
+
+// bearerToken returns the token of a Bearer Authorization header, empty without one
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get(headerAuthorization)
+	if len(auth) <= len(bearerPrefix) || !strings.EqualFold(auth[:len(bearerPrefix)], bearerPrefix) {
+		return ""
+	}
+	return strings.TrimSpace(auth[len(bearerPrefix):])
+}
+
+// Authenticate returns the key presented from the cache, or from the store. Only the known keys are remembered, so
+// that clients sending made up keys can't push the valid ones out of the cache.
+func (a *APIKeyLimiter) Authenticate(ctx context.Context, presented string) (*APIKey, error) {
+	now := time.Now()
+	if a.opts.CacheTTL > 0 {
+		a.mu.Lock()
+		cached, ok := a.cache[presented]
+		a.mu.Unlock()
+		if ok && now.Before(cached.expires) {
+			return cached.key, nil
+		}
+	}
+
+	key, err := a.store.Authenticate(ctx, presented)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.opts.CacheTTL > 0 && key != nil {
+		a.mu.Lock()
+		if _, ok := a.cache[presented]; !ok && len(a.cache) >= maxCachedAPIKeys {
+			a.evictCached(now)
+		}
+		a.cache[presented] = cachedAPIKey{key: key, expires: now.Add(a.opts.CacheTTL)}
+		a.mu.Unlock()
+	}
+	return key, nil
+}
+
+// evictCached drops the expired keys of the cache, or the oldest one when none has expired, a.mu is held
+func (a *APIKeyLimiter) evictCached(now time.Time) {
+	var oldest string
+	var oldestExpires time.Time
+	for presented, cached := range a.cache {
+		if !now.Before(cached.expires) {
+			delete(a.cache, presented)
+			continue
+		}
+		if oldest == "" || cached.expires.Before(oldestExpires) {
+			oldest, oldestExpires = presented, cached.expires
+		}
+	}
+	if len(a.cache) >= maxCachedAPIKeys {
+		delete(a.cache, oldest)
+	}
+}
+
+// Take takes a token from the bucket of key, which must have a rate
+func (a *APIKeyLimiter) Take(key *APIKey) RateLimit {
+	return a.take(key, time.Now())
+}
+
+func (a *APIKeyLimiter) take(key *APIKey, now time.Time) RateLimit {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	burst := float64(key.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	b, ok := a.buckets[key.ID]
+	if !ok {
+		b = &tokenBucket{tokens: burst, last: now}
+		a.buckets[key.ID] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*key.Rate)
+	b.last = now
+
+	limit := RateLimit{Allowed: b.tokens >= 1}
+	if limit.Allowed {
+		b.tokens--
+	} else {
+		limit.RetryAfter = secondsDuration((1 - b.tokens) / key.Rate)
+	}
+	limit.Remaining = int(b.tokens)
+	limit.Reset = secondsDuration((burst - b.tokens) / key.Rate)
+	return limit
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// ceilSeconds rounds d up to whole seconds, so that clients waiting that long don't come back too early
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// NewAdminAuth returns a middleware letting through the requests with token as their Authorization: Bearer header,
+// the others are a 401
+func NewAdminAuth(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			presented := bearerToken(r)
+			if presented == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+				w.Header().Set(headerWWWAuthenticate, "Bearer")
+				RenderError(w, NewHttpError("invalid admin token", 401))
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
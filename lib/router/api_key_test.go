@@ -0,0 +1,187 @@
+package router_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/stretchr/testify/assert"
+)
+
+// testKeyStore knows the keys of its map and counts the lookups
+type testKeyStore struct {
+	mu      sync.Mutex
+	keys    map[string]*router.APIKey
+	lookups int
+}
+
+func (s *testKeyStore) Authenticate(ctx context.Context, key string) (*router.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookups++
+	if key == "broken" {
+		return nil, errors.New("connection refused")
+	}
+	return s.keys[key], nil
+}
+
+func newTestKeyStore() *testKeyStore {
+	return &testKeyStore{keys: map[string]*router.APIKey{
+		"fh_frontend": {ID: "1", Name: "frontend", Rate: 1.0 / 60, Burst: 2},
+		"fh_internal": {ID: "2", Name: "internal"},
+	}}
+}
+
+func serveWithKey(handler http.Handler, header, value string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	cases := []struct {
+		Name           string
+		Header         string
+		Value          string
+		ExpectedStatus int
+		ExpectedBody   string
+	}{
+		{
+			Name:           "bearer",
+			Header:         "Authorization",
+			Value:          "Bearer fh_frontend",
+			ExpectedStatus: 200,
+			ExpectedBody:   "frontend",
+		},
+		{
+			Name:           "x-api-key",
+			Header:         "X-API-Key",
+			Value:          "fh_internal",
+			ExpectedStatus: 200,
+			ExpectedBody:   "internal",
+		},
+		{
+			Name:           "missing",
+			ExpectedStatus: 401,
+			ExpectedBody:   `{"message":"missing api key, expected an Authorization: Bearer or X-API-Key header","status":401}`,
+		},
+		{
+			Name:           "unknown",
+			Header:         "Authorization",
+			Value:          "bearer fh_unknown",
+			ExpectedStatus: 401,
+			ExpectedBody:   `{"message":"invalid api key","status":401}`,
+		},
+		{
+			Name:           "store failing",
+			Header:         "X-API-Key",
+			Value:          "broken",
+			ExpectedStatus: 500,
+			ExpectedBody:   `{"message":"Internal Server Error","status":500}`,
+		},
+	}
+
+	auth := router.NewAPIKeyAuth(newTestKeyStore(), router.APIKeyOptions{})
+	handler := auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(router.APIKeyFromContext(r.Context()).Name))
+	}))
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			w := serveWithKey(handler, tt.Header, tt.Value)
+			assert.Equal(tt.ExpectedStatus, w.Code)
+			assert.Equal(tt.ExpectedBody, w.Body.String())
+			if tt.ExpectedStatus == 401 {
+				assert.NotEmpty(w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAPIKeyRateLimit(t *testing.T) {
+	assert := assert.New(t)
+	handler := router.NewAPIKeyAuth(newTestKeyStore(), router.APIKeyOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// a bucket of 2, refilled with a token a minute
+	w := serveWithKey(handler, "X-API-Key", "fh_frontend")
+	assert.Equal(200, w.Code)
+	assert.Equal("2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal("1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal("60", w.Header().Get("X-RateLimit-Reset"))
+
+	w = serveWithKey(handler, "X-API-Key", "fh_frontend")
+	assert.Equal(200, w.Code)
+	assert.Equal("0", w.Header().Get("X-RateLimit-Remaining"))
+
+	w = serveWithKey(handler, "X-API-Key", "fh_frontend")
+	assert.Equal(429, w.Code)
+	assert.Equal("0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal("60", w.Header().Get("Retry-After"))
+	assert.Equal(`{"message":"rate limit exceeded, retry in 60s","status":429}`, w.Body.String())
+
+	// the limits are per key, and keys without a rate aren't limited
+	for i := 0; i < 5; i++ {
+		w = serveWithKey(handler, "X-API-Key", "fh_internal")
+		assert.Equal(200, w.Code)
+		assert.Empty(w.Header().Get("X-RateLimit-Limit"))
+	}
+}
+
+func TestAPIKeyCache(t *testing.T) {
+	assert := assert.New(t)
+	store := newTestKeyStore()
+	handler := router.NewAPIKeyAuth(store, router.APIKeyOptions{CacheTTL: time.Minute})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// the unknown keys aren't remembered, they are looked up every time
+	for i := 0; i < 3; i++ {
+		assert.Equal(200, serveWithKey(handler, "X-API-Key", "fh_internal").Code)
+		assert.Equal(401, serveWithKey(handler, "X-API-Key", "fh_unknown").Code)
+	}
+	assert.Equal(4, store.lookups)
+}
+
+func TestAPIKeyCacheUnknownKeys(t *testing.T) {
+	assert := assert.New(t)
+	store := newTestKeyStore()
+	limiter := router.NewAPIKeyLimiter(store, router.APIKeyOptions{CacheTTL: time.Minute})
+
+	key, err := limiter.Authenticate(context.TODO(), "fh_internal")
+	assert.Nil(err)
+	assert.Equal("internal", key.Name)
+
+	// made up keys don't push the valid ones out of the cache
+	for i := 0; i < 20000; i++ {
+		key, err := limiter.Authenticate(context.TODO(), "fh_unknown_"+strconv.Itoa(i))
+		assert.Nil(err)
+		assert.Nil(key)
+	}
+	lookups := store.lookups
+
+	key, err = limiter.Authenticate(context.TODO(), "fh_internal")
+	assert.Nil(err)
+	assert.Equal("internal", key.Name)
+	assert.Equal(lookups, store.lookups)
+}
+
+func TestAdminAuth(t *testing.T) {
+	assert := assert.New(t)
+	handler := router.NewAdminAuth("s3cret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	assert.Equal(200, serveWithKey(handler, "Authorization", "Bearer s3cret").Code)
+	assert.Equal(401, serveWithKey(handler, "Authorization", "Bearer s3cre").Code)
+	assert.Equal(401, serveWithKey(handler, "X-API-Key", "s3cret").Code)
+	assert.Equal(401, serveWithKey(handler, "", "").Code)
+}
@@ -0,0 +1,129 @@
+package router
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	headerForwarded     = "Forwarded"
+	headerXForwardedFor = "X-Forwarded-For"
+)
+
+// NewRealIP returns a middleware setting the RemoteAddr of the requests coming from one of the trusted proxy networks
+// to the address of the client, as forwarded by the proxies in the Forwarded or else the X-Forwarded-For header.
+// Anyone can set those headers, so they are ignored on requests from other addresses.
+func NewRealIP(trustedProxies []string) (func(http.Handler) http.Handler, error) {
+	trusted := make([]*net.IPNet, 0, len(trustedProxies))
+	for _, cidr := range trustedProxies {
+		if !strings.Contains(cidr, "/") {
+			cidr = singleAddressNetwork(cidr)
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+		}
+		trusted = append(trusted, network)
+	}
+
+	isTrusted := func(ip net.IP) bool {
+		for _, network := range trusted {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if ip := realIP(r, isTrusted); ip != nil {
+				r.RemoteAddr = ip.String()
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}, nil
+}
+
+// singleAddressNetwork returns the network of addr alone, so trusted proxies can be listed by address
+func singleAddressNetwork(addr string) string {
+	if strings.Contains(addr, ":") {
+		return addr + "/128"
+	}
+	return addr + "/32"
+}
+
+// ClientIP returns the address of the client of r, RemoteAddr without its port
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr // already set to the address alone by NewRealIP
+	}
+	return host
+}
+
+// realIP walks the forwarded addresses from the nearest proxy back, and returns the first one that isn't a trusted
+// proxy, or nil when the request didn't come from a trusted proxy. When the chain is cut by an address the proxies
+// hid or didn't know, the last proxy it reached is returned.
+func realIP(r *http.Request, isTrusted func(net.IP) bool) net.IP {
+	peer := net.ParseIP(ClientIP(r))
+	if peer == nil || !isTrusted(peer) {
+		return nil
+	}
+
+	chain := forwardedFor(r.Header.Values(headerForwarded))
+	if len(chain) == 0 {
+		chain = xForwardedFor(r.Header.Values(headerXForwardedFor))
+	}
+
+	ip := peer
+	for i := len(chain) - 1; i >= 0; i-- {
+		hop := parseHop(chain[i])
+		if hop == nil {
+			return ip
+		}
+		ip = hop
+		if !isTrusted(hop) {
+			return ip
+		}
+	}
+	return ip
+}
+
+// forwardedFor returns the for parameters of the elements of Forwarded headers (RFC 7239), in order
+func forwardedFor(values []string) []string {
+	var chain []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					chain = append(chain, strings.Trim(val, `"`))
+				}
+			}
+		}
+	}
+	return chain
+}
+
+func xForwardedFor(values []string) []string {
+	var chain []string
+	for _, value := range values {
+		for _, hop := range strings.Split(value, ",") {
+			chain = append(chain, strings.TrimSpace(hop))
+		}
+	}
+	return chain
+}
+
+// parseHop parses a forwarded address, which may have a port and ipv6 ones brackets. Obfuscated and unknown
+// addresses are nil.
+func parseHop(hop string) net.IP {
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		hop = host
+	}
+	return net.ParseIP(strings.Trim(hop, "[]"))
+}
@@ -0,0 +1,107 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealIP(t *testing.T) {
+	cases := []struct {
+		Name          string
+		RemoteAddr    string
+		Forwarded     []string
+		XForwardedFor []string
+		ExpectedIP    string
+	}{
+		{
+			Name:       "direct",
+			RemoteAddr: "1.2.3.4:5678",
+			ExpectedIP: "1.2.3.4",
+		},
+		{
+			Name:          "headers of an untrusted peer ignored",
+			RemoteAddr:    "1.2.3.4:5678",
+			XForwardedFor: []string{"5.6.7.8"},
+			ExpectedIP:    "1.2.3.4",
+		},
+		{
+			Name:          "x-forwarded-for",
+			RemoteAddr:    "10.0.0.1:5678",
+			XForwardedFor: []string{"5.6.7.8"},
+			ExpectedIP:    "5.6.7.8",
+		},
+		{
+			Name:          "spoofed hops before the client ignored",
+			RemoteAddr:    "10.0.0.1:5678",
+			XForwardedFor: []string{"9.9.9.9, 5.6.7.8", "10.0.0.2"},
+			ExpectedIP:    "5.6.7.8",
+		},
+		{
+			Name:       "forwarded",
+			RemoteAddr: "10.0.0.1:5678",
+			Forwarded:  []string{`for=9.9.9.9;proto=https, for="[2001:db8::1]:4711"`, "for=10.0.0.2;by=10.0.0.1"},
+			ExpectedIP: "2001:db8::1",
+		},
+		{
+			Name:          "forwarded preferred",
+			RemoteAddr:    "10.0.0.1:5678",
+			Forwarded:     []string{"For=5.6.7.8"},
+			XForwardedFor: []string{"9.9.9.9"},
+			ExpectedIP:    "5.6.7.8",
+		},
+		{
+			Name:       "hidden client",
+			RemoteAddr: "10.0.0.1:5678",
+			Forwarded:  []string{"for=unknown, for=10.0.0.2"},
+			ExpectedIP: "10.0.0.2",
+		},
+		{
+			Name:       "trusted proxy by address",
+			RemoteAddr: "[2001:db8::53]:443",
+			Forwarded:  []string{"for=5.6.7.8:1234"},
+			ExpectedIP: "5.6.7.8",
+		},
+		{
+			Name:       "trusted peer without headers",
+			RemoteAddr: "10.0.0.1:5678",
+			ExpectedIP: "10.0.0.1",
+		},
+	}
+
+	realIP, err := router.NewRealIP([]string{"10.0.0.0/8", "2001:db8::53"})
+	if err != nil {
+		t.Fatalf("Error parsing the trusted proxies %v", err)
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.RemoteAddr
+			for _, value := range tt.Forwarded {
+				req.Header.Add("Forwarded", value)
+			}
+			for _, value := range tt.XForwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+
+			var ip string
+			realIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ip = router.ClientIP(r)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(tt.ExpectedIP, ip)
+		})
+	}
+}
+
+func TestRealIPInvalidProxy(t *testing.T) {
+	_, err := router.NewRealIP([]string{"10.0.0.0/33"})
+	assert.NotNil(t, err)
+}
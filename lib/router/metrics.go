@@ -0,0 +1,59 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// routeUnmatched is the route of the requests no route matched, so that unknown paths don't add a series each
+const routeUnmatched = "unmatched"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "The requests served, by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "The duration of the requests, by route, method and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+)
+
+// Metrics counts the requests and observes their duration by route pattern, e.g. /v1/stats/countries/{code}/cities,
+// rather than by path. It goes before LoggerAndRecover, so that the requests it recovers count as the 500 they are.
+func Metrics(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		sw := statusWriter{ResponseWriter: w}
+		start := time.Now()
+
+		next.ServeHTTP(&sw, r)
+
+		route := routeUnmatched
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := sw.status
+		if status == 0 { // nothing was written
+			status = http.StatusOK
+		}
+
+		labels := prometheus.Labels{"route": route, "method": r.Method, "status": strconv.Itoa(status)}
+		httpRequests.With(labels).Inc()
+		httpRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// MetricsHandler serves the metrics of the process in the Prometheus exposition format
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,56 @@
+package router_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	zlog "github.com/ohmpatel1997/findhotel/lib/log"
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	assert := assert.New(t)
+	_ = zlog.New()
+
+	r := router.NewBasicRouter()
+	r.Route("/metrics-test", func(r router.Router) {
+		r.Get("/countries/{code}", func(w http.ResponseWriter, r *http.Request) {
+			if router.URLParam(r, "code") == "XX" {
+				router.RenderError(w, router.NewHttpError("country not found", 404))
+			}
+		})
+		r.Post("/panic", func(w http.ResponseWriter, r *http.Request) {
+			panic(errors.New("boom"))
+		})
+	})
+	r.Handle("/metrics", router.MetricsHandler())
+
+	for _, path := range []string{"/metrics-test/countries/IN", "/metrics-test/countries/DE", "/metrics-test/countries/XX"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/metrics-test/panic", nil))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(200, w.Code)
+
+	var lines []string
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if strings.Contains(line, "/metrics-test/") && (strings.HasPrefix(line, "http_requests_total") || strings.HasPrefix(line, "http_request_duration_seconds_count")) {
+			lines = append(lines, line)
+		}
+	}
+	// by route pattern rather than path, the recovered panic counted as a 500
+	assert.ElementsMatch([]string{
+		`http_request_duration_seconds_count{method="GET",route="/metrics-test/countries/{code}",status="200"} 2`,
+		`http_request_duration_seconds_count{method="GET",route="/metrics-test/countries/{code}",status="404"} 1`,
+		`http_request_duration_seconds_count{method="POST",route="/metrics-test/panic",status="500"} 1`,
+		`http_requests_total{method="GET",route="/metrics-test/countries/{code}",status="200"} 2`,
+		`http_requests_total{method="GET",route="/metrics-test/countries/{code}",status="404"} 1`,
+		`http_requests_total{method="POST",route="/metrics-test/panic",status="500"} 1`,
+	}, lines)
+}
@@ -0,0 +1,365 @@
+package router
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ParamFormat picks the format of the response by name, it wins over the Accept header
+const ParamFormat = "format"
+
+const (
+	FormatJSON    = "json"
+	FormatCSV     = "csv"
+	FormatMsgPack = "msgpack"
+	FormatXML     = "xml"
+)
+
+// renderer encodes the responses of a format, root names the value for the formats that need a name for it
+type renderer struct {
+	contentType string
+	encode      func(w io.Writer, root string, data interface{}) error
+}
+
+var renderers = map[string]renderer{
+	FormatJSON:    {"application/json", encodeJSON},
+	FormatCSV:     {"text/csv; charset=utf-8", encodeCSV},
+	FormatMsgPack: {"application/msgpack", encodeMsgPack},
+	FormatXML:     {"application/xml; charset=utf-8", encodeXML},
+}
+
+// mediaTypes are the media types of the Accept header the formats are picked by
+var mediaTypes = map[string]string{
+	"application/json":        FormatJSON,
+	"application/*":           FormatJSON,
+	"*/*":                     FormatJSON,
+	"text/csv":                FormatCSV,
+	"application/msgpack":     FormatMsgPack,
+	"application/x-msgpack":   FormatMsgPack,
+	"application/vnd.msgpack": FormatMsgPack,
+	"application/xml":         FormatXML,
+	"text/xml":                FormatXML,
+}
+
+// formatWriter carries the format negotiated for a request to Render and RenderError
+type formatWriter struct {
+	http.ResponseWriter
+	format string
+}
+
+// Negotiate is a middleware picking the format Render and RenderError use for the request, from the format query
+// param or else the Accept header. JSON is the default, also when Accept has no supported type, while an unknown
+// format param is a 400.
+func Negotiate(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+
+		format := r.URL.Query().Get(ParamFormat)
+		if format == "" {
+			format = acceptedFormat(r.Header.Values("Accept"))
+		}
+		if _, ok := renderers[format]; !ok {
+			RenderError(w, NewHttpError("unknown format "+format+", expected one of "+strings.Join(formatNames(), ", "), 400))
+			return
+		}
+		next.ServeHTTP(&formatWriter{ResponseWriter: w, format: format}, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// acceptedFormat returns the format of the media type with the highest quality in the Accept headers, the first
+// one of equal ones
+func acceptedFormat(values []string) string {
+	best, bestQuality := FormatJSON, 0.0
+	for _, value := range values {
+		for _, accepted := range strings.Split(value, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+			if err != nil {
+				continue
+			}
+			format, ok := mediaTypes[mediaType]
+			if !ok {
+				continue
+			}
+			quality := 1.0
+			if q, ok := params["q"]; ok {
+				if quality, err = strconv.ParseFloat(q, 64); err != nil {
+					continue
+				}
+			}
+			if quality > bestQuality {
+				best, bestQuality = format, quality
+			}
+		}
+	}
+	return best
+}
+
+func formatNames() []string {
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatOf returns the format negotiated for the response written to w, JSON outside of Negotiate
+func formatOf(w http.ResponseWriter) string {
+	if fw, ok := w.(*formatWriter); ok {
+		return fw.format
+	}
+	return FormatJSON
+}
+
+// Render writes the data of r in the format negotiated for the request
+func Render(r Response) {
+	render(r.Writer, r.Status, "response", r.Data)
+}
+
+func render(w http.ResponseWriter, status int, root string, data interface{}) {
+	rd := renderers[formatOf(w)]
+
+	var buf bytes.Buffer
+	if err := rd.encode(&buf, root, data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal server error"))
+		return
+	}
+
+	w.Header().Set("Content-Type", rd.contentType)
+	if status > 0 {
+		w.WriteHeader(status)
+	}
+	w.Write(buf.Bytes())
+}
+
+func encodeJSON(w io.Writer, root string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// encodeMsgPack names the fields like JSON does, so that clients can switch formats without renaming them
+func encodeMsgPack(w io.Writer, root string, data interface{}) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(data)
+}
+
+// encodeXML writes data as elements named like the JSON fields, under a root element. The items of arrays are item
+// elements.
+func encodeXML(w io.Writer, root string, data interface{}) error {
+	value, err := toOrdered(data)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	if err := writeXML(enc, root, value); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func writeXML(enc *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case object:
+		for _, f := range v {
+			if err := writeXML(enc, f.key, f.value); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := writeXML(enc, "item", item); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := enc.EncodeToken(xml.CharData(scalarString(v))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// encodeCSV writes the rows of data with a header naming the columns like the JSON fields. The rows are the items of
+// an array, or of the first array of an object, whose other fields are repeated on every row, or else the object
+// alone. Nested objects are flattened into columns named by their path, like centroid.latitude.
+func encodeCSV(w io.Writer, root string, data interface{}) error {
+	value, err := toOrdered(data)
+	if err != nil {
+		return err
+	}
+
+	var items []interface{}
+	var shared object
+	switch v := value.(type) {
+	case []interface{}:
+		items = v
+	case object:
+		items = []interface{}{v}
+		for i, f := range v {
+			if list, ok := f.value.([]interface{}); ok {
+				items = list
+				shared = append(append(object{}, v[:i]...), v[i+1:]...)
+				break
+			}
+		}
+	default:
+		items = []interface{}{object{{root, v}}}
+	}
+
+	var columns []string
+	seen := make(map[string]bool)
+	rows := make([]map[string]string, 0, len(items))
+	for _, item := range items {
+		row := make(map[string]string)
+		add := func(column, cell string) {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+			row[column] = cell
+		}
+		flatten("", item, add)
+		flatten("", shared, add)
+		rows = append(rows, row)
+	}
+	if len(items) == 0 {
+		flatten("", shared, func(column, cell string) { columns = append(columns, column) })
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = row[column]
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// flatten calls add with the column and cell of every scalar in value, named by its path below prefix
+func flatten(prefix string, value interface{}, add func(column, cell string)) {
+	switch v := value.(type) {
+	case object:
+		for _, f := range v {
+			flatten(join(prefix, f.key), f.value, add)
+		}
+	case []interface{}:
+		for i, item := range v {
+			flatten(join(prefix, strconv.Itoa(i)), item, add)
+		}
+	default:
+		if prefix == "" {
+			prefix = "value"
+		}
+		add(prefix, scalarString(v))
+	}
+}
+
+func join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// field is a member of a JSON object. Objects are decoded in order, so that the columns and elements of the formats
+// built from JSON keep the order of the struct fields.
+type field struct {
+	key   string
+	value interface{}
+}
+
+type object []field
+
+// toOrdered returns data as its JSON encoding decodes: objects, arrays and scalars
+func toOrdered(data interface{}) (interface{}, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return decodeOrdered(dec)
+}
+
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := object{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, field{key.(string), value})
+		}
+		_, err := dec.Token() // }
+		return obj, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			item, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		_, err := dec.Token() // ]
+		return list, err
+	}
+	return tok, nil
+}
+
+func scalarString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
@@ -0,0 +1,156 @@
+package router_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ohmpatel1997/findhotel/lib/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type testCity struct {
+	City     string       `json:"city"`
+	IPs      int64        `json:"ips"`
+	Centroid testCentroid `json:"centroid"`
+	Network  string       `json:"network,omitempty"`
+}
+
+type testCentroid struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type testCities struct {
+	CountryCode string      `json:"country_code"`
+	Cities      []*testCity `json:"cities"`
+}
+
+var cities = &testCities{
+	CountryCode: "DE",
+	Cities: []*testCity{
+		{City: "Berlin", IPs: 3, Centroid: testCentroid{52.5, 13.4}},
+		{City: "Köln, Cologne", IPs: 1, Centroid: testCentroid{50.9, 6.9}, Network: "10.0.0.0/8"},
+	},
+}
+
+func serve(target string, accept string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	router.Negotiate(handler).ServeHTTP(w, req)
+	return w
+}
+
+func TestRender(t *testing.T) {
+	cases := []struct {
+		Name                string
+		Target              string
+		Accept              string
+		Err                 error
+		ExpectedStatus      int
+		ExpectedContentType string
+		ExpectedBody        string
+	}{
+		{
+			Name:                "json by default",
+			Target:              "/",
+			ExpectedStatus:      200,
+			ExpectedContentType: "application/json",
+			ExpectedBody:        `{"country_code":"DE","cities":[{"city":"Berlin","ips":3,"centroid":{"latitude":52.5,"longitude":13.4}},{"city":"Köln, Cologne","ips":1,"centroid":{"latitude":50.9,"longitude":6.9},"network":"10.0.0.0/8"}]}`,
+		},
+		{
+			Name:                "csv rows of the array",
+			Target:              "/",
+			Accept:              "text/html, text/csv;q=0.9, application/json;q=0.5",
+			ExpectedStatus:      200,
+			ExpectedContentType: "text/csv; charset=utf-8",
+			ExpectedBody: "city,ips,centroid.latitude,centroid.longitude,country_code,network\n" +
+				"Berlin,3,52.5,13.4,DE,\n" +
+				"\"Köln, Cologne\",1,50.9,6.9,DE,10.0.0.0/8\n",
+		},
+		{
+			Name:                "xml by format param",
+			Target:              "/?format=xml",
+			Accept:              "text/csv",
+			ExpectedStatus:      200,
+			ExpectedContentType: "application/xml; charset=utf-8",
+			ExpectedBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<response><country_code>DE</country_code><cities>` +
+				`<item><city>Berlin</city><ips>3</ips><centroid><latitude>52.5</latitude><longitude>13.4</longitude></centroid></item>` +
+				`<item><city>Köln, Cologne</city><ips>1</ips><centroid><latitude>50.9</latitude><longitude>6.9</longitude></centroid><network>10.0.0.0/8</network></item>` +
+				`</cities></response>`,
+		},
+		{
+			Name:                "unsupported accept falls back to json",
+			Target:              "/",
+			Accept:              "text/html",
+			Err:                 router.NewHttpError("country not found", 404),
+			ExpectedStatus:      404,
+			ExpectedContentType: "application/json",
+			ExpectedBody:        `{"message":"country not found","status":404}`,
+		},
+		{
+			Name:                "csv error",
+			Target:              "/?format=csv",
+			Err:                 router.NewHttpError("country not found", 404),
+			ExpectedStatus:      404,
+			ExpectedContentType: "text/csv; charset=utf-8",
+			ExpectedBody:        "message,status\ncountry not found,404\n",
+		},
+		{
+			Name:                "xml error of another type",
+			Target:              "/",
+			Accept:              "application/xml",
+			Err:                 errors.New("connection refused"),
+			ExpectedStatus:      500,
+			ExpectedContentType: "application/xml; charset=utf-8",
+			ExpectedBody:        `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<error><message>Internal Server Error</message><status>500</status></error>`,
+		},
+		{
+			Name:                "unknown format",
+			Target:              "/?format=yaml",
+			ExpectedStatus:      400,
+			ExpectedContentType: "application/json",
+			ExpectedBody:        `{"message":"unknown format yaml, expected one of csv, json, msgpack, xml","status":400}`,
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			w := serve(tt.Target, tt.Accept, func(w http.ResponseWriter, r *http.Request) {
+				if tt.Err != nil {
+					router.RenderError(w, tt.Err)
+					return
+				}
+				router.Render(router.Response{Writer: w, Data: cities, Status: 200})
+			})
+			assert.Equal(tt.ExpectedStatus, w.Code)
+			assert.Equal(tt.ExpectedContentType, w.Header().Get("Content-Type"))
+			assert.Equal(tt.ExpectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestRenderMsgPack(t *testing.T) {
+	assert := assert.New(t)
+	w := serve("/", "application/x-msgpack", func(w http.ResponseWriter, r *http.Request) {
+		router.Render(router.Response{Writer: w, Data: cities, Status: 200})
+	})
+	assert.Equal("application/msgpack", w.Header().Get("Content-Type"))
+
+	// the fields are named like in json
+	var decoded map[string]interface{}
+	assert.Nil(msgpack.Unmarshal(w.Body.Bytes(), &decoded))
+	assert.Equal("DE", decoded["country_code"])
+	berlin := decoded["cities"].([]interface{})[0].(map[string]interface{})
+	assert.Equal("Berlin", berlin["city"])
+	assert.NotContains(berlin, "network")
+}
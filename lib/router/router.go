@@ -30,6 +30,7 @@ type Router interface {
 	Handle(string, http.Handler)
 	HandleFunc(string, http.HandlerFunc)
 	With(middlewares ...func(http.Handler) http.Handler) Router
+	Use(middlewares ...func(http.Handler) http.Handler)
 
 	ListenAndServeTLS(cfg *config.Server) error
 	ServeHTTP(http.ResponseWriter, *http.Request)
@@ -42,7 +43,9 @@ type router struct {
 // NewBasicRouter is a basic router without authorization for back compat
 func NewBasicRouter() Router {
 	rchi := chi.NewRouter()
+	rchi.Use(Metrics)
 	rchi.Use(LoggerAndRecover)
+	rchi.Use(Negotiate)
 
 	return &router{
 		chi: rchi,
@@ -54,6 +57,11 @@ func (r *router) With(middlewares ...func(http.Handler) http.Handler) Router {
 	return r
 }
 
+// Use adds middlewares to every route of r, it has to be called before the routes are registered
+func (r *router) Use(middlewares ...func(http.Handler) http.Handler) {
+	r.chi.Use(middlewares...)
+}
+
 func (r *router) Delete(p string, h http.HandlerFunc, middlewares ...func(http.Handler) http.Handler) {
 	r.chi.With(middlewares...).Delete(p, h)
 }
@@ -139,6 +147,11 @@ func (r *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	r.chi.ServeHTTP(w, req)
 }
 
+// URLParam returns the value of the {key} placeholder of the route pattern r was matched with
+func URLParam(r *http.Request, key string) string {
+	return chi.URLParam(r, key)
+}
+
 //Response is all the info we need to properly render json ResponseWriter, Data, Logger, Status
 type Response struct {
 	Writer http.ResponseWriter
@@ -179,12 +192,12 @@ func RenderJSON(r Response) {
 	r.Writer.Write(j)
 }
 
+// RenderError writes err in the format negotiated for the request, errors other than HttpError as a 500
 func RenderError(r http.ResponseWriter, err error) {
 	httpErr := &HttpError{
 		Message: "Internal Server Error",
 		Status:  500,
 	}
-	r.Header().Set("Content-Type", "application/json")
 
 	var httpError *HttpError
 	switch {
@@ -193,11 +206,5 @@ func RenderError(r http.ResponseWriter, err error) {
 		httpErr.Message = httpError.Message
 	}
 
-	r.WriteHeader(httpError.Status)
-	resp, err := json.Marshal(httpError)
-	if err != nil {
-		r.Write([]byte("internal server error"))
-		return
-	}
-	r.Write(resp)
+	render(r, httpErr.Status, "error", httpErr)
 }
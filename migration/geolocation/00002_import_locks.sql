@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE import_locks (
+                       name                        TEXT PRIMARY KEY NOT NULL,
+                       owner                       UUID NOT NULL,
+                       hostname                    TEXT NOT NULL DEFAULT '',
+                       pid                         INTEGER NOT NULL DEFAULT 0,
+                       acquired_at                 TIMESTAMP with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                       heartbeat_at                TIMESTAMP with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE import_locks;
@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE import_runs (
+                       id                          UUID PRIMARY KEY NOT NULL DEFAULT uuid_generate_v4(),
+                       source                      TEXT NOT NULL DEFAULT '',
+                       status                      TEXT NOT NULL DEFAULT 'running',
+                       started_at                  TIMESTAMP with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                       finished_at                 TIMESTAMP with time zone,
+                       rolled_back_at              TIMESTAMP with time zone
+);
+
+-- one row per ip written by a run; the previous_* columns hold the overwritten row and are NULL when the run inserted it
+CREATE TABLE import_run_changes (
+                       run_id                      UUID NOT NULL REFERENCES import_runs(id) ON DELETE CASCADE,
+                       ip                          TEXT NOT NULL,
+                       existed                     BOOLEAN NOT NULL,
+                       previous_country_code       TEXT,
+                       previous_country            TEXT,
+                       previous_city               TEXT,
+                       previous_latitude           TEXT,
+                       previous_longitude          TEXT,
+                       previous_mystery_value      TEXT,
+                       PRIMARY KEY (run_id, ip)
+);
+
+CREATE INDEX index_import_runs_started_at ON import_runs(started_at);
+
+-- +goose Down
+DROP INDEX index_import_runs_started_at;
+DROP TABLE import_run_changes;
+DROP TABLE import_runs;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE import_ledger (
+                       id                          UUID PRIMARY KEY NOT NULL DEFAULT uuid_generate_v4(),
+                       run_id                      UUID NOT NULL REFERENCES import_runs(id) ON DELETE CASCADE,
+                       file_name                   TEXT NOT NULL DEFAULT '',
+                       sha256                      TEXT NOT NULL,
+                       size_bytes                  BIGINT NOT NULL DEFAULT 0,
+                       status                      TEXT NOT NULL DEFAULT 'running',
+                       valid_count                 BIGINT NOT NULL DEFAULT 0,
+                       invalid_count               BIGINT NOT NULL DEFAULT 0,
+                       duration_seconds            DOUBLE PRECISION NOT NULL DEFAULT 0,
+                       created_at                  TIMESTAMP with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                       finished_at                 TIMESTAMP with time zone
+);
+
+CREATE INDEX index_import_ledger_sha256 ON import_ledger(sha256);
+
+-- +goose Down
+DROP INDEX index_import_ledger_sha256;
+DROP TABLE import_ledger;
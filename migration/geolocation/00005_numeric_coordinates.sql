@@ -0,0 +1,43 @@
+-- +goose Up
+ALTER TABLE geolocations
+    ALTER COLUMN latitude DROP DEFAULT,
+    ALTER COLUMN longitude DROP DEFAULT;
+
+-- backfills the existing rows in place, the importer never stored empty coordinates
+ALTER TABLE geolocations
+    ALTER COLUMN latitude TYPE DOUBLE PRECISION USING COALESCE(NULLIF(trim(latitude), ''), '0')::DOUBLE PRECISION,
+    ALTER COLUMN longitude TYPE DOUBLE PRECISION USING COALESCE(NULLIF(trim(longitude), ''), '0')::DOUBLE PRECISION;
+
+ALTER TABLE geolocations
+    ALTER COLUMN latitude SET DEFAULT 0,
+    ALTER COLUMN longitude SET DEFAULT 0,
+    ADD CONSTRAINT check_latitude CHECK (latitude BETWEEN -90 AND 90),
+    ADD CONSTRAINT check_longitude CHECK (longitude BETWEEN -180 AND 180);
+
+CREATE INDEX index_coordinates ON geolocations(latitude, longitude);
+
+-- the snapshots of overwritten rows have to match, rollbacks copy them back
+ALTER TABLE import_run_changes
+    ALTER COLUMN previous_latitude TYPE DOUBLE PRECISION USING NULLIF(trim(previous_latitude), '')::DOUBLE PRECISION,
+    ALTER COLUMN previous_longitude TYPE DOUBLE PRECISION USING NULLIF(trim(previous_longitude), '')::DOUBLE PRECISION;
+
+-- +goose Down
+ALTER TABLE import_run_changes
+    ALTER COLUMN previous_latitude TYPE TEXT,
+    ALTER COLUMN previous_longitude TYPE TEXT;
+
+DROP INDEX index_coordinates;
+
+ALTER TABLE geolocations
+    DROP CONSTRAINT check_latitude,
+    DROP CONSTRAINT check_longitude,
+    ALTER COLUMN latitude DROP DEFAULT,
+    ALTER COLUMN longitude DROP DEFAULT;
+
+ALTER TABLE geolocations
+    ALTER COLUMN latitude TYPE TEXT,
+    ALTER COLUMN longitude TYPE TEXT;
+
+ALTER TABLE geolocations
+    ALTER COLUMN latitude SET DEFAULT '',
+    ALTER COLUMN longitude SET DEFAULT '';
@@ -0,0 +1,27 @@
+-- +goose Up
+-- every row covers the inclusive range start_ip..end_ip, single ips and CIDR networks included
+CREATE TYPE inetrange AS RANGE (subtype = inet);
+
+ALTER TABLE geolocations
+    ADD COLUMN start_ip INET,
+    ADD COLUMN end_ip INET;
+
+UPDATE geolocations SET start_ip = ip::inet, end_ip = ip::inet;
+
+ALTER TABLE geolocations
+    ALTER COLUMN start_ip SET NOT NULL,
+    ALTER COLUMN end_ip SET NOT NULL,
+    ADD CONSTRAINT check_ip_range CHECK (family(start_ip) = family(end_ip) AND start_ip <= end_ip);
+
+-- serves the containment lookups, FindDataByIP uses the same expression
+CREATE INDEX index_ip_range ON geolocations USING gist (inetrange(start_ip, end_ip, '[]'));
+
+-- +goose Down
+DROP INDEX index_ip_range;
+
+ALTER TABLE geolocations
+    DROP CONSTRAINT check_ip_range,
+    DROP COLUMN start_ip,
+    DROP COLUMN end_ip;
+
+DROP TYPE inetrange;
@@ -0,0 +1,60 @@
+-- +goose Up
+-- every version of every geolocation, current in [valid_from, valid_to); valid_to is NULL while the version still is.
+-- The versions are written by a trigger, so rollbacks and manual fixes are recorded as well.
+CREATE TABLE geolocation_history (
+                       id                          BIGSERIAL PRIMARY KEY,
+                       geolocation_id              UUID NOT NULL,
+                       ip                          TEXT NOT NULL,
+                       start_ip                    INET NOT NULL,
+                       end_ip                      INET NOT NULL,
+                       country_code                TEXT NOT NULL,
+                       country                     TEXT NOT NULL,
+                       city                        TEXT NOT NULL,
+                       latitude                    DOUBLE PRECISION NOT NULL,
+                       longitude                   DOUBLE PRECISION NOT NULL,
+                       mystery_value               TEXT NOT NULL,
+                       valid_from                  TIMESTAMP with time zone NOT NULL,
+                       valid_to                    TIMESTAMP with time zone,
+                       CHECK (valid_to IS NULL OR valid_from < valid_to)
+);
+
+-- serves the as-of lookups, FindDataByIP uses the same expressions
+CREATE INDEX index_history_ip_range ON geolocation_history USING gist (inetrange(start_ip, end_ip, '[]'), tstzrange(valid_from, valid_to, '[)'));
+CREATE INDEX index_history_open ON geolocation_history(ip) WHERE valid_to IS NULL;
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_geolocation_history()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND (OLD.ip, OLD.start_ip, OLD.end_ip, OLD.country_code, OLD.country, OLD.city, OLD.latitude, OLD.longitude, OLD.mystery_value)
+        IS NOT DISTINCT FROM (NEW.ip, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value) THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        -- a version replaced in the transaction that wrote it was never visible
+        DELETE FROM geolocation_history WHERE ip = OLD.ip AND valid_to IS NULL AND valid_from >= now();
+        UPDATE geolocation_history SET valid_to = now() WHERE ip = OLD.ip AND valid_to IS NULL;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO geolocation_history (geolocation_id, ip, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, valid_from)
+        VALUES (NEW.id, NEW.ip, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value, now());
+    END IF;
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+-- +goose StatementEnd
+
+-- the rows as they are now are the first versions known
+INSERT INTO geolocation_history (geolocation_id, ip, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, valid_from)
+SELECT id, ip, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, modified_at FROM geolocations;
+
+CREATE TRIGGER record_geolocation_history AFTER INSERT OR UPDATE OR DELETE ON geolocations FOR EACH ROW EXECUTE PROCEDURE record_geolocation_history();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS record_geolocation_history ON geolocations;
+DROP FUNCTION IF EXISTS record_geolocation_history;
+DROP INDEX index_history_open;
+DROP INDEX index_history_ip_range;
+DROP TABLE geolocation_history;
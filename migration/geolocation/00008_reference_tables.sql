@@ -0,0 +1,137 @@
+-- +goose Up
+-- the countries and cities the geolocations point to. A country is identified by its code, a city by its country and
+-- its name compared case insensitively, with whitespace collapsed; the importer keeps the names first imported.
+CREATE TABLE countries (
+                       id                          SERIAL PRIMARY KEY,
+                       code                        TEXT NOT NULL UNIQUE,
+                       name                        TEXT NOT NULL DEFAULT '',
+                       created_at                  TIMESTAMP with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE cities (
+                       id                          SERIAL PRIMARY KEY,
+                       country_id                  INTEGER NOT NULL REFERENCES countries(id),
+                       name                        TEXT NOT NULL,
+                       name_key                    TEXT NOT NULL,
+                       created_at                  TIMESTAMP with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                       UNIQUE (country_id, name_key)
+);
+
+ALTER TABLE geolocations
+    ADD COLUMN country_id INTEGER REFERENCES countries(id),
+    ADD COLUMN city_id INTEGER REFERENCES cities(id);
+
+CREATE INDEX index_geolocations_country_id ON geolocations(country_id);
+CREATE INDEX index_geolocations_city_id ON geolocations(city_id);
+
+-- rollbacks restore the references with the rest of the row
+ALTER TABLE import_run_changes
+    ADD COLUMN previous_country_id INTEGER,
+    ADD COLUMN previous_city_id INTEGER;
+
+-- backfills the references of the existing rows, taking the most common spelling as the name and keying the city
+-- names like the importer does
+INSERT INTO countries (code, name)
+SELECT upper(trim(country_code)), mode() WITHIN GROUP (ORDER BY trim(country))
+FROM geolocations
+WHERE trim(country_code) != ''
+GROUP BY upper(trim(country_code));
+
+INSERT INTO cities (country_id, name, name_key)
+SELECT c.id, mode() WITHIN GROUP (ORDER BY trim(regexp_replace(g.city, '\s+', ' ', 'g'))), lower(trim(regexp_replace(g.city, '\s+', ' ', 'g')))
+FROM geolocations g
+JOIN countries c ON c.code = upper(trim(g.country_code))
+WHERE trim(g.city) != ''
+GROUP BY c.id, lower(trim(regexp_replace(g.city, '\s+', ' ', 'g')));
+
+UPDATE geolocations g
+SET country_id = c.id
+FROM countries c
+WHERE c.code = upper(trim(g.country_code));
+
+UPDATE geolocations g
+SET city_id = c.id
+FROM cities c
+WHERE c.country_id = g.country_id AND c.name_key = lower(trim(regexp_replace(g.city, '\s+', ' ', 'g')));
+
+-- the versions point to the references too, the ones of the past to those their text has
+ALTER TABLE geolocation_history
+    ADD COLUMN country_id INTEGER,
+    ADD COLUMN city_id INTEGER;
+
+UPDATE geolocation_history h
+SET country_id = c.id
+FROM countries c
+WHERE c.code = upper(trim(h.country_code));
+
+UPDATE geolocation_history h
+SET city_id = c.id
+FROM cities c
+WHERE c.country_id = h.country_id AND c.name_key = lower(trim(regexp_replace(h.city, '\s+', ' ', 'g')));
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_geolocation_history()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND (OLD.ip, OLD.start_ip, OLD.end_ip, OLD.country_code, OLD.country, OLD.city, OLD.latitude, OLD.longitude, OLD.mystery_value, OLD.country_id, OLD.city_id)
+        IS NOT DISTINCT FROM (NEW.ip, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value, NEW.country_id, NEW.city_id) THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        -- a version replaced in the transaction that wrote it was never visible
+        DELETE FROM geolocation_history WHERE ip = OLD.ip AND valid_to IS NULL AND valid_from >= now();
+        UPDATE geolocation_history SET valid_to = now() WHERE ip = OLD.ip AND valid_to IS NULL;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO geolocation_history (geolocation_id, ip, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, country_id, city_id, valid_from)
+        VALUES (NEW.id, NEW.ip, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value, NEW.country_id, NEW.city_id, now());
+    END IF;
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_geolocation_history()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND (OLD.ip, OLD.start_ip, OLD.end_ip, OLD.country_code, OLD.country, OLD.city, OLD.latitude, OLD.longitude, OLD.mystery_value)
+        IS NOT DISTINCT FROM (NEW.ip, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value) THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        -- a version replaced in the transaction that wrote it was never visible
+        DELETE FROM geolocation_history WHERE ip = OLD.ip AND valid_to IS NULL AND valid_from >= now();
+        UPDATE geolocation_history SET valid_to = now() WHERE ip = OLD.ip AND valid_to IS NULL;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO geolocation_history (geolocation_id, ip, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, valid_from)
+        VALUES (NEW.id, NEW.ip, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value, now());
+    END IF;
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+-- +goose StatementEnd
+
+ALTER TABLE geolocation_history
+    DROP COLUMN country_id,
+    DROP COLUMN city_id;
+
+ALTER TABLE import_run_changes
+    DROP COLUMN previous_country_id,
+    DROP COLUMN previous_city_id;
+
+DROP INDEX index_geolocations_city_id;
+DROP INDEX index_geolocations_country_id;
+
+ALTER TABLE geolocations
+    DROP COLUMN country_id,
+    DROP COLUMN city_id;
+
+DROP TABLE cities;
+DROP TABLE countries;
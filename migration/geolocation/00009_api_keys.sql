@@ -0,0 +1,16 @@
+-- +goose Up
+-- the keys the api authenticates requests with. Only the sha256 of a key is stored, the key is shown once when issued;
+-- prefix is its start, to tell the keys apart. rate_limit is in requests per minute.
+CREATE TABLE api_keys (
+                       id                          UUID PRIMARY KEY NOT NULL DEFAULT uuid_generate_v4(),
+                       name                        TEXT NOT NULL DEFAULT '',
+                       hash                        TEXT NOT NULL UNIQUE,
+                       prefix                      TEXT NOT NULL DEFAULT '',
+                       rate_limit                  INTEGER NOT NULL,
+                       burst                       INTEGER NOT NULL,
+                       created_at                  TIMESTAMP with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                       revoked_at                  TIMESTAMP with time zone
+);
+
+-- +goose Down
+DROP TABLE api_keys;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- the orders the geolocations are listed in, ties broken by id, so that the pages are read from an index
+CREATE INDEX index_list_ip ON geolocations(start_ip, end_ip, id);
+CREATE INDEX index_list_country ON geolocations(country, id);
+CREATE INDEX index_list_city ON geolocations(city, id);
+CREATE INDEX index_list_latitude ON geolocations(latitude, id);
+CREATE INDEX index_list_longitude ON geolocations(longitude, id);
+
+-- the city filter finds its cities by key, in every country
+CREATE INDEX index_cities_name_key ON cities(name_key);
+
+-- +goose Down
+DROP INDEX index_cities_name_key;
+DROP INDEX index_list_longitude;
+DROP INDEX index_list_latitude;
+DROP INDEX index_list_city;
+DROP INDEX index_list_country;
+DROP INDEX index_list_ip;
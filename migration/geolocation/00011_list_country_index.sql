@@ -0,0 +1,6 @@
+-- +goose Up
+-- the country filter compares the country of the rows, as they were imported, case insensitively
+CREATE INDEX index_list_country_name ON geolocations(lower(trim(country)), id);
+
+-- +goose Down
+DROP INDEX index_list_country_name;
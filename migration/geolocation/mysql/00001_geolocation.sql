@@ -0,0 +1,79 @@
+-- +goose Up
+-- the mysql schema of the tables created by the postgres migrations one directory up. Timestamps are written by
+-- the application in UTC, and the ranges are kept as 16 byte addresses, ipv4 ones mapped into ipv6, which compare
+-- in address order. The CHECK constraints are enforced from MySQL 8.0.16 on.
+CREATE TABLE geolocations (
+                       id                          CHAR(36) PRIMARY KEY NOT NULL,
+                       ip                          VARCHAR(100) NOT NULL UNIQUE,
+                       family                      TINYINT NOT NULL CHECK (family IN (4, 6)),
+                       start_ip                    VARBINARY(16) NOT NULL CHECK (length(start_ip) = 16),
+                       end_ip                      VARBINARY(16) NOT NULL CHECK (length(end_ip) = 16),
+                       country_code                VARCHAR(10) NOT NULL DEFAULT '',
+                       country                     VARCHAR(255) NOT NULL DEFAULT '',
+                       city                        VARCHAR(255) NOT NULL DEFAULT '',
+                       latitude                    DOUBLE NOT NULL DEFAULT 0 CHECK (latitude BETWEEN -90 AND 90),
+                       longitude                   DOUBLE NOT NULL DEFAULT 0 CHECK (longitude BETWEEN -180 AND 180),
+                       mystery_value               VARCHAR(255) NOT NULL DEFAULT '',
+                       created_at                  DATETIME(6) NOT NULL,
+                       modified_at                 DATETIME(6) NOT NULL,
+                       CHECK (start_ip <= end_ip),
+                       INDEX index_ip_range (family, start_ip, end_ip),
+                       INDEX index_coordinates (latitude, longitude)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE import_locks (
+                       name                        VARCHAR(100) PRIMARY KEY NOT NULL,
+                       owner                       CHAR(36) NOT NULL,
+                       hostname                    VARCHAR(255) NOT NULL DEFAULT '',
+                       pid                         INT NOT NULL DEFAULT 0,
+                       acquired_at                 DATETIME(6) NOT NULL,
+                       heartbeat_at                DATETIME(6) NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE import_runs (
+                       id                          CHAR(36) PRIMARY KEY NOT NULL,
+                       source                      VARCHAR(1024) NOT NULL DEFAULT '',
+                       status                      VARCHAR(20) NOT NULL DEFAULT 'running',
+                       started_at                  DATETIME(6) NOT NULL,
+                       finished_at                 DATETIME(6) NULL,
+                       rolled_back_at              DATETIME(6) NULL,
+                       INDEX index_import_runs_started_at (started_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- one row per ip written by a run; the previous_* columns hold the overwritten row and are NULL when the run inserted it
+CREATE TABLE import_run_changes (
+                       run_id                      CHAR(36) NOT NULL,
+                       ip                          VARCHAR(100) NOT NULL,
+                       existed                     BOOLEAN NOT NULL,
+                       previous_country_code       VARCHAR(10) NULL,
+                       previous_country            VARCHAR(255) NULL,
+                       previous_city               VARCHAR(255) NULL,
+                       previous_latitude           DOUBLE NULL,
+                       previous_longitude          DOUBLE NULL,
+                       previous_mystery_value      VARCHAR(255) NULL,
+                       PRIMARY KEY (run_id, ip),
+                       FOREIGN KEY (run_id) REFERENCES import_runs(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE import_ledger (
+                       id                          CHAR(36) PRIMARY KEY NOT NULL,
+                       run_id                      CHAR(36) NOT NULL,
+                       file_name                   VARCHAR(1024) NOT NULL DEFAULT '',
+                       sha256                      CHAR(64) NOT NULL,
+                       size_bytes                  BIGINT NOT NULL DEFAULT 0,
+                       status                      VARCHAR(20) NOT NULL DEFAULT 'running',
+                       valid_count                 BIGINT NOT NULL DEFAULT 0,
+                       invalid_count               BIGINT NOT NULL DEFAULT 0,
+                       duration_seconds            DOUBLE NOT NULL DEFAULT 0,
+                       created_at                  DATETIME(6) NOT NULL,
+                       finished_at                 DATETIME(6) NULL,
+                       INDEX index_import_ledger_sha256 (sha256),
+                       FOREIGN KEY (run_id) REFERENCES import_runs(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- +goose Down
+DROP TABLE import_ledger;
+DROP TABLE import_run_changes;
+DROP TABLE import_runs;
+DROP TABLE import_locks;
+DROP TABLE geolocations;
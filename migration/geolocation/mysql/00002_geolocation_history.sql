@@ -0,0 +1,61 @@
+-- +goose Up
+-- every version of every geolocation, current in [valid_from, valid_to); valid_to is NULL while the version still is.
+-- The versions are written by triggers, taking the time of a write from the modified_at the application sets.
+CREATE TABLE geolocation_history (
+                       id                          BIGINT AUTO_INCREMENT PRIMARY KEY,
+                       geolocation_id              CHAR(36) NOT NULL,
+                       ip                          VARCHAR(100) NOT NULL,
+                       family                      TINYINT NOT NULL,
+                       start_ip                    VARBINARY(16) NOT NULL,
+                       end_ip                      VARBINARY(16) NOT NULL,
+                       country_code                VARCHAR(10) NOT NULL,
+                       country                     VARCHAR(255) NOT NULL,
+                       city                        VARCHAR(255) NOT NULL,
+                       latitude                    DOUBLE NOT NULL,
+                       longitude                   DOUBLE NOT NULL,
+                       mystery_value               VARCHAR(255) NOT NULL,
+                       valid_from                  DATETIME(6) NOT NULL,
+                       valid_to                    DATETIME(6) NULL,
+                       INDEX index_history_ip_range (family, start_ip, end_ip),
+                       INDEX index_history_ip (ip, valid_to)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- the rows as they are now are the first versions known
+INSERT INTO geolocation_history (geolocation_id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, valid_from)
+SELECT id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, modified_at FROM geolocations;
+
+-- +goose StatementBegin
+CREATE TRIGGER geolocation_history_insert AFTER INSERT ON geolocations FOR EACH ROW
+BEGIN
+    INSERT INTO geolocation_history (geolocation_id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, valid_from)
+    VALUES (NEW.id, NEW.ip, NEW.family, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value, NEW.modified_at);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER geolocation_history_update AFTER UPDATE ON geolocations FOR EACH ROW
+BEGIN
+    IF NOT (OLD.ip <=> NEW.ip AND OLD.start_ip <=> NEW.start_ip AND OLD.end_ip <=> NEW.end_ip
+        AND OLD.country_code <=> NEW.country_code AND OLD.country <=> NEW.country AND OLD.city <=> NEW.city
+        AND OLD.latitude <=> NEW.latitude AND OLD.longitude <=> NEW.longitude AND OLD.mystery_value <=> NEW.mystery_value) THEN
+        -- a version replaced at the time it was written was never visible
+        DELETE FROM geolocation_history WHERE ip = OLD.ip AND valid_to IS NULL AND valid_from >= NEW.modified_at;
+        UPDATE geolocation_history SET valid_to = NEW.modified_at WHERE ip = OLD.ip AND valid_to IS NULL;
+        INSERT INTO geolocation_history (geolocation_id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, valid_from)
+        VALUES (NEW.id, NEW.ip, NEW.family, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value, NEW.modified_at);
+    END IF;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER geolocation_history_delete AFTER DELETE ON geolocations FOR EACH ROW
+BEGIN
+    UPDATE geolocation_history SET valid_to = UTC_TIMESTAMP(6) WHERE ip = OLD.ip AND valid_to IS NULL;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER geolocation_history_delete;
+DROP TRIGGER geolocation_history_update;
+DROP TRIGGER geolocation_history_insert;
+DROP TABLE geolocation_history;
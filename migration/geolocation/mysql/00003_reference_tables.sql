@@ -0,0 +1,143 @@
+-- +goose Up
+-- the countries and cities the geolocations point to. A country is identified by its code, a city by its country and
+-- its name compared case insensitively, with whitespace collapsed; the importer keeps the names first imported.
+CREATE TABLE countries (
+                       id                          INT AUTO_INCREMENT PRIMARY KEY,
+                       code                        VARCHAR(10) NOT NULL UNIQUE,
+                       name                        VARCHAR(255) NOT NULL DEFAULT '',
+                       created_at                  DATETIME(6) NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- name_key is compared as bytes, the importer folds it already
+CREATE TABLE cities (
+                       id                          INT AUTO_INCREMENT PRIMARY KEY,
+                       country_id                  INT NOT NULL,
+                       name                        VARCHAR(255) NOT NULL,
+                       name_key                    VARCHAR(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL,
+                       created_at                  DATETIME(6) NOT NULL,
+                       UNIQUE (country_id, name_key),
+                       FOREIGN KEY (country_id) REFERENCES countries(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE geolocations
+    ADD COLUMN country_id INT NULL,
+    ADD COLUMN city_id INT NULL,
+    ADD INDEX index_geolocations_country_id (country_id),
+    ADD INDEX index_geolocations_city_id (city_id),
+    ADD FOREIGN KEY (country_id) REFERENCES countries(id),
+    ADD FOREIGN KEY (city_id) REFERENCES cities(id);
+
+-- rollbacks restore the references with the rest of the row
+ALTER TABLE import_run_changes
+    ADD COLUMN previous_country_id INT NULL,
+    ADD COLUMN previous_city_id INT NULL;
+
+-- backfills the references of the existing rows, the city names keyed like the importer does
+INSERT INTO countries (code, name, created_at)
+SELECT upper(trim(country_code)), min(trim(country)), UTC_TIMESTAMP(6)
+FROM geolocations
+WHERE trim(country_code) != ''
+GROUP BY upper(trim(country_code));
+
+INSERT INTO cities (country_id, name, name_key, created_at)
+SELECT c.id, min(trim(g.city)), lower(trim(REGEXP_REPLACE(g.city, '[[:space:]]+', ' '))), UTC_TIMESTAMP(6)
+FROM geolocations g
+JOIN countries c ON c.code = upper(trim(g.country_code))
+WHERE trim(g.city) != ''
+GROUP BY c.id, lower(trim(REGEXP_REPLACE(g.city, '[[:space:]]+', ' ')));
+
+UPDATE geolocations g
+JOIN countries c ON c.code = upper(trim(g.country_code))
+SET g.country_id = c.id;
+
+UPDATE geolocations g
+JOIN cities c ON c.country_id = g.country_id AND c.name_key = lower(trim(REGEXP_REPLACE(g.city, '[[:space:]]+', ' ')))
+SET g.city_id = c.id;
+
+-- the versions point to the references too, the ones of the past to those their text has
+ALTER TABLE geolocation_history
+    ADD COLUMN country_id INT NULL,
+    ADD COLUMN city_id INT NULL;
+
+UPDATE geolocation_history h
+JOIN countries c ON c.code = upper(trim(h.country_code))
+SET h.country_id = c.id;
+
+UPDATE geolocation_history h
+JOIN cities c ON c.country_id = h.country_id AND c.name_key = lower(trim(REGEXP_REPLACE(h.city, '[[:space:]]+', ' ')))
+SET h.city_id = c.id;
+
+DROP TRIGGER geolocation_history_insert;
+DROP TRIGGER geolocation_history_update;
+
+-- +goose StatementBegin
+CREATE TRIGGER geolocation_history_insert AFTER INSERT ON geolocations FOR EACH ROW
+BEGIN
+    INSERT INTO geolocation_history (geolocation_id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, country_id, city_id, valid_from)
+    VALUES (NEW.id, NEW.ip, NEW.family, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value, NEW.country_id, NEW.city_id, NEW.modified_at);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER geolocation_history_update AFTER UPDATE ON geolocations FOR EACH ROW
+BEGIN
+    IF NOT (OLD.ip <=> NEW.ip AND OLD.start_ip <=> NEW.start_ip AND OLD.end_ip <=> NEW.end_ip
+        AND OLD.country_code <=> NEW.country_code AND OLD.country <=> NEW.country AND OLD.city <=> NEW.city
+        AND OLD.latitude <=> NEW.latitude AND OLD.longitude <=> NEW.longitude AND OLD.mystery_value <=> NEW.mystery_value
+        AND OLD.country_id <=> NEW.country_id AND OLD.city_id <=> NEW.city_id) THEN
+        -- a version replaced at the time it was written was never visible
+        DELETE FROM geolocation_history WHERE ip = OLD.ip AND valid_to IS NULL AND valid_from >= NEW.modified_at;
+        UPDATE geolocation_history SET valid_to = NEW.modified_at WHERE ip = OLD.ip AND valid_to IS NULL;
+        INSERT INTO geolocation_history (geolocation_id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, country_id, city_id, valid_from)
+        VALUES (NEW.id, NEW.ip, NEW.family, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value, NEW.country_id, NEW.city_id, NEW.modified_at);
+    END IF;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER geolocation_history_update;
+DROP TRIGGER geolocation_history_insert;
+
+-- +goose StatementBegin
+CREATE TRIGGER geolocation_history_insert AFTER INSERT ON geolocations FOR EACH ROW
+BEGIN
+    INSERT INTO geolocation_history (geolocation_id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, valid_from)
+    VALUES (NEW.id, NEW.ip, NEW.family, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value, NEW.modified_at);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER geolocation_history_update AFTER UPDATE ON geolocations FOR EACH ROW
+BEGIN
+    IF NOT (OLD.ip <=> NEW.ip AND OLD.start_ip <=> NEW.start_ip AND OLD.end_ip <=> NEW.end_ip
+        AND OLD.country_code <=> NEW.country_code AND OLD.country <=> NEW.country AND OLD.city <=> NEW.city
+        AND OLD.latitude <=> NEW.latitude AND OLD.longitude <=> NEW.longitude AND OLD.mystery_value <=> NEW.mystery_value) THEN
+        -- a version replaced at the time it was written was never visible
+        DELETE FROM geolocation_history WHERE ip = OLD.ip AND valid_to IS NULL AND valid_from >= NEW.modified_at;
+        UPDATE geolocation_history SET valid_to = NEW.modified_at WHERE ip = OLD.ip AND valid_to IS NULL;
+        INSERT INTO geolocation_history (geolocation_id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, valid_from)
+        VALUES (NEW.id, NEW.ip, NEW.family, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value, NEW.modified_at);
+    END IF;
+END;
+-- +goose StatementEnd
+
+ALTER TABLE geolocation_history
+    DROP COLUMN city_id,
+    DROP COLUMN country_id;
+
+ALTER TABLE import_run_changes
+    DROP COLUMN previous_country_id,
+    DROP COLUMN previous_city_id;
+
+ALTER TABLE geolocations
+    DROP FOREIGN KEY geolocations_ibfk_1,
+    DROP FOREIGN KEY geolocations_ibfk_2;
+
+ALTER TABLE geolocations
+    DROP INDEX index_geolocations_city_id,
+    DROP INDEX index_geolocations_country_id,
+    DROP COLUMN city_id,
+    DROP COLUMN country_id;
+
+DROP TABLE cities;
+DROP TABLE countries;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- the keys the api authenticates requests with. Only the sha256 of a key is stored, the key is shown once when issued;
+-- prefix is its start, to tell the keys apart. rate_limit is in requests per minute.
+CREATE TABLE api_keys (
+                       id                          CHAR(36) PRIMARY KEY NOT NULL,
+                       name                        VARCHAR(255) NOT NULL DEFAULT '',
+                       hash                        CHAR(64) NOT NULL UNIQUE,
+                       prefix                      VARCHAR(20) NOT NULL DEFAULT '',
+                       rate_limit                  INT NOT NULL,
+                       burst                       INT NOT NULL,
+                       created_at                  DATETIME(6) NOT NULL,
+                       revoked_at                  DATETIME(6) NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- +goose Down
+DROP TABLE api_keys;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- the orders the geolocations are listed in, ties broken by id, so that the pages are read from an index. InnoDB
+-- appends the primary key to the secondary indexes, id is named regardless to keep the orders spelled out.
+ALTER TABLE geolocations
+    ADD INDEX index_list_ip (family, start_ip, end_ip, id),
+    ADD INDEX index_list_country (country, id),
+    ADD INDEX index_list_city (city, id),
+    ADD INDEX index_list_latitude (latitude, id),
+    ADD INDEX index_list_longitude (longitude, id);
+
+-- the city filter finds its cities by key, in every country
+ALTER TABLE cities ADD INDEX index_cities_name_key (name_key);
+
+-- +goose Down
+ALTER TABLE cities DROP INDEX index_cities_name_key;
+
+ALTER TABLE geolocations
+    DROP INDEX index_list_longitude,
+    DROP INDEX index_list_latitude,
+    DROP INDEX index_list_city,
+    DROP INDEX index_list_country,
+    DROP INDEX index_list_ip;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- the country filter compares the country of the rows, as they were imported, case insensitively
+ALTER TABLE geolocations ADD INDEX index_list_country_name ((lower(trim(country))), id);
+
+-- +goose Down
+ALTER TABLE geolocations DROP INDEX index_list_country_name;
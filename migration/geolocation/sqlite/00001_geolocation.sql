@@ -0,0 +1,84 @@
+-- +goose Up
+-- the sqlite schema of the tables created by the postgres migrations one directory up. Timestamps are written by
+-- the application in UTC, and the ranges are kept as 16 byte addresses, ipv4 ones mapped into ipv6, which compare
+-- in address order.
+CREATE TABLE geolocations (
+                       id                          TEXT PRIMARY KEY NOT NULL,
+                       ip                          TEXT NOT NULL UNIQUE,
+                       family                      INTEGER NOT NULL CHECK (family IN (4, 6)),
+                       start_ip                    BLOB NOT NULL CHECK (length(start_ip) = 16),
+                       end_ip                      BLOB NOT NULL CHECK (length(end_ip) = 16),
+                       country_code                TEXT NOT NULL DEFAULT '',
+                       country                     TEXT NOT NULL DEFAULT '',
+                       city                        TEXT NOT NULL DEFAULT '',
+                       latitude                    REAL NOT NULL DEFAULT 0 CHECK (latitude BETWEEN -90 AND 90),
+                       longitude                   REAL NOT NULL DEFAULT 0 CHECK (longitude BETWEEN -180 AND 180),
+                       mystery_value               TEXT NOT NULL DEFAULT '',
+                       created_at                  TIMESTAMP NOT NULL,
+                       modified_at                 TIMESTAMP NOT NULL,
+                       CHECK (start_ip <= end_ip)
+);
+
+CREATE INDEX index_ip_range ON geolocations(family, start_ip, end_ip);
+CREATE INDEX index_coordinates ON geolocations(latitude, longitude);
+
+CREATE TABLE import_locks (
+                       name                        TEXT PRIMARY KEY NOT NULL,
+                       owner                       TEXT NOT NULL,
+                       hostname                    TEXT NOT NULL DEFAULT '',
+                       pid                         INTEGER NOT NULL DEFAULT 0,
+                       acquired_at                 TIMESTAMP NOT NULL,
+                       heartbeat_at                TIMESTAMP NOT NULL
+);
+
+CREATE TABLE import_runs (
+                       id                          TEXT PRIMARY KEY NOT NULL,
+                       source                      TEXT NOT NULL DEFAULT '',
+                       status                      TEXT NOT NULL DEFAULT 'running',
+                       started_at                  TIMESTAMP NOT NULL,
+                       finished_at                 TIMESTAMP,
+                       rolled_back_at              TIMESTAMP
+);
+
+CREATE INDEX index_import_runs_started_at ON import_runs(started_at);
+
+-- one row per ip written by a run; the previous_* columns hold the overwritten row and are NULL when the run inserted it
+CREATE TABLE import_run_changes (
+                       run_id                      TEXT NOT NULL REFERENCES import_runs(id) ON DELETE CASCADE,
+                       ip                          TEXT NOT NULL,
+                       existed                     BOOLEAN NOT NULL,
+                       previous_country_code       TEXT,
+                       previous_country            TEXT,
+                       previous_city               TEXT,
+                       previous_latitude           REAL,
+                       previous_longitude          REAL,
+                       previous_mystery_value      TEXT,
+                       PRIMARY KEY (run_id, ip)
+);
+
+CREATE TABLE import_ledger (
+                       id                          TEXT PRIMARY KEY NOT NULL,
+                       run_id                      TEXT NOT NULL REFERENCES import_runs(id) ON DELETE CASCADE,
+                       file_name                   TEXT NOT NULL DEFAULT '',
+                       sha256                      TEXT NOT NULL,
+                       size_bytes                  INTEGER NOT NULL DEFAULT 0,
+                       status                      TEXT NOT NULL DEFAULT 'running',
+                       valid_count                 INTEGER NOT NULL DEFAULT 0,
+                       invalid_count               INTEGER NOT NULL DEFAULT 0,
+                       duration_seconds            REAL NOT NULL DEFAULT 0,
+                       created_at                  TIMESTAMP NOT NULL,
+                       finished_at                 TIMESTAMP
+);
+
+CREATE INDEX index_import_ledger_sha256 ON import_ledger(sha256);
+
+-- +goose Down
+DROP INDEX index_import_ledger_sha256;
+DROP TABLE import_ledger;
+DROP TABLE import_run_changes;
+DROP INDEX index_import_runs_started_at;
+DROP TABLE import_runs;
+DROP TABLE import_locks;
+DROP INDEX index_coordinates;
+DROP INDEX index_ip_range;
+DROP TABLE geolocations;
@@ -0,0 +1,63 @@
+-- +goose Up
+-- every version of every geolocation, current in [valid_from, valid_to); valid_to is NULL while the version still is.
+-- The versions are written by triggers, taking the time of a write from the modified_at the application sets.
+CREATE TABLE geolocation_history (
+                       id                          INTEGER PRIMARY KEY AUTOINCREMENT,
+                       geolocation_id              TEXT NOT NULL,
+                       ip                          TEXT NOT NULL,
+                       family                      INTEGER NOT NULL,
+                       start_ip                    BLOB NOT NULL,
+                       end_ip                      BLOB NOT NULL,
+                       country_code                TEXT NOT NULL,
+                       country                     TEXT NOT NULL,
+                       city                        TEXT NOT NULL,
+                       latitude                    REAL NOT NULL,
+                       longitude                   REAL NOT NULL,
+                       mystery_value               TEXT NOT NULL,
+                       valid_from                  TIMESTAMP NOT NULL,
+                       valid_to                    TIMESTAMP
+);
+
+CREATE INDEX index_history_ip_range ON geolocation_history(family, start_ip, end_ip);
+CREATE INDEX index_history_open ON geolocation_history(ip) WHERE valid_to IS NULL;
+
+-- the rows as they are now are the first versions known
+INSERT INTO geolocation_history (geolocation_id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, valid_from)
+SELECT id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, modified_at FROM geolocations;
+
+-- +goose StatementBegin
+CREATE TRIGGER geolocation_history_insert AFTER INSERT ON geolocations
+BEGIN
+    INSERT INTO geolocation_history (geolocation_id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, valid_from)
+    VALUES (NEW.id, NEW.ip, NEW.family, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value, NEW.modified_at);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER geolocation_history_update AFTER UPDATE ON geolocations
+WHEN OLD.ip IS NOT NEW.ip OR OLD.start_ip IS NOT NEW.start_ip OR OLD.end_ip IS NOT NEW.end_ip
+    OR OLD.country_code IS NOT NEW.country_code OR OLD.country IS NOT NEW.country OR OLD.city IS NOT NEW.city
+    OR OLD.latitude IS NOT NEW.latitude OR OLD.longitude IS NOT NEW.longitude OR OLD.mystery_value IS NOT NEW.mystery_value
+BEGIN
+    -- a version replaced at the time it was written was never visible
+    DELETE FROM geolocation_history WHERE ip = OLD.ip AND valid_to IS NULL AND valid_from >= NEW.modified_at;
+    UPDATE geolocation_history SET valid_to = NEW.modified_at WHERE ip = OLD.ip AND valid_to IS NULL;
+    INSERT INTO geolocation_history (geolocation_id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, valid_from)
+    VALUES (NEW.id, NEW.ip, NEW.family, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value, NEW.modified_at);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER geolocation_history_delete AFTER DELETE ON geolocations
+BEGIN
+    UPDATE geolocation_history SET valid_to = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now') WHERE ip = OLD.ip AND valid_to IS NULL;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER geolocation_history_delete;
+DROP TRIGGER geolocation_history_update;
+DROP TRIGGER geolocation_history_insert;
+DROP INDEX index_history_open;
+DROP INDEX index_history_ip_range;
+DROP TABLE geolocation_history;
@@ -0,0 +1,122 @@
+-- +goose Up
+-- the countries and cities the geolocations point to. A country is identified by its code, a city by its country and
+-- its name compared case insensitively, with whitespace collapsed; the importer keeps the names first imported.
+CREATE TABLE countries (
+                       id                          INTEGER PRIMARY KEY AUTOINCREMENT,
+                       code                        TEXT NOT NULL UNIQUE,
+                       name                        TEXT NOT NULL DEFAULT '',
+                       created_at                  TIMESTAMP NOT NULL
+);
+
+CREATE TABLE cities (
+                       id                          INTEGER PRIMARY KEY AUTOINCREMENT,
+                       country_id                  INTEGER NOT NULL REFERENCES countries(id),
+                       name                        TEXT NOT NULL,
+                       name_key                    TEXT NOT NULL,
+                       created_at                  TIMESTAMP NOT NULL,
+                       UNIQUE (country_id, name_key)
+);
+
+ALTER TABLE geolocations ADD COLUMN country_id INTEGER REFERENCES countries(id);
+ALTER TABLE geolocations ADD COLUMN city_id INTEGER REFERENCES cities(id);
+
+CREATE INDEX index_geolocations_country_id ON geolocations(country_id);
+CREATE INDEX index_geolocations_city_id ON geolocations(city_id);
+
+-- rollbacks restore the references with the rest of the row
+ALTER TABLE import_run_changes ADD COLUMN previous_country_id INTEGER;
+ALTER TABLE import_run_changes ADD COLUMN previous_city_id INTEGER;
+
+-- backfills the references of the existing rows. sqlite only folds the case of ascii letters in country codes, the
+-- city names are keyed by city_key, which the application registers as the key the importer compares them in.
+INSERT INTO countries (code, name, created_at)
+SELECT upper(trim(country_code)), min(trim(country)), strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+FROM geolocations
+WHERE trim(country_code) != ''
+GROUP BY upper(trim(country_code));
+
+INSERT INTO cities (country_id, name, name_key, created_at)
+SELECT c.id, min(trim(g.city)), city_key(g.city), strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+FROM geolocations g
+JOIN countries c ON c.code = upper(trim(g.country_code))
+WHERE city_key(g.city) != ''
+GROUP BY c.id, city_key(g.city);
+
+UPDATE geolocations
+SET country_id = (SELECT c.id FROM countries c WHERE c.code = upper(trim(geolocations.country_code)));
+
+UPDATE geolocations
+SET city_id = (SELECT c.id FROM cities c WHERE c.country_id = geolocations.country_id AND c.name_key = city_key(geolocations.city));
+
+-- the versions point to the references too, the ones of the past to those their text has
+ALTER TABLE geolocation_history ADD COLUMN country_id INTEGER;
+ALTER TABLE geolocation_history ADD COLUMN city_id INTEGER;
+
+UPDATE geolocation_history
+SET country_id = (SELECT c.id FROM countries c WHERE c.code = upper(trim(geolocation_history.country_code)));
+
+UPDATE geolocation_history
+SET city_id = (SELECT c.id FROM cities c WHERE c.country_id = geolocation_history.country_id AND c.name_key = city_key(geolocation_history.city));
+
+DROP TRIGGER geolocation_history_insert;
+DROP TRIGGER geolocation_history_update;
+
+-- +goose StatementBegin
+CREATE TRIGGER geolocation_history_insert AFTER INSERT ON geolocations
+BEGIN
+    INSERT INTO geolocation_history (geolocation_id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, country_id, city_id, valid_from)
+    VALUES (NEW.id, NEW.ip, NEW.family, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value, NEW.country_id, NEW.city_id, NEW.modified_at);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER geolocation_history_update AFTER UPDATE ON geolocations
+WHEN OLD.ip IS NOT NEW.ip OR OLD.start_ip IS NOT NEW.start_ip OR OLD.end_ip IS NOT NEW.end_ip
+    OR OLD.country_code IS NOT NEW.country_code OR OLD.country IS NOT NEW.country OR OLD.city IS NOT NEW.city
+    OR OLD.latitude IS NOT NEW.latitude OR OLD.longitude IS NOT NEW.longitude OR OLD.mystery_value IS NOT NEW.mystery_value
+    OR OLD.country_id IS NOT NEW.country_id OR OLD.city_id IS NOT NEW.city_id
+BEGIN
+    -- a version replaced at the time it was written was never visible
+    DELETE FROM geolocation_history WHERE ip = OLD.ip AND valid_to IS NULL AND valid_from >= NEW.modified_at;
+    UPDATE geolocation_history SET valid_to = NEW.modified_at WHERE ip = OLD.ip AND valid_to IS NULL;
+    INSERT INTO geolocation_history (geolocation_id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, country_id, city_id, valid_from)
+    VALUES (NEW.id, NEW.ip, NEW.family, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value, NEW.country_id, NEW.city_id, NEW.modified_at);
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER geolocation_history_update;
+DROP TRIGGER geolocation_history_insert;
+
+-- +goose StatementBegin
+CREATE TRIGGER geolocation_history_insert AFTER INSERT ON geolocations
+BEGIN
+    INSERT INTO geolocation_history (geolocation_id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, valid_from)
+    VALUES (NEW.id, NEW.ip, NEW.family, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value, NEW.modified_at);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER geolocation_history_update AFTER UPDATE ON geolocations
+WHEN OLD.ip IS NOT NEW.ip OR OLD.start_ip IS NOT NEW.start_ip OR OLD.end_ip IS NOT NEW.end_ip
+    OR OLD.country_code IS NOT NEW.country_code OR OLD.country IS NOT NEW.country OR OLD.city IS NOT NEW.city
+    OR OLD.latitude IS NOT NEW.latitude OR OLD.longitude IS NOT NEW.longitude OR OLD.mystery_value IS NOT NEW.mystery_value
+BEGIN
+    -- a version replaced at the time it was written was never visible
+    DELETE FROM geolocation_history WHERE ip = OLD.ip AND valid_to IS NULL AND valid_from >= NEW.modified_at;
+    UPDATE geolocation_history SET valid_to = NEW.modified_at WHERE ip = OLD.ip AND valid_to IS NULL;
+    INSERT INTO geolocation_history (geolocation_id, ip, family, start_ip, end_ip, country_code, country, city, latitude, longitude, mystery_value, valid_from)
+    VALUES (NEW.id, NEW.ip, NEW.family, NEW.start_ip, NEW.end_ip, NEW.country_code, NEW.country, NEW.city, NEW.latitude, NEW.longitude, NEW.mystery_value, NEW.modified_at);
+END;
+-- +goose StatementEnd
+
+ALTER TABLE geolocation_history DROP COLUMN city_id;
+ALTER TABLE geolocation_history DROP COLUMN country_id;
+DROP INDEX index_geolocations_city_id;
+DROP INDEX index_geolocations_country_id;
+ALTER TABLE import_run_changes DROP COLUMN previous_city_id;
+ALTER TABLE import_run_changes DROP COLUMN previous_country_id;
+ALTER TABLE geolocations DROP COLUMN city_id;
+ALTER TABLE geolocations DROP COLUMN country_id;
+DROP TABLE cities;
+DROP TABLE countries;
@@ -7,6 +7,9 @@ import (
 	"log"
 	"os"
 
+	"github.com/ohmpatel1997/findhotel/lib/config"
+	"github.com/ohmpatel1997/findhotel/lib/db/mysql"
+	"github.com/ohmpatel1997/findhotel/lib/db/sqlite"
 	"github.com/pressly/goose"
 
 	// Init DB drivers.
@@ -17,8 +20,11 @@ import (
 )
 
 var (
-	flags = flag.NewFlagSet("goose", flag.ExitOnError)
-	dir   = flags.String("dir", ".", "directory with migration files")
+	flags    = flag.NewFlagSet("goose", flag.ExitOnError)
+	dir      = flags.String("dir", ".", "directory with migration files")
+	cfgPath  = flags.String("p", "", "a configuration file to take the dialect and the sqlite3 database file from")
+	dialect  = flags.String("dialect", "postgres", "the database dialect: postgres, mysql or sqlite3")
+	dbstring = flags.String("dbstring", "", "the database file for sqlite3, postgres and mysql are configured by the POSTGRES_* and MYSQL_* variables")
 )
 
 func main() {
@@ -45,18 +51,37 @@ func main() {
 	}
 
 	command := args[0]
-	if err := goose.SetDialect("postgres"); err != nil {
+	if *cfgPath != "" {
+		loadConfig(*cfgPath)
+	}
+	if err := goose.SetDialect(*dialect); err != nil {
 		log.Fatal(err)
 	}
 
-	host := os.Getenv("POSTGRES_HOST")
-	dbName := os.Getenv("POSTGRES_DB")
-	password := os.Getenv("POSTGRES_PASSWORD")
-	user := os.Getenv("POSTGRES_USER")
-	dbPort := os.Getenv("POSTGRES_PORT")
-	conStr := fmt.Sprintf("postgres://%v:%v@%v:%v/%v?sslmode=disable", user, password, host, dbPort, dbName)
+	conStr := *dbstring
+	switch *dialect {
+	case "postgres":
+		host := os.Getenv("POSTGRES_HOST")
+		dbName := os.Getenv("POSTGRES_DB")
+		password := os.Getenv("POSTGRES_PASSWORD")
+		user := os.Getenv("POSTGRES_USER")
+		dbPort := os.Getenv("POSTGRES_PORT")
+		conStr = fmt.Sprintf("postgres://%v:%v@%v:%v/%v?sslmode=disable", user, password, host, dbPort, dbName)
+	case "mysql":
+		conStr = mysql.DSN() + "?parseTime=true"
+	case "sqlite3":
+		if conStr == "" {
+			log.Fatal("-dbstring is required for sqlite3")
+		}
+	default:
+		log.Fatalf("unsupported dialect %q", *dialect)
+	}
 
-	db, err := sql.Open("postgres", conStr)
+	driver := *dialect
+	if driver == sqlite.DriverName {
+		driver = sqlite.FuncDriverName
+	}
+	db, err := sql.Open(driver, conStr)
 	if err != nil {
 		log.Fatalf("-dbstring=%q: %v\n", conStr, err)
 	}
@@ -71,6 +96,26 @@ func main() {
 	}
 }
 
+// loadConfig takes the dialect and the database file from the configuration of the commands, flags given explicitly win
+func loadConfig(path string) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.DB == nil {
+		return
+	}
+
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["dialect"] && cfg.DB.Dialect != "" {
+		*dialect = cfg.DB.Dialect
+	}
+	if !set["dbstring"] && cfg.DB.Path != "" {
+		*dbstring = cfg.DB.Path
+	}
+}
+
 func usage() {
 	log.Print(usagePrefix)
 	flags.PrintDefaults()